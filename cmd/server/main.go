@@ -1,79 +1,625 @@
 package main
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/task4233/oauth/pkg/api/client"
 	authNServer "github.com/task4233/oauth/pkg/api/server/authentication"
 	authZServer "github.com/task4233/oauth/pkg/api/server/authorization"
 	resourceServer "github.com/task4233/oauth/pkg/api/server/resource"
+	"github.com/task4233/oauth/pkg/api/server/tenant"
+	"github.com/task4233/oauth/pkg/api/tokenauth"
+	"github.com/task4233/oauth/pkg/config"
+	"github.com/task4233/oauth/pkg/domain/model"
 	"github.com/task4233/oauth/pkg/domain/service"
 	"github.com/task4233/oauth/pkg/infra"
+	authNUseCase "github.com/task4233/oauth/pkg/usecase/authentication"
 	authZUseCase "github.com/task4233/oauth/pkg/usecase/authorization"
 	"golang.org/x/oauth2"
 	"golang.org/x/sync/errgroup"
 )
 
-const (
-	AppServerPort            = 9000
-	authorizationServerPort  = 9001
-	authenticationServerPort = 9002
-	resourceServerPort       = 9003
-)
+// keyRotationInterval is how often the signing keys are checked against the rotation schedule.
+const keyRotationInterval = time.Hour
+
+// command is a component of the sample, which runs alone or with the others in a process.
+type command struct {
+	name  string
+	usage string
+	start func(eg *errgroup.Group, cfg *config.Config) error
+}
+
+var commands = []command{
+	{"all", "run all the servers together as the demo (default)", startAll},
+	{"authz", "run the authorization server", startAuthorization},
+	{"authn", "run the authentication server", startAuthentication},
+	{"resource", "run the resource server", startResource},
+	{"demo-client", "run the sample app", startDemoClient},
+}
 
 func main() {
-	oauthConfig := setupOAuthConfig()
+	name, args := "all", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+	if i < 0 {
+		fmt.Fprintf(os.Stderr, "error: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	cmd := commands[i]
 
-	fixedKey := os.Getenv("CLIENT_SECRET_FIXED_KEY")
+	fs := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "JSON file of the configuration (CONFIG_FILE)")
+	checkOnly := fs.Bool("check-config", false, "validate the configuration and the files which it refers to, and exit")
+	applyFlags := config.Flags(fs)
+	fs.Usage = func() {
+		usage()
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
 
-	authStorage := infra.NewAuthorizationStorage(fixedKey)
-	authZUC := authZUseCase.NewAuthUseCase(authStorage, service.NewSha256Hasher(fixedKey))
-	authZSV := authZServer.NewAuthorization(authZUC)
-	authNSV := authNServer.NewAuthentication()
-	resourceSV := resourceServer.NewResource()
-	appSV := client.NewApp(oauthConfig)
+	cfg, err := loadConfig(*configFile, applyFlags)
+	if err == nil {
+		err = validateCommand(cmd, cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if *checkOnly {
+		if err := checkConfig(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("config is valid")
+		return
+	}
 
 	eg := &errgroup.Group{}
+	if err := cmd.start(eg, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := eg.Wait(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-	eg.Go(func() error {
-		return appSV.Run(AppServerPort)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.usage)
+	}
+}
+
+// loadConfig reads the configuration file, which is overridden by the environment variables and then by the flags.
+func loadConfig(path string, applyFlags func(*config.Config) error) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := applyFlags(cfg); err != nil {
+		return nil, err
+	}
+	cfg.Complete()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validateCommand reports the configuration which cmd needs in addition to the common one.
+func validateCommand(cmd command, cfg *config.Config) error {
+	switch cmd.name {
+	case "authz", "authn":
+		// the logins are verified by the authorization server in another process.
+		if cfg.Keys.LoginTokenKey == "" {
+			return fmt.Errorf("%w: keys.login_token_key is required to run %s alone", config.ErrConfigInvalid, cmd.name)
+		}
+	}
+	return nil
+}
+
+// checkConfig reads the files which the configuration refers to without starting the servers nor writing the keys.
+func checkConfig(cfg *config.Config) error {
+	if err := loadTrustedIssuers(infra.NewEmptyAuthorizationStorage(), cfg.TrustedIssuersFile); err != nil {
+		return err
+	}
+	if _, err := loadUpstreamProviders(cfg.UpstreamProvidersFile); err != nil {
+		return err
+	}
+	tenants, err := loadTenants(cfg.TenantsFile)
+	if err != nil {
+		return err
+	}
+	router := tenant.NewRouter(http.NotFoundHandler())
+	for _, t := range tenants {
+		if err := router.Add(&t.Tenant, http.NotFoundHandler()); err != nil {
+			return fmt.Errorf("tenant %s: %w", t.ID, err)
+		}
+		for _, base := range []string{cfg.Authorization.BaseURL, cfg.Authentication.BaseURL} {
+			if _, err := t.BaseURL(base); err != nil {
+				return fmt.Errorf("tenant %s: %w", t.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// startAll runs the servers in a process, which share the users, the lockouts and the key of the logins.
+func startAll(eg *errgroup.Group, cfg *config.Config) error {
+	if err := startIssuers(eg, cfg, true, true); err != nil {
+		return err
+	}
+	if err := startResource(eg, cfg); err != nil {
+		return err
+	}
+	return startDemoClient(eg, cfg)
+}
+
+func startAuthorization(eg *errgroup.Group, cfg *config.Config) error {
+	return startIssuers(eg, cfg, true, false)
+}
+
+func startAuthentication(eg *errgroup.Group, cfg *config.Config) error {
+	return startIssuers(eg, cfg, false, true)
+}
+
+// startIssuers runs the authorization server and/or the authentication server of the default issuer and the tenants.
+func startIssuers(eg *errgroup.Group, cfg *config.Config, withAuthZ, withAuthN bool) error {
+	kek, err := cfg.Keys.KeyEncryptionKey()
+	if err != nil {
+		return err
+	}
+	// the keys are sealed with a random key while they are kept in memory.
+	if kek == nil {
+		kek = make([]byte, 32)
+		if _, err := rand.Read(kek); err != nil {
+			return err
+		}
+	}
+	loginTokenKey, err := cfg.Keys.LoginTokenSigningKey()
+	if err != nil {
+		return err
+	}
+
+	tenants, err := loadTenants(cfg.TenantsFile)
+	if err != nil {
+		return err
+	}
+	// the servers without tenant serve the sample app, the resource server and the corporate users.
+	var authZRouter, authNRouter *tenant.Router
+	for _, t := range slices.Concat([]*config.Tenant{nil}, tenants) {
+		shared, err := newTenantShared(cfg, t, loginTokenKey)
+		if err != nil {
+			return tenantError(t, err)
+		}
+		if withAuthZ {
+			authZSV, keys, err := newAuthorizationServer(cfg, shared, kek)
+			if err != nil {
+				return tenantError(t, err)
+			}
+			if t == nil {
+				authZRouter = tenant.NewRouter(authZSV.Handler())
+			} else if err := authZRouter.Add(&t.Tenant, authZSV.Handler()); err != nil {
+				return tenantError(t, err)
+			}
+			for _, keys := range keys {
+				eg.Go(func() error {
+					return keys.Run(context.Background(), keyRotationInterval)
+				})
+			}
+		}
+		if withAuthN {
+			authNSV, err := newAuthenticationServer(cfg, shared)
+			if err != nil {
+				return tenantError(t, err)
+			}
+			if t == nil {
+				authNRouter = tenant.NewRouter(authNSV.Handler())
+			} else if err := authNRouter.Add(&t.Tenant, authNSV.Handler()); err != nil {
+				return tenantError(t, err)
+			}
+		}
+	}
+
+	if withAuthZ {
+		eg.Go(func() error {
+			return authZRouter.Run(cfg.Authorization.Listen)
+		})
+	}
+	if withAuthN {
+		eg.Go(func() error {
+			return authNRouter.Run(cfg.Authentication.Listen)
+		})
+	}
+	return nil
+}
+
+func tenantError(t *config.Tenant, err error) error {
+	if t == nil {
+		return err
+	}
+	return fmt.Errorf("tenant %s: %w", t.ID, err)
+}
+
+func startResource(eg *errgroup.Group, cfg *config.Config) error {
+	validator, err := tokenauth.NewValidator(tokenauth.Config{
+		Realm:                 "resource",
+		Issuer:                cfg.Authorization.BaseURL,
+		IntrospectionEndpoint: cfg.Authorization.BaseURL + "/introspect",
+		ClientID:              cfg.Resource.ID,
+		ClientSecret:          cfg.Resource.Secret,
+		HTTPClient:            http.DefaultClient,
 	})
+	if err != nil {
+		return err
+	}
+	resourceSV := resourceServer.NewResource(validator, cfg.Resource.BaseURL)
+	resourceSV.RequiredScopes = cfg.Resource.RequiredScopes
+
 	eg.Go(func() error {
-		return authZSV.Run(authorizationServerPort)
+		return resourceSV.Run(cfg.Resource.Listen)
 	})
+	return nil
+}
+
+func startDemoClient(eg *errgroup.Group, cfg *config.Config) error {
+	appSV := client.NewApp(setupOAuthConfig(cfg), cfg.Authorization.BaseURL, cfg.Resource.BaseURL)
+	appSV.EndSessionEndpoint = cfg.Authorization.BaseURL + "/end_session"
+	appSV.CheckSessionIframe = cfg.Authentication.BaseURL + "/check_session"
+
 	eg.Go(func() error {
-		return authNSV.Run(authenticationServerPort)
+		return appSV.Run(cfg.App.Listen)
 	})
-	eg.Go(func() error {
-		return resourceSV.Run(resourceServerPort)
+	return nil
+}
+
+// tenantShared is what the authorization server and the authentication server of a tenant share when they run in a process.
+// The tenants share no storage nor keys with the others, so the tokens of a tenant are unknown to the others.
+type tenantShared struct {
+	tenant       *model.Tenant
+	authZBaseURL string
+	authNBaseURL string
+	hasher       *service.Sha256Hasher
+	loginTokens  *service.LoginTokenCodec
+	lockout      *service.Lockout
+	users        *infra.UserStorage
+	// clients are registered to the authorization server of the tenant.
+	clients []*config.Client
+}
+
+// newTenantShared builds what the servers of t share, or of the default issuer if t is nil.
+// The logins are signed with loginTokenKey derived for the tenant, or with a random key if it is nil.
+func newTenantShared(cfg *config.Config, t *config.Tenant, loginTokenKey []byte) (*tenantShared, error) {
+	authZBaseURL, authNBaseURL := cfg.Authorization.BaseURL, cfg.Authentication.BaseURL
+	lockoutFile := cfg.LockoutStorageFile
+	// the tenants have their own clients and users, and do not know those of the default issuer.
+	clients, users := cfg.Clients, cfg.Users
+	var tenant *model.Tenant
+	if t != nil {
+		tenant = &t.Tenant
+		clients, users = t.Clients, t.Users
+		var err error
+		authZBaseURL, err = t.BaseURL(authZBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		authNBaseURL, err = t.BaseURL(authNBaseURL)
+		if err != nil {
+			return nil, err
+		}
+		if lockoutFile != "" {
+			lockoutFile += "." + t.ID
+		}
+	}
+
+	// the login result is handed over from the authentication server to the authorization server with this key.
+	if loginTokenKey == nil {
+		loginTokenKey = make([]byte, 32)
+		if _, err := rand.Read(loginTokenKey); err != nil {
+			return nil, err
+		}
+	} else if t != nil {
+		mac := hmac.New(sha256.New, loginTokenKey)
+		mac.Write([]byte(t.ID))
+		loginTokenKey = mac.Sum(nil)
+	}
+
+	hasher := service.NewSha256Hasher(cfg.SecretFixedKey)
+	// the failed attempts are counted in the file so that restarting the server does not reset them.
+	lockoutStorage, err := infra.NewLockoutStorage(lockoutFile)
+	if err != nil {
+		return nil, err
+	}
+	userStorage, err := newUserStorage(users, hasher)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tenantShared{
+		tenant:       tenant,
+		authZBaseURL: authZBaseURL,
+		authNBaseURL: authNBaseURL,
+		hasher:       hasher,
+		loginTokens:  service.NewLoginTokenCodec(loginTokenKey),
+		lockout:      service.NewLockout(lockoutStorage),
+		users:        userStorage,
+		clients:      clients,
+	}, nil
+}
+
+// newAuthorizationServer builds the authorization server of the tenant, whose signing keys and encryption keys are sealed
+// with kek. The returned managers rotate the keys.
+func newAuthorizationServer(cfg *config.Config, shared *tenantShared, kek []byte) (*authZServer.Authorization, []*service.KeyManager, error) {
+	keyFile := cfg.Keys.StorageFile
+	if shared.tenant != nil && keyFile != "" {
+		keyFile += "." + shared.tenant.ID
+	}
+
+	authStorage, err := newAuthorizationStorage(shared.clients, cfg.Resources, shared.hasher)
+	if err != nil {
+		return nil, nil, err
+	}
+	if shared.tenant == nil {
+		if err := loadTrustedIssuers(authStorage, cfg.TrustedIssuersFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	authZUC := authZUseCase.NewAuthUseCase(shared.authZBaseURL, authStorage, shared.hasher, shared.loginTokens)
+	authZUC.RequirePushedAuthRequests = cfg.RequirePushedAuthRequests
+	authZUC.AccessTokenLifetime = cfg.Tokens.AccessTokenLifetime.Duration
+	authZUC.RefreshTokenLifetime = cfg.Tokens.RefreshTokenLifetime.Duration
+	authZUC.IDTokenLifetime = cfg.Tokens.IDTokenLifetime.Duration
+	// the signing keys are kept in the file so that restarting the server does not invalidate the signed JWTs.
+	keyStorage, err := infra.NewKeyStorage(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	policy, err := cfg.Keys.RotationPolicy()
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := service.NewKeyManager(keyStorage, kek, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	// a key is activated before the first JWT is signed.
+	if err := keys.Rotate(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	authZUC.SigningKeys = keys
+	// the encryption keys are rotated on the schedule of the signing keys in their own file, so that the request objects
+	// encrypted to the keys published before restarting or rotating are decrypted.
+	encryptionKeyFile := keyFile
+	if encryptionKeyFile != "" {
+		encryptionKeyFile += ".enc"
+	}
+	encryptionKeyStorage, err := infra.NewKeyStorage(encryptionKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	encryptionPolicy := policy
+	encryptionPolicy.Algorithm = service.AlgRSAOAEP256
+	encryptionKeys, err := service.NewEncryptionKeyManager(encryptionKeyStorage, kek, encryptionPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := encryptionKeys.Rotate(context.Background()); err != nil {
+		return nil, nil, err
+	}
+	authZUC.EncryptionKeys = encryptionKeys
+	authZUC.TokenExchangePolicy = authZUseCase.AllowTokenExchange(authZUseCase.TokenExchangeRule{
+		ClientID:          cfg.App.ClientID,
+		SubjectTokenTypes: []model.TokenTypeURI{model.TokenTypeURIAccessToken},
 	})
+	authZUC.Lockout = shared.lockout
+	authZUC.Users = shared.users
+	authZUC.DeviceNotifier = infra.NewAuthenticationDeviceSimulator(shared.authZBaseURL + "/bc-device")
+	authZSV := authZServer.NewAuthorization(authZUC)
+	authZSV.AdminToken = cfg.AdminToken
+	authZSV.AuthenticationURL = shared.authNBaseURL
 
-	if err := eg.Wait(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v", err)
-		os.Exit(1)
+	return authZSV, []*service.KeyManager{keys, encryptionKeys}, nil
+}
+
+// newAuthenticationServer builds the authentication server of the tenant.
+func newAuthenticationServer(cfg *config.Config, shared *tenantShared) (*authNServer.Authentication, error) {
+	authNUC := authNUseCase.NewAuthNUseCase(shared.users, shared.hasher, shared.loginTokens)
+	authNUC.Lockout = shared.lockout
+	authNUC.Sessions = infra.NewSessionStorage()
+	authNUC.WebAuthn = infra.NewWebAuthnStorage()
+	authNURL, err := url.Parse(shared.authNBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	authNUC.RelyingParty = authNUseCase.WebAuthnRelyingParty{
+		ID:     authNURL.Hostname(),
+		Name:   "oauth-go",
+		Origin: authNURL.Scheme + "://" + authNURL.Host,
+	}
+	if shared.tenant == nil {
+		upstreamProviders, err := loadUpstreamProviders(cfg.UpstreamProvidersFile)
+		if err != nil {
+			return nil, err
+		}
+		authNUC.UpstreamProviders = upstreamProviders
+	}
+	authNUC.UpstreamLogins = infra.NewUpstreamLoginStorage()
+	authNUC.UpstreamRedirectURI = shared.authNBaseURL + "/login/upstream/callback"
+	authNSV := authNServer.NewAuthentication(authNUC)
+	authNSV.AuthorizationURL = shared.authZBaseURL
+	if shared.tenant != nil {
+		authNSV.BasePath = shared.tenant.BasePath()
+	}
+
+	return authNSV, nil
+}
+
+// newAuthorizationStorage returns the storage with the clients and the resources of the configuration.
+func newAuthorizationStorage(clients []*config.Client, resources []*config.ProtectedResource, hasher *service.Sha256Hasher) (*infra.AuthorizationStorage, error) {
+	ctx := context.Background()
+	storage := infra.NewEmptyAuthorizationStorage()
+	for _, c := range clients {
+		secretHash, err := hasher.Hash(ctx, []byte(c.Secret))
+		if err != nil {
+			return nil, err
+		}
+		opts := []model.ClientOption{
+			model.WithPostLogoutRedirectURIs(c.PostLogoutRedirectURIs...),
+		}
+		if c.FrontchannelLogoutURI != "" {
+			opts = append(opts, model.WithFrontchannelLogoutURI(c.FrontchannelLogoutURI))
+		}
+		if c.BackchannelLogoutURI != "" {
+			opts = append(opts, model.WithBackchannelLogoutURI(c.BackchannelLogoutURI))
+		}
+		if c.BackchannelTokenDeliveryMode != "" {
+			opts = append(opts, model.WithBackchannelTokenDelivery(model.BackchannelTokenDeliveryMode(c.BackchannelTokenDeliveryMode), c.BackchannelClientNotificationEndpoint))
+		}
+		if c.RequirePushedAuthRequests {
+			opts = append(opts, model.WithPushedAuthRequestRequired())
+		}
+		client := model.NewConfidentialClient(model.AuthMethodBasic, c.ID, string(secretHash), c.RedirectURIs, opts...)
+		if err := storage.AddClient(client); err != nil {
+			return nil, fmt.Errorf("client %s: %w", c.ID, err)
+		}
+	}
+	for _, r := range resources {
+		secretHash, err := hasher.Hash(ctx, []byte(r.Secret))
+		if err != nil {
+			return nil, err
+		}
+		if err := storage.AddProtectedResource(&model.ProtectedResource{
+			URI:        r.URI,
+			Scopes:     r.Scopes,
+			ID:         r.ID,
+			SecretHash: string(secretHash),
+		}); err != nil {
+			return nil, fmt.Errorf("resource %s: %w", r.URI, err)
+		}
+	}
+	return storage, nil
+}
+
+// newUserStorage returns the storage with the users of the configuration.
+func newUserStorage(users []*config.User, hasher *service.Sha256Hasher) (*infra.UserStorage, error) {
+	ctx := context.Background()
+	storage := infra.NewEmptyUserStorage()
+	for _, u := range users {
+		passwordHash, err := hasher.Hash(ctx, []byte(u.Password))
+		if err != nil {
+			return nil, err
+		}
+		if err := storage.CreateUser(ctx, &model.User{
+			ID:           u.ID,
+			Username:     u.Username,
+			PasswordHash: string(passwordHash),
+		}); err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Username, err)
+		}
 	}
+	return storage, nil
 }
 
-func setupOAuthConfig() *oauth2.Config {
-	authZServerBaseURL := "http://localhost:" + strconv.Itoa(authorizationServerPort)
-	authZURL := authZServerBaseURL + "/authorize"
-	tokenURL := authZServerBaseURL + "/token"
+func setupOAuthConfig(cfg *config.Config) *oauth2.Config {
+	authZURL := cfg.Authorization.BaseURL + "/authorize"
+	tokenURL := cfg.Authorization.BaseURL + "/token"
 
-	clientBaseURL := "http://localhost:" + strconv.Itoa(AppServerPort)
-	callbackURL := clientBaseURL + "/auth/callback"
+	callbackURL := cfg.App.BaseURL + "/auth/callback"
 
 	oauthConfig := &oauth2.Config{
-		ClientID:     os.Getenv("CLIENT_ID"),
-		ClientSecret: os.Getenv("CLIENT_SECRET"),
+		ClientID:     cfg.App.ClientID,
+		ClientSecret: cfg.App.ClientSecret,
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authZURL,
 			TokenURL: tokenURL,
 		},
 		RedirectURL: callbackURL,
-		Scopes:      []string{"openid", "profile", "email"},
+		Scopes:      cfg.App.Scopes,
 	}
 
 	return oauthConfig
 }
+
+// loadTrustedIssuers registers the issuers of JWT assertions in the JSON file, which is an array of model.TrustedIssuer.
+func loadTrustedIssuers(storage *infra.AuthorizationStorage, path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var issuers []*model.TrustedIssuer
+	if err := json.Unmarshal(b, &issuers); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, issuer := range issuers {
+		if err := storage.AddTrustedIssuer(issuer); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// loadUpstreamProviders reads the OpenID providers with which users log in from the JSON file, which is an array of model.UpstreamProvider.
+func loadUpstreamProviders(path string) ([]*model.UpstreamProvider, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var providers []*model.UpstreamProvider
+	if err := json.Unmarshal(b, &providers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, p := range providers {
+		if p.ID == "" || p.Issuer == "" || p.ClientID == "" {
+			return nil, fmt.Errorf("%s: id, issuer and client_id are required", path)
+		}
+	}
+	return providers, nil
+}
+
+// loadTenants reads the tenants served in addition to the default issuer from the JSON file, which is an array of config.Tenant.
+func loadTenants(path string) ([]*config.Tenant, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []*config.Tenant
+	if err := json.Unmarshal(b, &tenants); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, t := range tenants {
+		if err := t.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: tenant %s: %w", path, t.ID, err)
+		}
+	}
+	return tenants, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/config"
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestTenantClients(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.Default()
+	cfg.Complete()
+	tenants := map[string]*config.Tenant{}
+	for _, id := range []string{"brand-a", "brand-b"} {
+		tenants[id] = &config.Tenant{
+			Tenant: model.Tenant{ID: id},
+			Clients: []*config.Client{{
+				ID:           id + "-client-id",
+				Secret:       id + "-client-secret",
+				RedirectURIs: []string{"http://localhost:9000/auth/callback"},
+			}},
+			Users: []*config.User{{ID: id + "-user-id", Username: id + "-user", Password: "password"}},
+		}
+	}
+
+	tests := map[string]struct {
+		tenant     string
+		clientID   string
+		wantStatus int
+	}{
+		// the client is authenticated, and the dummy code is rejected.
+		"ok: client of the tenant": {
+			tenant:     "brand-a",
+			clientID:   "brand-a",
+			wantStatus: http.StatusBadRequest,
+		},
+		"ng: client of another tenant": {
+			tenant:     "brand-b",
+			clientID:   "brand-a",
+			wantStatus: http.StatusUnauthorized,
+		},
+		"ng: client of the default issuer": {
+			tenant:     "brand-b",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			shared, err := newTenantShared(cfg, tenants[tt.tenant], nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			authZSV, _, err := newAuthorizationServer(cfg, shared, make([]byte, 32))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clientID, clientSecret := cfg.App.ClientID, cfg.App.ClientSecret
+			if tt.clientID != "" {
+				clientID, clientSecret = tt.clientID+"-client-id", tt.clientID+"-client-secret"
+			}
+			body := url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"dummy-code"},
+				"redirect_uri": {"http://localhost:9000/auth/callback"},
+			}
+			r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.SetBasicAuth(clientID, clientSecret)
+			w := httptest.NewRecorder()
+			authZSV.Handler().ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("want status %d, got %d: %s", tt.wantStatus, w.Code, w.Body)
+			}
+		})
+	}
+}
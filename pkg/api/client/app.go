@@ -4,9 +4,9 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"html/template"
 	"log/slog"
 	"net/http"
-	"text/template"
 
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
@@ -21,31 +21,72 @@ var (
 
 	//go:embed templates/callback.html.tmpl
 	callbackTemplate string
+
+	//go:embed templates/frontchannel_logout.html.tmpl
+	frontchannelLogoutTemplate string
 )
 
 type App struct {
 	oauthConfig  *oauth2.Config
+	issuer       string
+	resourceURL  string
 	stateStorage map[string]struct{}
+
+	// EndSessionEndpoint logs the user out at the authorization server, if it is set.
+	// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+	EndSessionEndpoint string
+	// CheckSessionIframe tells whether the user is still logged in at the authorization server, if it is set.
+	// ref: https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+	CheckSessionIframe string
 }
 
-func NewApp(oauthConfig *oauth2.Config) *App {
+// NewApp creates the client app. The authorization responses must come from issuer,
+// and the access tokens are requested for resourceURL.
+func NewApp(oauthConfig *oauth2.Config, issuer, resourceURL string) *App {
 	return &App{
 		oauthConfig:  oauthConfig,
+		issuer:       issuer,
+		resourceURL:  resourceURL,
 		stateStorage: make(map[string]struct{}),
 	}
 }
 
-func (s *App) Run(port int) error {
+func (s *App) Run(addr string) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.Index)
 	mux.HandleFunc("/login", s.Login)
 	mux.HandleFunc("/auth/callback", s.Callback)
+	mux.HandleFunc("/frontchannel_logout", s.FrontchannelLogout)
+
+	return http.ListenAndServe(addr, mux)
+}
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+type indexPage struct {
+	ClientID              string
+	ResourceURL           string
+	EndSessionEndpoint    string
+	PostLogoutRedirectURI string
+	CheckSessionIframe    string
 }
 
 func (s *App) Index(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte(indexTemplate))
+	t, err := template.New("index").Parse(indexTemplate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = t.Execute(w, &indexPage{
+		ClientID:              s.oauthConfig.ClientID,
+		ResourceURL:           s.resourceURL,
+		EndSessionEndpoint:    s.EndSessionEndpoint,
+		PostLogoutRedirectURI: "http://" + r.Host + "/",
+		CheckSessionIframe:    s.CheckSessionIframe,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
 
 func (s *App) Login(w http.ResponseWriter, r *http.Request) {
@@ -78,13 +119,23 @@ func (s *App) LoginPOST(w http.ResponseWriter, r *http.Request) {
 	// TODO: need to consider how to recognize each state
 	s.stateStorage[state] = struct{}{}
 
-	authCodeURL := s.oauthConfig.AuthCodeURL(state)
+	// ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2.1
+	authCodeURL := s.oauthConfig.AuthCodeURL(state, oauth2.SetAuthURLParam("resource", s.resourceURL))
 	http.Redirect(w, r, authCodeURL, http.StatusFound)
 }
 
 type CallbackRequest struct {
 	Code  string // required
 	State string // required if an AuthZRequest has the state
+	Iss   string // required as the authorization server supports it, ref: https://datatracker.ietf.org/doc/html/rfc9207#section-2
+	// required as the authorization server supports the session management, ref: https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions
+	SessionState string
+}
+
+type callbackPage struct {
+	AccessToken  string
+	IDToken      string
+	SessionState string
 }
 
 func (r *CallbackRequest) Validate() error {
@@ -93,6 +144,12 @@ func (r *CallbackRequest) Validate() error {
 
 func (s *App) Callback(w http.ResponseWriter, r *http.Request) {
 	params := r.URL.Query()
+	// the issuer is checked before the other parameters to prevent mix-up attacks,
+	// ref: https://datatracker.ietf.org/doc/html/rfc9207#section-2.4
+	if params.Get("iss") != s.issuer {
+		http.Error(w, "invalid issuer", http.StatusUnauthorized)
+		return
+	}
 	if params.Get("error") != "" {
 		http.Error(w, params.Get("error")+":"+params.Get("error_description"), http.StatusInternalServerError)
 		return
@@ -123,7 +180,12 @@ func (s *App) Callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = t.Execute(w, tokens.AccessToken)
+	idToken, _ := tokens.AccessToken.Extra("id_token").(string)
+	err = t.Execute(w, &callbackPage{
+		AccessToken:  tokens.AccessToken.AccessToken,
+		IDToken:      idToken,
+		SessionState: params.Get("session_state"),
+	})
 	if err != nil {
 		slog.Error("failed to execute template", slog.String("error", err.Error()))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -152,3 +214,16 @@ func (s *App) CodeExchange(ctx context.Context, code string, opts ...CodeExchang
 
 	return &CodeExchangeResponse{AccessToken: token}, nil
 }
+
+// FrontchannelLogout is rendered by the authorization server in an iframe when the user logs out there,
+// and removes the tokens of the user.
+// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+func (s *App) FrontchannelLogout(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("iss") != s.issuer {
+		http.Error(w, "invalid issuer", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store")
+	w.Write([]byte(frontchannelLogoutTemplate))
+}
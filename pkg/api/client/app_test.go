@@ -0,0 +1,78 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func TestAppCallbackIssuer(t *testing.T) {
+	t.Parallel()
+
+	const issuer = "http://localhost:9001"
+
+	tests := map[string]struct {
+		params     url.Values
+		wantStatus int
+		wantBody   string
+	}{
+		"ok: error from the expected issuer": {
+			params:     url.Values{"iss": {issuer}, "error": {"access_denied"}},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "access_denied",
+		},
+		"ng: another issuer": {
+			params:     url.Values{"iss": {"https://attacker.example.com"}, "code": {"code"}, "state": {"state"}},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "invalid issuer",
+		},
+		"ng: no issuer": {
+			params:     url.Values{"code": {"code"}, "state": {"state"}},
+			wantStatus: http.StatusUnauthorized,
+			wantBody:   "invalid issuer",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			app := NewApp(&oauth2.Config{}, issuer, "http://localhost:9003")
+			app.stateStorage["state"] = struct{}{}
+
+			rec := httptest.NewRecorder()
+			app.Callback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?"+tt.params.Encode(), nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("want status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("want body containing %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAppIndexEscapesHost(t *testing.T) {
+	t.Parallel()
+
+	app := NewApp(&oauth2.Config{ClientID: "dummy-client-id"}, "http://localhost:9001", "http://localhost:9003")
+	app.EndSessionEndpoint = "http://localhost:9001/end_session"
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = `localhost:9000";alert(document.cookie);"`
+	rec := httptest.NewRecorder()
+	app.Index(rec, r)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("want status %d, got %d", http.StatusOK, rec.Code)
+	}
+	// the host is escaped in the string of the script, so that it does not end the string.
+	if strings.Contains(rec.Body.String(), `";alert(document.cookie);"`) {
+		t.Errorf("the host is not escaped: %s", rec.Body.String())
+	}
+}
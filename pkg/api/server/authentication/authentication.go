@@ -2,27 +2,99 @@ package authentication
 
 import (
 	_ "embed"
-	"fmt"
+	"errors"
+	"html/template"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
-	"text/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/usecase/authentication"
+)
+
+const (
+	sessionCookie = "session_id"
+	// browserStateCookie is read by the check session iframe, so it is not HttpOnly.
+	// ref: https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+	browserStateCookie = "op_browser_state"
 )
 
-type Authentication struct{}
+// defaultAuthorizationURL is the authorization server which is run together in cmd/server.
+const defaultAuthorizationURL = "http://localhost:9001"
+
+type Authentication struct {
+	authNUC *authentication.AuthNUseCase
+
+	// AuthorizationURL is the issuer of the authorization server to which the logins are returned.
+	AuthorizationURL string
+	// BasePath is the path at which the server is mounted, such as /t/{tenant}. The pages and the cookies are scoped to it.
+	BasePath string
+}
 
-func NewAuthentication() *Authentication {
-	return &Authentication{}
+func NewAuthentication(authNUC *authentication.AuthNUseCase) *Authentication {
+	return &Authentication{
+		authNUC:          authNUC,
+		AuthorizationURL: defaultAuthorizationURL,
+	}
 }
 
-func (s *Authentication) Run(port int) error {
+func (s *Authentication) Run(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Handler returns the endpoints of the authentication server, which are served at BasePath.
+func (s *Authentication) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/login", s.Login)
+	mux.HandleFunc("/login/second_factor", s.SecondFactor)
+	mux.HandleFunc("/login/webauthn", s.WebAuthnLogin)
+	mux.HandleFunc("/webauthn/register", s.WebAuthnRegister)
+	mux.HandleFunc("/login/upstream", s.UpstreamLogin)
+	mux.HandleFunc("/login/upstream/callback", s.UpstreamCallback)
+	mux.HandleFunc("/upstream/link", s.UpstreamLink)
+	mux.HandleFunc("/logout", s.Logout)
+	mux.HandleFunc("/check_session", s.CheckSession)
+
+	return withRemoteIP(mux)
+}
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+// withRemoteIP passes the address of the request to the use case, by which the failed logins are counted.
+// X-Forwarded-For is not trusted, as the server is not deployed behind proxies.
+func withRemoteIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		next.ServeHTTP(w, r.WithContext(service.ContextWithRemoteIP(r.Context(), ip)))
+	})
 }
 
 //go:embed templates/login.html.tmpl
 var loginTemplate string
 
+var loginTmpl = template.Must(template.New("login").Parse(loginTemplate))
+
+type loginPage struct {
+	BasePath  string
+	ID        string
+	ACRValues string
+	MaxAge    string
+	Device    bool // the user logs in on the simulated authentication device for a backchannel authentication request
+	Error     string
+
+	// WebAuthn lets the user log in with a passkey instead of the password.
+	// The ceremony is started when the user chooses it, so that rendering the page does not store a ceremony.
+	WebAuthn bool
+	// UpstreamProviders let the user log in with the accounts of them.
+	UpstreamProviders []*model.UpstreamProvider
+}
+
 func (s *Authentication) Login(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -34,20 +106,224 @@ func (s *Authentication) Login(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// newLoginPage takes over the parameters of the authorization request through the login steps.
+func newLoginPage(r *http.Request) *loginPage {
+	return &loginPage{
+		ID:        r.FormValue("id"),
+		ACRValues: r.FormValue("acr_values"),
+		MaxAge:    r.FormValue("max_age"),
+		Device:    r.FormValue("device") == "true",
+	}
+}
+
 func (s *Authentication) LoginGET(w http.ResponseWriter, r *http.Request) {
-	t, err := template.New("login").Parse(loginTemplate)
+	page := newLoginPage(r)
+
+	// the user does not log in again while the session satisfies the request.
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		var maxAge *int64
+		if v, err := strconv.ParseInt(page.MaxAge, 10, 64); err == nil {
+			maxAge = &v
+		}
+		authn, err := s.authNUC.ReuseSession(r.Context(), cookie.Value, strings.Fields(page.ACRValues), maxAge)
+		if err == nil {
+			slog.Info("session is reused", slog.String("id", page.ID))
+			s.redirectWithLoginToken(w, r, page, authn)
+			return
+		}
+	}
+
+	s.renderLogin(w, r, page)
+}
+
+func (s *Authentication) LoginPOST(w http.ResponseWriter, r *http.Request) {
+	page := newLoginPage(r)
+
+	authn, err := s.authNUC.Login(r.Context(), r.FormValue("username"), r.FormValue("password"))
+	var locked *service.LockedOutError
+	if errors.As(err, &locked) {
+		slog.Info("login is locked out", slog.String("id", page.ID))
+		page.Error = locked.Error()
+		// ref: https://datatracker.ietf.org/doc/html/rfc6585#section-4
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(locked.RetryAfter.Seconds())), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		s.renderLogin(w, r, page)
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, authentication.ErrInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("login failed", slog.String("id", page.ID))
+		page.Error = err.Error()
+		w.WriteHeader(http.StatusUnauthorized)
+		s.renderLogin(w, r, page)
+		return
+	}
+
+	s.afterFirstFactor(w, r, page, authn)
+}
+
+// afterFirstFactor asks the user for the second factor if it is needed, and completes the login otherwise.
+func (s *Authentication) afterFirstFactor(w http.ResponseWriter, r *http.Request, page *loginPage, authn *model.Authentication) {
+	factor, err := s.authNUC.SecondFactor(r.Context(), authn, strings.Fields(page.ACRValues))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if factor != authentication.SecondFactorNone {
+		s.startSecondFactor(w, r, page, authn, factor)
+		return
+	}
+
+	s.completeLogin(w, r, page, authn)
+}
+
+// completeLogin starts the session of the authenticated user and continues the request.
+func (s *Authentication) completeLogin(w http.ResponseWriter, r *http.Request, page *loginPage, authn *model.Authentication) {
+	session, err := s.authNUC.StartSession(r.Context(), authn)
+	switch {
+	case err == nil:
+		authn = session.Authentication
+		s.setSessionCookies(w, session)
+	case !errors.Is(err, authentication.ErrNoSession):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.redirectWithLoginToken(w, r, page, authn)
+}
+
+// redirectWithLoginToken continues the request at the authorization server with the result of the authentication.
+func (s *Authentication) redirectWithLoginToken(w http.ResponseWriter, r *http.Request, page *loginPage, authn *model.Authentication) {
+	loginToken, err := s.authNUC.LoginToken(page.ID, authn)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	err = t.Execute(w, nil)
+
+	u := url.Values{}
+	u.Set("login_token", loginToken)
+	if page.Device {
+		u.Set("id", page.ID)
+		http.Redirect(w, r, s.AuthorizationURL+"/bc-device?"+u.Encode(), http.StatusFound)
+		return
+	}
+	u.Set("client", page.ID)
+	http.Redirect(w, r, s.AuthorizationURL+"/authorize?"+u.Encode(), http.StatusFound)
+}
+
+func (s *Authentication) renderLogin(w http.ResponseWriter, r *http.Request, page *loginPage) {
+	page.BasePath = s.BasePath
+	page.WebAuthn = s.authNUC.WebAuthnEnabled()
+	page.UpstreamProviders = s.authNUC.EnabledUpstreamProviders()
+
+	err := loginTmpl.Execute(w, page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
-func (s *Authentication) LoginPOST(w http.ResponseWriter, r *http.Request) {
-	client := r.FormValue("client")
-	http.Redirect(w, r, "http://localhost:9001/authorize?client="+client, http.StatusFound)
+// cookiePath scopes the cookies to the server, so that the sessions of the tenants on the same host do not overwrite each other.
+func (s *Authentication) cookiePath() string {
+	return s.BasePath + "/"
+}
+
+func (s *Authentication) setSessionCookies(w http.ResponseWriter, session *model.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session.ID,
+		Path:     s.cookiePath(),
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     browserStateCookie,
+		Value:    model.BrowserState(session.ID),
+		Path:     s.cookiePath(),
+		Expires:  session.ExpiresAt,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (s *Authentication) clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{sessionCookie, browserStateCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:   name,
+			Path:   s.cookiePath(),
+			MaxAge: -1,
+		})
+	}
+}
+
+//go:embed templates/logout.html.tmpl
+var logoutTemplate string
+
+var logoutTmpl = template.Must(template.New("logout").Parse(logoutTemplate))
+
+type logoutPage struct {
+	BasePath string
+	ID       string
+}
+
+// Logout ends the session of the user for the logout request of the authorization server.
+// The user confirms it so that other sites cannot log the user out.
+// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+func (s *Authentication) Logout(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		id := r.FormValue("id")
+		if id == "" {
+			// the logout is started at the authorization server to notify the clients of the session.
+			http.Redirect(w, r, s.AuthorizationURL+"/end_session", http.StatusFound)
+			return
+		}
+		err := logoutTmpl.Execute(w, &logoutPage{BasePath: s.BasePath, ID: id})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case http.MethodPost:
+		s.LogoutPOST(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Authentication) LogoutPOST(w http.ResponseWriter, r *http.Request) {
+	id := r.PostFormValue("id")
+
+	u := url.Values{}
+	u.Set("id", id)
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		authn, err := s.authNUC.EndSession(r.Context(), cookie.Value)
+		switch {
+		case err == nil:
+			// the authorization server learns which session is ended.
+			loginToken, err := s.authNUC.LoginToken(id, authn)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			u.Set("login_token", loginToken)
+		case !errors.Is(err, authentication.ErrNoSession):
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.clearSessionCookies(w)
+
+	http.Redirect(w, r, s.AuthorizationURL+"/end_session?"+u.Encode(), http.StatusFound)
+}
+
+//go:embed templates/check_session.html.tmpl
+var checkSessionTemplate []byte
+
+// CheckSession is the check session iframe, which tells relying parties whether the session of the user has changed.
+// ref: https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+func (s *Authentication) CheckSession(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(checkSessionTemplate)
 }
@@ -0,0 +1,143 @@
+package authentication
+
+import (
+	_ "embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/usecase/authentication"
+)
+
+//go:embed templates/second_factor.html.tmpl
+var secondFactorTemplate string
+
+var secondFactorTmpl = template.Must(template.New("second_factor").Parse(secondFactorTemplate))
+
+//go:embed templates/recovery_codes.html.tmpl
+var recoveryCodesTemplate string
+
+var recoveryCodesTmpl = template.Must(template.New("recovery_codes").Parse(recoveryCodesTemplate))
+
+type secondFactorPage struct {
+	*loginPage
+	Token string // keeps the user authenticated with the password
+	// Enrollment is set while the user registers the secret with the authenticator.
+	Enrollment *authentication.TOTPEnrollment
+}
+
+type recoveryCodesPage struct {
+	*loginPage
+	Token         string
+	RecoveryCodes []string
+}
+
+// startSecondFactor asks the user authenticated with the password to prove the second factor, or to enroll it first.
+func (s *Authentication) startSecondFactor(w http.ResponseWriter, r *http.Request, page *loginPage, authn *model.Authentication, factor authentication.SecondFactor) {
+	token, err := s.authNUC.SecondFactorToken(page.ID, authn)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sfPage := &secondFactorPage{loginPage: page, Token: token}
+	if factor == authentication.SecondFactorEnroll {
+		sfPage.Enrollment, err = s.authNUC.StartTOTPEnrollment(r.Context(), page.ID, token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	s.renderSecondFactor(w, sfPage)
+}
+
+// SecondFactor verifies the TOTP code or a recovery code of the user, or confirms the enrollment of TOTP.
+// ref: https://datatracker.ietf.org/doc/html/rfc6238
+func (s *Authentication) SecondFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page := &secondFactorPage{
+		loginPage: newLoginPage(r),
+		Token:     r.PostFormValue("token"),
+	}
+	code := r.PostFormValue("code")
+
+	switch r.PostFormValue("action") {
+	case "enroll":
+		page.Enrollment = &authentication.TOTPEnrollment{
+			Secret: r.PostFormValue("secret"),
+			KeyURI: r.PostFormValue("key_uri"),
+		}
+		authn, recoveryCodes, err := s.authNUC.ConfirmTOTPEnrollment(r.Context(), page.ID, page.Token, code)
+		if err != nil {
+			s.secondFactorError(w, page, err)
+			return
+		}
+		token, err := s.authNUC.SecondFactorToken(page.ID, authn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.BasePath = s.BasePath
+		err = recoveryCodesTmpl.Execute(w, &recoveryCodesPage{loginPage: page.loginPage, Token: token, RecoveryCodes: recoveryCodes})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case "continue":
+		// the user has saved the recovery codes after the enrollment.
+		authn, err := s.authNUC.CompleteSecondFactor(page.ID, page.Token)
+		if err != nil {
+			s.secondFactorError(w, page, err)
+			return
+		}
+		s.completeLogin(w, r, page.loginPage, authn)
+	default:
+		authn, err := s.authNUC.VerifySecondFactor(r.Context(), page.ID, page.Token, code)
+		if err != nil {
+			s.secondFactorError(w, page, err)
+			return
+		}
+		s.completeLogin(w, r, page.loginPage, authn)
+	}
+}
+
+func (s *Authentication) secondFactorError(w http.ResponseWriter, page *secondFactorPage, err error) {
+	var locked *service.LockedOutError
+	if errors.As(err, &locked) {
+		slog.Info("second factor is locked out", slog.String("id", page.ID))
+		page.Error = locked.Error()
+		// ref: https://datatracker.ietf.org/doc/html/rfc6585#section-4
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(locked.RetryAfter.Seconds())), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+		s.renderSecondFactor(w, page)
+		return
+	}
+	if !errors.Is(err, authentication.ErrInvalidOTP) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("second factor failed", slog.String("id", page.ID))
+	page.Error = authentication.ErrInvalidOTP.Error()
+	if errors.Is(err, authentication.ErrSecondFactorTokenInvalidated) {
+		page.Error = authentication.ErrSecondFactorTokenInvalidated.Error()
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	s.renderSecondFactor(w, page)
+}
+
+func (s *Authentication) renderSecondFactor(w http.ResponseWriter, page *secondFactorPage) {
+	page.BasePath = s.BasePath
+	w.Header().Set("Cache-Control", "no-store")
+	err := secondFactorTmpl.Execute(w, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
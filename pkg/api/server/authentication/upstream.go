@@ -0,0 +1,165 @@
+package authentication
+
+import (
+	_ "embed"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/usecase/authentication"
+)
+
+//go:embed templates/upstream_link.html.tmpl
+var upstreamLinkTemplate string
+
+var upstreamLinkTmpl = template.Must(template.New("upstream_link").Parse(upstreamLinkTemplate))
+
+type upstreamLinkPage struct {
+	BasePath  string
+	Providers []*model.UpstreamProvider
+	Linked    string // the name of the provider whose account is linked
+	Error     string
+}
+
+// UpstreamLogin redirects the user to the upstream provider chosen on the login page.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (s *Authentication) UpstreamLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page := newLoginPage(r)
+
+	s.redirectToUpstream(w, r, model.UpstreamLoginParams{
+		AuthReqID: page.ID,
+		ACRValues: page.ACRValues,
+		MaxAge:    page.MaxAge,
+		Device:    page.Device,
+	})
+}
+
+// UpstreamLink lets the logged-in user link the account of an upstream provider, with which the user logs in from the next time.
+func (s *Authentication) UpstreamLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the account is linked to the user of the session.
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		http.Error(w, "log in first to link an account", http.StatusUnauthorized)
+		return
+	}
+	authn, err := s.authNUC.ReuseSession(r.Context(), cookie.Value, nil, nil)
+	if err != nil {
+		http.Error(w, "log in first to link an account", http.StatusUnauthorized)
+		return
+	}
+
+	if r.FormValue("provider") == "" {
+		s.renderUpstreamLink(w, &upstreamLinkPage{})
+		return
+	}
+	s.redirectToUpstream(w, r, model.UpstreamLoginParams{LinkSubject: authn.Subject})
+}
+
+func (s *Authentication) redirectToUpstream(w http.ResponseWriter, r *http.Request, params model.UpstreamLoginParams) {
+	authURL, err := s.authNUC.StartUpstreamLogin(r.Context(), r.FormValue("provider"), params)
+	switch {
+	case err == nil:
+		http.Redirect(w, r, authURL, http.StatusFound)
+	case errors.Is(err, authentication.ErrUpstreamDisabled), errors.Is(err, authentication.ErrUnknownUpstreamProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		// the provider may be unreachable on the discovery.
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+// UpstreamCallback is the redirection endpoint to which the upstream provider returns the user with the code.
+// The login continues with the local user of the upstream account, who may be asked for the second factor.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthResponse
+func (s *Authentication) UpstreamCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+
+	req, authn, err := s.authNUC.FinishUpstreamLogin(r.Context(), q.Get("state"), q.Get("code"), q.Get("error"))
+	if req == nil {
+		if errors.Is(err, authentication.ErrUpstreamDisabled) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.LinkSubject != "" {
+		s.finishUpstreamLink(w, req, err)
+		return
+	}
+
+	page := &loginPage{
+		ID:        req.AuthReqID,
+		ACRValues: req.ACRValues,
+		MaxAge:    req.MaxAge,
+		Device:    req.Device,
+	}
+	if err != nil {
+		if !isUpstreamLoginFailure(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("upstream login failed", slog.String("id", page.ID), slog.String("provider", req.ProviderID), slog.String("error", err.Error()))
+		page.Error = err.Error()
+		w.WriteHeader(http.StatusUnauthorized)
+		s.renderLogin(w, r, page)
+		return
+	}
+
+	slog.Info("user logged in with upstream provider", slog.String("id", page.ID), slog.String("provider", req.ProviderID))
+	s.afterFirstFactor(w, r, page, authn)
+}
+
+func (s *Authentication) finishUpstreamLink(w http.ResponseWriter, req *model.UpstreamLoginRequest, err error) {
+	page := &upstreamLinkPage{}
+	switch {
+	case err == nil:
+		for _, p := range s.authNUC.EnabledUpstreamProviders() {
+			if p.ID == req.ProviderID {
+				page.Linked = p.Name
+			}
+		}
+	case isUpstreamLoginFailure(err):
+		slog.Info("upstream account linking failed", slog.String("subject", req.LinkSubject), slog.String("provider", req.ProviderID), slog.String("error", err.Error()))
+		page.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.renderUpstreamLink(w, page)
+}
+
+// isUpstreamLoginFailure reports whether err is shown to the user, who may log in in another way.
+func isUpstreamLoginFailure(err error) bool {
+	return errors.Is(err, authentication.ErrInvalidUpstreamLogin) ||
+		errors.Is(err, authentication.ErrUpstreamAccountConflict) ||
+		errors.Is(err, authentication.ErrUpstreamIdentityLinked) ||
+		errors.Is(err, authentication.ErrUpstreamUserNotProvisioned)
+}
+
+func (s *Authentication) renderUpstreamLink(w http.ResponseWriter, page *upstreamLinkPage) {
+	page.BasePath = s.BasePath
+	page.Providers = s.authNUC.EnabledUpstreamProviders()
+	w.Header().Set("Cache-Control", "no-store")
+	err := upstreamLinkTmpl.Execute(w, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
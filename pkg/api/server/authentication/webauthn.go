@@ -0,0 +1,178 @@
+package authentication
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/usecase/authentication"
+)
+
+//go:embed templates/webauthn_register.html.tmpl
+var webAuthnRegisterTemplate string
+
+var webAuthnRegisterTmpl = template.Must(template.New("webauthn_register").Parse(webAuthnRegisterTemplate))
+
+// webAuthnCeremonyPage passes the options of the ceremony to the script which calls the authenticator.
+type webAuthnCeremonyPage struct {
+	CeremonyID string `json:"ceremony_id"`
+	Options    any    `json:"options"` // marshaled to JSON in the script
+}
+
+type webAuthnRegisterPage struct {
+	*webAuthnCeremonyPage
+	BasePath   string
+	Registered bool
+	Error      string
+}
+
+// WebAuthnRegister lets the logged-in user register a passkey.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-registering-a-new-credential
+func (s *Authentication) WebAuthnRegister(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// the passkey is registered to the user of the session.
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		http.Error(w, "log in first to register a passkey", http.StatusUnauthorized)
+		return
+	}
+	authn, err := s.authNUC.ReuseSession(r.Context(), cookie.Value, nil, nil)
+	if err != nil {
+		http.Error(w, "log in first to register a passkey", http.StatusUnauthorized)
+		return
+	}
+
+	page := &webAuthnRegisterPage{}
+	if r.Method == http.MethodPost {
+		err := s.finishWebAuthnRegistration(r, authn.Subject)
+		switch {
+		case err == nil:
+			slog.Info("passkey is registered", slog.String("subject", authn.Subject))
+			page.Registered = true
+			s.renderWebAuthnRegister(w, page)
+			return
+		case errors.Is(err, authentication.ErrInvalidWebAuthn), errors.Is(err, authentication.ErrWebAuthnCredentialExists):
+			slog.Info("passkey registration failed", slog.String("subject", authn.Subject), slog.String("error", err.Error()))
+			page.Error = err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ceremony, options, err := s.authNUC.BeginWebAuthnRegistration(r.Context(), authn.Subject)
+	if err != nil {
+		if errors.Is(err, authentication.ErrWebAuthnDisabled) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page.webAuthnCeremonyPage = &webAuthnCeremonyPage{CeremonyID: ceremony.ID, Options: options}
+	s.renderWebAuthnRegister(w, page)
+}
+
+func (s *Authentication) finishWebAuthnRegistration(r *http.Request, subject string) error {
+	values, err := decodeBase64URLForm(r, "credential_id", "client_data_json", "attestation_object")
+	if err != nil {
+		return err
+	}
+	_, err = s.authNUC.FinishWebAuthnRegistration(r.Context(), subject, r.PostFormValue("ceremony_id"), &model.WebAuthnAttestationResponse{
+		CredentialID:      values[0],
+		ClientDataJSON:    values[1],
+		AttestationObject: values[2],
+	})
+	return err
+}
+
+func (s *Authentication) renderWebAuthnRegister(w http.ResponseWriter, page *webAuthnRegisterPage) {
+	page.BasePath = s.BasePath
+	w.Header().Set("Cache-Control", "no-store")
+	err := webAuthnRegisterTmpl.Execute(w, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WebAuthnLogin starts the ceremony on GET when the user chooses the passkey on the login page,
+// and authenticates the user with the assertion of the passkey, which is posted from it.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-verifying-assertion
+func (s *Authentication) WebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.beginWebAuthnLogin(w, r)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page := newLoginPage(r)
+
+	values, err := decodeBase64URLForm(r, "credential_id", "client_data_json", "authenticator_data", "signature", "user_handle")
+	if err == nil {
+		var authn *model.Authentication
+		authn, err = s.authNUC.FinishWebAuthnLogin(r.Context(), r.PostFormValue("ceremony_id"), &model.WebAuthnAssertionResponse{
+			CredentialID:      values[0],
+			ClientDataJSON:    values[1],
+			AuthenticatorData: values[2],
+			Signature:         values[3],
+			UserHandle:        values[4],
+		})
+		if err == nil {
+			s.afterFirstFactor(w, r, page, authn)
+			return
+		}
+	}
+
+	if !errors.Is(err, authentication.ErrInvalidWebAuthn) && !errors.Is(err, authentication.ErrWebAuthnSignCountRegressed) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slog.Info("passkey login failed", slog.String("id", page.ID), slog.String("error", err.Error()))
+	page.Error = authentication.ErrInvalidWebAuthn.Error()
+	w.WriteHeader(http.StatusUnauthorized)
+	s.renderLogin(w, r, page)
+}
+
+// beginWebAuthnLogin returns the options of the new ceremony to the script of the login page, which passes them to the authenticator.
+func (s *Authentication) beginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	ceremony, options, err := s.authNUC.BeginWebAuthnLogin(r.Context())
+	if err != nil {
+		if errors.Is(err, authentication.ErrWebAuthnDisabled) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(&webAuthnCeremonyPage{CeremonyID: ceremony.ID, Options: options})
+}
+
+// decodeBase64URLForm decodes the binary values of the credential, which the script posts in base64url.
+func decodeBase64URLForm(r *http.Request, names ...string) ([][]byte, error) {
+	values := make([][]byte, 0, len(names))
+	for _, name := range names {
+		v, err := base64.RawURLEncoding.DecodeString(r.PostFormValue(name))
+		if err != nil {
+			return nil, errors.Join(authentication.ErrInvalidWebAuthn, fmt.Errorf("%s: %w", name, err))
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
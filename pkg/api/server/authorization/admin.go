@@ -0,0 +1,111 @@
+package authorization
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/usecase/authorization"
+)
+
+type FailureCountersResponse struct {
+	Counters []*model.FailureCounter `json:"counters"`
+}
+
+// Lockouts lists the failure counters on GET, and unlocks the one of kind and key on DELETE.
+// Administrators call it with AdminToken as the bearer token.
+func (s *Authorization) Lockouts(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticateAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		counters, err := s.authUC.FailureCounters(r.Context())
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		if counters == nil {
+			counters = []*model.FailureCounter{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(&FailureCountersResponse{Counters: counters})
+	case http.MethodDelete:
+		err := s.authUC.Unlock(r.Context(), model.FailureKey{
+			Kind: model.FailureKind(r.FormValue("kind")),
+			Key:  r.FormValue("key"),
+		})
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type SigningKeysResponse struct {
+	Keys []*model.KeyRecord `json:"keys"`
+}
+
+// SigningKeys lists the signing keys on GET, activates the next key on POST, and revokes the key of kid on DELETE.
+// Administrators call it with AdminToken as the bearer token.
+func (s *Authorization) SigningKeys(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticateAdmin(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := s.authUC.SigningKeyRecords(r.Context())
+		if err != nil {
+			adminError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(&SigningKeysResponse{Keys: keys})
+	case http.MethodPost:
+		if err := s.authUC.RotateSigningKeys(r.Context()); err != nil {
+			adminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := s.authUC.RevokeSigningKey(r.Context(), r.FormValue("kid")); err != nil {
+			adminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authenticateAdmin reports whether the request has the admin token. The admin API is closed if the token is not set.
+func (s *Authorization) authenticateAdmin(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.AdminToken)) == 1
+}
+
+func adminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, authorization.ErrLockoutDisabled), errors.Is(err, authorization.ErrKeyRotationDisabled), errors.Is(err, service.ErrKeyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, authorization.ErrInvalidRequest):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -2,9 +2,14 @@ package authorization
 
 import (
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
 	"github.com/task4233/oauth/pkg/repository"
 	"github.com/task4233/oauth/pkg/usecase/authorization"
 )
@@ -13,34 +18,85 @@ type Authorizer interface {
 	Storage() repository.Storage
 }
 
+// defaultAuthenticationURL is the authentication server which is run together in cmd/server.
+const defaultAuthenticationURL = "http://localhost:9002"
+
 type Authorization struct {
 	Authorizer
 	authUC *authorization.AuthUseCase
+
+	// AuthenticationURL is the base URL of the authentication server at which users log in.
+	AuthenticationURL string
+
+	// AdminToken authenticates the administrators calling the admin API, which is closed if it is empty.
+	AdminToken string
+
+	// RateLimits limit the requests to the endpoints, which are DefaultRateLimits unless they are replaced.
+	RateLimits  RateLimits
+	rateLimiter *service.RateLimiter
 }
 
 func NewAuthorization(authUC *authorization.AuthUseCase) *Authorization {
 	return &Authorization{
-		authUC: authUC,
+		authUC:            authUC,
+		AuthenticationURL: defaultAuthenticationURL,
+		RateLimits:        DefaultRateLimits,
+		rateLimiter:       service.NewRateLimiter(),
 	}
 }
 
-func (s *Authorization) Run(port int) error {
+func (s *Authorization) Run(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// Handler returns the endpoints of the authorization server, which are served at the root of the issuer.
+func (s *Authorization) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/authorize", s.Authorize)
+	mux.HandleFunc("/par", s.PushedAuthorization)
+	mux.HandleFunc("/bc-authorize", s.BackchannelAuthentication)
+	mux.HandleFunc("/bc-device", s.Device)
+	mux.HandleFunc("/consent", s.Consent)
 	mux.HandleFunc("/token", s.Token)
 	mux.HandleFunc("/introspect", s.Introspect)
+	mux.HandleFunc("/end_session", s.EndSession)
+	mux.HandleFunc("/jwks", s.JWKS)
+	mux.HandleFunc(metadataPath, s.Metadata)
+	mux.HandleFunc("/admin/lockouts", s.Lockouts)
+	mux.HandleFunc("/admin/keys", s.SigningKeys)
+
+	return withRemoteIP(s.withRateLimit(mux))
+}
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+// withRemoteIP passes the address of the request to the use case, by which the failed attempts are counted.
+// X-Forwarded-For is not trusted, as the server is not deployed behind proxies.
+func withRemoteIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		next.ServeHTTP(w, r.WithContext(service.ContextWithRemoteIP(r.Context(), ip)))
+	})
 }
 
 // ref: http://openid-foundation-japan.github.io/rfc6749.ja.html#code-authz-req
 type AuthorizationRequest struct {
-	Scope        string // required
-	ResponseType string // required
-	ClientID     string // required
-	RedirectURI  string // required
-	State        string // recommended
-	Client       string // optional (not in RFC), this value is set after user login
+	Scope        string   // required
+	ResponseType string   // required
+	ResponseMode string   // optional, ref: https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+	ClientID     string   // required
+	RedirectURI  string   // required
+	State        string   // recommended
+	ACRValues    string   // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-4
+	MaxAge       string   // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-4
+	Resources    []string // optional, ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2.1
+	// optional, JSON array, ref: https://datatracker.ietf.org/doc/html/rfc9396#section-2
+	AuthorizationDetails string
+	Request              string // optional, ref: https://datatracker.ietf.org/doc/html/rfc9101#section-5.1
+	RequestURI           string // optional, ref: https://datatracker.ietf.org/doc/html/rfc9101#section-5.2
+	Client               string // optional (not in RFC), this value is set after user login
+	LoginToken           string // optional (not in RFC), this value is set after user login
 }
 
 func (r *AuthorizationRequest) Validate() error {
@@ -53,98 +109,175 @@ func (r *AuthorizationRequest) Validate() error {
 	if r.ClientID == "" {
 		return fmt.Errorf("client_id is required")
 	}
+	if r.ResponseMode != "" && !model.ResponseMode(r.ResponseMode).IsValid() {
+		return fmt.Errorf("response_mode is unsupported")
+	}
 	if r.RedirectURI == "" {
 		return fmt.Errorf("redirect_uri is required")
 	}
+	if r.MaxAge != "" {
+		maxAge, err := strconv.ParseInt(r.MaxAge, 10, 64)
+		if err != nil || maxAge < 0 {
+			return fmt.Errorf("max_age must be a non-negative integer")
+		}
+	}
+	if _, err := parseAuthorizationDetails(r.AuthorizationDetails); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (r *AuthorizationRequest) ToModel() *model.AuthRequest {
-	return &model.AuthRequest{
+	req := &model.AuthRequest{
 		ID:           r.Client,
 		ClientID:     r.ClientID,
 		RedirectURI:  r.RedirectURI,
 		ResponseType: r.ResponseType,
+		ResponseMode: model.ResponseMode(r.ResponseMode),
 		State:        r.State,
 		Scope:        r.Scope,
+		ACRValues:    strings.Fields(r.ACRValues),
+		Resources:    r.Resources,
 	}
+	req.AuthorizationDetails, _ = parseAuthorizationDetails(r.AuthorizationDetails)
+	if maxAge, err := strconv.ParseInt(r.MaxAge, 10, 64); err == nil {
+		req.MaxAge = &maxAge
+	}
+	return req
 }
 
 // ref: http://openid-foundation-japan.github.io/rfc6749.ja.html#code-authz-resp
 type AuthorizationResponse struct {
 	Code  string `form:"code"`  // required
 	State string `form:"state"` // recommended
+
+	// ref: https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions
+	SessionState string `form:"session_state"` // required if the session management is supported
 }
 
 func (s *Authorization) Authorize(w http.ResponseWriter, r *http.Request) {
-	req := s.ParseAuthorizeRequest(r)
+	req, err := s.ParseAuthorizeRequest(r)
+	if err != nil {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       validationErrorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
 
 	// before login
+	if req.Client == "" && req.RequestURI != "" {
+		s.authorizeWithRequestURI(w, r, req)
+		return
+	}
 	if req.Client == "" {
+		// the errors are sent to the redirect_uri only if the client registers it.
+		if _, err := s.authUC.ValidateRedirectURI(r.Context(), req.ClientID, req.RedirectURI); err != nil {
+			ErrorPage(w, r, &ErrorResponse{
+				Error:       errorType(err),
+				Description: err.Error(),
+			})
+			return
+		}
 		err := req.Validate()
 		if err != nil {
-			RequestError(w, r, &ErrorResponse{
-				Error:       InvalidRequest,
-				Description: err.Error(),
-				ErrorURI:    req.RedirectURI,
-				State:       req.State,
+			s.RequestError(w, r, &ErrorResponse{
+				Error:        validationErrorType(err),
+				Description:  err.Error(),
+				ErrorURI:     req.RedirectURI,
+				State:        req.State,
+				ClientID:     req.ClientID,
+				ResponseMode: model.ResponseMode(req.ResponseMode),
 			})
 			return
 		}
 
 		authReq, client, err := s.authUC.AuthorizeBeforeLogin(r.Context(), req.ToModel())
 		if err != nil {
-			RequestError(w, r, &ErrorResponse{
-				Error:       ServerError,
-				Description: err.Error(),
-				ErrorURI:    req.RedirectURI,
-				State:       req.State,
+			s.RequestError(w, r, &ErrorResponse{
+				Error:        errorType(err),
+				Description:  err.Error(),
+				ErrorURI:     req.RedirectURI,
+				State:        req.State,
+				ClientID:     req.ClientID,
+				ResponseMode: model.ResponseMode(req.ResponseMode),
 			})
 			return
 		}
-		RedirectToLogin(w, r, client, authReq.ID)
+		s.RedirectToLogin(w, r, client, authReq)
 		return
 	}
 
 	// after login
-	authReq, client, err := s.authUC.AuthorizeAfterLogin(r.Context(), req.ToModel())
+	authReq, client, err := s.authUC.AuthorizeAfterLogin(r.Context(), req.ToModel(), req.LoginToken)
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
-			Error:       ServerError,
+		// the redirect_uri is unknown here as the request only has its ID, so the error is shown to the user.
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       errorType(err),
 			Description: err.Error(),
-			ErrorURI:    req.RedirectURI,
-			State:       req.State,
 		})
 		return
 	}
-	s.AuthResponseCode(w, r, authReq, client)
+	s.RenderConsent(w, r, authReq, client)
 }
 
 func (s *Authorization) AuthResponseCode(w http.ResponseWriter, r *http.Request, authReq *model.AuthRequest, client model.Client) {
 	res := &AuthorizationResponse{
-		Code:  authReq.Code,
-		State: authReq.State,
+		Code:         authReq.Code,
+		State:        authReq.State,
+		SessionState: s.authUC.SessionState(authReq),
 	}
-	callback := fmt.Sprintf("%s?code=%s", authReq.RedirectURI, res.Code)
+	params := url.Values{}
+	params.Set("code", res.Code)
 	if res.State != "" {
-		callback += "&state=" + res.State
+		params.Set("state", res.State)
+	}
+	if res.SessionState != "" {
+		params.Set("session_state", res.SessionState)
 	}
-	http.Redirect(w, r, callback, http.StatusFound)
+	s.respond(w, r, authReq.RedirectURI, client.GetID(), authReq.ResponseMode, params)
 }
 
-func (s *Authorization) ParseAuthorizeRequest(r *http.Request) *AuthorizationRequest {
+// ParseAuthorizeRequest parses the parameters of the authorization request.
+// If a request object is passed, the parameters are taken from its verified claims.
+func (s *Authorization) ParseAuthorizeRequest(r *http.Request) (*AuthorizationRequest, error) {
 	res := &AuthorizationRequest{}
 	res.Scope = r.FormValue("scope")
 	res.ResponseType = r.FormValue("response_type")
+	res.ResponseMode = r.FormValue("response_mode")
 	res.ClientID = r.FormValue("client_id")
 	res.RedirectURI = r.FormValue("redirect_uri")
 	res.State = r.FormValue("state")
+	res.ACRValues = r.FormValue("acr_values")
+	res.MaxAge = r.FormValue("max_age")
+	res.Resources = r.Form["resource"]
+	res.AuthorizationDetails = r.FormValue("authorization_details")
+	res.Request = r.FormValue("request")
+	res.RequestURI = r.FormValue("request_uri")
 	res.Client = r.URL.Query().Get("client")
+	res.LoginToken = r.URL.Query().Get("login_token")
 
-	return res
+	if err := s.resolveRequestObject(r, res); err != nil {
+		return res, err
+	}
+	return res, nil
 }
 
-func RedirectToLogin(w http.ResponseWriter, r *http.Request, client model.Client, authReqID string) {
-	loginURI := "http://localhost:9002/" + client.GetLoginURL(authReqID)
+func (s *Authorization) RedirectToLogin(w http.ResponseWriter, r *http.Request, client model.Client, authReq *model.AuthRequest) {
+	loginURI := s.AuthenticationURL + "/" + client.GetLoginURL(authReq.ID)
+
+	// the authentication server uses them to choose how to authenticate the user,
+	// and the result is verified again by AuthorizeAfterLogin.
+	u := url.Values{}
+	if len(authReq.ACRValues) > 0 {
+		u.Set("acr_values", strings.Join(authReq.ACRValues, " "))
+	}
+	if authReq.MaxAge != nil {
+		u.Set("max_age", strconv.FormatInt(*authReq.MaxAge, 10))
+	}
+	if len(u) > 0 {
+		loginURI += "&" + u.Encode()
+	}
+
 	http.Redirect(w, r, loginURI, http.StatusFound)
 }
@@ -0,0 +1,31 @@
+package authorization
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/usecase/authorization"
+)
+
+// parseAuthorizationDetails decodes the authorization_details parameter. It returns nil for an empty parameter.
+// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-2
+func parseAuthorizationDetails(param string) ([]model.AuthorizationDetail, error) {
+	if param == "" {
+		return nil, nil
+	}
+
+	details := []model.AuthorizationDetail{}
+	if err := json.Unmarshal([]byte(param), &details); err != nil {
+		return nil, fmt.Errorf("%w: must be a JSON array of objects", authorization.ErrInvalidAuthorizationDetails)
+	}
+	if len(details) == 0 {
+		return nil, fmt.Errorf("%w: must not be empty", authorization.ErrInvalidAuthorizationDetails)
+	}
+	for i, detail := range details {
+		if detail == nil {
+			return nil, fmt.Errorf("%w: authorization_details[%d] must be an object", authorization.ErrInvalidAuthorizationDetails, i)
+		}
+	}
+	return details, nil
+}
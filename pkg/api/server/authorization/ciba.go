@@ -0,0 +1,181 @@
+package authorization
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.1
+type BackchannelAuthenticationRequest struct {
+	Scope                   string // required
+	ClientNotificationToken string // required for ping and push modes
+	ACRValues               string // optional
+	LoginHintToken          string // one of the hints is required
+	IDTokenHint             string
+	LoginHint               string
+	BindingMessage          string // optional
+	UserCode                string // optional, unsupported
+	RequestedExpiry         string // optional
+}
+
+func (r *BackchannelAuthenticationRequest) Validate() error {
+	if r.Scope == "" {
+		return fmt.Errorf("scope is required")
+	}
+	if r.RequestedExpiry != "" {
+		if v, err := strconv.ParseInt(r.RequestedExpiry, 10, 64); err != nil || v <= 0 {
+			return fmt.Errorf("requested_expiry must be a positive integer")
+		}
+	}
+	return nil
+}
+
+func (r *BackchannelAuthenticationRequest) ToModel() *model.BackchannelAuthRequest {
+	requestedExpiry, _ := strconv.ParseInt(r.RequestedExpiry, 10, 64)
+	return &model.BackchannelAuthRequest{
+		Scope:                   r.Scope,
+		ClientNotificationToken: r.ClientNotificationToken,
+		ACRValues:               strings.Fields(r.ACRValues),
+		LoginHintToken:          r.LoginHintToken,
+		IDTokenHint:             r.IDTokenHint,
+		LoginHint:               r.LoginHint,
+		BindingMessage:          r.BindingMessage,
+		RequestedExpiry:         requestedExpiry,
+	}
+}
+
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.3
+type BackchannelAuthenticationResponse struct {
+	AuthReqID string `json:"auth_req_id"`        // required
+	ExpiresIn int64  `json:"expires_in"`         // required
+	Interval  int64  `json:"interval,omitempty"` // optional, for poll and ping modes
+}
+
+// BackchannelAuthentication starts the authentication of the user on the authentication device for the authenticated client.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7
+func (s *Authorization) BackchannelAuthentication(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID, clientSecret := parseClientCredentials(r)
+	req := s.ParseBackchannelAuthenticationRequest(r)
+	err := req.Validate()
+	if err != nil {
+		TokenError(w, r, &ErrorResponse{
+			Error:       validationErrorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
+
+	bcReq, err := s.authUC.BackchannelAuthenticate(r.Context(), req.ToModel(), clientID, clientSecret)
+	if err != nil {
+		TokenError(w, r, &ErrorResponse{
+			Error:       errorType(err),
+			Description: err.Error(),
+			RetryAfter:  retryAfter(err),
+		})
+		return
+	}
+
+	res := &BackchannelAuthenticationResponse{
+		AuthReqID: bcReq.AuthReqID,
+		ExpiresIn: bcReq.ExpiresIn(),
+	}
+	if bcReq.DeliveryMode != model.BackchannelTokenDeliveryModePush {
+		res.Interval = int64(bcReq.Interval.Seconds())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Authorization) ParseBackchannelAuthenticationRequest(r *http.Request) *BackchannelAuthenticationRequest {
+	return &BackchannelAuthenticationRequest{
+		Scope:                   r.PostFormValue("scope"),
+		ClientNotificationToken: r.PostFormValue("client_notification_token"),
+		ACRValues:               r.PostFormValue("acr_values"),
+		LoginHintToken:          r.PostFormValue("login_hint_token"),
+		IDTokenHint:             r.PostFormValue("id_token_hint"),
+		LoginHint:               r.PostFormValue("login_hint"),
+		BindingMessage:          r.PostFormValue("binding_message"),
+		UserCode:                r.PostFormValue("user_code"),
+		RequestedExpiry:         r.PostFormValue("requested_expiry"),
+	}
+}
+
+//go:embed templates/device.html.tmpl
+var deviceTemplate string
+
+var deviceTmpl = template.Must(template.New("device").Parse(deviceTemplate))
+
+type devicePage struct {
+	ID             string
+	LoginToken     string
+	ClientID       string
+	Scopes         []string
+	BindingMessage string
+}
+
+// Device is the authentication device of users simulated in the browser.
+// The user logs in at the authentication server and approves or denies the backchannel authentication request.
+func (s *Authorization) Device(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	loginToken := r.FormValue("login_token")
+	if r.Method == http.MethodGet && loginToken == "" {
+		u := url.Values{}
+		u.Set("id", id)
+		u.Set("device", "true")
+		http.Redirect(w, r, s.AuthenticationURL+"/login?"+u.Encode(), http.StatusFound)
+		return
+	}
+
+	req, authn, err := s.authUC.BackchannelAuthRequestForDevice(r.Context(), id, loginToken)
+	if err != nil {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       errorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Cache-Control", "no-store")
+		err := deviceTmpl.Execute(w, &devicePage{
+			ID:             req.AuthReqID,
+			LoginToken:     loginToken,
+			ClientID:       req.ClientID,
+			Scopes:         strings.Fields(req.Scope),
+			BindingMessage: req.BindingMessage,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	case http.MethodPost:
+		if r.PostFormValue("decision") != "approve" {
+			authn = nil
+		}
+		err := s.authUC.CompleteBackchannelAuthentication(r.Context(), req.AuthReqID, authn)
+		if err != nil {
+			ErrorPage(w, r, &ErrorResponse{
+				Error:       errorType(err),
+				Description: err.Error(),
+			})
+			return
+		}
+		fmt.Fprintln(w, "Done. You can close this page.")
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,88 @@
+package authorization
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/usecase/authorization"
+)
+
+//go:embed templates/consent.html.tmpl
+var consentTemplate string
+
+var consentTmpl = template.Must(template.New("consent").Parse(consentTemplate))
+
+type consentPage struct {
+	ID                   string
+	ClientID             string
+	Scopes               []string
+	Resources            []string
+	AuthorizationDetails []consentDetail
+}
+
+type consentDetail struct {
+	Type        string
+	Description string
+	JSON        string
+}
+
+// RenderConsent asks the user to approve the authorization request.
+func (s *Authorization) RenderConsent(w http.ResponseWriter, r *http.Request, authReq *model.AuthRequest, client model.Client) {
+	page := &consentPage{
+		ID:        authReq.ID,
+		ClientID:  client.GetID(),
+		Scopes:    strings.Fields(authReq.Scope),
+		Resources: authReq.Resources,
+	}
+	for _, detail := range authReq.AuthorizationDetails {
+		b, err := json.MarshalIndent(detail, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		d := consentDetail{Type: detail.Type(), JSON: string(b)}
+		if typ, err := s.authUC.Storage.GetAuthorizationDetailType(r.Context(), detail.Type()); err == nil {
+			d.Description = typ.Description
+		}
+		page.AuthorizationDetails = append(page.AuthorizationDetails, d)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err := consentTmpl.Execute(w, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Authorization) Consent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	approved := r.PostFormValue("decision") == "approve"
+	authReq, client, err := s.authUC.Consent(r.Context(), r.PostFormValue("id"), approved)
+	if err != nil {
+		if !errors.Is(err, authorization.ErrAccessDenied) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.RequestError(w, r, &ErrorResponse{
+			Error:        AccessDenied,
+			Description:  err.Error(),
+			ErrorURI:     authReq.RedirectURI,
+			State:        authReq.State,
+			ClientID:     authReq.ClientID,
+			ResponseMode: authReq.ResponseMode,
+		})
+		return
+	}
+
+	s.AuthResponseCode(w, r, authReq, client)
+}
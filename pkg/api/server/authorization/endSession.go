@@ -0,0 +1,118 @@
+package authorization
+
+import (
+	_ "embed"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+type EndSessionRequest struct {
+	IDTokenHint           string // recommended
+	ClientID              string // optional
+	PostLogoutRedirectURI string // optional
+	State                 string // optional
+}
+
+func (r *EndSessionRequest) ToModel() *model.LogoutRequest {
+	return &model.LogoutRequest{
+		IDTokenHint:           r.IDTokenHint,
+		ClientID:              r.ClientID,
+		PostLogoutRedirectURI: r.PostLogoutRedirectURI,
+		State:                 r.State,
+	}
+}
+
+// EndSession logs the user out at the request of the relying party.
+// The authentication server ends the session of the user and comes back with the id of the logout request.
+// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+func (s *Authorization) EndSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// after logout
+	if id := r.URL.Query().Get("id"); id != "" {
+		s.endSessionAfterLogout(w, r, id)
+		return
+	}
+
+	req := s.ParseEndSessionRequest(r)
+	logoutReq, err := s.authUC.StartLogout(r.Context(), req.ToModel())
+	if err != nil {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       errorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
+
+	u := url.Values{}
+	u.Set("id", logoutReq.ID)
+	http.Redirect(w, r, s.AuthenticationURL+"/logout?"+u.Encode(), http.StatusFound)
+}
+
+//go:embed templates/logged_out.html.tmpl
+var loggedOutTemplate string
+
+var loggedOutTmpl = template.Must(template.New("logged_out").Parse(loggedOutTemplate))
+
+type loggedOutPage struct {
+	FrontchannelLogoutURIs []string
+	RedirectURI            string
+}
+
+// endSessionAfterLogout notifies the clients of the ended session, and redirects the user to post_logout_redirect_uri
+// after the front-channel logout URIs are rendered.
+func (s *Authorization) endSessionAfterLogout(w http.ResponseWriter, r *http.Request, id string) {
+	logoutReq, authn, err := s.authUC.CompleteLogout(r.Context(), id, r.URL.Query().Get("login_token"))
+	if err != nil {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       errorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
+
+	frontchannelLogoutURIs, err := s.authUC.NotifyLogout(r.Context(), authn)
+	if err != nil {
+		// the user is logged out even if some clients are not notified.
+		slog.Warn("failed to notify the logout", slog.String("error", err.Error()))
+	}
+
+	page := &loggedOutPage{FrontchannelLogoutURIs: frontchannelLogoutURIs}
+	if logoutReq.PostLogoutRedirectURI != "" {
+		// post_logout_redirect_uri is verified to be registered when the logout is started.
+		u, err := url.Parse(logoutReq.PostLogoutRedirectURI)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if logoutReq.State != "" {
+			q := u.Query()
+			q.Set("state", logoutReq.State)
+			u.RawQuery = q.Encode()
+		}
+		page.RedirectURI = u.String()
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	err = loggedOutTmpl.Execute(w, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Authorization) ParseEndSessionRequest(r *http.Request) *EndSessionRequest {
+	return &EndSessionRequest{
+		IDTokenHint:           r.FormValue("id_token_hint"),
+		ClientID:              r.FormValue("client_id"),
+		PostLogoutRedirectURI: r.FormValue("post_logout_redirect_uri"),
+		State:                 r.FormValue("state"),
+	}
+}
@@ -1,32 +1,180 @@
 package authorization
 
 import (
+	"encoding/json"
+	"errors"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/usecase/authorization"
 )
 
 type ErrorType string
 
 const (
-	InvalidRequest ErrorType = "invalid_request"
-	ServerError    ErrorType = "server_error"
+	InvalidRequest       ErrorType = "invalid_request"
+	InvalidClient        ErrorType = "invalid_client"
+	InvalidGrant         ErrorType = "invalid_grant"
+	InvalidScope         ErrorType = "invalid_scope"
+	UnsupportedGrantType ErrorType = "unsupported_grant_type"
+	UnauthorizedClient   ErrorType = "unauthorized_client"
+	ServerError          ErrorType = "server_error"
+	LoginRequired        ErrorType = "login_required" // ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+	AccessDenied         ErrorType = "access_denied"
+	InvalidTarget        ErrorType = "invalid_target" // ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-7
+	InvalidRequestURI    ErrorType = "invalid_request_uri"
+	InvalidRequestObject ErrorType = "invalid_request_object"
+
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+	AuthorizationPending ErrorType = "authorization_pending"
+	SlowDown             ErrorType = "slow_down"
+	ExpiredToken         ErrorType = "expired_token"
+	UnknownUserID        ErrorType = "unknown_user_id"
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-5
+	InvalidAuthorizationDetails ErrorType = "invalid_authorization_details"
 )
 
 type ErrorResponse struct {
-	Error       ErrorType // required
-	Description string    // optional
-	ErrorURI    string    // optional
-	State       string    // optional
+	Error        ErrorType          // required
+	Description  string             // optional
+	ErrorURI     string             // optional
+	State        string             // optional
+	ClientID     string             // optional, the audience of JWT-secured responses
+	ResponseMode model.ResponseMode // optional
+	RetryAfter   time.Duration      // optional, how long the client locked out has to wait
 }
 
-func RequestError(w http.ResponseWriter, r *http.Request, errResp *ErrorResponse) {
+// RequestError returns the error of the authorization request to the redirect_uri.
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+// The error is shown to the user instead if the redirect_uri is not registered by the client.
+func (s *Authorization) RequestError(w http.ResponseWriter, r *http.Request, errResp *ErrorResponse) {
+	if _, err := s.authUC.ValidateRedirectURI(r.Context(), errResp.ClientID, errResp.ErrorURI); err != nil {
+		ErrorPage(w, r, errResp)
+		return
+	}
+
 	u := url.Values{}
 	u.Set("error", string(errResp.Error))
-	u.Set("description", errResp.Description)
-	u.Set("state", errResp.State)
+	if errResp.Description != "" {
+		u.Set("error_description", errResp.Description)
+	}
+	if errResp.State != "" {
+		u.Set("state", errResp.State)
+	}
 
 	slog.Info("error", slog.String("u", u.Encode()))
 
-	http.Redirect(w, r, errResp.ErrorURI+"?"+u.Encode(), http.StatusFound)
+	s.respond(w, r, errResp.ErrorURI, errResp.ClientID, errResp.ResponseMode, u)
+}
+
+// ErrorPage shows the error to the user instead of redirecting it, as the redirect_uri is not trusted yet.
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+func ErrorPage(w http.ResponseWriter, r *http.Request, errResp *ErrorResponse) {
+	slog.Info("error", slog.String("error", string(errResp.Error)), slog.String("description", errResp.Description))
+
+	http.Error(w, string(errResp.Error)+": "+errResp.Description, http.StatusBadRequest)
+}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+type TokenErrorResponse struct {
+	Error       ErrorType `json:"error"`                       // required
+	Description string    `json:"error_description,omitempty"` // optional
+}
+
+// TokenError writes an error response of the endpoints which are called by clients directly.
+func TokenError(w http.ResponseWriter, r *http.Request, errResp *ErrorResponse) {
+	slog.Info("error", slog.String("error", string(errResp.Error)), slog.String("description", errResp.Description))
+
+	status := http.StatusBadRequest
+	switch errResp.Error {
+	case InvalidClient:
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	case ServerError:
+		status = http.StatusInternalServerError
+	}
+	// ref: https://datatracker.ietf.org/doc/html/rfc6585#section-4
+	if errResp.RetryAfter > 0 {
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(errResp.RetryAfter.Seconds())), 10))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&TokenErrorResponse{
+		Error:       errResp.Error,
+		Description: errResp.Description,
+	})
+}
+
+// errorType maps errors of the use case to the error codes.
+func errorType(err error) ErrorType {
+	switch {
+	// the client polling too frequently is told to slow down after it is authenticated.
+	case errors.Is(err, authorization.ErrSlowDown):
+		return SlowDown
+	case errors.Is(err, authorization.ErrInvalidRequest), errors.Is(err, authorization.ErrInvalidRedirectURI):
+		return InvalidRequest
+	case errors.Is(err, authorization.ErrInvalidRequestURI):
+		return InvalidRequestURI
+	case errors.Is(err, authorization.ErrInvalidRequestObject):
+		return InvalidRequestObject
+	case errors.Is(err, authorization.ErrInvalidClient):
+		return InvalidClient
+	case errors.Is(err, authorization.ErrInvalidGrant):
+		return InvalidGrant
+	case errors.Is(err, authorization.ErrInvalidScope):
+		return InvalidScope
+	case errors.Is(err, authorization.ErrUnsupportedGrantType):
+		return UnsupportedGrantType
+	case errors.Is(err, authorization.ErrUnauthorizedClient):
+		return UnauthorizedClient
+	case errors.Is(err, authorization.ErrInvalidTarget):
+		return InvalidTarget
+	case errors.Is(err, authorization.ErrLoginRequired):
+		return LoginRequired
+	case errors.Is(err, authorization.ErrAccessDenied):
+		return AccessDenied
+	case errors.Is(err, authorization.ErrInvalidAuthorizationDetails):
+		return InvalidAuthorizationDetails
+	case errors.Is(err, authorization.ErrAuthorizationPending):
+		return AuthorizationPending
+	case errors.Is(err, authorization.ErrExpiredToken):
+		return ExpiredToken
+	case errors.Is(err, authorization.ErrUnknownUserID):
+		return UnknownUserID
+	default:
+		return ServerError
+	}
+}
+
+// retryAfter returns how long the client has to wait if it is locked out by the failed authentications
+// or over its rate limit.
+func retryAfter(err error) time.Duration {
+	var locked *service.LockedOutError
+	if errors.As(err, &locked) {
+		return locked.RetryAfter
+	}
+	var limited *service.RateLimitedError
+	if errors.As(err, &limited) {
+		return limited.RetryAfter
+	}
+	return 0
+}
+
+// validationErrorType maps errors of request validation, which are invalid_request unless they have a specific code.
+func validationErrorType(err error) ErrorType {
+	if t := errorType(err); t != ServerError {
+		return t
+	}
+	return InvalidRequest
 }
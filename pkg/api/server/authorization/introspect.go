@@ -6,52 +6,75 @@ import (
 	"net/http"
 
 	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
 )
 
 // ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.1
 type IntrospectRequest struct {
 	Token         string          `form:"token"`           // required
 	TokenTypeHint model.TokenType `form:"token_type_hint"` // optional
+	CallerID      string          // required, the protected resource or the client calling this endpoint
+	CallerSecret  string          // required
 }
 
 func (r *IntrospectRequest) Validate() error {
 	if r.Token == "" {
 		return fmt.Errorf("token is required")
 	}
+	if r.CallerID == "" {
+		return fmt.Errorf("caller authentication is required")
+	}
 	return nil
 }
 
+func (r *IntrospectRequest) ToModel() *model.IntrospectRequest {
+	return &model.IntrospectRequest{
+		Token:         r.Token,
+		TokenTypeHint: r.TokenTypeHint,
+		CallerID:      r.CallerID,
+		CallerSecret:  r.CallerSecret,
+	}
+}
+
 // ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
 type IntrospectResponse struct {
-	Active    bool            `json:"active"`     // required
-	Scope     string          `json:"scope"`      // optional
-	ClientID  string          `json:"client_id"`  // optional
-	Username  string          `json:"username"`   // optional
-	TokenType model.TokenType `json:"token_type"` // optional
-	Exp       int64           `json:"exp"`        // optional, expiration time
-	Iat       int64           `json:"iat"`        // optional, issued at
-	Nbf       int64           `json:"nbf"`        // optional, not to be used before
-	Sub       string          `json:"sub"`        // optional, subject of the token
-	Aud       string          `json:"aud"`        // optional, audience
-	Jti       string          `json:"jti"`        // optional, JWT ID
+	Active    bool             `json:"active"`              // required
+	Scope     string           `json:"scope"`               // optional
+	ClientID  string           `json:"client_id"`           // optional
+	Username  string           `json:"username"`            // optional
+	TokenType model.TokenType  `json:"token_type"`          // optional
+	Exp       int64            `json:"exp"`                 // optional, expiration time
+	Iat       int64            `json:"iat"`                 // optional, issued at
+	Nbf       int64            `json:"nbf"`                 // optional, not to be used before
+	Sub       string           `json:"sub"`                 // optional, subject of the token
+	Aud       service.Audience `json:"aud,omitempty"`       // optional, audience
+	Iss       string           `json:"iss,omitempty"`       // optional, issuer
+	Jti       string           `json:"jti"`                 // optional, JWT ID
+	AuthTime  int64            `json:"auth_time,omitempty"` // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6.2
+	ACR       string           `json:"acr,omitempty"`       // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6.2
+	AMR       []string         `json:"amr,omitempty"`       // optional, ref: https://datatracker.ietf.org/doc/html/rfc8176#section-1
+	// optional, ref: https://datatracker.ietf.org/doc/html/rfc9396#section-9.2
+	AuthorizationDetails []model.AuthorizationDetail `json:"authorization_details,omitempty"`
+	Act                  *model.Actor                `json:"act,omitempty"` // optional, ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
 }
 
 func (s *Authorization) Introspect(w http.ResponseWriter, r *http.Request) {
 	req := s.ParseIntrospectRequest(r)
 	err := req.Validate()
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
+		TokenError(w, r, &ErrorResponse{
 			Error:       InvalidRequest,
 			Description: err.Error(),
 		})
 		return
 	}
 
-	res, err := s.authUC.Introspect(r.Context(), req.Token, req.TokenTypeHint)
+	res, err := s.authUC.Introspect(r.Context(), req.ToModel())
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
-			Error:       ServerError,
+		TokenError(w, r, &ErrorResponse{
+			Error:       errorType(err),
 			Description: err.Error(),
+			RetryAfter:  retryAfter(err),
 		})
 		return
 	}
@@ -71,14 +94,21 @@ func (s *Authorization) IntrospectResponse(w http.ResponseWriter, r *http.Reques
 		Nbf:       introspect.Nbf,
 		Sub:       introspect.Sub,
 		Aud:       introspect.Aud,
+		Iss:       introspect.Iss,
 		Jti:       introspect.Jti,
+		AuthTime:  introspect.AuthTime,
+		ACR:       introspect.ACR,
+		AMR:       introspect.AMR,
+
+		AuthorizationDetails: introspect.AuthorizationDetails,
+		Act:                  introspect.Act,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
 	err := json.NewEncoder(w).Encode(res)
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
+		TokenError(w, r, &ErrorResponse{
 			Error:       ServerError,
 			Description: err.Error(),
 		})
@@ -87,8 +117,10 @@ func (s *Authorization) IntrospectResponse(w http.ResponseWriter, r *http.Reques
 }
 
 func (s *Authorization) ParseIntrospectRequest(r *http.Request) *IntrospectRequest {
-	return &IntrospectRequest{
+	req := &IntrospectRequest{
 		Token:         r.FormValue("token"),
 		TokenTypeHint: model.TokenType(r.FormValue("token_type_hint")),
 	}
+	req.CallerID, req.CallerSecret = parseClientCredentials(r)
+	return req
 }
@@ -0,0 +1,24 @@
+package authorization
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JWKS publishes the public keys of the authorization server.
+// ref: https://datatracker.ietf.org/doc/html/rfc7517#section-5
+func (s *Authorization) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	jwks, err := s.authUC.JWKS(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	json.NewEncoder(w).Encode(jwks)
+}
@@ -0,0 +1,141 @@
+package authorization
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// ref: https://datatracker.ietf.org/doc/html/rfc8414#section-3
+const metadataPath = "/.well-known/oauth-authorization-server"
+
+// ref: https://datatracker.ietf.org/doc/html/rfc8414#section-2
+type ServerMetadata struct {
+	Issuer                                    string   `json:"issuer"`                 // required
+	AuthorizationEndpoint                     string   `json:"authorization_endpoint"` // required
+	TokenEndpoint                             string   `json:"token_endpoint"`         // required
+	JWKSURI                                   string   `json:"jwks_uri"`
+	ResponseTypesSupported                    []string `json:"response_types_supported"` // required
+	ResponseModesSupported                    []string `json:"response_modes_supported"`
+	GrantTypesSupported                       []string `json:"grant_types_supported"`
+	TokenEndpointAuthMethodsSupported         []string `json:"token_endpoint_auth_methods_supported"`
+	IntrospectionEndpoint                     string   `json:"introspection_endpoint"`
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported"`
+	ACRValuesSupported                        []string `json:"acr_values_supported"`
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-5
+	PushedAuthorizationRequestEndpoint string `json:"pushed_authorization_request_endpoint"`
+	RequirePushedAuthorizationRequests bool   `json:"require_pushed_authorization_requests"`
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-10.5
+	RequestParameterSupported                 bool     `json:"request_parameter_supported"`
+	RequestURIParameterSupported              bool     `json:"request_uri_parameter_supported"`
+	RequestObjectSigningAlgValuesSupported    []string `json:"request_object_signing_alg_values_supported"`
+	RequestObjectEncryptionAlgValuesSupported []string `json:"request_object_encryption_alg_values_supported"`
+	RequestObjectEncryptionEncValuesSupported []string `json:"request_object_encryption_enc_values_supported"`
+
+	// ref: https://openid.net/specs/oauth-v2-jarm.html#section-4
+	AuthorizationSigningAlgValuesSupported []string `json:"authorization_signing_alg_values_supported"`
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9207#section-3
+	AuthorizationResponseISSParameterSupported bool `json:"authorization_response_iss_parameter_supported"`
+
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.4
+	BackchannelAuthenticationEndpoint     string   `json:"backchannel_authentication_endpoint,omitempty"`
+	BackchannelTokenDeliveryModes         []string `json:"backchannel_token_delivery_modes_supported,omitempty"`
+	BackchannelUserCodeParameterSupported bool     `json:"backchannel_user_code_parameter_supported"`
+
+	// ref: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+
+	// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#OPMetadata
+	EndSessionEndpoint string `json:"end_session_endpoint"`
+	// ref: https://openid.net/specs/openid-connect-session-1_0.html#OPMetadata
+	CheckSessionIframe string `json:"check_session_iframe"`
+	// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCSupport
+	BackchannelLogoutSupported        bool `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported bool `json:"backchannel_logout_session_supported"`
+	// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#OPLogout
+	FrontchannelLogoutSupported        bool `json:"frontchannel_logout_supported"`
+	FrontchannelLogoutSessionSupported bool `json:"frontchannel_logout_session_supported"`
+}
+
+// Metadata publishes the authorization server metadata.
+// ref: https://datatracker.ietf.org/doc/html/rfc8414#section-3
+func (s *Authorization) Metadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	issuer := s.authUC.Issuer
+	res := &ServerMetadata{
+		Issuer:                 issuer,
+		AuthorizationEndpoint:  issuer + "/authorize",
+		TokenEndpoint:          issuer + "/token",
+		JWKSURI:                issuer + "/jwks",
+		ResponseTypesSupported: []string{"code"},
+		ResponseModesSupported: []string{
+			string(model.ResponseModeQuery),
+			string(model.ResponseModeFragment),
+			string(model.ResponseModeFormPost),
+			string(model.ResponseModeQueryJWT),
+			string(model.ResponseModeFragmentJWT),
+			string(model.ResponseModeFormPostJWT),
+			string(model.ResponseModeJWT),
+		},
+		GrantTypesSupported: []string{
+			string(model.GrantTypeAuthorizationCode),
+			string(model.GrantTypeRefreshToken),
+			string(model.GrantTypeTokenExchange),
+			string(model.GrantTypeJWTBearer),
+			string(model.GrantTypeCIBA),
+		},
+		TokenEndpointAuthMethodsSupported:         []string{string(model.AuthMethodBasic)},
+		IntrospectionEndpoint:                     issuer + "/introspect",
+		IntrospectionEndpointAuthMethodsSupported: []string{string(model.AuthMethodBasic)},
+		ACRValuesSupported:                        []string{model.ACRBasic, model.ACRMultiFactor},
+
+		PushedAuthorizationRequestEndpoint: issuer + "/par",
+		RequirePushedAuthorizationRequests: s.authUC.RequirePushedAuthRequests,
+
+		RequestParameterSupported:                 true,
+		RequestURIParameterSupported:              true,
+		RequestObjectSigningAlgValuesSupported:    service.AsymmetricAlgs,
+		RequestObjectEncryptionAlgValuesSupported: []string{service.AlgRSAOAEP, service.AlgRSAOAEP256},
+		RequestObjectEncryptionEncValuesSupported: []string{service.EncA128GCM, service.EncA256GCM},
+
+		AuthorizationResponseISSParameterSupported: true,
+
+		EndSessionEndpoint: issuer + "/end_session",
+		CheckSessionIframe: s.AuthenticationURL + "/check_session",
+
+		FrontchannelLogoutSupported:        true,
+		FrontchannelLogoutSessionSupported: true,
+	}
+	algs, err := s.authUC.SigningAlgs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(algs) > 0 {
+		res.AuthorizationSigningAlgValuesSupported = algs
+		res.IDTokenSigningAlgValuesSupported = algs
+		// logout tokens are signed with the key.
+		res.BackchannelLogoutSupported = true
+		res.BackchannelLogoutSessionSupported = true
+	}
+	if s.authUC.DeviceNotifier != nil {
+		res.BackchannelAuthenticationEndpoint = issuer + "/bc-authorize"
+		res.BackchannelTokenDeliveryModes = []string{
+			string(model.BackchannelTokenDeliveryModePoll),
+			string(model.BackchannelTokenDeliveryModePing),
+			string(model.BackchannelTokenDeliveryModePush),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,83 @@
+package authorization
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-2.2
+type PushedAuthorizationResponse struct {
+	RequestURI string `json:"request_uri"` // required
+	ExpiresIn  int64  `json:"expires_in"`  // required
+}
+
+// PushedAuthorization accepts an authorization request from the authenticated client.
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-2
+func (s *Authorization) PushedAuthorization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	clientID, clientSecret := parseClientCredentials(r)
+	req, err := s.ParseAuthorizeRequest(r)
+	if err == nil && r.FormValue("request_uri") != "" {
+		err = fmt.Errorf("request_uri must not be included")
+	}
+	if err == nil {
+		if req.ClientID == "" {
+			req.ClientID = clientID
+		}
+		err = req.Validate()
+	}
+	if err != nil {
+		TokenError(w, r, &ErrorResponse{
+			Error:       validationErrorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
+
+	par, err := s.authUC.PushAuthorizationRequest(r.Context(), req.ToModel(), clientID, clientSecret)
+	if err != nil {
+		TokenError(w, r, &ErrorResponse{
+			Error:       errorType(err),
+			Description: err.Error(),
+			RetryAfter:  retryAfter(err),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&PushedAuthorizationResponse{
+		RequestURI: par.RequestURI,
+		ExpiresIn:  par.ExpiresIn(),
+	})
+}
+
+// authorizeWithRequestURI starts the authorization with the pushed request.
+// Other parameters than client_id are ignored, and errors are not redirected
+// because the redirect_uri is not trusted until the request is found.
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-4
+func (s *Authorization) authorizeWithRequestURI(w http.ResponseWriter, r *http.Request, req *AuthorizationRequest) {
+	if req.ClientID == "" {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       InvalidRequest,
+			Description: "client_id is required",
+		})
+		return
+	}
+
+	authReq, client, err := s.authUC.AuthorizeWithRequestURI(r.Context(), req.ClientID, req.RequestURI)
+	if err != nil {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       errorType(err),
+			Description: err.Error(),
+		})
+		return
+	}
+	s.RedirectToLogin(w, r, client, authReq)
+}
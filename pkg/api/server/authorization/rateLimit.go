@@ -0,0 +1,109 @@
+package authorization
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/usecase/authorization"
+)
+
+// EndpointRateLimit limits the requests to an endpoint for each client and each address.
+type EndpointRateLimit struct {
+	PerClient model.RateLimit `json:"per_client"`
+	PerIP     model.RateLimit `json:"per_ip"`
+}
+
+// RateLimits are the limits of the endpoints, and Default is applied to the endpoints which are not listed.
+type RateLimits struct {
+	Default   EndpointRateLimit            `json:"default"`
+	Endpoints map[string]EndpointRateLimit `json:"endpoints"` // keyed by the path
+}
+
+// DefaultRateLimits allow the normal use of the clients and the protected resources, which introspect tokens more often.
+var DefaultRateLimits = RateLimits{
+	Default: EndpointRateLimit{
+		PerClient: model.RateLimit{Rate: 10, Burst: 20},
+		PerIP:     model.RateLimit{Rate: 20, Burst: 40},
+	},
+	Endpoints: map[string]EndpointRateLimit{
+		"/token": {
+			PerClient: model.RateLimit{Rate: 5, Burst: 10},
+			PerIP:     model.RateLimit{Rate: 10, Burst: 20},
+		},
+		"/introspect": {
+			PerClient: model.RateLimit{Rate: 50, Burst: 100},
+			PerIP:     model.RateLimit{Rate: 50, Burst: 100},
+		},
+	},
+}
+
+// endpoint returns the key and the limit of the endpoint at path. The paths which are not listed share the default key,
+// so that requests to random paths do not create buckets.
+func (l *RateLimits) endpoint(path string) (string, EndpointRateLimit) {
+	if v, ok := l.Endpoints[path]; ok {
+		return path, v
+	}
+	return "default", l.Default
+}
+
+// withRateLimit rejects the requests over the limit of the address with 429 and Retry-After, and passes the limit
+// of the client to the use case, which applies it once the client is authenticated.
+// The clients polling the token endpoint are told to slow down instead, as the polling grants define.
+// ref: https://datatracker.ietf.org/doc/html/rfc6585#section-4
+func (s *Authorization) withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint, limit := s.RateLimits.endpoint(r.URL.Path)
+		polling := model.GrantType(formValue(r, "grant_type")) == model.GrantTypeCIBA
+
+		if ip, ok := service.RemoteIPFromContext(r.Context()); ok {
+			if retryAfter, ok := s.allow(endpoint+" ip:"+ip, limit.PerIP); !ok {
+				// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+				if polling {
+					TokenError(w, r, &ErrorResponse{
+						Error:       SlowDown,
+						Description: "client polls too frequently",
+					})
+					return
+				}
+				w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		ctx := service.ContextWithClientRateLimit(r.Context(), func(clientID string) error {
+			retryAfter, ok := s.allow(endpoint+" client:"+clientID, limit.PerClient)
+			if ok {
+				return nil
+			}
+			if polling {
+				return fmt.Errorf("%w: client polls too frequently", authorization.ErrSlowDown)
+			}
+			return &service.RateLimitedError{ClientID: clientID, RetryAfter: retryAfter}
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// formValue returns the value of the form posted to /token or /par, and is empty at the other endpoints,
+// whose bodies are left to their handlers.
+func formValue(r *http.Request, key string) string {
+	if r.Method != http.MethodPost || (r.URL.Path != "/token" && r.URL.Path != "/par") {
+		return ""
+	}
+	return r.PostFormValue(key)
+}
+
+func (s *Authorization) allow(key string, limit model.RateLimit) (time.Duration, bool) {
+	retryAfter, ok := s.rateLimiter.Allow(key, limit)
+	if !ok {
+		slog.Info("rate limited", slog.String("key", key), slog.Duration("retry_after", retryAfter))
+	}
+	return retryAfter, ok
+}
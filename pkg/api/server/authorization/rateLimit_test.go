@@ -0,0 +1,58 @@
+package authorization
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method string
+		path   string
+		want   string
+	}{
+		"ok: token": {
+			method: http.MethodPost,
+			path:   "/token",
+			want:   "urn:openid:params:grant-type:ciba",
+		},
+		"ok: par": {
+			method: http.MethodPost,
+			path:   "/par",
+			want:   "urn:openid:params:grant-type:ciba",
+		},
+		"ng: other endpoint": {
+			method: http.MethodPost,
+			path:   "/introspect",
+		},
+		"ng: get": {
+			method: http.MethodGet,
+			path:   "/token",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body := "grant_type=urn%3Aopenid%3Aparams%3Agrant-type%3Aciba"
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if got := formValue(r, "grant_type"); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+			// the body is left to the handlers of the other endpoints.
+			if tt.want == "" {
+				if b, _ := io.ReadAll(r.Body); string(b) != body {
+					t.Errorf("body is read: %q", b)
+				}
+			}
+		})
+	}
+}
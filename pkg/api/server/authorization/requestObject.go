@@ -0,0 +1,114 @@
+package authorization
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/usecase/authorization"
+)
+
+// requestObject holds the authorization request parameters in a request object.
+// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-4
+type requestObject struct {
+	Scope                *string         `json:"scope"`
+	ResponseType         *string         `json:"response_type"`
+	ResponseMode         *string         `json:"response_mode"`
+	ClientID             *string         `json:"client_id"`
+	RedirectURI          *string         `json:"redirect_uri"`
+	State                *string         `json:"state"`
+	ACRValues            *string         `json:"acr_values"`
+	MaxAge               *int64          `json:"max_age"`
+	Resources            stringOrArray   `json:"resource"`
+	AuthorizationDetails json.RawMessage `json:"authorization_details"`
+}
+
+// apply overwrites the parameters of req, as the parameters in the request object take precedence.
+func (o *requestObject) apply(req *AuthorizationRequest) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&req.Scope, o.Scope)
+	set(&req.ResponseType, o.ResponseType)
+	set(&req.ResponseMode, o.ResponseMode)
+	set(&req.ClientID, o.ClientID)
+	set(&req.RedirectURI, o.RedirectURI)
+	set(&req.State, o.State)
+	set(&req.ACRValues, o.ACRValues)
+	if o.MaxAge != nil {
+		req.MaxAge = strconv.FormatInt(*o.MaxAge, 10)
+	}
+	if o.Resources != nil {
+		req.Resources = o.Resources
+	}
+	if len(o.AuthorizationDetails) > 0 {
+		req.AuthorizationDetails = string(o.AuthorizationDetails)
+	}
+}
+
+// stringOrArray is a claim which is either a single string or an array of strings.
+type stringOrArray []string
+
+func (a *stringOrArray) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = stringOrArray{single}
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(b, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+// resolveRequestObject verifies the request object passed by value or by reference,
+// and overwrites the parameters of req with its claims.
+// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-5
+func (s *Authorization) resolveRequestObject(r *http.Request, req *AuthorizationRequest) error {
+	if req.Request != "" && req.RequestURI != "" {
+		return fmt.Errorf("%w: request and request_uri must not be used together", authorization.ErrInvalidRequest)
+	}
+
+	clientID := req.ClientID
+	if clientID == "" {
+		clientID, _ = parseClientCredentials(r)
+	}
+
+	jwt := req.Request
+	// request_uri of pushed authorization requests refers to the stored request, not to a request object.
+	if req.RequestURI != "" && !strings.HasPrefix(req.RequestURI, model.RequestURIPrefix) {
+		var err error
+		jwt, err = s.authUC.FetchRequestObject(r.Context(), clientID, req.RequestURI)
+		if err != nil {
+			return err
+		}
+		req.RequestURI = ""
+	}
+	if jwt == "" {
+		return nil
+	}
+
+	payload, err := s.authUC.VerifyRequestObject(r.Context(), clientID, jwt)
+	if err != nil {
+		return err
+	}
+	claims := &requestObject{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return fmt.Errorf("%w: %w", authorization.ErrInvalidRequestObject, err)
+	}
+	claims.apply(req)
+	if req.ClientID == "" {
+		req.ClientID = clientID
+	}
+	req.Request = ""
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package authorization
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+//go:embed templates/form_post.html.tmpl
+var formPostTemplate string
+
+var formPostTmpl = template.Must(template.New("form_post").Parse(formPostTemplate))
+
+type formPostPage struct {
+	RedirectURI string
+	Params      url.Values
+}
+
+// respond returns the parameters of the authorization response to the redirect_uri of the client
+// in the response mode requested by the client.
+// ref: https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+func (s *Authorization) respond(w http.ResponseWriter, r *http.Request, redirectURI, clientID string, requested model.ResponseMode, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil || redirectURI == "" {
+		ErrorPage(w, r, &ErrorResponse{
+			Error:       ErrorType(params.Get("error")),
+			Description: params.Get("error_description"),
+		})
+		return
+	}
+
+	mode := s.authUC.ResponseMode(r.Context(), clientID, requested)
+	if mode.IsJWT() {
+		// ref: https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+		claims := map[string]string{}
+		for k := range params {
+			claims[k] = params.Get(k)
+		}
+		jwt, err := s.authUC.SignAuthorizationResponse(r.Context(), clientID, claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		params = url.Values{"response": {jwt}}
+	} else {
+		// the issuer is the iss claim of the JWT in JARM modes, ref: https://datatracker.ietf.org/doc/html/rfc9207#section-2.4
+		params.Set("iss", s.authUC.Issuer)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	switch mode.Base() {
+	case model.ResponseModeFragment:
+		u.Fragment = ""
+		http.Redirect(w, r, u.String()+"#"+params.Encode(), http.StatusFound)
+	case model.ResponseModeFormPost:
+		// ref: https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html#FormPostResponseMode
+		err := formPostTmpl.Execute(w, &formPostPage{RedirectURI: u.String(), Params: params})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		// the query component of the redirect_uri must be retained, ref: https://datatracker.ietf.org/doc/html/rfc6749#section-3.1.2
+		q := u.Query()
+		for k, v := range params {
+			q[k] = v
+		}
+		u.RawQuery = q.Encode()
+		http.Redirect(w, r, u.String(), http.StatusFound)
+	}
+}
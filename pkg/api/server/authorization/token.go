@@ -4,72 +4,142 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/task4233/oauth/pkg/domain/model"
 )
 
-// ref: http://openid-foundation-japan.github.io/rfc6749.ja.html#token-req
+// ref:
+// - http://openid-foundation-japan.github.io/rfc6749.ja.html#token-req
+// - https://datatracker.ietf.org/doc/html/rfc6749#section-6
 type AccessTokenRequest struct {
-	GrantType    string // required
-	Code         string // required
-	RedirectURI  string // required
+	GrantType    string   // required
+	Code         string   // required for authorization_code
+	RedirectURI  string   // required for authorization_code
+	RefreshToken string   // required for refresh_token
+	Scope        string   // optional
+	Resources    []string // optional, ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2.2
+	// optional, JSON array, ref: https://datatracker.ietf.org/doc/html/rfc9396#section-6
+	AuthorizationDetails string
+	// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+	SubjectToken       string   // required for token-exchange
+	SubjectTokenType   string   // required for token-exchange
+	ActorToken         string   // optional
+	ActorTokenType     string   // required if actor_token is present
+	RequestedTokenType string   // optional
+	Audiences          []string // optional
+	Assertion          string   // required for jwt-bearer, ref: https://datatracker.ietf.org/doc/html/rfc7523#section-2.1
+	// required for ciba, ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1
+	AuthReqID    string
 	ClientID     string // required
 	ClientSecret string // optional
 }
 
 func (r *AccessTokenRequest) Validate() error {
-	if r.GrantType != "authorization_code" {
-		return fmt.Errorf("grant_type must be authorization_code")
-	}
-	if r.Code == "" {
-		return fmt.Errorf("code is required")
-	}
-	if r.RedirectURI == "" {
-		return fmt.Errorf("redirect_uri is required")
+	switch model.GrantType(r.GrantType) {
+	case model.GrantTypeAuthorizationCode:
+		if r.Code == "" {
+			return fmt.Errorf("code is required")
+		}
+		if r.RedirectURI == "" {
+			return fmt.Errorf("redirect_uri is required")
+		}
+	case model.GrantTypeRefreshToken:
+		if r.RefreshToken == "" {
+			return fmt.Errorf("refresh_token is required")
+		}
+	case model.GrantTypeTokenExchange:
+		if r.SubjectToken == "" {
+			return fmt.Errorf("subject_token is required")
+		}
+		if r.SubjectTokenType == "" {
+			return fmt.Errorf("subject_token_type is required")
+		}
+		if r.ActorToken != "" && r.ActorTokenType == "" {
+			return fmt.Errorf("actor_token_type is required")
+		}
+		if r.ActorToken == "" && r.ActorTokenType != "" {
+			return fmt.Errorf("actor_token_type must not be present without actor_token")
+		}
+	case model.GrantTypeJWTBearer:
+		if r.Assertion == "" {
+			return fmt.Errorf("assertion is required")
+		}
+	case model.GrantTypeCIBA:
+		if r.AuthReqID == "" {
+			return fmt.Errorf("auth_req_id is required")
+		}
+	case "":
+		return fmt.Errorf("grant_type is required")
 	}
 	if r.ClientID == "" {
 		return fmt.Errorf("client_id is required")
 	}
+	if _, err := parseAuthorizationDetails(r.AuthorizationDetails); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (r *AccessTokenRequest) ToModel() *model.TokenRequest {
+	details, _ := parseAuthorizationDetails(r.AuthorizationDetails)
 	return &model.TokenRequest{
+		GrantType:    model.GrantType(r.GrantType),
 		Code:         r.Code,
 		RedirectURI:  r.RedirectURI,
+		RefreshToken: r.RefreshToken,
+		Scope:        r.Scope,
+		Resources:    r.Resources,
 		ClientID:     r.ClientID,
 		ClientSecret: r.ClientSecret,
+
+		AuthorizationDetails: details,
+
+		SubjectToken:       r.SubjectToken,
+		SubjectTokenType:   model.TokenTypeURI(r.SubjectTokenType),
+		ActorToken:         r.ActorToken,
+		ActorTokenType:     model.TokenTypeURI(r.ActorTokenType),
+		RequestedTokenType: model.TokenTypeURI(r.RequestedTokenType),
+		Audiences:          r.Audiences,
+
+		Assertion: r.Assertion,
+		AuthReqID: r.AuthReqID,
 	}
 }
 
 // ref: http://openid-foundation-japan.github.io/rfc6749.ja.html#token-response
 type AccessTokenResponse struct {
-	AccessToken  string `json:"access_token"`  // required
-	TokenType    string `json:"token_type"`    // required
-	ExpiresIn    int64  `json:"expires_in"`    // recommended
-	RefreshToken string `json:"refresh_token"` // optional
-	Scope        string `json:"scope"`         // optional
+	AccessToken  string `json:"access_token"`            // required
+	TokenType    string `json:"token_type"`              // required
+	ExpiresIn    int64  `json:"expires_in"`              // recommended
+	RefreshToken string `json:"refresh_token,omitempty"` // optional
+	Scope        string `json:"scope"`                   // optional
+	// optional, ref: https://datatracker.ietf.org/doc/html/rfc9396#section-7
+	AuthorizationDetails []model.AuthorizationDetail `json:"authorization_details,omitempty"`
+	// required for token-exchange, ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2.2.1
+	IssuedTokenType model.TokenTypeURI `json:"issued_token_type,omitempty"`
+	// ref: https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
+	IDToken string `json:"id_token,omitempty"`
 }
 
 func (s *Authorization) Token(w http.ResponseWriter, r *http.Request) {
 	req := s.ParseAccessTokenRequest(r)
 	err := req.Validate()
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
-			Error:       InvalidRequest,
+		TokenError(w, r, &ErrorResponse{
+			Error:       validationErrorType(err),
 			Description: err.Error(),
-			ErrorURI:    req.RedirectURI,
 		})
 		return
 	}
 
 	accessToken, err := s.authUC.Token(r.Context(), req.ToModel())
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
-			Error:       ServerError,
+		TokenError(w, r, &ErrorResponse{
+			Error:       errorType(err),
 			Description: err.Error(),
-			ErrorURI:    req.RedirectURI,
+			RetryAfter:  retryAfter(err),
 		})
 		return
 	}
@@ -81,18 +151,22 @@ func (s *Authorization) TokenResponse(w http.ResponseWriter, r *http.Request, re
 	res := &AccessTokenResponse{
 		AccessToken:  accessToken.AccessToken,
 		TokenType:    accessToken.TokenType,
-		ExpiresIn:    accessToken.ExpiresIn,
+		ExpiresIn:    accessToken.ExpiresIn(),
 		RefreshToken: accessToken.RefreshToken,
 		Scope:        accessToken.Scope,
+
+		AuthorizationDetails: accessToken.AuthorizationDetails,
+		IssuedTokenType:      accessToken.IssuedTokenType,
+		IDToken:              accessToken.IDToken,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
 	err := json.NewEncoder(w).Encode(res)
 	if err != nil {
-		RequestError(w, r, &ErrorResponse{
+		TokenError(w, r, &ErrorResponse{
 			Error:       ServerError,
 			Description: err.Error(),
-			ErrorURI:    req.RedirectURI,
 		})
 		return
 	}
@@ -103,16 +177,38 @@ func (s *Authorization) ParseAccessTokenRequest(r *http.Request) *AccessTokenReq
 	req.GrantType = r.FormValue("grant_type")
 	req.Code = r.FormValue("code")
 	req.RedirectURI = r.FormValue("redirect_uri")
+	req.RefreshToken = r.FormValue("refresh_token")
+	req.Scope = r.FormValue("scope")
+	req.Resources = r.PostForm["resource"]
+	req.AuthorizationDetails = r.FormValue("authorization_details")
+	req.SubjectToken = r.FormValue("subject_token")
+	req.SubjectTokenType = r.FormValue("subject_token_type")
+	req.ActorToken = r.FormValue("actor_token")
+	req.ActorTokenType = r.FormValue("actor_token_type")
+	req.RequestedTokenType = r.FormValue("requested_token_type")
+	req.Audiences = r.PostForm["audience"]
+	req.Assertion = r.FormValue("assertion")
+	req.AuthReqID = r.FormValue("auth_req_id")
+
+	req.ClientID, req.ClientSecret = parseClientCredentials(r)
 
+	return req
+}
+
+// parseClientCredentials returns the credentials of HTTP Basic authentication, which are form-urlencoded
+// before they are encoded in base64, or the credentials of the request body.
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+func parseClientCredentials(r *http.Request) (string, string) {
 	clientID, clientSecret, ok := r.BasicAuth()
 	if ok {
-		req.ClientID = clientID
-		req.ClientSecret = clientSecret
-	} else {
-		// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
-		req.ClientID = r.FormValue("client_id")
-		req.ClientSecret = r.FormValue("client_secret")
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return "", ""
+		}
+		if clientSecret, err = url.QueryUnescape(clientSecret); err != nil {
+			return "", ""
+		}
+		return clientID, clientSecret
 	}
-
-	return req
+	return r.PostFormValue("client_id"), r.PostFormValue("client_secret")
 }
@@ -0,0 +1,64 @@
+package authorization
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	type wants struct {
+		clientID     string
+		clientSecret string
+	}
+
+	tests := map[string]struct {
+		setup func(r *http.Request)
+		body  url.Values
+		wants wants
+	}{
+		"ok: basic": {
+			setup: func(r *http.Request) {
+				r.SetBasicAuth("dummy-client-id", "dummy-client-secret")
+			},
+			wants: wants{clientID: "dummy-client-id", clientSecret: "dummy-client-secret"},
+		},
+		"ok: basic with reserved characters": {
+			setup: func(r *http.Request) {
+				r.SetBasicAuth(url.QueryEscape("client:id"), url.QueryEscape("s3cr:t/+%&= ?"))
+			},
+			wants: wants{clientID: "client:id", clientSecret: "s3cr:t/+%&= ?"},
+		},
+		"ok: body": {
+			body:  url.Values{"client_id": {"client:id"}, "client_secret": {"s3cr:t/+%&= ?"}},
+			wants: wants{clientID: "client:id", clientSecret: "s3cr:t/+%&= ?"},
+		},
+		"ng: basic with invalid escape": {
+			setup: func(r *http.Request) {
+				r.SetBasicAuth("dummy-client-id", "100%")
+			},
+			wants: wants{},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(tt.body.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+
+			clientID, clientSecret := parseClientCredentials(r)
+			if clientID != tt.wants.clientID || clientSecret != tt.wants.clientSecret {
+				t.Errorf("want %q:%q, got %q:%q", tt.wants.clientID, tt.wants.clientSecret, clientID, clientSecret)
+			}
+		})
+	}
+}
@@ -2,22 +2,35 @@ package resource
 
 import (
 	"encoding/json"
-	"fmt"
 	"log/slog"
 	"net/http"
+
+	"github.com/task4233/oauth/pkg/api/tokenauth"
 )
 
-type Resource struct{}
+type Resource struct {
+	validator *tokenauth.Validator
+	uri       string
+
+	// RequiredScopes are the scopes which the access tokens must have, profile by default.
+	RequiredScopes []string
+}
 
-func NewResource() *Resource {
-	return &Resource{}
+// NewResource creates the resource server identified by uri, the resource indicator registered at the authorization server.
+func NewResource(validator *tokenauth.Validator, uri string) *Resource {
+	return &Resource{
+		validator: validator,
+		uri:       uri,
+
+		RequiredScopes: []string{"profile"},
+	}
 }
 
-func (s *Resource) Run(port int) error {
+func (s *Resource) Run(addr string) error {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/resource", AuthAdapter(s.Resource))
+	mux.Handle("/resource", s.validator.Middleware(http.HandlerFunc(s.Resource), tokenauth.RequireScopes(s.RequiredScopes...), tokenauth.RequireAudience(s.uri)))
 
-	return http.ListenAndServe(fmt.Sprintf(":%d", port), mux)
+	return http.ListenAndServe(addr, mux)
 }
 
 func (s *Resource) Resource(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +49,8 @@ func (s *Resource) Resource(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Resource) ResourceGET(w http.ResponseWriter, r *http.Request) {
-	slog.Info("resource get")
+	sub, _ := tokenauth.SubjectFromContext(r.Context())
+	slog.Info("resource get", slog.String("sub", sub))
 	json.NewEncoder(w).Encode(map[string]string{"message": "Hello, World!"})
 }
 
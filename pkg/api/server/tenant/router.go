@@ -0,0 +1,108 @@
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var ErrTenantExists = errors.New("tenant already exists")
+
+// wellKnownPrefix is where the metadata of the issuers with paths are published.
+// ref: https://datatracker.ietf.org/doc/html/rfc8414#section-3.1
+const wellKnownPrefix = "/.well-known/"
+
+// Router serves the servers of the tenants on one port. A request is routed to the tenant of its host, or of the
+// path prefix /t/{tenant} which is removed before the server of the tenant handles it. The other requests are
+// handled by the default server, so the tenants never see the requests of each other.
+type Router struct {
+	defaultHandler http.Handler
+	byHost         map[string]http.Handler
+	byID           map[string]http.Handler
+}
+
+func NewRouter(defaultHandler http.Handler) *Router {
+	return &Router{
+		defaultHandler: defaultHandler,
+		byHost:         make(map[string]http.Handler),
+		byID:           make(map[string]http.Handler),
+	}
+}
+
+// Add serves the tenant with handler, which serves the endpoints at its root.
+func (rt *Router) Add(tenant *model.Tenant, handler http.Handler) error {
+	if err := tenant.Validate(); err != nil {
+		return err
+	}
+	if tenant.Host != "" {
+		if _, ok := rt.byHost[tenant.Host]; ok {
+			return fmt.Errorf("%w: %s", ErrTenantExists, tenant.Host)
+		}
+		rt.byHost[tenant.Host] = handler
+		return nil
+	}
+	if _, ok := rt.byID[tenant.ID]; ok {
+		return fmt.Errorf("%w: %s", ErrTenantExists, tenant.ID)
+	}
+	rt.byID[tenant.ID] = handler
+	return nil
+}
+
+func (rt *Router) Run(addr string) error {
+	return http.ListenAndServe(addr, rt)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if handler, ok := rt.byHost[host]; ok {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	path := r.URL.Path
+	if rest, ok := strings.CutPrefix(path, model.TenantPathPrefix); ok {
+		id, sub, _ := strings.Cut(rest, "/")
+		handler, ok := rt.byID[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler.ServeHTTP(w, withPath(r, "/"+sub))
+		return
+	}
+
+	// the metadata of the issuer https://example.com/t/{tenant} is at https://example.com/.well-known/{name}/t/{tenant}.
+	// ref: https://datatracker.ietf.org/doc/html/rfc8414#section-3.1
+	if rest, ok := strings.CutPrefix(path, wellKnownPrefix); ok {
+		if name, issuerPath, ok := strings.Cut(rest, model.TenantPathPrefix); ok && !strings.Contains(issuerPath, "/") {
+			handler, ok := rt.byID[issuerPath]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			handler.ServeHTTP(w, withPath(r, wellKnownPrefix+name))
+			return
+		}
+	}
+
+	rt.defaultHandler.ServeHTTP(w, r)
+}
+
+// withPath returns r whose path is replaced as http.StripPrefix does.
+func withPath(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = path
+	r2.URL.RawPath = ""
+	return r2
+}
@@ -0,0 +1,103 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// echo responds with the name of the server and the path which it sees.
+func echo(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + r.URL.Path))
+	})
+}
+
+func TestRouter(t *testing.T) {
+	t.Parallel()
+
+	rt := NewRouter(echo("default"))
+	for _, tenant := range []*model.Tenant{{ID: "brand-a"}, {ID: "brand-b", Host: "brand-b.example.com"}} {
+		if err := rt.Add(tenant, echo(tenant.ID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]struct {
+		host       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		"ok: default": {
+			host:       "localhost:9001",
+			path:       "/token",
+			wantStatus: http.StatusOK,
+			wantBody:   "default /token",
+		},
+		"ok: tenant of the path": {
+			host:       "localhost:9001",
+			path:       "/t/brand-a/introspect",
+			wantStatus: http.StatusOK,
+			wantBody:   "brand-a /introspect",
+		},
+		"ok: metadata of the tenant of the path": {
+			host:       "localhost:9001",
+			path:       "/.well-known/oauth-authorization-server/t/brand-a",
+			wantStatus: http.StatusOK,
+			wantBody:   "brand-a /.well-known/oauth-authorization-server",
+		},
+		"ok: tenant of the host": {
+			host:       "brand-b.example.com:9001",
+			path:       "/t/brand-a/introspect",
+			wantStatus: http.StatusOK,
+			wantBody:   "brand-b /t/brand-a/introspect",
+		},
+		"ng: unknown tenant": {
+			host:       "localhost:9001",
+			path:       "/t/brand-c/introspect",
+			wantStatus: http.StatusNotFound,
+		},
+		"ng: tenant of the host is not served at the path": {
+			host:       "localhost:9001",
+			path:       "/t/brand-b/introspect",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Host = tt.host
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("unexpected status: want %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("unexpected body: want %q, got %q", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRouterAdd(t *testing.T) {
+	t.Parallel()
+
+	rt := NewRouter(echo("default"))
+	if err := rt.Add(&model.Tenant{ID: "brand-a"}, echo("brand-a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Add(&model.Tenant{ID: "brand-a"}, echo("brand-a")); !errors.Is(err, ErrTenantExists) {
+		t.Errorf("unexpected error: want %v, got %v", ErrTenantExists, err)
+	}
+	if err := rt.Add(&model.Tenant{ID: "../admin"}, echo("admin")); !errors.Is(err, model.ErrTenantInvalid) {
+		t.Errorf("unexpected error: want %v, got %v", model.ErrTenantInvalid, err)
+	}
+}
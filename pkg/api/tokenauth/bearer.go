@@ -0,0 +1,117 @@
+package tokenauth
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const accessTokenParam = "access_token"
+
+// extractToken finds the bearer token in the request.
+// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-2
+func (v *Validator) extractToken(r *http.Request) (string, *Error) {
+	var tokens []string
+
+	if header := r.Header.Values("Authorization"); len(header) > 0 {
+		token, ok, err := parseAuthorizationHeader(header)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			tokens = append(tokens, token)
+		}
+	}
+
+	token, ok, err := formToken(r)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		tokens = append(tokens, token)
+	}
+
+	if values, ok := r.URL.Query()[accessTokenParam]; ok {
+		if !v.config.AllowQueryToken {
+			return "", &Error{Code: InvalidRequest, Description: "access token in the query is not supported"}
+		}
+		if len(values) != 1 {
+			return "", &Error{Code: InvalidRequest, Description: "multiple access tokens are given"}
+		}
+		tokens = append(tokens, values[0])
+	}
+
+	switch len(tokens) {
+	case 0:
+		return "", &Error{}
+	case 1:
+		if tokens[0] == "" {
+			return "", &Error{Code: InvalidRequest, Description: "access token is empty"}
+		}
+		return tokens[0], nil
+	default:
+		// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-2
+		return "", &Error{Code: InvalidRequest, Description: "more than one method is used to transmit the access token"}
+	}
+}
+
+// parseAuthorizationHeader returns ok=false if the header uses another authentication scheme.
+// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-2.1
+func parseAuthorizationHeader(header []string) (string, bool, *Error) {
+	if len(header) != 1 {
+		return "", false, &Error{Code: InvalidRequest, Description: "multiple authorization headers are given"}
+	}
+
+	scheme, credentials, _ := strings.Cut(strings.TrimSpace(header[0]), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", false, nil
+	}
+
+	token := strings.TrimLeft(credentials, " ")
+	if !isToken68(token) {
+		return "", false, &Error{Code: InvalidRequest, Description: "bearer credentials are malformed"}
+	}
+	return token, true, nil
+}
+
+// isToken68 reports whether s matches b64token = 1*( ALPHA / DIGIT / "-" / "." / "_" / "~" / "+" / "/" ) *"="
+func isToken68(s string) bool {
+	body := strings.TrimRight(s, "=")
+	if body == "" {
+		return false
+	}
+	for _, r := range body {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case strings.ContainsRune("-._~+/", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// formToken reads the token from a form-encoded body. The body is parsed only
+// when all the conditions of the spec are met, so other bodies are left untouched.
+// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-2.2
+func formToken(r *http.Request) (string, bool, *Error) {
+	if r.Method == http.MethodGet || r.Body == nil {
+		return "", false, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return "", false, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", false, &Error{Code: InvalidRequest, Description: "request body is malformed"}
+	}
+
+	values, ok := r.PostForm[accessTokenParam]
+	if !ok {
+		return "", false, nil
+	}
+	if len(values) != 1 {
+		return "", false, &Error{Code: InvalidRequest, Description: "multiple access tokens are given"}
+	}
+	return values[0], true, nil
+}
@@ -0,0 +1,202 @@
+package tokenauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidatorExtractToken(t *testing.T) {
+	t.Parallel()
+
+	const form = "application/x-www-form-urlencoded"
+
+	type wants struct {
+		token string
+		code  ErrorCode
+		err   bool
+	}
+
+	tests := map[string]struct {
+		method      string
+		target      string
+		header      []string
+		contentType string
+		body        string
+		allowQuery  bool
+		wants       wants
+	}{
+		"ok: header": {
+			header: []string{"Bearer mF_9.B5f-4.1JqM"},
+			wants:  wants{token: "mF_9.B5f-4.1JqM"},
+		},
+		"ok: scheme is case-insensitive": {
+			header: []string{"bEaReR token=="},
+			wants:  wants{token: "token=="},
+		},
+		"ok: form body": {
+			method:      http.MethodPost,
+			contentType: form,
+			body:        "access_token=token&other=value",
+			wants:       wants{token: "token"},
+		},
+		"ok: query": {
+			target:     "/resource?access_token=token",
+			allowQuery: true,
+			wants:      wants{token: "token"},
+		},
+		"ng: no credentials": {
+			wants: wants{err: true},
+		},
+		"ng: another scheme": {
+			header: []string{"Basic dXNlcjpwYXNz"},
+			wants:  wants{err: true},
+		},
+		"ng: malformed credentials": {
+			header: []string{"Bearer to ken"},
+			wants:  wants{err: true, code: InvalidRequest},
+		},
+		"ng: empty credentials": {
+			header: []string{"Bearer "},
+			wants:  wants{err: true, code: InvalidRequest},
+		},
+		"ng: form body in GET": {
+			method:      http.MethodGet,
+			contentType: form,
+			body:        "access_token=token",
+			wants:       wants{err: true},
+		},
+		"ng: form body with another content type": {
+			method:      http.MethodPost,
+			contentType: "application/json",
+			body:        `{"access_token":"token"}`,
+			wants:       wants{err: true},
+		},
+		"ng: query is not allowed": {
+			target: "/resource?access_token=token",
+			wants:  wants{err: true, code: InvalidRequest},
+		},
+		"ng: multiple methods": {
+			method:      http.MethodPost,
+			header:      []string{"Bearer token"},
+			contentType: form,
+			body:        "access_token=token",
+			wants:       wants{err: true, code: InvalidRequest},
+		},
+		"ng: multiple headers": {
+			header: []string{"Bearer token", "Bearer token"},
+			wants:  wants{err: true, code: InvalidRequest},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			target := tt.target
+			if target == "" {
+				target = "/resource"
+			}
+
+			req := httptest.NewRequest(method, target, strings.NewReader(tt.body))
+			for _, h := range tt.header {
+				req.Header.Add("Authorization", h)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			v := &Validator{config: Config{AllowQueryToken: tt.allowQuery}}
+			token, err := v.extractToken(req)
+			if (err != nil) != tt.wants.err {
+				t.Fatalf("want error %v, got %v", tt.wants.err, err)
+			}
+			if err != nil && err.Code != tt.wants.code {
+				t.Errorf("want code %q, got %q", tt.wants.code, err.Code)
+			}
+			if token != tt.wants.token {
+				t.Errorf("want token %q, got %q", tt.wants.token, token)
+			}
+		})
+	}
+}
+
+func TestValidatorMiddlewareChallenge(t *testing.T) {
+	t.Parallel()
+
+	srv := newIntrospectionServer(t, map[string]*Claims{
+		"active": {Active: true, Scope: "openid", Exp: time.Now().Add(time.Hour).Unix()},
+	})
+	v, err := NewValidator(Config{
+		Realm:                 "example",
+		IntrospectionEndpoint: srv.URL,
+		ClientID:              "resource-id",
+		ClientSecret:          "resource-secret",
+		HTTPClient:            srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	type wants struct {
+		status    int
+		challenge string
+	}
+
+	tests := map[string]struct {
+		header string
+		wants  wants
+	}{
+		"ok": {
+			header: "Bearer active",
+			wants:  wants{status: http.StatusOK},
+		},
+		"ng: no credentials": {
+			wants: wants{
+				status:    http.StatusUnauthorized,
+				challenge: `Bearer realm="example"`,
+			},
+		},
+		"ng: invalid request": {
+			header: "Bearer a b",
+			wants: wants{
+				status:    http.StatusBadRequest,
+				challenge: `Bearer realm="example", error="invalid_request", error_description="bearer credentials are malformed"`,
+			},
+		},
+		"ng: invalid token": {
+			header: "Bearer unknown",
+			wants: wants{
+				status:    http.StatusUnauthorized,
+				challenge: `Bearer realm="example", error="invalid_token", error_description="access token is inactive"`,
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wants.status {
+				t.Errorf("want status %d, got %d", tt.wants.status, rec.Code)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wants.challenge {
+				t.Errorf("want %s, got %s", tt.wants.challenge, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package tokenauth
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheConfig configures how introspection results are cached by the resource server.
+type CacheConfig struct {
+	// Size is the maximum number of cached tokens. Zero or less disables caching.
+	Size int
+	// MaxStaleness is how long an active result may be served without asking the authorization server again.
+	// The entry never outlives the token's exp.
+	MaxStaleness time.Duration
+	// NegativeTTL is how long an inactive result is cached.
+	NegativeTTL time.Duration
+}
+
+var DefaultCacheConfig = CacheConfig{
+	Size:         1024,
+	MaxStaleness: 30 * time.Second,
+	NegativeTTL:  5 * time.Second,
+}
+
+type introspectFunc func(ctx context.Context, token string) (*Claims, error)
+
+type cacheEntry struct {
+	key       string
+	result    *Claims
+	expiresAt time.Time
+}
+
+// introspectionCache is an LRU cache of introspection results keyed by the token hash.
+// Concurrent lookups of the same token are de-duplicated.
+type introspectionCache struct {
+	config CacheConfig
+	now    func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+
+	group singleflight.Group
+}
+
+func newIntrospectionCache(config CacheConfig) *introspectionCache {
+	return &introspectionCache{
+		config: config,
+		now:    time.Now,
+		ll:     list.New(),
+		items:  make(map[string]*list.Element),
+	}
+}
+
+func (c *introspectionCache) Lookup(ctx context.Context, token string, introspect introspectFunc) (*Claims, error) {
+	key := tokenHash(token)
+	if res, ok := c.get(key); ok {
+		return res, nil
+	}
+
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		if res, ok := c.get(key); ok {
+			return res, nil
+		}
+
+		res, err := introspect(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		c.add(key, res)
+		return res, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*Claims), nil
+}
+
+func (c *introspectionCache) get(key string) (*Claims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.ll.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *introspectionCache) add(key string, res *Claims) {
+	if c.config.Size <= 0 {
+		return
+	}
+
+	expiresAt := c.expiresAt(res)
+	if !c.now().Before(expiresAt) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = &cacheEntry{key: key, result: res, expiresAt: expiresAt}
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, result: res, expiresAt: expiresAt})
+	for c.ll.Len() > c.config.Size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *introspectionCache) expiresAt(res *Claims) time.Time {
+	now := c.now()
+	if !res.Active {
+		return now.Add(c.config.NegativeTTL)
+	}
+
+	expiresAt := now.Add(c.config.MaxStaleness)
+	if res.Exp > 0 {
+		exp := time.Unix(res.Exp, 0)
+		if exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+	}
+	return expiresAt
+}
+
+func (c *introspectionCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
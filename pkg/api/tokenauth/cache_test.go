@@ -0,0 +1,172 @@
+package tokenauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIntrospectionCacheLookup(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	config := CacheConfig{Size: 2, MaxStaleness: time.Minute, NegativeTTL: 5 * time.Second}
+
+	type wants struct {
+		calls  int32
+		active bool
+	}
+
+	tests := map[string]struct {
+		res     *Claims
+		elapsed time.Duration
+		wants   wants
+	}{
+		"ok: active result is cached": {
+			res:     &Claims{Active: true, Exp: now.Add(time.Hour).Unix()},
+			elapsed: 30 * time.Second,
+			wants:   wants{calls: 1, active: true},
+		},
+		"ok: active result expires after max staleness": {
+			res:     &Claims{Active: true, Exp: now.Add(time.Hour).Unix()},
+			elapsed: 2 * time.Minute,
+			wants:   wants{calls: 2, active: true},
+		},
+		"ok: active result is capped at exp": {
+			res:     &Claims{Active: true, Exp: now.Add(10 * time.Second).Unix()},
+			elapsed: 20 * time.Second,
+			wants:   wants{calls: 2, active: true},
+		},
+		"ok: inactive result is cached": {
+			res:     &Claims{Active: false},
+			elapsed: time.Second,
+			wants:   wants{calls: 1, active: false},
+		},
+		"ok: inactive result expires after negative ttl": {
+			res:     &Claims{Active: false},
+			elapsed: 10 * time.Second,
+			wants:   wants{calls: 2, active: false},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			current := now
+			cache := newIntrospectionCache(config)
+			cache.now = func() time.Time { return current }
+
+			var calls atomic.Int32
+			introspect := func(context.Context, string) (*Claims, error) {
+				calls.Add(1)
+				return tt.res, nil
+			}
+
+			_, err := cache.Lookup(context.Background(), "token", introspect)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			current = current.Add(tt.elapsed)
+			got, err := cache.Lookup(context.Background(), "token", introspect)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if calls.Load() != tt.wants.calls {
+				t.Errorf("want %d calls, got %d", tt.wants.calls, calls.Load())
+			}
+			if got.Active != tt.wants.active {
+				t.Errorf("want active %v, got %v", tt.wants.active, got.Active)
+			}
+		})
+	}
+}
+
+func TestIntrospectionCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	cache := newIntrospectionCache(CacheConfig{Size: 2, MaxStaleness: time.Minute})
+
+	calls := map[string]int{}
+	introspect := func(_ context.Context, token string) (*Claims, error) {
+		calls[token]++
+		return &Claims{Active: true}, nil
+	}
+
+	for _, token := range []string{"a", "b", "a", "c", "a", "b"} {
+		if _, err := cache.Lookup(context.Background(), token, introspect); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// "b" is the least recently used token when "c" is added.
+	want := map[string]int{"a": 1, "b": 2, "c": 1}
+	for token, n := range want {
+		if calls[token] != n {
+			t.Errorf("token %q: want %d calls, got %d", token, n, calls[token])
+		}
+	}
+}
+
+func TestIntrospectionCacheErrorIsNotCached(t *testing.T) {
+	t.Parallel()
+
+	cache := newIntrospectionCache(DefaultCacheConfig)
+
+	var calls int
+	introspect := func(context.Context, string) (*Claims, error) {
+		calls++
+		return nil, errors.New("unavailable")
+	}
+
+	for range 2 {
+		if _, err := cache.Lookup(context.Background(), "token", introspect); err == nil {
+			t.Fatal("want error, got nil")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("want 2 calls, got %d", calls)
+	}
+}
+
+func TestIntrospectionCacheSingleflight(t *testing.T) {
+	t.Parallel()
+
+	cache := newIntrospectionCache(DefaultCacheConfig)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	introspect := func(context.Context, string) (*Claims, error) {
+		calls.Add(1)
+		<-release
+		return &Claims{Active: true}, nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	var started sync.WaitGroup
+	wg.Add(n)
+	started.Add(n)
+	for range n {
+		go func() {
+			defer wg.Done()
+			started.Done()
+			if _, err := cache.Lookup(context.Background(), "token", introspect); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	started.Wait()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("want 1 call, got %d", calls.Load())
+	}
+}
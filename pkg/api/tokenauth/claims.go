@@ -0,0 +1,114 @@
+package tokenauth
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Claims is a verified introspection response.
+// ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type Claims struct {
+	Active    bool     `json:"active"`     // required
+	Scope     string   `json:"scope"`      // optional
+	ClientID  string   `json:"client_id"`  // optional
+	Username  string   `json:"username"`   // optional
+	TokenType string   `json:"token_type"` // optional
+	Exp       int64    `json:"exp"`        // optional, expiration time
+	Iat       int64    `json:"iat"`        // optional, issued at
+	Nbf       int64    `json:"nbf"`        // optional, not to be used before
+	Sub       string   `json:"sub"`        // optional, subject of the token
+	Aud       Audience `json:"aud"`        // optional, audience
+	Iss       string   `json:"iss"`        // optional, issuer
+	Jti       string   `json:"jti"`        // optional, JWT ID
+	AuthTime  int64    `json:"auth_time"`  // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6.2
+	ACR       string   `json:"acr"`        // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6.2
+	AMR       []string `json:"amr"`        // optional, ref: https://datatracker.ietf.org/doc/html/rfc8176#section-1
+	Act       *Actor   `json:"act"`        // optional, ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+
+	// validatedAt is when Validator verified the claims on its clock, against which the requirements are evaluated.
+	validatedAt time.Time
+}
+
+// Actor is the party acting on behalf of the subject. Act is the prior actor of the delegation chain.
+// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+type Actor struct {
+	Sub      string `json:"sub"`
+	ClientID string `json:"client_id"`
+	Act      *Actor `json:"act"`
+}
+
+// now returns when the claims are verified, which is the current time if they are not verified by Validator.
+func (c *Claims) now() time.Time {
+	if c.validatedAt.IsZero() {
+		return time.Now()
+	}
+	return c.validatedAt
+}
+
+// Scopes returns the space-delimited scope as a slice.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Audience is the aud member, which is either a single string or an array of strings.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = nil
+		if single != "" {
+			*a = Audience{single}
+		}
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(b, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+type claimsKey struct{}
+
+// NewContext returns a copy of ctx carrying the verified claims.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by Validator.Middleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// SubjectFromContext returns the subject of the verified token.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.Sub == "" {
+		return "", false
+	}
+	return claims.Sub, true
+}
+
+// ClientIDFromContext returns the client the verified token was issued to.
+func ClientIDFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.ClientID == "" {
+		return "", false
+	}
+	return claims.ClientID, true
+}
+
+// ScopesFromContext returns the scopes granted to the verified token.
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	return claims.Scopes(), true
+}
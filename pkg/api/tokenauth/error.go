@@ -0,0 +1,118 @@
+package tokenauth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorCode is an error code of the Bearer authentication scheme.
+// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-3.1
+type ErrorCode string
+
+const (
+	InvalidRequest    ErrorCode = "invalid_request"
+	InvalidToken      ErrorCode = "invalid_token"
+	InsufficientScope ErrorCode = "insufficient_scope"
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9470#section-3
+	InsufficientUserAuthentication ErrorCode = "insufficient_user_authentication"
+)
+
+// Error is returned when a request is not authorized.
+// An Error without Code means the request has no authentication information at all.
+type Error struct {
+	Code        ErrorCode
+	Description string
+	// Scope is the scope necessary to access the resource. It is sent as the scope attribute of the challenge.
+	Scope string
+	// ACRValues and MaxAge tell the client how to authenticate the user again. They are sent with
+	// insufficient_user_authentication.
+	// ref: https://datatracker.ietf.org/doc/html/rfc9470#section-3
+	ACRValues string
+	MaxAge    time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return ErrMissingToken.Error()
+	}
+	if e.Description == "" {
+		return string(e.Code)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func (e *Error) status() int {
+	switch e.Code {
+	case InvalidRequest:
+		return http.StatusBadRequest
+	case InsufficientScope:
+		return http.StatusForbidden
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// challenge builds the WWW-Authenticate header value.
+// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-3
+func (e *Error) challenge(realm string) string {
+	params := []string{}
+	if realm != "" {
+		params = append(params, authParam("realm", realm))
+	}
+	// the error attributes are omitted when the request lacks any authentication information.
+	if e.Code != "" {
+		params = append(params, authParam("error", string(e.Code)))
+		if e.Description != "" {
+			params = append(params, authParam("error_description", e.Description))
+		}
+		if e.Scope != "" {
+			params = append(params, authParam("scope", e.Scope))
+		}
+		if e.ACRValues != "" {
+			params = append(params, authParam("acr_values", e.ACRValues))
+		}
+		if e.MaxAge > 0 {
+			params = append(params, authParam("max_age", strconv.FormatInt(int64(e.MaxAge/time.Second), 10)))
+		}
+	}
+
+	if len(params) == 0 {
+		return "Bearer"
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// authParam drops the characters which are not allowed in the attribute values.
+// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-3
+func authParam(key, value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, value)
+	return fmt.Sprintf(`%s="%s"`, key, value)
+}
+
+type errorResponse struct {
+	Error       ErrorCode `json:"error,omitempty"`
+	Description string    `json:"error_description,omitempty"`
+	Scope       string    `json:"scope,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, realm string, e *Error) {
+	w.Header().Set("WWW-Authenticate", e.challenge(realm))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(e.status())
+	json.NewEncoder(w).Encode(&errorResponse{
+		Error:       e.Code,
+		Description: e.Description,
+		Scope:       e.Scope,
+	})
+}
@@ -0,0 +1,117 @@
+package tokenauth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Requirement is a per-route authorization rule evaluated against verified claims.
+// It returns an *Error when the token does not satisfy the rule.
+type Requirement func(*Claims) error
+
+// RequireScopes requires all of the scopes to be granted.
+func RequireScopes(scopes ...string) Requirement {
+	return func(c *Claims) error {
+		granted := c.Scopes()
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				return &Error{
+					Code:        InsufficientScope,
+					Description: fmt.Sprintf("scope %s is required", scope),
+					Scope:       strings.Join(scopes, " "),
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// RequireAnyScope requires at least one of the scopes to be granted.
+func RequireAnyScope(scopes ...string) Requirement {
+	return func(c *Claims) error {
+		granted := c.Scopes()
+		for _, scope := range scopes {
+			if slices.Contains(granted, scope) {
+				return nil
+			}
+		}
+		return &Error{
+			Code:        InsufficientScope,
+			Description: "one of the scopes is required",
+			Scope:       strings.Join(scopes, " "),
+		}
+	}
+}
+
+// RequireAudience requires the token to be issued for one of the audiences.
+func RequireAudience(audiences ...string) Requirement {
+	return func(c *Claims) error {
+		for _, aud := range audiences {
+			if slices.Contains(c.Aud, aud) {
+				return nil
+			}
+		}
+		return &Error{
+			Code:        InsufficientScope,
+			Description: "token is not issued for this resource",
+		}
+	}
+}
+
+// RequireClientIDs requires the token to be issued to one of the clients.
+func RequireClientIDs(clientIDs ...string) Requirement {
+	return func(c *Claims) error {
+		if slices.Contains(clientIDs, c.ClientID) {
+			return nil
+		}
+		return &Error{
+			Code:        InsufficientScope,
+			Description: "client is not allowed to access this resource",
+		}
+	}
+}
+
+// RequireClaims requires pred to hold for the claims. description is returned to the client when it does not.
+func RequireClaims(description string, pred func(*Claims) bool) Requirement {
+	return func(c *Claims) error {
+		if pred(c) {
+			return nil
+		}
+		return &Error{
+			Code:        InsufficientScope,
+			Description: description,
+		}
+	}
+}
+
+// RequireACR requires the user to be authenticated with one of the authentication context classes.
+// ref: https://datatracker.ietf.org/doc/html/rfc9470#section-3
+func RequireACR(values ...string) Requirement {
+	return func(c *Claims) error {
+		if slices.Contains(values, c.ACR) {
+			return nil
+		}
+		return &Error{
+			Code:        InsufficientUserAuthentication,
+			Description: "a different authentication level is required",
+			ACRValues:   strings.Join(values, " "),
+		}
+	}
+}
+
+// RequireMaxAge requires the user to be authenticated within maxAge before the token is verified by Validator.
+// ref: https://datatracker.ietf.org/doc/html/rfc9470#section-3
+func RequireMaxAge(maxAge time.Duration) Requirement {
+	return func(c *Claims) error {
+		if c.AuthTime > 0 && c.now().Sub(time.Unix(c.AuthTime, 0)) <= maxAge {
+			return nil
+		}
+		return &Error{
+			Code:        InsufficientUserAuthentication,
+			Description: "more recent authentication is required",
+			MaxAge:      maxAge,
+		}
+	}
+}
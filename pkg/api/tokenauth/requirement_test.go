@@ -0,0 +1,234 @@
+package tokenauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequirements(t *testing.T) {
+	t.Parallel()
+
+	claims := &Claims{
+		Active:   true,
+		Scope:    "openid profile",
+		ClientID: "client-1",
+		Aud:      Audience{"https://api.example.com"},
+		Sub:      "user-1",
+		ACR:      "basic",
+		AuthTime: time.Now().Add(-10 * time.Minute).Unix(),
+	}
+
+	tests := map[string]struct {
+		req       Requirement
+		wantCode  ErrorCode
+		wantScope string
+		wantErr   bool
+	}{
+		"ok: all scopes": {
+			req: RequireScopes("openid", "profile"),
+		},
+		"ng: all scopes": {
+			req:       RequireScopes("profile", "email"),
+			wantScope: "profile email",
+			wantErr:   true,
+		},
+		"ok: any scope": {
+			req: RequireAnyScope("email", "profile"),
+		},
+		"ng: any scope": {
+			req:       RequireAnyScope("email", "admin"),
+			wantScope: "email admin",
+			wantErr:   true,
+		},
+		"ok: audience": {
+			req: RequireAudience("https://other.example.com", "https://api.example.com"),
+		},
+		"ng: audience": {
+			req:     RequireAudience("https://other.example.com"),
+			wantErr: true,
+		},
+		"ok: client id": {
+			req: RequireClientIDs("client-1"),
+		},
+		"ng: client id": {
+			req:     RequireClientIDs("client-2"),
+			wantErr: true,
+		},
+		"ok: claims": {
+			req: RequireClaims("sub is required", func(c *Claims) bool { return c.Sub != "" }),
+		},
+		"ng: claims": {
+			req:     RequireClaims("admin only", func(c *Claims) bool { return c.Sub == "admin" }),
+			wantErr: true,
+		},
+		"ok: acr": {
+			req: RequireACR("basic", "mfa"),
+		},
+		"ng: acr": {
+			req:      RequireACR("mfa"),
+			wantCode: InsufficientUserAuthentication,
+			wantErr:  true,
+		},
+		"ok: max age": {
+			req: RequireMaxAge(time.Hour),
+		},
+		"ng: max age": {
+			req:      RequireMaxAge(5 * time.Minute),
+			wantCode: InsufficientUserAuthentication,
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.req(claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil {
+				return
+			}
+
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("want *Error, got %T", err)
+			}
+			wantCode := tt.wantCode
+			if wantCode == "" {
+				wantCode = InsufficientScope
+			}
+			if e.Code != wantCode {
+				t.Errorf("want %q, got %q", wantCode, e.Code)
+			}
+			if e.Scope != tt.wantScope {
+				t.Errorf("want scope %q, got %q", tt.wantScope, e.Scope)
+			}
+		})
+	}
+}
+
+func TestValidatorMiddlewareInsufficientScope(t *testing.T) {
+	t.Parallel()
+
+	srv := newIntrospectionServer(t, map[string]*Claims{
+		"active": {Active: true, Scope: "openid", Exp: time.Now().Add(time.Hour).Unix()},
+	})
+	v, err := NewValidator(Config{
+		IntrospectionEndpoint: srv.URL,
+		ClientID:              "resource-id",
+		ClientSecret:          "resource-secret",
+		HTTPClient:            srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("next handler must not be called")
+	}), RequireScopes("profile"))
+
+	req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("Authorization", "Bearer active")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("want status %d, got %d", http.StatusForbidden, rec.Code)
+	}
+	want := `Bearer error="insufficient_scope", error_description="scope profile is required", scope="profile"`
+	if got := rec.Header().Get("WWW-Authenticate"); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestValidatorMiddlewareInsufficientUserAuthentication(t *testing.T) {
+	t.Parallel()
+
+	srv := newIntrospectionServer(t, map[string]*Claims{
+		"active": {Active: true, ACR: "basic", AuthTime: time.Now().Add(-time.Hour).Unix(), Exp: time.Now().Add(time.Hour).Unix()},
+	})
+	v, err := NewValidator(Config{
+		IntrospectionEndpoint: srv.URL,
+		ClientID:              "resource-id",
+		ClientSecret:          "resource-secret",
+		HTTPClient:            srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]struct {
+		req  Requirement
+		want string
+	}{
+		"acr": {
+			req:  RequireACR("mfa"),
+			want: `Bearer error="insufficient_user_authentication", error_description="a different authentication level is required", acr_values="mfa"`,
+		},
+		"max age": {
+			req:  RequireMaxAge(5 * time.Minute),
+			want: `Bearer error="insufficient_user_authentication", error_description="more recent authentication is required", max_age="300"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				t.Error("next handler must not be called")
+			}), tt.req)
+
+			req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+			req.Header.Set("Authorization", "Bearer active")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("want status %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidatorMiddlewareMaxAgeOnClock(t *testing.T) {
+	t.Parallel()
+
+	authTime := time.Now().Add(-time.Hour)
+	srv := newIntrospectionServer(t, map[string]*Claims{
+		"active": {Active: true, AuthTime: authTime.Unix(), Exp: time.Now().Add(time.Hour).Unix()},
+	})
+	v, err := NewValidator(Config{
+		IntrospectionEndpoint: srv.URL,
+		ClientID:              "resource-id",
+		ClientSecret:          "resource-secret",
+		HTTPClient:            srv.Client(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the max age is measured on the clock of the validator, not on the wall clock.
+	v.now = func() time.Time { return authTime.Add(time.Minute) }
+
+	called := false
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), RequireMaxAge(5*time.Minute))
+
+	req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+	req.Header.Set("Authorization", "Bearer active")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if !called {
+		t.Errorf("next handler must be called, got status %d: %s", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,196 @@
+package tokenauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Second
+
+var (
+	ErrMissingToken   = errors.New("access token is missing")
+	ErrInactiveToken  = errors.New("access token is inactive")
+	ErrIssuerMismatch = errors.New("access token is issued by an unexpected issuer")
+)
+
+// Config configures a Validator.
+type Config struct {
+	// Realm is sent in the WWW-Authenticate challenge when it is not empty.
+	Realm string
+	// AllowQueryToken accepts the access_token URI query parameter. It is disabled by default
+	// because the token may leak through logs and the Referer header.
+	// ref: https://datatracker.ietf.org/doc/html/rfc6750#section-2.3
+	AllowQueryToken bool
+	// Issuer is the expected issuer of the tokens. The introspection responses without iss
+	// or with another iss are rejected if it is set.
+	Issuer string
+	// IntrospectionEndpoint is the RFC 7662 endpoint of the authorization server. (required)
+	IntrospectionEndpoint string
+	// ClientID and ClientSecret authenticate the resource server at the introspection endpoint
+	// with client_secret_basic. They are omitted when ClientID is empty.
+	ClientID     string
+	ClientSecret string
+	// HTTPClient is used to call the introspection endpoint. Defaults to a new http.Client.
+	HTTPClient *http.Client
+	// Timeout bounds a single introspection request. Defaults to 5 seconds.
+	Timeout time.Duration
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+	// Cache defaults to DefaultCacheConfig when Size is zero.
+	Cache CacheConfig
+}
+
+// Validator verifies bearer tokens against an introspection endpoint.
+type Validator struct {
+	config Config
+	cache  *introspectionCache
+	now    func() time.Time
+}
+
+func NewValidator(config Config) (*Validator, error) {
+	if config.IntrospectionEndpoint == "" {
+		return nil, fmt.Errorf("introspection endpoint is required")
+	}
+	if _, err := url.ParseRequestURI(config.IntrospectionEndpoint); err != nil {
+		return nil, fmt.Errorf("introspection endpoint is invalid: %w", err)
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{}
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if config.Cache == (CacheConfig{}) {
+		config.Cache = DefaultCacheConfig
+	}
+
+	return &Validator{
+		config: config,
+		cache:  newIntrospectionCache(config.Cache),
+		now:    time.Now,
+	}, nil
+}
+
+// Middleware rejects requests without an active token or whose token does not meet reqs,
+// and stores the verified claims in the request context for next.
+func (v *Validator) Middleware(next http.Handler, reqs ...Requirement) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflight requests never carry credentials.
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, authErr := v.extractToken(r)
+		if authErr != nil {
+			writeError(w, v.config.Realm, authErr)
+			return
+		}
+
+		claims, err := v.Validate(r.Context(), token)
+		if err != nil {
+			v.config.Logger.InfoContext(r.Context(), "token rejected", slog.String("error", err.Error()))
+			if errors.Is(err, ErrInactiveToken) || errors.Is(err, ErrIssuerMismatch) {
+				writeError(w, v.config.Realm, &Error{Code: InvalidToken, Description: err.Error()})
+				return
+			}
+			http.Error(w, "authorization server is unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		for _, req := range reqs {
+			if err := req(claims); err != nil {
+				v.config.Logger.InfoContext(r.Context(), "token does not meet the requirement", slog.String("error", err.Error()))
+				var e *Error
+				if !errors.As(err, &e) {
+					e = &Error{Code: InsufficientScope, Description: err.Error()}
+				}
+				writeError(w, v.config.Realm, e)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), claims)))
+	})
+}
+
+// Validate returns the claims of token if it is active.
+func (v *Validator) Validate(ctx context.Context, token string) (*Claims, error) {
+	if token == "" {
+		return nil, ErrMissingToken
+	}
+
+	claims, err := v.cache.Lookup(ctx, token, v.introspect)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.Active {
+		return nil, ErrInactiveToken
+	}
+
+	now := v.now()
+	if claims.Exp > 0 && !now.Before(time.Unix(claims.Exp, 0)) {
+		return nil, ErrInactiveToken
+	}
+	if claims.Nbf > 0 && now.Before(time.Unix(claims.Nbf, 0)) {
+		return nil, ErrInactiveToken
+	}
+	if v.config.Issuer != "" && claims.Iss != v.config.Issuer {
+		return nil, ErrIssuerMismatch
+	}
+
+	// the cached claims are shared by the requests, so the time of this request is set to a copy.
+	validated := *claims
+	validated.validatedAt = now
+	return &validated, nil
+}
+
+func (v *Validator) introspect(ctx context.Context, token string) (*Claims, error) {
+	// the result is shared by all callers waiting for the same token,
+	// so it must not be canceled by the first caller.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), v.config.Timeout)
+	defer cancel()
+
+	u := &url.Values{}
+	u.Set("token", token)
+	u.Set("token_type_hint", "access_token")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.config.IntrospectionEndpoint, strings.NewReader(u.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if v.config.ClientID != "" {
+		// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+		req.SetBasicAuth(url.QueryEscape(v.config.ClientID), url.QueryEscape(v.config.ClientSecret))
+	}
+
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected introspection status: %d", resp.StatusCode)
+	}
+
+	claims := &Claims{}
+	err = json.NewDecoder(resp.Body).Decode(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,144 @@
+package tokenauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newIntrospectionServer(t *testing.T, claims map[string]*Claims) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "resource-id" || secret != "resource-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		c, ok := claims[r.FormValue("token")]
+		if !ok {
+			c = &Claims{Active: false}
+		}
+		json.NewEncoder(w).Encode(c)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestValidatorMiddleware(t *testing.T) {
+	t.Parallel()
+
+	exp := time.Now().Add(time.Hour).Unix()
+	srv := newIntrospectionServer(t, map[string]*Claims{
+		"active":         {Active: true, Sub: "user-1", ClientID: "client-1", Scope: "openid profile", Exp: exp, Iss: "https://as.example.com"},
+		"expired":        {Active: true, Sub: "user-1", Exp: time.Now().Add(-time.Minute).Unix(), Iss: "https://as.example.com"},
+		"foreign-issuer": {Active: true, Sub: "user-1", Exp: exp, Iss: "https://evil.example.com"},
+		"missing-issuer": {Active: true, Sub: "user-1", Exp: exp},
+	})
+
+	type wants struct {
+		status int
+		sub    string
+	}
+
+	tests := map[string]struct {
+		header string
+		wants  wants
+	}{
+		"ok": {
+			header: "Bearer active",
+			wants:  wants{status: http.StatusOK, sub: "user-1"},
+		},
+		"ng: missing token": {
+			header: "",
+			wants:  wants{status: http.StatusUnauthorized},
+		},
+		"ng: unknown token": {
+			header: "Bearer unknown",
+			wants:  wants{status: http.StatusUnauthorized},
+		},
+		"ng: expired token": {
+			header: "Bearer expired",
+			wants:  wants{status: http.StatusUnauthorized},
+		},
+		"ng: issuer mismatch": {
+			header: "Bearer foreign-issuer",
+			wants:  wants{status: http.StatusUnauthorized},
+		},
+		"ng: issuer missing": {
+			header: "Bearer missing-issuer",
+			wants:  wants{status: http.StatusUnauthorized},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := NewValidator(Config{
+				Issuer:                "https://as.example.com",
+				IntrospectionEndpoint: srv.URL,
+				ClientID:              "resource-id",
+				ClientSecret:          "resource-secret",
+				HTTPClient:            srv.Client(),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var sub string
+			h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sub, _ = SubjectFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/resource", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wants.status {
+				t.Errorf("want status %d, got %d", tt.wants.status, rec.Code)
+			}
+			if sub != tt.wants.sub {
+				t.Errorf("want sub %q, got %q", tt.wants.sub, sub)
+			}
+		})
+	}
+}
+
+func TestNewValidator(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  Config
+		wantErr bool
+	}{
+		"ok": {
+			config: Config{IntrospectionEndpoint: "http://localhost:9001/introspect"},
+		},
+		"ng: endpoint is missing": {
+			config:  Config{},
+			wantErr: true,
+		},
+		"ng: endpoint is not a URL": {
+			config:  Config{IntrospectionEndpoint: "introspect"},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewValidator(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	authZUseCase "github.com/task4233/oauth/pkg/usecase/authorization"
+)
+
+var (
+	ErrConfigInvalid = errors.New("config is invalid")
+	ErrLoginTokenKey = errors.New("login token key must be 32 bytes")
+)
+
+// Config is the configuration of cmd/server. It is read from the JSON file, and overridden by the environment
+// variables and then by the flags. The clients, the resources and the users of the sample app are configured
+// if none is listed.
+type Config struct {
+	App            AppServer      `json:"app"`
+	Authorization  Server         `json:"authorization"`
+	Authentication Server         `json:"authentication"`
+	Resource       ResourceServer `json:"resource"`
+
+	Tokens Tokens `json:"tokens"`
+	Keys   Keys   `json:"keys"`
+
+	Clients   []*Client            `json:"clients"`
+	Resources []*ProtectedResource `json:"resources"`
+	Users     []*User              `json:"users"`
+
+	// SecretFixedKey is mixed into the hashes of the secrets of the clients, the resources and the users.
+	SecretFixedKey string `json:"secret_fixed_key"`
+	// AdminToken authenticates the administrators calling the admin API, which is closed if it is empty.
+	AdminToken string `json:"admin_token"`
+	// RequirePushedAuthRequests rejects authorization requests of every client unless they are pushed.
+	RequirePushedAuthRequests bool `json:"require_pushed_auth_requests"`
+
+	TrustedIssuersFile    string `json:"trusted_issuers_file"`
+	LockoutStorageFile    string `json:"lockout_storage_file"`
+	UpstreamProvidersFile string `json:"upstream_providers_file"`
+	TenantsFile           string `json:"tenants_file"`
+}
+
+// Server is where a server listens, and the URL at which it is reached.
+type Server struct {
+	Listen string `json:"listen"` // e.g. :9001
+	// BaseURL is http://localhost with the port of Listen if it is empty.
+	BaseURL string `json:"base_url"`
+}
+
+// AppServer is the sample app, which is a client of the authorization server.
+type AppServer struct {
+	Server
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// ResourceServer is the sample resource server, which introspects the access tokens with its credentials.
+type ResourceServer struct {
+	Server
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	// RequiredScopes are the scopes which the access tokens must have to access the resource.
+	RequiredScopes []string `json:"required_scopes"`
+}
+
+type Tokens struct {
+	AccessTokenLifetime  Duration `json:"access_token_lifetime"`
+	RefreshTokenLifetime Duration `json:"refresh_token_lifetime"`
+	IDTokenLifetime      Duration `json:"id_token_lifetime"`
+}
+
+// Keys configures the rotation of the signing keys. The keys are kept in StorageFile sealed with EncryptionKey,
+// or only in memory if StorageFile is empty. The encryption keys are rotated on the same schedule in StorageFile.enc.
+type Keys struct {
+	StorageFile string `json:"storage_file"`
+	// EncryptionKey is the 32-byte key-encryption key in base64.
+	EncryptionKey  string   `json:"encryption_key"`
+	Algorithm      string   `json:"algorithm"`
+	RotationPeriod Duration `json:"rotation_period"`
+	PublishBefore  Duration `json:"publish_before"`
+	RetireAfter    Duration `json:"retire_after"`
+	// LoginTokenKey is the 32-byte key in base64 with which the authentication server hands the logins over to the
+	// authorization server. It is required if they run in separate processes, and random otherwise.
+	LoginTokenKey string `json:"login_token_key"`
+}
+
+// Client is a confidential client authenticated with client_secret_basic.
+type Client struct {
+	ID                     string   `json:"id"`
+	Secret                 string   `json:"secret"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	FrontchannelLogoutURI  string   `json:"frontchannel_logout_uri"`
+	BackchannelLogoutURI   string   `json:"backchannel_logout_uri"`
+	// BackchannelTokenDeliveryMode allows the client to start CIBA, which is poll, ping or push.
+	BackchannelTokenDeliveryMode          string `json:"backchannel_token_delivery_mode"`
+	BackchannelClientNotificationEndpoint string `json:"backchannel_client_notification_endpoint"`
+	RequirePushedAuthRequests             bool   `json:"require_pushed_auth_requests"`
+}
+
+// ProtectedResource is a resource server registered with its resource indicator.
+type ProtectedResource struct {
+	URI    string   `json:"uri"`
+	ID     string   `json:"id"`
+	Secret string   `json:"secret"`
+	Scopes []string `json:"scopes"`
+}
+
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Tenant is a tenant in TenantsFile with its own clients and users, which are not shared with the default issuer nor
+// the other tenants.
+type Tenant struct {
+	model.Tenant
+	Clients []*Client `json:"clients"`
+	Users   []*User   `json:"users"`
+}
+
+// Duration is a time.Duration written as a string such as "10m" in the file.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default returns the configuration of the sample app on localhost, with which the server runs without the file.
+func Default() *Config {
+	return &Config{
+		App: AppServer{
+			Server:       Server{Listen: ":9000"},
+			ClientID:     "dummy-client-id",
+			ClientSecret: "dummy-client-secret",
+			Scopes:       []string{"openid", "profile", "email"},
+		},
+		Authorization:  Server{Listen: ":9001"},
+		Authentication: Server{Listen: ":9002"},
+		Resource: ResourceServer{
+			Server:         Server{Listen: ":9003"},
+			ID:             "dummy-resource-id",
+			Secret:         "dummy-resource-secret",
+			RequiredScopes: []string{"profile"},
+		},
+		Tokens: Tokens{
+			AccessTokenLifetime:  Duration{model.DefaultAccessTokenLifetime},
+			RefreshTokenLifetime: Duration{model.DefaultRefreshTokenLifetime},
+			IDTokenLifetime:      Duration{authZUseCase.DefaultIDTokenLifetime},
+		},
+		Keys: Keys{
+			Algorithm:      model.DefaultKeyRotationPolicy.Algorithm,
+			RotationPeriod: Duration{model.DefaultKeyRotationPolicy.RotationPeriod},
+			PublishBefore:  Duration{model.DefaultKeyRotationPolicy.PublishBefore},
+			RetireAfter:    Duration{model.DefaultKeyRotationPolicy.RetireAfter},
+		},
+	}
+}
+
+// Load reads the file at path over the default configuration. The unknown fields are rejected, as they are likely typos.
+func Load(path string) (*Config, error) {
+	c := Default()
+	if path == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Complete fills the base URLs from the listen addresses, and the clients, the resources and the users of
+// the sample app if none is listed. It is called after the overrides.
+func (c *Config) Complete() {
+	for _, s := range c.servers() {
+		if s.BaseURL == "" {
+			_, port, _ := net.SplitHostPort(s.Listen)
+			s.BaseURL = "http://localhost:" + port
+		}
+		s.BaseURL = strings.TrimSuffix(s.BaseURL, "/")
+	}
+
+	if len(c.Clients) == 0 {
+		c.Clients = []*Client{{
+			ID:                           c.App.ClientID,
+			Secret:                       c.App.ClientSecret,
+			RedirectURIs:                 []string{c.App.BaseURL + "/auth/callback"},
+			PostLogoutRedirectURIs:       []string{c.App.BaseURL + "/"},
+			FrontchannelLogoutURI:        c.App.BaseURL + "/frontchannel_logout",
+			BackchannelTokenDeliveryMode: string(model.BackchannelTokenDeliveryModePoll),
+		}}
+	}
+	if len(c.Resources) == 0 {
+		c.Resources = []*ProtectedResource{{
+			URI:    c.Resource.BaseURL,
+			ID:     c.Resource.ID,
+			Secret: c.Resource.Secret,
+			Scopes: []string{"profile", "email"},
+		}}
+	}
+	if len(c.Users) == 0 {
+		c.Users = []*User{{ID: "dummy-user-id", Username: "dummy-user", Password: "dummy-password"}}
+	}
+}
+
+// Validate reports all the problems of the configuration at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrConfigInvalid}, args...)...))
+	}
+
+	listens := map[string]string{}
+	for _, s := range c.servers() {
+		if _, _, err := net.SplitHostPort(s.Listen); err != nil {
+			invalid("%s.listen: %v", s.name, err)
+		} else if other, ok := listens[s.Listen]; ok {
+			invalid("%s.listen: %s is also the address of %s", s.name, s.Listen, other)
+		} else {
+			listens[s.Listen] = s.name
+		}
+		if err := validateURL(s.BaseURL); err != nil {
+			invalid("%s.base_url: %v", s.name, err)
+		}
+	}
+
+	for _, d := range []struct {
+		name string
+		Duration
+	}{
+		{"tokens.access_token_lifetime", c.Tokens.AccessTokenLifetime},
+		{"tokens.refresh_token_lifetime", c.Tokens.RefreshTokenLifetime},
+		{"tokens.id_token_lifetime", c.Tokens.IDTokenLifetime},
+	} {
+		if d.Duration.Duration <= 0 {
+			invalid("%s must be positive", d.name)
+		}
+	}
+	if _, err := c.Keys.RotationPolicy(); err != nil {
+		invalid("keys: %v", err)
+	}
+	// the signed JWTs are verified until they expire.
+	if c.Keys.RetireAfter.Duration < c.Tokens.IDTokenLifetime.Duration {
+		invalid("keys.retire_after must not be shorter than tokens.id_token_lifetime")
+	}
+	if _, err := c.Keys.KeyEncryptionKey(); err != nil {
+		invalid("keys.encryption_key: %v", err)
+	}
+	if _, err := c.Keys.LoginTokenSigningKey(); err != nil {
+		invalid("keys.login_token_key: %v", err)
+	}
+	if c.Keys.StorageFile != "" && c.Keys.EncryptionKey == "" {
+		invalid("keys.encryption_key is required to store the signing keys in keys.storage_file")
+	}
+
+	validateClients(c.Clients, invalid)
+	// the sample app logs in with one of the clients.
+	if app := c.client(c.App.ClientID); app == nil {
+		invalid("app.client_id %s is not in clients", c.App.ClientID)
+	} else {
+		if app.Secret != c.App.ClientSecret {
+			invalid("app.client_secret is not the secret of the client %s", app.ID)
+		}
+		if !slices.Contains(app.RedirectURIs, c.App.BaseURL+"/auth/callback") {
+			invalid("the client %s must have the redirect URI %s/auth/callback of the app", app.ID, c.App.BaseURL)
+		}
+	}
+
+	resourceURIs, resourceIDs := map[string]bool{}, map[string]bool{}
+	for i, r := range c.Resources {
+		switch {
+		case r.URI == "" || r.ID == "" || r.Secret == "":
+			invalid("resources[%d]: uri, id and secret are required", i)
+		case resourceURIs[r.URI] || resourceIDs[r.ID]:
+			invalid("resources[%d]: uri %s or id %s is duplicated", i, r.URI, r.ID)
+		}
+		resourceURIs[r.URI], resourceIDs[r.ID] = true, true
+		if err := validateURL(r.URI); err != nil {
+			invalid("resources[%d].uri: %v", i, err)
+		}
+	}
+	// the sample resource server introspects the tokens issued for its base URL.
+	if !slices.ContainsFunc(c.Resources, func(r *ProtectedResource) bool {
+		return r.URI == c.Resource.BaseURL && r.ID == c.Resource.ID && r.Secret == c.Resource.Secret
+	}) {
+		invalid("resources must have the resource server at %s with resource.id and resource.secret", c.Resource.BaseURL)
+	}
+
+	validateUsers(c.Users, invalid)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports all the problems of the tenant at once.
+func (t *Tenant) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrConfigInvalid}, args...)...))
+	}
+
+	if err := t.Tenant.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	validateClients(t.Clients, invalid)
+	validateUsers(t.Users, invalid)
+
+	return errors.Join(errs...)
+}
+
+func validateClients(clients []*Client, invalid func(format string, args ...any)) {
+	clientIDs := map[string]bool{}
+	for i, client := range clients {
+		switch {
+		case client.ID == "" || client.Secret == "":
+			invalid("clients[%d]: id and secret are required", i)
+		case clientIDs[client.ID]:
+			invalid("clients[%d]: id %s is duplicated", i, client.ID)
+		}
+		clientIDs[client.ID] = true
+		if len(client.RedirectURIs) == 0 {
+			invalid("clients[%d].redirect_uris are required", i)
+		}
+		uris := slices.Concat(client.RedirectURIs, client.PostLogoutRedirectURIs)
+		for _, uri := range []string{client.FrontchannelLogoutURI, client.BackchannelLogoutURI, client.BackchannelClientNotificationEndpoint} {
+			if uri != "" {
+				uris = append(uris, uri)
+			}
+		}
+		for _, uri := range uris {
+			if err := validateURL(uri); err != nil {
+				invalid("clients[%d]: %s: %v", i, uri, err)
+			}
+		}
+		switch model.BackchannelTokenDeliveryMode(client.BackchannelTokenDeliveryMode) {
+		case "", model.BackchannelTokenDeliveryModePoll:
+		case model.BackchannelTokenDeliveryModePing, model.BackchannelTokenDeliveryModePush:
+			if client.BackchannelClientNotificationEndpoint == "" {
+				invalid("clients[%d].backchannel_client_notification_endpoint is required for %s", i, client.BackchannelTokenDeliveryMode)
+			}
+		default:
+			invalid("clients[%d].backchannel_token_delivery_mode must be poll, ping or push", i)
+		}
+	}
+}
+
+func validateUsers(users []*User, invalid func(format string, args ...any)) {
+	userIDs, usernames := map[string]bool{}, map[string]bool{}
+	for i, u := range users {
+		switch {
+		case u.ID == "" || u.Username == "" || u.Password == "":
+			invalid("users[%d]: id, username and password are required", i)
+		case userIDs[u.ID] || usernames[u.Username]:
+			invalid("users[%d]: id %s or username %s is duplicated", i, u.ID, u.Username)
+		}
+		userIDs[u.ID], usernames[u.Username] = true, true
+	}
+}
+
+type namedServer struct {
+	name string
+	*Server
+}
+
+func (c *Config) servers() []namedServer {
+	return []namedServer{
+		{"app", &c.App.Server},
+		{"authorization", &c.Authorization},
+		{"authentication", &c.Authentication},
+		{"resource", &c.Resource.Server},
+	}
+}
+
+func (c *Config) client(id string) *Client {
+	for _, client := range c.Clients {
+		if client.ID == id {
+			return client
+		}
+	}
+	return nil
+}
+
+// validateURL accepts the absolute http(s) URLs without fragments.
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Fragment != "" {
+		return fmt.Errorf("%q must be an absolute http(s) URL without fragment", raw)
+	}
+	return nil
+}
+
+// RotationPolicy returns the schedule of the rotation of the signing keys.
+func (k *Keys) RotationPolicy() (model.KeyRotationPolicy, error) {
+	policy := model.KeyRotationPolicy{
+		Algorithm:      k.Algorithm,
+		RotationPeriod: k.RotationPeriod.Duration,
+		PublishBefore:  k.PublishBefore.Duration,
+		RetireAfter:    k.RetireAfter.Duration,
+	}
+	return policy, service.ValidateKeyRotationPolicy(policy)
+}
+
+// KeyEncryptionKey returns the decoded EncryptionKey, which is nil if it is not set.
+func (k *Keys) KeyEncryptionKey() ([]byte, error) {
+	return decodeKey(k.EncryptionKey, service.ErrKeyEncryptionKey)
+}
+
+// LoginTokenSigningKey returns the decoded LoginTokenKey, which is nil if it is not set.
+func (k *Keys) LoginTokenSigningKey() ([]byte, error) {
+	return decodeKey(k.LoginTokenKey, ErrLoginTokenKey)
+}
+
+// decodeKey decodes the 32-byte key in base64, or returns errLength if it is of another length.
+func decodeKey(encoded string, errLength error) ([]byte, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, errLength
+	}
+	return key, nil
+}
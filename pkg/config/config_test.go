@@ -0,0 +1,338 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestDefault(t *testing.T) {
+	t.Parallel()
+
+	c := Default()
+	c.Complete()
+	if err := c.Validate(); err != nil {
+		t.Fatalf("the default configuration must be valid: %v", err)
+	}
+	if c.Authorization.BaseURL != "http://localhost:9001" {
+		t.Errorf("unexpected base URL: %s", c.Authorization.BaseURL)
+	}
+	if len(c.Clients) != 1 || c.Clients[0].RedirectURIs[0] != "http://localhost:9000/auth/callback" {
+		t.Errorf("unexpected clients: %+v", c.Clients)
+	}
+	if len(c.Resources) != 1 || c.Resources[0].URI != "http://localhost:9003" {
+		t.Errorf("unexpected resources: %+v", c.Resources)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content string
+		wantErr bool
+		check   func(t *testing.T, c *Config)
+	}{
+		"ok: overrides the defaults": {
+			content: `{
+				"authorization": {"listen": ":8001", "base_url": "https://auth.example.com/"},
+				"tokens": {"access_token_lifetime": "5m"},
+				"keys": {"algorithm": "ES256"}
+			}`,
+			check: func(t *testing.T, c *Config) {
+				if c.Authorization.Listen != ":8001" {
+					t.Errorf("unexpected listen: %s", c.Authorization.Listen)
+				}
+				if c.Tokens.AccessTokenLifetime.Duration != 5*time.Minute {
+					t.Errorf("unexpected lifetime: %v", c.Tokens.AccessTokenLifetime)
+				}
+				// the unspecified fields keep the defaults.
+				if c.Tokens.RefreshTokenLifetime.Duration != 24*time.Hour {
+					t.Errorf("unexpected lifetime: %v", c.Tokens.RefreshTokenLifetime)
+				}
+				if c.Keys.Algorithm != "ES256" || c.Keys.RotationPeriod.Duration != 30*24*time.Hour {
+					t.Errorf("unexpected keys: %+v", c.Keys)
+				}
+				c.Complete()
+				if c.Authorization.BaseURL != "https://auth.example.com" {
+					t.Errorf("unexpected base URL: %s", c.Authorization.BaseURL)
+				}
+			},
+		},
+		"ng: unknown field": {
+			content: `{"tokens": {"access_token_lifetme": "5m"}}`,
+			wantErr: true,
+		},
+		"ng: duration is not a string": {
+			content: `{"tokens": {"access_token_lifetime": 300}}`,
+			wantErr: true,
+		},
+		"ng: invalid duration": {
+			content: `{"tokens": {"access_token_lifetime": "5 minutes"}}`,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			c, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		env     map[string]string
+		args    []string
+		wantErr bool
+		check   func(t *testing.T, c *Config)
+	}{
+		"ok: env overrides the file": {
+			env: map[string]string{"AUTHORIZATION_URL": "https://auth.example.com", "REQUIRE_PUSHED_AUTH_REQUESTS": "true"},
+			check: func(t *testing.T, c *Config) {
+				if c.Authorization.BaseURL != "https://auth.example.com" || !c.RequirePushedAuthRequests {
+					t.Errorf("unexpected config: %+v", c)
+				}
+			},
+		},
+		"ok: empty env is ignored": {
+			env: map[string]string{"CLIENT_ID": ""},
+			check: func(t *testing.T, c *Config) {
+				if c.App.ClientID != "dummy-client-id" {
+					t.Errorf("unexpected client_id: %s", c.App.ClientID)
+				}
+			},
+		},
+		"ok: flags override env": {
+			env:  map[string]string{"ID_TOKEN_LIFETIME": "5m"},
+			args: []string{"--id-token-lifetime", "15m", "--authorization-listen=:8001"},
+			check: func(t *testing.T, c *Config) {
+				if c.Tokens.IDTokenLifetime.Duration != 15*time.Minute || c.Authorization.Listen != ":8001" {
+					t.Errorf("unexpected config: %+v", c)
+				}
+			},
+		},
+		"ng: invalid env": {
+			env:     map[string]string{"ACCESS_TOKEN_LIFETIME": "forever"},
+			wantErr: true,
+		},
+		"ng: invalid flag": {
+			args:    []string{"--require-pushed-auth-requests=maybe"},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := Default()
+			err := c.ApplyEnv(func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return v, ok
+			})
+			if err == nil {
+				fs := flag.NewFlagSet("test", flag.ContinueOnError)
+				apply := Flags(fs)
+				if err := fs.Parse(tt.args); err != nil {
+					t.Fatal(err)
+				}
+				err = apply(c)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err != nil && !errors.Is(err, ErrConfigInvalid) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, c)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		modify  func(c *Config)
+		wantErr []string
+	}{
+		"ok: custom base URLs": {
+			modify: func(c *Config) {
+				c.App.BaseURL = "https://app.example.com"
+				c.Resource.BaseURL = "https://api.example.com"
+				// the client and the resource of the sample app follow the base URLs.
+				c.Clients, c.Resources = nil, nil
+				c.Complete()
+			},
+		},
+		"ok: keys are stored with the encryption key": {
+			modify: func(c *Config) {
+				c.Keys.StorageFile = "keys.json"
+				c.Keys.EncryptionKey = "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE="
+			},
+		},
+		"ng: duplicated listen address": {
+			modify: func(c *Config) {
+				c.Resource.Listen = ":9001"
+			},
+			wantErr: []string{"resource.listen"},
+		},
+		"ng: invalid base URL": {
+			modify: func(c *Config) {
+				c.Authorization.BaseURL = "localhost:9001"
+			},
+			wantErr: []string{"authorization.base_url"},
+		},
+		"ng: non-positive lifetime": {
+			modify: func(c *Config) {
+				c.Tokens.AccessTokenLifetime.Duration = 0
+			},
+			wantErr: []string{"tokens.access_token_lifetime"},
+		},
+		"ng: retired keys expire before ID tokens": {
+			modify: func(c *Config) {
+				c.Tokens.IDTokenLifetime.Duration = 48 * time.Hour
+			},
+			wantErr: []string{"keys.retire_after"},
+		},
+		"ng: unsupported algorithm": {
+			modify: func(c *Config) {
+				c.Keys.Algorithm = "HS256"
+			},
+			wantErr: []string{"keys:"},
+		},
+		"ng: keys are stored without the encryption key": {
+			modify: func(c *Config) {
+				c.Keys.StorageFile = "keys.json"
+			},
+			wantErr: []string{"keys.encryption_key"},
+		},
+		"ng: login token key is not 32 bytes": {
+			modify: func(c *Config) {
+				c.Keys.LoginTokenKey = "c2hvcnQ="
+			},
+			wantErr: []string{"keys.login_token_key"},
+		},
+		"ng: app client is not registered": {
+			modify: func(c *Config) {
+				c.App.ClientID = "another-client-id"
+			},
+			wantErr: []string{"app.client_id"},
+		},
+		"ng: push mode without notification endpoint": {
+			modify: func(c *Config) {
+				c.Clients[0].BackchannelTokenDeliveryMode = "push"
+			},
+			wantErr: []string{"backchannel_client_notification_endpoint"},
+		},
+		"ng: duplicated users": {
+			modify: func(c *Config) {
+				c.Users = append(c.Users, &User{ID: "dummy-user-id", Username: "another-user", Password: "password"})
+			},
+			wantErr: []string{"users[1]"},
+		},
+		"ng: every problem is reported": {
+			modify: func(c *Config) {
+				c.Resource.Secret = "another-secret"
+				c.Clients[0].RedirectURIs = []string{"/auth/callback"}
+			},
+			wantErr: []string{"resources must have", "clients[0]", "redirect URI"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := Default()
+			c.Complete()
+			tt.modify(c)
+
+			err := c.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrConfigInvalid) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTenantValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		tenant  *Tenant
+		wantErr []string
+	}{
+		"ok": {
+			tenant: &Tenant{
+				Tenant:  model.Tenant{ID: "brand-a"},
+				Clients: []*Client{{ID: "brand-a-client-id", Secret: "secret", RedirectURIs: []string{"https://app.example.com/callback"}}},
+				Users:   []*User{{ID: "brand-a-user-id", Username: "brand-a-user", Password: "password"}},
+			},
+		},
+		"ng: invalid clients and users": {
+			tenant: &Tenant{
+				Tenant:  model.Tenant{ID: "brand-a"},
+				Clients: []*Client{{ID: "brand-a-client-id", Secret: "secret"}},
+				Users:   []*User{{ID: "brand-a-user-id", Username: "brand-a-user"}},
+			},
+			wantErr: []string{"clients[0].redirect_uris", "users[0]"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.tenant.Validate()
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrConfigInvalid) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
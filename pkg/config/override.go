@@ -0,0 +1,123 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// override is a setting which is overridden by the environment variable env, and then by the flag of the same
+// name in kebab case, e.g. AUTHORIZATION_URL by --authorization-url.
+type override struct {
+	env   string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func (o *override) flag() string {
+	return strings.ToLower(strings.ReplaceAll(o.env, "_", "-"))
+}
+
+func setString(field func(c *Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		field(c).Duration = d
+		return nil
+	}
+}
+
+// overrides keep the environment variables which configured the server before the configuration file.
+var overrides = []override{
+	{"APP_LISTEN", "listen address of the sample app", setString(func(c *Config) *string { return &c.App.Listen })},
+	{"APP_URL", "public base URL of the sample app", setString(func(c *Config) *string { return &c.App.BaseURL })},
+	{"AUTHORIZATION_LISTEN", "listen address of the authorization server", setString(func(c *Config) *string { return &c.Authorization.Listen })},
+	{"AUTHORIZATION_URL", "public base URL of the authorization server, which is the issuer", setString(func(c *Config) *string { return &c.Authorization.BaseURL })},
+	{"AUTHENTICATION_LISTEN", "listen address of the authentication server", setString(func(c *Config) *string { return &c.Authentication.Listen })},
+	{"AUTHENTICATION_URL", "public base URL of the authentication server, which serves the login page", setString(func(c *Config) *string { return &c.Authentication.BaseURL })},
+	{"RESOURCE_LISTEN", "listen address of the resource server", setString(func(c *Config) *string { return &c.Resource.Listen })},
+	{"RESOURCE_URL", "public base URL of the resource server, which is its resource indicator", setString(func(c *Config) *string { return &c.Resource.BaseURL })},
+
+	{"CLIENT_ID", "client_id of the sample app", setString(func(c *Config) *string { return &c.App.ClientID })},
+	{"CLIENT_SECRET", "client_secret of the sample app", setString(func(c *Config) *string { return &c.App.ClientSecret })},
+	{"CLIENT_SECRET_FIXED_KEY", "key mixed into the hashes of the secrets", setString(func(c *Config) *string { return &c.SecretFixedKey })},
+	{"RESOURCE_SERVER_ID", "id with which the resource server introspects tokens", setString(func(c *Config) *string { return &c.Resource.ID })},
+	{"RESOURCE_SERVER_SECRET", "secret with which the resource server introspects tokens", setString(func(c *Config) *string { return &c.Resource.Secret })},
+	{"ADMIN_TOKEN", "bearer token of the admin API", setString(func(c *Config) *string { return &c.AdminToken })},
+	{"REQUIRE_PUSHED_AUTH_REQUESTS", "reject authorization requests unless they are pushed", setBool(func(c *Config) *bool { return &c.RequirePushedAuthRequests })},
+
+	{"ACCESS_TOKEN_LIFETIME", "lifetime of access tokens, e.g. 1m", setDuration(func(c *Config) *Duration { return &c.Tokens.AccessTokenLifetime })},
+	{"REFRESH_TOKEN_LIFETIME", "lifetime of refresh tokens, e.g. 24h", setDuration(func(c *Config) *Duration { return &c.Tokens.RefreshTokenLifetime })},
+	{"ID_TOKEN_LIFETIME", "lifetime of ID tokens, e.g. 10m", setDuration(func(c *Config) *Duration { return &c.Tokens.IDTokenLifetime })},
+
+	{"KEY_STORAGE_FILE", "file in which the signing keys are kept", setString(func(c *Config) *string { return &c.Keys.StorageFile })},
+	{"KEY_ENCRYPTION_KEY", "32-byte key in base64 with which the signing keys and the encryption keys are sealed", setString(func(c *Config) *string { return &c.Keys.EncryptionKey })},
+	{"LOGIN_TOKEN_KEY", "32-byte key in base64 shared by the authorization server and the authentication server", setString(func(c *Config) *string { return &c.Keys.LoginTokenKey })},
+	{"SIGNING_KEY_ALGORITHM", "algorithm of the signing keys, RS256, PS256, ES256 or EdDSA", setString(func(c *Config) *string { return &c.Keys.Algorithm })},
+
+	{"TRUSTED_ISSUERS_FILE", "JSON file of the issuers of JWT assertions", setString(func(c *Config) *string { return &c.TrustedIssuersFile })},
+	{"LOCKOUT_STORAGE_FILE", "file in which the failed attempts are counted", setString(func(c *Config) *string { return &c.LockoutStorageFile })},
+	{"UPSTREAM_PROVIDERS_FILE", "JSON file of the upstream OpenID providers", setString(func(c *Config) *string { return &c.UpstreamProvidersFile })},
+	{"TENANTS_FILE", "JSON file of the tenants", setString(func(c *Config) *string { return &c.TenantsFile })},
+}
+
+// ApplyEnv overrides the configuration with the environment variables found by lookup, e.g. os.LookupEnv.
+// The empty variables are ignored, so that a sourced .envrc does not clear the file.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	for _, o := range overrides {
+		v, ok := lookup(o.env)
+		if !ok || v == "" {
+			continue
+		}
+		if err := o.set(c, v); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrConfigInvalid, o.env, err)
+		}
+	}
+	return nil
+}
+
+// Flags defines the flags of the overrides on fs, and returns the function which applies the flags given on
+// the command line to the configuration.
+func Flags(fs *flag.FlagSet) func(*Config) error {
+	values := map[string]string{}
+	for _, o := range overrides {
+		fs.Func(o.flag(), o.usage+" ("+o.env+")", func(v string) error {
+			values[o.env] = v
+			return nil
+		})
+	}
+	return func(c *Config) error {
+		for _, o := range overrides {
+			v, ok := values[o.env]
+			if !ok {
+				continue
+			}
+			if err := o.set(c, v); err != nil {
+				return fmt.Errorf("%w: --%s: %w", ErrConfigInvalid, o.flag(), err)
+			}
+		}
+		return nil
+	}
+}
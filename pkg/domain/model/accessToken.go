@@ -6,23 +6,87 @@ import (
 	"github.com/google/uuid"
 )
 
+// DefaultAccessTokenLifetime is short, as the access tokens are refreshed with the refresh tokens.
+const DefaultAccessTokenLifetime = time.Minute
+
 type AccessToken struct {
 	AccessToken  string
 	TokenType    string
 	RefreshToken string
-	ExpiresIn    int64
-	Scope        string // space-delimited, ref: https://datatracker.ietf.org/doc/html/rfc6749#section-3.3
+	ExpiresAt    int64
+	Scope        string   // space-delimited, ref: https://datatracker.ietf.org/doc/html/rfc6749#section-3.3
+	Audience     []string // ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2.2
+	// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-7
+	AuthorizationDetails []AuthorizationDetail
+	ClientID             string
+	Subject              string
+	IssuedAt             int64
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6
+	AuthTime int64
+	ACR      string
+	AMR      []string
+
+	Actor *Actor // ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+
+	// IssuedTokenType is set when the token is issued by token exchange.
+	// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2.2.1
+	IssuedTokenType TokenTypeURI
+
+	// IDToken is issued with the access token when openid is granted.
+	// ref: https://openid.net/specs/openid-connect-core-1_0.html#TokenResponse
+	IDToken string
 }
 
-func NewAccessToken(authReq *AuthRequest) *AccessToken {
-	if authReq == nil {
+// NewAccessToken issues a token for grant, which expires after lifetime. The resources of grant are the audience of the token.
+func NewAccessToken(grant *Grant, lifetime time.Duration) *AccessToken {
+	if grant == nil {
 		return nil
 	}
 
-	return &AccessToken{
+	now := time.Now()
+	token := &AccessToken{
 		AccessToken: uuid.NewString(),
 		TokenType:   "Bearer",
-		ExpiresIn:   time.Now().Add(time.Minute).Unix(),
-		Scope:       authReq.Scope,
+		ExpiresAt:   now.Add(lifetime).Unix(),
+		Scope:       grant.Scope,
+		Audience:    grant.Resources,
+		ClientID:    grant.ClientID,
+		IssuedAt:    now.Unix(),
+
+		AuthorizationDetails: grant.AuthorizationDetails,
+		Actor:                grant.Actor,
+	}
+	if authn := grant.Authentication; authn != nil {
+		token.Subject = authn.Subject
+		token.AuthTime = authn.AuthTime.Unix()
+		token.ACR = authn.ACR
+		token.AMR = authn.AMR
 	}
+	return token
+}
+
+// Grant returns what the token grants, e.g. to exchange it for another token.
+func (t *AccessToken) Grant() *Grant {
+	grant := &Grant{
+		ClientID:             t.ClientID,
+		Scope:                t.Scope,
+		Resources:            t.Audience,
+		AuthorizationDetails: t.AuthorizationDetails,
+		Actor:                t.Actor,
+	}
+	if t.Subject != "" {
+		grant.Authentication = &Authentication{
+			Subject:  t.Subject,
+			AuthTime: time.Unix(t.AuthTime, 0),
+			ACR:      t.ACR,
+			AMR:      t.AMR,
+		}
+	}
+	return grant
+}
+
+// ExpiresIn returns the lifetime in seconds.
+func (t *AccessToken) ExpiresIn() int64 {
+	return t.ExpiresAt - time.Now().Unix()
 }
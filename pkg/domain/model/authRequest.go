@@ -8,14 +8,63 @@ type AuthRequest struct {
 	Code         string
 	RedirectURI  string
 	ResponseType string
+	ResponseMode ResponseMode // as requested, ref: https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
 	State        string
 	Scope        string
-	DisabledAt   time.Time
+	ACRValues    []string // ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	MaxAge       *int64   // seconds, nil if not requested
+	Resources    []string // ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2.1
+	// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-2
+	AuthorizationDetails []AuthorizationDetail
+	DisabledAt           time.Time
+
+	// Authentication is set after the user logs in.
+	Authentication *Authentication
+}
+
+func (r *AuthRequest) Grant() *Grant {
+	return &Grant{
+		ClientID:             r.ClientID,
+		Scope:                r.Scope,
+		Resources:            r.Resources,
+		AuthorizationDetails: r.AuthorizationDetails,
+		Authentication:       r.Authentication,
+	}
 }
 
+type GrantType string
+
+const (
+	GrantTypeAuthorizationCode GrantType = "authorization_code"
+	GrantTypeRefreshToken      GrantType = "refresh_token"
+	GrantTypeTokenExchange     GrantType = "urn:ietf:params:oauth:grant-type:token-exchange" // ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+	GrantTypeJWTBearer         GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"     // ref: https://datatracker.ietf.org/doc/html/rfc7523#section-2.1
+	GrantTypeCIBA              GrantType = "urn:openid:params:grant-type:ciba"               // ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1
+)
+
 type TokenRequest struct {
+	GrantType    GrantType
 	Code         string
 	RedirectURI  string
-	ClientID     string
-	ClientSecret string
+	RefreshToken string
+	Scope        string
+	Resources    []string
+	// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-6.1
+	AuthorizationDetails []AuthorizationDetail
+	ClientID             string
+	ClientSecret         string
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+	SubjectToken       string
+	SubjectTokenType   TokenTypeURI
+	ActorToken         string
+	ActorTokenType     TokenTypeURI
+	RequestedTokenType TokenTypeURI
+	Audiences          []string
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc7523#section-2.1
+	Assertion string
+
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1
+	AuthReqID string
 }
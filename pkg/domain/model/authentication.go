@@ -0,0 +1,56 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+const (
+	ACRBasic       = "basic"
+	ACRMultiFactor = "mfa"
+)
+
+// acrLevels order the authentication context classes, so that a stronger class satisfies the request of a weaker one.
+var acrLevels = map[string]int{
+	ACRBasic:       1,
+	ACRMultiFactor: 2,
+}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc8176#section-2
+const (
+	AMRPassword     = "pwd"
+	AMROTP          = "otp"
+	AMRHardwareKey  = "hwk"
+	AMRUserPresence = "user"
+	AMRMultiFactor  = "mfa"
+)
+
+// Authentication is the result of a user authentication at the authentication server.
+type Authentication struct {
+	Subject  string
+	AuthTime time.Time
+	ACR      string
+	AMR      []string
+
+	// SessionID is the single sign-on session in which the user is authenticated.
+	// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#ClaimsContents
+	SessionID string
+}
+
+// SatisfiesACR reports whether the authentication is as strong as any of acrValues.
+// The unknown classes are satisfied only by the same class.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (a *Authentication) SatisfiesACR(acrValues []string) bool {
+	if len(acrValues) == 0 || slices.Contains(acrValues, a.ACR) {
+		return true
+	}
+	level, ok := acrLevels[a.ACR]
+	if !ok {
+		return false
+	}
+	return slices.ContainsFunc(acrValues, func(v string) bool {
+		required, ok := acrLevels[v]
+		return ok && level >= required
+	})
+}
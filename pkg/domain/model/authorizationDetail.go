@@ -0,0 +1,155 @@
+package model
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+)
+
+// AuthorizationDetail is an element of the authorization_details parameter.
+// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-2
+type AuthorizationDetail map[string]any
+
+func (d AuthorizationDetail) Type() string {
+	t, _ := d["type"].(string)
+	return t
+}
+
+// AuthorizationDetailType is a type of authorization details accepted by the authorization server.
+type AuthorizationDetailType struct {
+	Type        string
+	Description string // shown on the consent screen
+	Schema      *Schema
+}
+
+func (t *AuthorizationDetailType) Validate(detail AuthorizationDetail) error {
+	if detail.Type() != t.Type {
+		return fmt.Errorf("type must be %q", t.Type)
+	}
+	if t.Schema == nil {
+		return nil
+	}
+	return t.Schema.Validate(map[string]any(detail))
+}
+
+// Schema is a subset of JSON Schema used to validate authorization details.
+// ref: https://json-schema.org/draft/2020-12/json-schema-validation
+type Schema struct {
+	Type                 string             `json:"type,omitempty"` // object, array, string, number, integer or boolean
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+}
+
+// Validate checks v decoded by encoding/json against the schema.
+func (s *Schema) Validate(v any) error {
+	return s.validate("$", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		return fmt.Errorf("%s must be one of %v", path, s.Enum)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		return s.validateObject(path, obj)
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			return fmt.Errorf("%s must have at least %d items", path, *s.MinItems)
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		return s.validateString(path, str)
+	case "number", "integer":
+		num, ok := v.(float64)
+		if !ok || (s.Type == "integer" && num != float64(int64(num))) {
+			return fmt.Errorf("%s must be a %s", path, s.Type)
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			return fmt.Errorf("%s must be >= %v", path, *s.Minimum)
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			return fmt.Errorf("%s must be <= %v", path, *s.Maximum)
+		}
+		return nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, s.Type)
+	}
+}
+
+func (s *Schema) validateObject(path string, obj map[string]any) error {
+	for _, key := range s.Required {
+		if _, ok := obj[key]; !ok {
+			return fmt.Errorf("%s.%s is required", path, key)
+		}
+	}
+	for key, value := range obj {
+		prop, ok := s.Properties[key]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return fmt.Errorf("%s.%s is not allowed", path, key)
+			}
+			continue
+		}
+		if err := prop.validate(path+"."+key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateString(path, str string) error {
+	if s.MinLength != nil && len(str) < *s.MinLength {
+		return fmt.Errorf("%s must be at least %d characters", path, *s.MinLength)
+	}
+	if s.MaxLength != nil && len(str) > *s.MaxLength {
+		return fmt.Errorf("%s must be at most %d characters", path, *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", path, err)
+		}
+		if !re.MatchString(str) {
+			return fmt.Errorf("%s must match %s", path, s.Pattern)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BackchannelTokenDeliveryMode is how the client receives the result of a backchannel authentication.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.5
+type BackchannelTokenDeliveryMode string
+
+const (
+	BackchannelTokenDeliveryModePoll BackchannelTokenDeliveryMode = "poll"
+	BackchannelTokenDeliveryModePing BackchannelTokenDeliveryMode = "ping"
+	BackchannelTokenDeliveryModePush BackchannelTokenDeliveryMode = "push"
+)
+
+func (m BackchannelTokenDeliveryMode) IsValid() bool {
+	switch m {
+	case BackchannelTokenDeliveryModePoll, BackchannelTokenDeliveryModePing, BackchannelTokenDeliveryModePush:
+		return true
+	default:
+		return false
+	}
+}
+
+type BackchannelAuthStatus string
+
+const (
+	BackchannelAuthStatusPending  BackchannelAuthStatus = "pending"
+	BackchannelAuthStatusApproved BackchannelAuthStatus = "approved"
+	BackchannelAuthStatusDenied   BackchannelAuthStatus = "denied"
+	BackchannelAuthStatusIssued   BackchannelAuthStatus = "issued" // tokens are issued, so auth_req_id is no longer valid
+)
+
+const (
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.3
+	BackchannelAuthRequestLifetime    = 2 * time.Minute
+	BackchannelAuthRequestMaxLifetime = 10 * time.Minute
+	BackchannelPollingInterval        = 5 * time.Second
+)
+
+// BackchannelAuthRequest is an authentication request which the client starts without redirecting the user,
+// who approves it on the authentication device.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.1
+type BackchannelAuthRequest struct {
+	AuthReqID      string
+	ClientID       string
+	Scope          string
+	ACRValues      []string
+	BindingMessage string
+	// ClientNotificationToken authenticates the authorization server at the notification endpoint of the client in ping and push modes.
+	ClientNotificationToken string
+	RequestedExpiry         int64 // seconds, 0 if not requested
+
+	// hints to identify the user, exactly one of them is required.
+	LoginHint      string
+	IDTokenHint    string
+	LoginHintToken string
+
+	// set when the request is accepted.
+	Subject      string // the user identified by the hint
+	DeliveryMode BackchannelTokenDeliveryMode
+	ExpiresAt    time.Time
+	Interval     time.Duration // the minimum polling interval
+	LastPolledAt time.Time
+	Status       BackchannelAuthStatus
+
+	// Authentication is set when the user approves the request.
+	Authentication *Authentication
+}
+
+// Accept starts the request for the subject with the delivery mode of the client.
+func (r *BackchannelAuthRequest) Accept(subject string, mode BackchannelTokenDeliveryMode) {
+	lifetime := BackchannelAuthRequestLifetime
+	if r.RequestedExpiry > 0 {
+		lifetime = min(time.Duration(r.RequestedExpiry)*time.Second, BackchannelAuthRequestMaxLifetime)
+	}
+
+	r.AuthReqID = uuid.NewString()
+	r.Subject = subject
+	r.DeliveryMode = mode
+	r.ExpiresAt = time.Now().Add(lifetime)
+	r.Interval = BackchannelPollingInterval
+	r.Status = BackchannelAuthStatusPending
+}
+
+// ExpiresIn returns the lifetime of auth_req_id in seconds.
+func (r *BackchannelAuthRequest) ExpiresIn() int64 {
+	return int64(time.Until(r.ExpiresAt).Seconds())
+}
+
+func (r *BackchannelAuthRequest) IsExpired() bool {
+	return !time.Now().Before(r.ExpiresAt)
+}
+
+func (r *BackchannelAuthRequest) Grant() *Grant {
+	return &Grant{
+		ClientID:       r.ClientID,
+		Scope:          r.Scope,
+		Authentication: r.Authentication,
+	}
+}
@@ -21,6 +21,16 @@ type Client interface {
 	GetRedirectURIs() []string
 	IsPublic() bool
 	IsValidRedirectURI(string) bool
+	RequiresPushedAuthRequest() bool
+	GetJWKS() *JWKSet
+	GetRequestObjectSigningAlg() string
+	GetRequestURIs() []string
+	GetDefaultResponseMode() ResponseMode
+	GetBackchannelTokenDeliveryMode() BackchannelTokenDeliveryMode
+	GetBackchannelClientNotificationEndpoint() string
+	IsValidPostLogoutRedirectURI(string) bool
+	GetBackchannelLogoutURI() string
+	GetFrontchannelLogoutURI() string
 }
 
 type ConfidentialClient struct {
@@ -28,6 +38,101 @@ type ConfidentialClient struct {
 	id           string
 	secretHash   string
 	redirectURIs []string
+	requirePAR   bool
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc7591#section-2
+	jwks                    *JWKSet
+	requestObjectSigningAlg string
+	requestURIs             []string
+	defaultResponseMode     ResponseMode
+
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.4
+	backchannelTokenDeliveryMode          BackchannelTokenDeliveryMode
+	backchannelClientNotificationEndpoint string
+
+	// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#ClientMetadata
+	postLogoutRedirectURIs []string
+
+	// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRegistration
+	backchannelLogoutURI string
+	// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+	frontchannelLogoutURI string
+}
+
+// ClientOption configures optional settings of a client.
+type ClientOption func(*ConfidentialClient)
+
+// WithPushedAuthRequestRequired makes the client send authorization requests only via PAR.
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-6
+func WithPushedAuthRequestRequired() ClientOption {
+	return func(c *ConfidentialClient) {
+		c.requirePAR = true
+	}
+}
+
+// WithJWKS registers the public keys of the client, e.g. to verify its request objects.
+func WithJWKS(jwks *JWKSet) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.jwks = jwks
+	}
+}
+
+// WithRequestObjectSigningAlg restricts the algorithm of the request objects of the client.
+// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-10.5
+func WithRequestObjectSigningAlg(alg string) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.requestObjectSigningAlg = alg
+	}
+}
+
+// WithRequestURIs registers the URIs from which the authorization server fetches request objects of the client.
+// ref: https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+func WithRequestURIs(uris ...string) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.requestURIs = uris
+	}
+}
+
+// WithDefaultResponseMode sets the response mode used when the client does not request one.
+// ref: https://openid.net/specs/oauth-v2-jarm.html#section-3
+func WithDefaultResponseMode(mode ResponseMode) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.defaultResponseMode = mode
+	}
+}
+
+// WithBackchannelTokenDelivery allows the client to start backchannel authentication with the delivery mode.
+// The notification endpoint is required for ping and push modes.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.4
+func WithBackchannelTokenDelivery(mode BackchannelTokenDeliveryMode, notificationEndpoint string) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.backchannelTokenDeliveryMode = mode
+		c.backchannelClientNotificationEndpoint = notificationEndpoint
+	}
+}
+
+// WithPostLogoutRedirectURIs registers the URIs to which the user is redirected after logout.
+// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#ClientMetadata
+func WithPostLogoutRedirectURIs(uris ...string) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.postLogoutRedirectURIs = uris
+	}
+}
+
+// WithBackchannelLogoutURI registers the URI to which the logout token is posted when the user logs out.
+// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRegistration
+func WithBackchannelLogoutURI(uri string) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.backchannelLogoutURI = uri
+	}
+}
+
+// WithFrontchannelLogoutURI registers the URI which the user agent renders in an iframe when the user logs out.
+// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+func WithFrontchannelLogoutURI(uri string) ClientOption {
+	return func(c *ConfidentialClient) {
+		c.frontchannelLogoutURI = uri
+	}
 }
 
 func NewConfidentialClient(
@@ -35,13 +140,18 @@ func NewConfidentialClient(
 	id string,
 	secret string,
 	redirectURIs []string,
+	opts ...ClientOption,
 ) *ConfidentialClient {
-	return &ConfidentialClient{
+	c := &ConfidentialClient{
 		authMethod:   authMethod,
 		id:           id,
 		secretHash:   secret,
 		redirectURIs: redirectURIs,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *ConfidentialClient) GetID() string {
@@ -68,6 +178,46 @@ func (c *ConfidentialClient) IsValidRedirectURI(uri string) bool {
 	return slices.Contains(c.redirectURIs, uri)
 }
 
+func (c *ConfidentialClient) RequiresPushedAuthRequest() bool {
+	return c.requirePAR
+}
+
+func (c *ConfidentialClient) GetJWKS() *JWKSet {
+	return c.jwks
+}
+
+func (c *ConfidentialClient) GetRequestObjectSigningAlg() string {
+	return c.requestObjectSigningAlg
+}
+
+func (c *ConfidentialClient) GetRequestURIs() []string {
+	return c.requestURIs
+}
+
+func (c *ConfidentialClient) GetDefaultResponseMode() ResponseMode {
+	return c.defaultResponseMode
+}
+
+func (c *ConfidentialClient) GetBackchannelTokenDeliveryMode() BackchannelTokenDeliveryMode {
+	return c.backchannelTokenDeliveryMode
+}
+
+func (c *ConfidentialClient) GetBackchannelClientNotificationEndpoint() string {
+	return c.backchannelClientNotificationEndpoint
+}
+
+func (c *ConfidentialClient) IsValidPostLogoutRedirectURI(uri string) bool {
+	return slices.Contains(c.postLogoutRedirectURIs, uri)
+}
+
+func (c *ConfidentialClient) GetBackchannelLogoutURI() string {
+	return c.backchannelLogoutURI
+}
+
+func (c *ConfidentialClient) GetFrontchannelLogoutURI() string {
+	return c.frontchannelLogoutURI
+}
+
 func (c *ConfidentialClient) GetLoginURL(authReqID string) string {
 	return "login?id=" + authReqID
 }
@@ -0,0 +1,16 @@
+package model
+
+// Grant is what the resource owner authorized the client to access.
+type Grant struct {
+	ClientID  string
+	Scope     string   // space-delimited
+	Resources []string // ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-2
+	AuthorizationDetails []AuthorizationDetail
+
+	Authentication *Authentication
+
+	// Actor acts on behalf of the subject of Authentication, ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+	Actor *Actor
+}
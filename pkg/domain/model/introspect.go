@@ -7,6 +7,15 @@ const (
 	TokenTypeRefreshToken TokenType = "refresh_token"
 )
 
+// ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.1
+type IntrospectRequest struct {
+	Token         string
+	TokenTypeHint TokenType
+	// the caller is a protected resource or a client
+	CallerID     string
+	CallerSecret string
+}
+
 // ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
 type Introspect struct {
 	Active    bool      // required
@@ -18,6 +27,13 @@ type Introspect struct {
 	Iat       int64     // optional, issued at
 	Nbf       int64     // optional, not to be used before
 	Sub       string    // optional, subject of the token
-	Aud       string    // optional, audience
+	Aud       []string  // optional, audience
+	Iss       string    // optional, issuer
 	Jti       string    // optional, JWT ID
+	AuthTime  int64     // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6.2
+	ACR       string    // optional, ref: https://datatracker.ietf.org/doc/html/rfc9470#section-6.2
+	AMR       []string  // optional, ref: https://datatracker.ietf.org/doc/html/rfc8176#section-1
+
+	AuthorizationDetails []AuthorizationDetail // optional, ref: https://datatracker.ietf.org/doc/html/rfc9396#section-9.2
+	Act                  *Actor                // optional, ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
 }
@@ -0,0 +1,20 @@
+package model
+
+// JWK is a public JSON Web Key.
+// ref: https://datatracker.ietf.org/doc/html/rfc7517#section-4
+type JWK struct {
+	KeyType   string `json:"kty"`           // required, RSA, EC or OKP
+	KeyID     string `json:"kid,omitempty"` // optional
+	Use       string `json:"use,omitempty"` // optional, sig or enc
+	Algorithm string `json:"alg,omitempty"` // optional
+	Curve     string `json:"crv,omitempty"` // EC and OKP, ref: https://datatracker.ietf.org/doc/html/rfc7518#section-6.2.1.1
+	N         string `json:"n,omitempty"`   // RSA modulus, ref: https://datatracker.ietf.org/doc/html/rfc7518#section-6.3.1
+	E         string `json:"e,omitempty"`   // RSA exponent
+	X         string `json:"x,omitempty"`   // EC and OKP
+	Y         string `json:"y,omitempty"`   // EC
+}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc7517#section-5
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,63 @@
+package model
+
+import "time"
+
+// KeyState is the stage of the lifecycle of a signing key of the authorization server.
+type KeyState string
+
+const (
+	// KeyStateNext is published in JWKS before it signs, so that the clients caching JWKS know it on the rotation.
+	KeyStateNext KeyState = "next"
+	// KeyStateActive signs the JWTs. There is one active key at most.
+	KeyStateActive KeyState = "active"
+	// KeyStateRetired signs no more, and is published until the JWTs signed by it expire.
+	KeyStateRetired KeyState = "retired"
+	// KeyStateRevoked is unpublished at once, so that the JWTs signed by it are rejected, e.g. when it is leaked.
+	KeyStateRevoked KeyState = "revoked"
+)
+
+// KeyRecord is a signing key of the authorization server kept in the storage.
+type KeyRecord struct {
+	ID        string   `json:"kid"`
+	Use       string   `json:"use"`
+	Algorithm string   `json:"alg"`
+	State     KeyState `json:"state"`
+
+	// EncryptedKey is the private key in PKCS #8, which is sealed with the key-encryption key so that it is not stored in plaintext.
+	EncryptedKey []byte `json:"encrypted_key,omitempty"`
+
+	CreatedAt   time.Time `json:"created_at"`
+	ActivatedAt time.Time `json:"activated_at"`
+	RetiredAt   time.Time `json:"retired_at"`
+	// ExpiresAt is when the retired or revoked key is deleted.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// IsPublished reports whether the public key is in JWKS, with which the JWTs signed by the key are verified.
+func (k *KeyRecord) IsPublished() bool {
+	return k.State == KeyStateNext || k.State == KeyStateActive || k.State == KeyStateRetired
+}
+
+func (k *KeyRecord) IsExpired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// KeyRotationPolicy is the schedule on which the signing keys are rotated.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#RotateSigKeys
+type KeyRotationPolicy struct {
+	// Algorithm is the algorithm of the keys generated from now on, e.g. RS256, PS256, ES256 or EdDSA.
+	Algorithm string
+	// RotationPeriod is how long a key signs before the next key replaces it.
+	RotationPeriod time.Duration
+	// PublishBefore is how long the next key is published at least before it signs, which should be longer than the caches of JWKS.
+	PublishBefore time.Duration
+	// RetireAfter is how long the retired keys are published, which must be longer than the maximum lifetime of the signed JWTs.
+	RetireAfter time.Duration
+}
+
+var DefaultKeyRotationPolicy = KeyRotationPolicy{
+	Algorithm:      "RS256",
+	RotationPeriod: 30 * 24 * time.Hour,
+	PublishBefore:  24 * time.Hour,
+	RetireAfter:    24 * time.Hour,
+}
@@ -0,0 +1,133 @@
+package model
+
+import "time"
+
+// FailureKind is what the failed attempts are counted by.
+type FailureKind string
+
+const (
+	FailureKindUser   FailureKind = "user"   // the username of login attempts
+	FailureKindClient FailureKind = "client" // the client_id of client authentication
+	FailureKindIP     FailureKind = "ip"     // the address where the attempts come from
+	FailureKindOTP    FailureKind = "otp"    // the user ID of one-time password attempts
+	// FailureKindSecondFactorToken is the digest of the token with which the user proves the second factor.
+	FailureKindSecondFactorToken FailureKind = "second_factor_token"
+)
+
+type FailureKey struct {
+	Kind FailureKind `json:"kind"`
+	Key  string      `json:"key"`
+}
+
+func (k FailureKey) String() string {
+	return string(k.Kind) + ":" + k.Key
+}
+
+// FailureCounter counts the consecutive failed attempts of a user, a client or an address.
+type FailureCounter struct {
+	FailureKey
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+	// ExpiresAt is when the counter is forgotten, as the failures are reset and the lockout is over by then.
+	// The counter is kept until it is deleted if it is zero.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (c *FailureCounter) IsLocked(now time.Time) bool {
+	return now.Before(c.LockedUntil)
+}
+
+func (c *FailureCounter) IsExpired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// LockoutPolicy slows down guessing passwords and client secrets.
+// The attempts after FreeAttempts failures wait for BaseDelay doubled on each failure up to MaxDelay,
+// and LockoutThreshold failures lock the attempts out for LockoutDuration.
+// ref: https://pages.nist.gov/800-63-3/sp800-63b.html#throttle
+type LockoutPolicy struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int // no lockout if it is 0
+	LockoutDuration  time.Duration
+	// ResetAfter forgets the failures when no attempts have failed for it.
+	ResetAfter time.Duration
+}
+
+var DefaultLockoutPolicy = LockoutPolicy{
+	FreeAttempts:     3,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 10,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+// DefaultIPLockoutPolicy tolerates more failures, as many users may share an address.
+var DefaultIPLockoutPolicy = LockoutPolicy{
+	FreeAttempts:     10,
+	BaseDelay:        time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 100,
+	LockoutDuration:  15 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+// DefaultSecondFactorTokenPolicy invalidates the token with which the user proves the second factor after a few wrong codes.
+// The lockout outlasts the token, so the user has to log in with the password again.
+var DefaultSecondFactorTokenPolicy = LockoutPolicy{
+	FreeAttempts:     5,
+	LockoutThreshold: 5,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+}
+
+// RetryAfter returns how long the next attempt has to wait, which is 0 if it may be tried now.
+func (p LockoutPolicy) RetryAfter(c *FailureCounter, now time.Time) time.Duration {
+	if c.IsLocked(now) {
+		return c.LockedUntil.Sub(now)
+	}
+	if p.isReset(c, now) || c.Failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < c.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	if next := c.LastFailureAt.Add(delay); next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// RecordFailure counts the failed attempt, and reports whether it locks the attempts out.
+// The failures are forgotten on the lockout, so that the attempts after it start with the backoff again.
+func (p LockoutPolicy) RecordFailure(c *FailureCounter, now time.Time) bool {
+	if p.isReset(c, now) {
+		c.Failures = 0
+	}
+	c.Failures++
+	c.LastFailureAt = now
+
+	locked := p.LockoutThreshold > 0 && c.Failures >= p.LockoutThreshold
+	if locked {
+		c.Failures = 0
+		c.LockedUntil = now.Add(p.LockoutDuration)
+	}
+	c.ExpiresAt = time.Time{}
+	if p.ResetAfter > 0 {
+		c.ExpiresAt = now.Add(p.ResetAfter)
+		if c.LockedUntil.After(c.ExpiresAt) {
+			c.ExpiresAt = c.LockedUntil
+		}
+	}
+	return locked
+}
+
+func (p LockoutPolicy) isReset(c *FailureCounter, now time.Time) bool {
+	return p.ResetAfter > 0 && now.Sub(c.LastFailureAt) >= p.ResetAfter
+}
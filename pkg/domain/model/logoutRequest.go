@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const LogoutRequestLifetime = 5 * time.Minute
+
+// LogoutRequest is the request of the relying party to log the user out, which is kept
+// while the authentication server ends the session.
+// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+type LogoutRequest struct {
+	ID                    string
+	IDTokenHint           string
+	ClientID              string
+	PostLogoutRedirectURI string
+	State                 string
+	ExpiresAt             time.Time
+
+	// set from the verified id_token_hint.
+	Subject   string
+	SessionID string
+}
+
+// Accept starts the logout request.
+func (r *LogoutRequest) Accept() {
+	r.ID = uuid.NewString()
+	r.ExpiresAt = time.Now().Add(LogoutRequestLifetime)
+}
+
+func (r *LogoutRequest) IsExpired() bool {
+	return !time.Now().Before(r.ExpiresAt)
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-2.2
+const RequestURIPrefix = "urn:ietf:params:oauth:request_uri:"
+
+// PushedAuthRequest is an authorization request pushed to the authorization server by the client.
+// ref: https://datatracker.ietf.org/doc/html/rfc9126
+type PushedAuthRequest struct {
+	RequestURI  string
+	ExpiresAt   int64
+	AuthRequest *AuthRequest
+}
+
+func NewPushedAuthRequest(req *AuthRequest) *PushedAuthRequest {
+	if req == nil {
+		return nil
+	}
+
+	return &PushedAuthRequest{
+		RequestURI:  RequestURIPrefix + uuid.NewString(),
+		ExpiresAt:   time.Now().Add(time.Minute).Unix(),
+		AuthRequest: req,
+	}
+}
+
+// ExpiresIn returns the lifetime of the request_uri in seconds.
+func (r *PushedAuthRequest) ExpiresIn() int64 {
+	return r.ExpiresAt - time.Now().Unix()
+}
@@ -0,0 +1,12 @@
+package model
+
+// RateLimit is the token bucket which is refilled with Rate tokens per second up to Burst.
+// Each request takes a token, and no requests are limited if Rate is 0.
+type RateLimit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func (l RateLimit) IsUnlimited() bool {
+	return l.Rate <= 0
+}
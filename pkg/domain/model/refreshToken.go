@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultRefreshTokenLifetime = 24 * time.Hour
+
+type RefreshToken struct {
+	RefreshToken string
+	ExpiresAt    int64
+	Grant        *Grant
+}
+
+// NewRefreshToken issues a token for grant, which expires after lifetime.
+func NewRefreshToken(grant *Grant, lifetime time.Duration) *RefreshToken {
+	if grant == nil {
+		return nil
+	}
+
+	return &RefreshToken{
+		RefreshToken: uuid.NewString(),
+		ExpiresAt:    time.Now().Add(lifetime).Unix(),
+		Grant:        grant,
+	}
+}
@@ -0,0 +1,16 @@
+package model
+
+import "slices"
+
+// ProtectedResource is a resource server registered at the authorization server.
+// ref: https://datatracker.ietf.org/doc/html/rfc8707
+type ProtectedResource struct {
+	URI        string   // resource indicator, an absolute URI without a fragment
+	Scopes     []string // scopes accepted by the resource
+	ID         string   // used to authenticate at the introspection endpoint
+	SecretHash string   // should be hashed with sha256
+}
+
+func (r *ProtectedResource) AllowsScope(scope string) bool {
+	return slices.Contains(r.Scopes, scope)
+}
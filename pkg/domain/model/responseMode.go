@@ -0,0 +1,39 @@
+package model
+
+import "strings"
+
+// ResponseMode is how the authorization server returns the parameters of the authorization response.
+// ref: https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+type ResponseMode string
+
+const (
+	ResponseModeQuery    ResponseMode = "query"
+	ResponseModeFragment ResponseMode = "fragment"
+	ResponseModeFormPost ResponseMode = "form_post" // ref: https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html
+
+	// ref: https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+	ResponseModeQueryJWT    ResponseMode = "query.jwt"
+	ResponseModeFragmentJWT ResponseMode = "fragment.jwt"
+	ResponseModeFormPostJWT ResponseMode = "form_post.jwt"
+	ResponseModeJWT         ResponseMode = "jwt"
+)
+
+func (m ResponseMode) IsValid() bool {
+	switch m {
+	case ResponseModeQuery, ResponseModeFragment, ResponseModeFormPost,
+		ResponseModeQueryJWT, ResponseModeFragmentJWT, ResponseModeFormPostJWT, ResponseModeJWT:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsJWT reports whether the response parameters are wrapped in a JWT.
+func (m ResponseMode) IsJWT() bool {
+	return m == ResponseModeJWT || strings.HasSuffix(string(m), ".jwt")
+}
+
+// Base returns how the response is delivered, e.g. query for query.jwt.
+func (m ResponseMode) Base() ResponseMode {
+	return ResponseMode(strings.TrimSuffix(string(m), ".jwt"))
+}
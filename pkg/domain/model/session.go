@@ -0,0 +1,66 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionLifetime is how long the user stays logged in at the authentication server.
+const SessionLifetime = 24 * time.Hour
+
+// Session is the single sign-on session of the user at the authentication server.
+// ref: https://openid.net/specs/openid-connect-session-1_0.html
+type Session struct {
+	ID             string
+	ExpiresAt      time.Time
+	Authentication *Authentication // SessionID is the ID of the session
+}
+
+func NewSession(authn *Authentication) *Session {
+	if authn == nil {
+		return nil
+	}
+
+	id := uuid.NewString()
+	a := *authn
+	a.SessionID = id
+	return &Session{
+		ID:             id,
+		ExpiresAt:      time.Now().Add(SessionLifetime),
+		Authentication: &a,
+	}
+}
+
+func (s *Session) IsExpired() bool {
+	return !time.Now().Before(s.ExpiresAt)
+}
+
+// Satisfies reports whether the user need not log in again for the authorization request with acrValues and maxAge.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (s *Session) Satisfies(acrValues []string, maxAge *int64) bool {
+	if s.IsExpired() {
+		return false
+	}
+	if maxAge != nil && time.Since(s.Authentication.AuthTime) > time.Duration(*maxAge)*time.Second {
+		return false
+	}
+	return s.Authentication.SatisfiesACR(acrValues)
+}
+
+// BrowserState returns the OP browser state of the session, which the check session iframe reads from the cookie.
+// ref: https://openid.net/specs/openid-connect-session-1_0.html#OPiframe
+func BrowserState(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
+// SessionState returns the session_state of the authorization response, with which the relying party
+// asks the check session iframe whether the session has changed.
+// ref: https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions
+func SessionState(clientID, origin, browserState, salt string) string {
+	sum := sha256.Sum256([]byte(clientID + " " + origin + " " + browserState + " " + salt))
+	return hex.EncodeToString(sum[:]) + "." + salt
+}
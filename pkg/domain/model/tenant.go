@@ -0,0 +1,57 @@
+package model
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+)
+
+// TenantPathPrefix is the path under which the tenants without their own hosts are served.
+const TenantPathPrefix = "/t/"
+
+var (
+	ErrTenantInvalid = errors.New("tenant is invalid")
+
+	// tenant IDs are used in the paths of the issuers as they are.
+	tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+)
+
+// Tenant is a brand hosted on the deployment. Each tenant is its own issuer, and has its own clients, users, keys and tokens.
+type Tenant struct {
+	ID string `json:"id"`
+	// Host serves the tenant at its own host name. The tenant is served at /t/{ID} of the servers if it is empty.
+	Host string `json:"host"`
+}
+
+func (t *Tenant) Validate() error {
+	if !tenantIDPattern.MatchString(t.ID) {
+		return errors.Join(ErrTenantInvalid, errors.New("id must consist of lowercase letters, digits and hyphens"))
+	}
+	return nil
+}
+
+// BasePath returns the path at which the servers of the tenant are mounted.
+func (t *Tenant) BasePath() string {
+	if t.Host != "" {
+		return ""
+	}
+	return TenantPathPrefix + t.ID
+}
+
+// BaseURL returns the URL of the tenant on the server whose URL is base, with the port of base.
+func (t *Tenant) BaseURL(base string) (string, error) {
+	if t.Host == "" {
+		return base + t.BasePath(), nil
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(t.Host, port)
+	} else {
+		u.Host = t.Host
+	}
+	return u.String(), nil
+}
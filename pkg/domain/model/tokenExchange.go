@@ -0,0 +1,18 @@
+package model
+
+// TokenTypeURI identifies the type of a token in token exchange.
+// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-3
+type TokenTypeURI string
+
+const (
+	TokenTypeURIAccessToken  TokenTypeURI = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeURIRefreshToken TokenTypeURI = "urn:ietf:params:oauth:token-type:refresh_token"
+)
+
+// Actor is the party acting on behalf of the subject. The prior actors of a delegation chain are nested.
+// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import "slices"
+
+// TrustedIssuer is an issuer of JWT assertions which the authorization server accepts as authorization grants.
+// ref: https://datatracker.ietf.org/doc/html/rfc7523#section-3
+type TrustedIssuer struct {
+	Issuer string  `json:"issuer"` // iss of the assertions
+	JWKS   *JWKSet `json:"jwks"`   // keys verifying the assertions
+	// Subjects maps sub of the assertions to the IDs of local users. Assertions for the other subjects are rejected.
+	Subjects map[string]string `json:"subjects"`
+	// ClientIDs are the clients which may present the assertions.
+	ClientIDs []string `json:"client_ids"`
+	// Scopes are the scopes which the assertions may grant.
+	Scopes []string `json:"scopes"`
+}
+
+// LocalSubject returns the ID of the local user who the subject of an assertion is.
+func (i *TrustedIssuer) LocalSubject(sub string) (string, bool) {
+	userID, ok := i.Subjects[sub]
+	return userID, ok
+}
+
+func (i *TrustedIssuer) AllowsClient(clientID string) bool {
+	return slices.Contains(i.ClientIDs, clientID)
+}
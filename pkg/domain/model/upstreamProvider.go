@@ -0,0 +1,134 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"slices"
+	"time"
+)
+
+const (
+	// UpstreamLoginLifetime is how long the user may take to log in at the upstream provider.
+	UpstreamLoginLifetime  = 10 * time.Minute
+	upstreamLoginStateSize = 32
+)
+
+// default claims of the ID tokens to which the local users are mapped.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+const (
+	defaultUpstreamUsernameClaim = "preferred_username"
+	upstreamEmailClaim           = "email"
+)
+
+// UpstreamProvider is an OpenID provider with which users log in to the authentication server instead of the local passwords.
+// The authentication server is its relying party with the authorization code flow and PKCE.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth
+type UpstreamProvider struct {
+	ID     string `json:"id"`   // identifies the provider in the URLs
+	Name   string `json:"name"` // shown on the login page
+	Issuer string `json:"issuer"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Scopes are requested in addition to openid.
+	Scopes []string `json:"scopes"`
+
+	// the endpoints are discovered from Issuer if they are empty.
+	// ref: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfig
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	// UsernameClaim is the claim of the ID tokens which is the username of the local user, preferred_username by default.
+	// email is accepted only if email_verified is true.
+	UsernameClaim string `json:"username_claim"`
+	// LinkExistingUsers links the upstream accounts to the local users of the same username on their first login.
+	// The other users have to log in with the local accounts to link them.
+	LinkExistingUsers bool `json:"link_existing_users"`
+	// JITProvisioning creates the local users of the upstream accounts on their first login.
+	JITProvisioning bool `json:"jit_provisioning"`
+	// TrustMultiFactor accepts the multi-factor authentication at the provider as mfa of the local users.
+	TrustMultiFactor bool `json:"trust_multi_factor"`
+}
+
+func (p *UpstreamProvider) GetUsernameClaim() string {
+	if p.UsernameClaim == "" {
+		return defaultUpstreamUsernameClaim
+	}
+	return p.UsernameClaim
+}
+
+// RequiresVerifiedEmail reports whether the username claim is trusted only with email_verified.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#ClaimStability
+func (p *UpstreamProvider) RequiresVerifiedEmail() bool {
+	return p.GetUsernameClaim() == upstreamEmailClaim
+}
+
+// GetScopes returns the scopes requested to the provider, which always include openid.
+func (p *UpstreamProvider) GetScopes() []string {
+	if slices.Contains(p.Scopes, "openid") {
+		return p.Scopes
+	}
+	return append([]string{"openid"}, p.Scopes...)
+}
+
+// UpstreamLoginRequest is the login which the authentication server has started at the upstream provider.
+// It is consumed on the callback so that the state is not replayed.
+type UpstreamLoginRequest struct {
+	State        string // ID of the request
+	ProviderID   string
+	Nonce        string
+	CodeVerifier string // ref: https://datatracker.ietf.org/doc/html/rfc7636#section-4.1
+	ExpiresAt    time.Time
+
+	UpstreamLoginParams
+}
+
+// UpstreamLoginParams are taken over from the login page to the callback of the upstream provider.
+type UpstreamLoginParams struct {
+	// the parameters of the authorization request which the login continues.
+	AuthReqID string
+	ACRValues string
+	MaxAge    string
+	Device    bool
+
+	// LinkSubject is the logged-in user to whom the upstream account is linked instead of logging in.
+	LinkSubject string
+}
+
+func NewUpstreamLoginRequest(providerID string, params UpstreamLoginParams) (*UpstreamLoginRequest, error) {
+	state, err := randomUpstreamLoginValue()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomUpstreamLoginValue()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomUpstreamLoginValue()
+	if err != nil {
+		return nil, err
+	}
+	return &UpstreamLoginRequest{
+		State:        state,
+		ProviderID:   providerID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(UpstreamLoginLifetime),
+
+		UpstreamLoginParams: params,
+	}, nil
+}
+
+func (r *UpstreamLoginRequest) IsExpired() bool {
+	return !time.Now().Before(r.ExpiresAt)
+}
+
+// randomUpstreamLoginValue returns 43 characters in base64url, which is the minimum length of the code verifier.
+func randomUpstreamLoginValue() (string, error) {
+	b := make([]byte, upstreamLoginStateSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"bytes"
+	"time"
+)
+
+type User struct {
+	ID           string
+	Username     string
+	PasswordHash string // should be hashed with repository.Hasher
+
+	// TOTP is the second factor of the user, which is nil until the user enrolls it.
+	TOTP *TOTPCredential
+	// WebAuthnCredentials are the passkeys of the user, with which the user logs in without the password.
+	WebAuthnCredentials []*WebAuthnCredential
+	// FederatedIdentities are the accounts of the upstream providers with which the user logs in.
+	FederatedIdentities []*FederatedIdentity
+}
+
+// FederatedIdentity links the account of an upstream provider to the local user.
+// The account is identified by iss and sub, as sub is unique only in the issuer.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#ClaimStability
+type FederatedIdentity struct {
+	ProviderID string
+	Issuer     string
+	Subject    string
+	LinkedAt   time.Time
+}
+
+// TOTPCredential is the secret which the user shares with the authenticator.
+// ref: https://datatracker.ietf.org/doc/html/rfc6238
+type TOTPCredential struct {
+	Secret []byte
+	// Confirmed is set once the user proves that the authenticator has the secret.
+	Confirmed bool
+	// LastUsedStep rejects the codes which are already used.
+	// ref: https://datatracker.ietf.org/doc/html/rfc6238#section-5.2
+	LastUsedStep int64
+	// RecoveryCodeHashes are the hashes of the unused recovery codes, with which the user logs in without the authenticator.
+	RecoveryCodeHashes []string
+}
+
+// HasSecondFactor reports whether the user has to prove the second factor on login.
+func (u *User) HasSecondFactor() bool {
+	return u.TOTP != nil && u.TOTP.Confirmed
+}
+
+// WebAuthnCredential returns the registered credential with id.
+func (u *User) WebAuthnCredential(id []byte) (*WebAuthnCredential, bool) {
+	for _, c := range u.WebAuthnCredentials {
+		if bytes.Equal(c.ID, id) {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// FederatedIdentity returns the linked account of issuer with subject.
+func (u *User) FederatedIdentity(issuer, subject string) (*FederatedIdentity, bool) {
+	for _, v := range u.FederatedIdentities {
+		if v.Issuer == issuer && v.Subject == subject {
+			return v, true
+		}
+	}
+	return nil, false
+}
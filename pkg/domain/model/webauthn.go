@@ -0,0 +1,82 @@
+package model
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// WebAuthnCeremonyLifetime is how long the user may take to respond to the challenge with the authenticator.
+	WebAuthnCeremonyLifetime = 5 * time.Minute
+	webAuthnChallengeSize    = 32
+)
+
+// ref: https://www.w3.org/TR/webauthn-3/#ceremony
+type WebAuthnCeremonyType string
+
+const (
+	WebAuthnCeremonyRegistration   WebAuthnCeremonyType = "registration"
+	WebAuthnCeremonyAuthentication WebAuthnCeremonyType = "authentication"
+)
+
+// WebAuthnCredential is the public key credential which the authenticator of the user has registered.
+// ref: https://www.w3.org/TR/webauthn-3/#credential-record
+type WebAuthnCredential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key
+	// SignCount detects the cloned authenticators, as the authenticators increase it on each assertion.
+	// ref: https://www.w3.org/TR/webauthn-3/#sctn-sign-counter
+	SignCount         uint32
+	AttestationFormat string
+	AAGUID            []byte
+	CreatedAt         time.Time
+}
+
+// WebAuthnCeremony is the challenge which the authentication server issued for a ceremony.
+// It is consumed on the response so that the challenge is not replayed.
+type WebAuthnCeremony struct {
+	ID        string
+	Type      WebAuthnCeremonyType
+	Challenge []byte
+	// UserID is the user who registers the credential, and is empty on the authentication as the user is unknown until the assertion.
+	UserID    string
+	ExpiresAt time.Time
+}
+
+func NewWebAuthnCeremony(typ WebAuthnCeremonyType, userID string) (*WebAuthnCeremony, error) {
+	challenge := make([]byte, webAuthnChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return &WebAuthnCeremony{
+		ID:        uuid.NewString(),
+		Type:      typ,
+		Challenge: challenge,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(WebAuthnCeremonyLifetime),
+	}, nil
+}
+
+func (c *WebAuthnCeremony) IsExpired() bool {
+	return !time.Now().Before(c.ExpiresAt)
+}
+
+// WebAuthnAttestationResponse is the response of the authenticator to the registration ceremony.
+// ref: https://www.w3.org/TR/webauthn-3/#iface-authenticatorattestationresponse
+type WebAuthnAttestationResponse struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AttestationObject []byte
+}
+
+// WebAuthnAssertionResponse is the response of the authenticator to the authentication ceremony.
+// ref: https://www.w3.org/TR/webauthn-3/#iface-authenticatorassertionresponse
+type WebAuthnAssertionResponse struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+	UserHandle        []byte
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// ref: https://www.rfc-editor.org/rfc/rfc8949#section-3.1
+const (
+	cborUnsignedInt = 0
+	cborNegativeInt = 1
+	cborByteString  = 2
+	cborTextString  = 3
+	cborArray       = 4
+	cborMap         = 5
+	cborSimple      = 7
+
+	cborFalse = 20
+	cborTrue  = 21
+	cborNull  = 22
+
+	// cborMaxDepth limits the nesting of data items, which is shallow in WebAuthn.
+	cborMaxDepth = 16
+)
+
+var ErrCBORMalformed = errors.New("cbor is malformed")
+
+// DecodeCBOR decodes the CBOR data item at the beginning of b, and returns it with the rest of b.
+// Only the types used by WebAuthn are supported, which are decoded as below.
+//   - integers: int64
+//   - byte strings: []byte
+//   - text strings: string
+//   - arrays: []any
+//   - maps: map[any]any, whose keys are int64 or string
+//   - true, false and null: bool and nil
+//
+// ref: https://www.rfc-editor.org/rfc/rfc8949
+func DecodeCBOR(b []byte) (any, []byte, error) {
+	return decodeCBOR(b, 0)
+}
+
+func decodeCBOR(b []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("%w: too deep", ErrCBORMalformed)
+	}
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("%w: unexpected end", ErrCBORMalformed)
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	if major == cborSimple {
+		switch info {
+		case cborFalse:
+			return false, b, nil
+		case cborTrue:
+			return true, b, nil
+		case cborNull:
+			return nil, b, nil
+		default:
+			return nil, nil, fmt.Errorf("%w: unsupported simple value %d", ErrCBORMalformed, info)
+		}
+	}
+
+	arg, b, err := decodeCBORArgument(info, b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUnsignedInt:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrCBORMalformed)
+		}
+		return int64(arg), b, nil
+	case cborNegativeInt:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("%w: integer overflow", ErrCBORMalformed)
+		}
+		return -1 - int64(arg), b, nil
+	case cborByteString, cborTextString:
+		if arg > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", ErrCBORMalformed)
+		}
+		if major == cborTextString {
+			return string(b[:arg]), b[arg:], nil
+		}
+		return slices.Clone(b[:arg]), b[arg:], nil
+	case cborArray:
+		// each item takes one byte at least.
+		if arg > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", ErrCBORMalformed)
+		}
+		v := make([]any, 0, arg)
+		for range arg {
+			var item any
+			item, b, err = decodeCBOR(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			v = append(v, item)
+		}
+		return v, b, nil
+	case cborMap:
+		if arg > uint64(len(b)) {
+			return nil, nil, fmt.Errorf("%w: unexpected end", ErrCBORMalformed)
+		}
+		v := make(map[any]any, arg)
+		for range arg {
+			var key, value any
+			key, b, err = decodeCBOR(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("%w: unsupported map key %T", ErrCBORMalformed, key)
+			}
+			if _, ok := v[key]; ok {
+				return nil, nil, fmt.Errorf("%w: duplicated map key %v", ErrCBORMalformed, key)
+			}
+			value, b, err = decodeCBOR(b, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			v[key] = value
+		}
+		return v, b, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported major type %d", ErrCBORMalformed, major)
+	}
+}
+
+// ref: https://www.rfc-editor.org/rfc/rfc8949#section-3
+func decodeCBORArgument(info byte, b []byte) (uint64, []byte, error) {
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// indefinite lengths are not used in WebAuthn.
+		return 0, nil, fmt.Errorf("%w: unsupported additional information %d", ErrCBORMalformed, info)
+	}
+	if len(b) < size {
+		return 0, nil, fmt.Errorf("%w: unexpected end", ErrCBORMalformed)
+	}
+
+	var arg uint64
+	for _, c := range b[:size] {
+		arg = arg<<8 | uint64(c)
+	}
+	return arg, b[size:], nil
+}
+
+// EncodeCBOR encodes v with the types which DecodeCBOR supports. Integers may be of any size, and
+// map keys are sorted as the core deterministic encoding requires.
+// ref: https://www.rfc-editor.org/rfc/rfc8949#section-4.2.1
+func EncodeCBOR(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := encodeCBOR(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeCBOR(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | cborNull)
+	case bool:
+		if v {
+			buf.WriteByte(cborSimple<<5 | cborTrue)
+		} else {
+			buf.WriteByte(cborSimple<<5 | cborFalse)
+		}
+	case int:
+		encodeCBORInt(buf, int64(v))
+	case int64:
+		encodeCBORInt(buf, v)
+	case uint32:
+		encodeCBORArgument(buf, cborUnsignedInt, uint64(v))
+	case []byte:
+		encodeCBORArgument(buf, cborByteString, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		encodeCBORArgument(buf, cborTextString, uint64(len(v)))
+		buf.WriteString(v)
+	case []any:
+		encodeCBORArgument(buf, cborArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		entries := make([][2][]byte, 0, len(v))
+		for key, value := range v {
+			k, err := EncodeCBOR(key)
+			if err != nil {
+				return err
+			}
+			val, err := EncodeCBOR(value)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, [2][]byte{k, val})
+		}
+		slices.SortFunc(entries, func(a, b [2][]byte) int { return bytes.Compare(a[0], b[0]) })
+
+		encodeCBORArgument(buf, cborMap, uint64(len(v)))
+		for _, entry := range entries {
+			buf.Write(entry[0])
+			buf.Write(entry[1])
+		}
+	default:
+		return fmt.Errorf("cbor: unsupported type %T", v)
+	}
+	return nil
+}
+
+func encodeCBORInt(buf *bytes.Buffer, v int64) {
+	if v < 0 {
+		encodeCBORArgument(buf, cborNegativeInt, uint64(-1-v))
+		return
+	}
+	encodeCBORArgument(buf, cborUnsignedInt, uint64(v))
+}
+
+func encodeCBORArgument(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
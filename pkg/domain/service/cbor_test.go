@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	t.Parallel()
+
+	// the examples of the encoded data items, ref: https://www.rfc-editor.org/rfc/rfc8949#appendix-A
+	tests := map[string]struct {
+		in      string
+		want    any
+		wantErr error
+	}{
+		"ok: 0":               {in: "00", want: int64(0)},
+		"ok: 1000000":         {in: "1a000f4240", want: int64(1000000)},
+		"ok: -1000":           {in: "3903e7", want: int64(-1000)},
+		"ok: false":           {in: "f4", want: false},
+		"ok: null":            {in: "f6", want: nil},
+		"ok: byte string":     {in: "4401020304", want: []byte{1, 2, 3, 4}},
+		"ok: text string":     {in: "6449455446", want: "IETF"},
+		"ok: array":           {in: "8301820203820405", want: []any{int64(1), []any{int64(2), int64(3)}, []any{int64(4), int64(5)}}},
+		"ok: map":             {in: "a201020304", want: map[any]any{int64(1): int64(2), int64(3): int64(4)}},
+		"ok: map of strings":  {in: "a26161016162820203", want: map[any]any{"a": int64(1), "b": []any{int64(2), int64(3)}}},
+		"ng: empty":           {in: "", wantErr: ErrCBORMalformed},
+		"ng: truncated":       {in: "64494554", wantErr: ErrCBORMalformed},
+		"ng: indefinite":      {in: "5f42010243030405ff", wantErr: ErrCBORMalformed},
+		"ng: tag":             {in: "c11a514b67b0", wantErr: ErrCBORMalformed},
+		"ng: float":           {in: "f93c00", wantErr: ErrCBORMalformed},
+		"ng: duplicated keys": {in: "a201020103", wantErr: ErrCBORMalformed},
+		"ng: array key":       {in: "a1800102", wantErr: ErrCBORMalformed},
+		"ng: too large array": {in: "9affffffff", wantErr: ErrCBORMalformed},
+		"ng: too deep":        {in: "818181818181818181818181818181818100", wantErr: ErrCBORMalformed},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := hex.DecodeString(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, rest, err := DecodeCBOR(b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if len(rest) != 0 {
+				t.Errorf("unexpected rest: %x", rest)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEncodeCBOR(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in   any
+		want string
+	}{
+		"ok: 23":          {in: 23, want: "17"},
+		"ok: 24":          {in: 24, want: "1818"},
+		"ok: -257":        {in: int64(-257), want: "390100"},
+		"ok: uint32":      {in: uint32(1000000), want: "1a000f4240"},
+		"ok: true":        {in: true, want: "f5"},
+		"ok: byte string": {in: []byte{1, 2, 3, 4}, want: "4401020304"},
+		"ok: text string": {in: "IETF", want: "6449455446"},
+		"ok: array":       {in: []any{1, "a"}, want: "82016161"},
+		// the keys are sorted by their encoded bytes, so that 10 precedes -1 and "a".
+		"ok: sorted map": {in: map[any]any{"a": 1, int64(-1): 2, 10: 3}, want: "a30a032002616101"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := EncodeCBOR(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("want %s, got %x", tt.want, got)
+			}
+
+			// the encoded data is decoded again.
+			decoded, _, err := DecodeCBOR(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := EncodeCBOR(decoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again, got) {
+				t.Errorf("want %x, got %x", got, again)
+			}
+		})
+	}
+}
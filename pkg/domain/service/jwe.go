@@ -0,0 +1,181 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+var (
+	ErrJWEMalformed      = errors.New("jwe is malformed")
+	ErrJWEDecryption     = errors.New("jwe decryption failed")
+	ErrJWEUnsupportedAlg = errors.New("jwe algorithm is unsupported")
+)
+
+// ref:
+// - https://datatracker.ietf.org/doc/html/rfc7518#section-4.1
+// - https://datatracker.ietf.org/doc/html/rfc7518#section-5.1
+const (
+	AlgRSAOAEP    = "RSA-OAEP"
+	AlgRSAOAEP256 = "RSA-OAEP-256"
+
+	EncA128GCM = "A128GCM"
+	EncA256GCM = "A256GCM"
+)
+
+// EncryptionAlgs are the key management algorithms of the keys to which clients encrypt JWTs.
+var EncryptionAlgs = []string{AlgRSAOAEP, AlgRSAOAEP256}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc7516#section-4
+type JWEHeader struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc"`
+	KeyID       string `json:"kid,omitempty"`
+	Type        string `json:"typ,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// IsJWE reports whether token is in the JWE compact serialization, which has five parts.
+func IsJWE(token string) bool {
+	return strings.Count(token, ".") == 4
+}
+
+// EncryptJWE encrypts plaintext to the RSA public key in the compact serialization.
+func EncryptJWE(header *JWEHeader, key *rsa.PublicKey, plaintext []byte) (string, error) {
+	keySize, err := contentKeySize(header.Encryption)
+	if err != nil {
+		return "", err
+	}
+	h, err := oaepHash(header.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	cek := make([]byte, keySize)
+	if _, err := rand.Read(cek); err != nil {
+		return "", err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(h, rand.Reader, key, cek, nil)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	protected := b64.EncodeToString(b)
+
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return strings.Join([]string{
+		protected,
+		b64.EncodeToString(encryptedKey),
+		b64.EncodeToString(iv),
+		b64.EncodeToString(ciphertext),
+		b64.EncodeToString(tag),
+	}, "."), nil
+}
+
+// DecryptJWE decrypts a JWE in the compact serialization with the RSA private key returned by keyFunc.
+func DecryptJWE(token string, keyFunc func(*JWEHeader) (*rsa.PrivateKey, error)) (*JWEHeader, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, nil, ErrJWEMalformed
+	}
+
+	b, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrJWEMalformed
+	}
+	header := &JWEHeader{}
+	if err := json.Unmarshal(b, header); err != nil {
+		return nil, nil, ErrJWEMalformed
+	}
+	decoded := make([][]byte, 4)
+	for i, part := range parts[1:] {
+		decoded[i], err = b64.DecodeString(part)
+		if err != nil {
+			return nil, nil, ErrJWEMalformed
+		}
+	}
+	encryptedKey, iv, ciphertext, tag := decoded[0], decoded[1], decoded[2], decoded[3]
+
+	keySize, err := contentKeySize(header.Encryption)
+	if err != nil {
+		return nil, nil, err
+	}
+	h, err := oaepHash(header.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := keyFunc(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cek, err := rsa.DecryptOAEP(h, nil, key, encryptedKey, nil)
+	if err != nil || len(cek) != keySize {
+		return nil, nil, ErrJWEDecryption
+	}
+	gcm, err := newGCM(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != gcm.NonceSize() {
+		return nil, nil, ErrJWEMalformed
+	}
+	plaintext, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, nil, ErrJWEDecryption
+	}
+
+	return header, plaintext, nil
+}
+
+func contentKeySize(enc string) (int, error) {
+	switch enc {
+	case EncA128GCM:
+		return 16, nil
+	case EncA256GCM:
+		return 32, nil
+	default:
+		return 0, fmt.Errorf("%w: enc %s", ErrJWEUnsupportedAlg, enc)
+	}
+}
+
+func oaepHash(alg string) (hash.Hash, error) {
+	switch alg {
+	case AlgRSAOAEP:
+		return sha1.New(), nil
+	case AlgRSAOAEP256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("%w: alg %s", ErrJWEUnsupportedAlg, alg)
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
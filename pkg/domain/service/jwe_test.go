@@ -0,0 +1,78 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncryptAndDecryptJWE(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		header     *JWEHeader
+		decryptKey *rsa.PrivateKey
+		tamper     func(string) string
+		want       error
+	}{
+		"ok: RSA-OAEP-256 and A256GCM": {
+			header:     &JWEHeader{Algorithm: AlgRSAOAEP256, Encryption: EncA256GCM},
+			decryptKey: key,
+		},
+		"ok: RSA-OAEP and A128GCM": {
+			header:     &JWEHeader{Algorithm: AlgRSAOAEP, Encryption: EncA128GCM},
+			decryptKey: key,
+		},
+		"ng: another key": {
+			header:     &JWEHeader{Algorithm: AlgRSAOAEP256, Encryption: EncA256GCM},
+			decryptKey: otherKey,
+			want:       ErrJWEDecryption,
+		},
+		"ng: header is tampered": {
+			header:     &JWEHeader{Algorithm: AlgRSAOAEP256, Encryption: EncA256GCM},
+			decryptKey: key,
+			tamper: func(token string) string {
+				parts := strings.Split(token, ".")
+				parts[0] = b64.EncodeToString([]byte(`{"alg":"RSA-OAEP-256","enc":"A256GCM","cty":"JWT"}`))
+				return strings.Join(parts, ".")
+			},
+			want: ErrJWEDecryption,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			token, err := EncryptJWE(tt.header, &key.PublicKey, []byte("plaintext"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !IsJWE(token) {
+				t.Fatalf("want JWE compact serialization, got %s", token)
+			}
+			if tt.tamper != nil {
+				token = tt.tamper(token)
+			}
+
+			_, plaintext, err := DecryptJWE(token, func(*JWEHeader) (*rsa.PrivateKey, error) { return tt.decryptKey, nil })
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want error %v, got %v", tt.want, err)
+			}
+			if err == nil && string(plaintext) != "plaintext" {
+				t.Errorf("unexpected plaintext: %s", plaintext)
+			}
+		})
+	}
+}
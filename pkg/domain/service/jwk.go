@@ -0,0 +1,154 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var (
+	ErrJWKInvalid  = errors.New("jwk is invalid")
+	ErrJWKNotFound = errors.New("jwk not found")
+)
+
+// ref: https://datatracker.ietf.org/doc/html/rfc7517#section-4.2
+const (
+	KeyUseSignature  = "sig"
+	KeyUseEncryption = "enc"
+)
+
+// NewJWK returns the JWK of the public key.
+func NewJWK(pub crypto.PublicKey, kid, use, alg string) (*model.JWK, error) {
+	jwk := &model.JWK{KeyID: kid, Use: use, Algorithm: alg}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64.EncodeToString(k.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrJWKInvalid, k.Curve.Params().Name)
+		}
+		jwk.KeyType = "EC"
+		jwk.Curve = "P-256"
+		jwk.X = b64.EncodeToString(k.X.FillBytes(make([]byte, 32)))
+		jwk.Y = b64.EncodeToString(k.Y.FillBytes(make([]byte, 32)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64.EncodeToString(k)
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %T", ErrJWKInvalid, pub)
+	}
+	return jwk, nil
+}
+
+// PublicKey returns the public key represented by jwk.
+func PublicKey(jwk *model.JWK) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := b64.DecodeString(jwk.N)
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("%w: n", ErrJWKInvalid)
+		}
+		e, err := b64.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: e", ErrJWKInvalid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if jwk.Curve != "P-256" {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrJWKInvalid, jwk.Curve)
+		}
+		x, err := b64.DecodeString(jwk.X)
+		if err != nil || len(x) != 32 {
+			return nil, fmt.Errorf("%w: x", ErrJWKInvalid)
+		}
+		y, err := b64.DecodeString(jwk.Y)
+		if err != nil || len(y) != 32 {
+			return nil, fmt.Errorf("%w: y", ErrJWKInvalid)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: point is not on the curve", ErrJWKInvalid)
+		}
+		return pub, nil
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: unsupported curve %s", ErrJWKInvalid, jwk.Curve)
+		}
+		x, err := b64.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: x", ErrJWKInvalid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %s", ErrJWKInvalid, jwk.KeyType)
+	}
+}
+
+// JWKSetKeyFunc returns a KeyFunc which finds the key in set by the kid and the alg of the header.
+// Only the given algorithms are accepted.
+func JWKSetKeyFunc(set *model.JWKSet, use string, algs ...string) KeyFunc {
+	return func(header *JWSHeader) (any, error) {
+		if !slices.Contains(algs, header.Algorithm) {
+			return nil, fmt.Errorf("%w: %s", ErrJWSUnsupportedAlg, header.Algorithm)
+		}
+		return FindKey(set, header.KeyID, use, header.Algorithm)
+	}
+}
+
+// FindKey returns the public key in set which matches kid, use and alg.
+// A key without kid is found only if it is the only candidate.
+func FindKey(set *model.JWKSet, kid, use, alg string) (crypto.PublicKey, error) {
+	if set == nil {
+		return nil, ErrJWKNotFound
+	}
+
+	var found crypto.PublicKey
+	for i := range set.Keys {
+		jwk := &set.Keys[i]
+		if kid != "" && jwk.KeyID != kid {
+			continue
+		}
+		if jwk.Use != "" && jwk.Use != use {
+			continue
+		}
+		if jwk.Algorithm != "" && jwk.Algorithm != alg {
+			continue
+		}
+		pub, err := PublicKey(jwk)
+		if err != nil || !keyTypeMatches(alg, pub) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: kid is required to choose one of the keys", ErrJWKNotFound)
+		}
+		found = pub
+	}
+	if found == nil {
+		return nil, ErrJWKNotFound
+	}
+	return found, nil
+}
+
+func keyTypeMatches(alg string, pub crypto.PublicKey) bool {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return alg == AlgRS256 || alg == AlgPS256 || alg == AlgRSAOAEP || alg == AlgRSAOAEP256
+	case *ecdsa.PublicKey:
+		return alg == AlgES256
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA
+	default:
+		return false
+	}
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrJWSMalformed        = errors.New("jws is malformed")
+	ErrJWSInvalidSignature = errors.New("jws signature is invalid")
+	ErrJWSUnsupportedAlg   = errors.New("jws algorithm is unsupported")
+
+	ErrJWTExpired         = errors.New("jwt is expired")
+	ErrJWTNotYetValid     = errors.New("jwt is not valid yet")
+	ErrJWTIssuerMismatch  = errors.New("jwt issuer is mismatched")
+	ErrJWTAudienceMissing = errors.New("jwt audience does not contain the expected value")
+)
+
+// ref:
+// - https://datatracker.ietf.org/doc/html/rfc7518#section-3.1
+// - https://datatracker.ietf.org/doc/html/rfc8037#section-3.1
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgPS256 = "PS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// AsymmetricAlgs are the signing algorithms with public keys.
+var AsymmetricAlgs = []string{AlgRS256, AlgPS256, AlgES256, AlgEdDSA}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc7515#section-4
+type JWSHeader struct {
+	Algorithm   string `json:"alg"`
+	KeyID       string `json:"kid,omitempty"`
+	Type        string `json:"typ,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// KeyFunc returns the key to verify a JWS with the given header.
+type KeyFunc func(*JWSHeader) (any, error)
+
+var b64 = base64.RawURLEncoding
+
+// SignJWS creates a JWS in the compact serialization.
+// key must be a []byte for HS256, and a private key of the algorithm for the others.
+func SignJWS(header *JWSHeader, key any, payload []byte) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+
+	sig, err := sign(header.Algorithm, key, []byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64.EncodeToString(sig), nil
+}
+
+// VerifyJWS verifies a JWS in the compact serialization and returns its header and payload.
+func VerifyJWS(token string, keyFunc KeyFunc) (*JWSHeader, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrJWSMalformed
+	}
+
+	h, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, ErrJWSMalformed
+	}
+	header := &JWSHeader{}
+	if err := json.Unmarshal(h, header); err != nil {
+		return nil, nil, ErrJWSMalformed
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, ErrJWSMalformed
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, ErrJWSMalformed
+	}
+
+	key, err := keyFunc(header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := verify(header.Algorithm, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, nil, err
+	}
+
+	return header, payload, nil
+}
+
+func sign(alg string, key any, signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+
+	switch alg {
+	case AlgHS256:
+		k, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a []byte key, got %T", alg, key)
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case AlgRS256, AlgPS256:
+		k, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a *rsa.PrivateKey, got %T", alg, key)
+		}
+		if alg == AlgPS256 {
+			return rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case AlgES256:
+		k, ok := key.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a *ecdsa.PrivateKey, got %T", alg, key)
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// ref: https://datatracker.ietf.org/doc/html/rfc7518#section-3.4
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	case AlgEdDSA:
+		k, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires a ed25519.PrivateKey, got %T", alg, key)
+		}
+		return ed25519.Sign(k, signingInput), nil
+	default:
+		return nil, ErrJWSUnsupportedAlg
+	}
+}
+
+func verify(alg string, key any, signingInput, sig []byte) error {
+	digest := sha256.Sum256(signingInput)
+
+	switch alg {
+	case AlgHS256:
+		expected, err := sign(alg, key, signingInput)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal(expected, sig) {
+			return ErrJWSInvalidSignature
+		}
+		return nil
+	case AlgRS256, AlgPS256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires a *rsa.PublicKey, got %T", alg, key)
+		}
+		var err error
+		if alg == AlgPS256 {
+			err = rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig)
+		}
+		if err != nil {
+			return ErrJWSInvalidSignature
+		}
+		return nil
+	case AlgES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires a *ecdsa.PublicKey, got %T", alg, key)
+		}
+		if len(sig) != 64 {
+			return ErrJWSInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return ErrJWSInvalidSignature
+		}
+		return nil
+	case AlgEdDSA:
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires a ed25519.PublicKey, got %T", alg, key)
+		}
+		if !ed25519.Verify(k, signingInput, sig) {
+			return ErrJWSInvalidSignature
+		}
+		return nil
+	default:
+		return ErrJWSUnsupportedAlg
+	}
+}
+
+// SignJWT signs claims as the JWS payload.
+func SignJWT(header *JWSHeader, key any, claims any) (string, error) {
+	if header.Type == "" {
+		header.Type = "JWT"
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	return SignJWS(header, key, payload)
+}
+
+// ParseJWT verifies token and decodes its payload into claims.
+// The registered claims must be validated by the caller, e.g. with RegisteredClaims.Validate.
+func ParseJWT(token string, keyFunc KeyFunc, claims any) (*JWSHeader, error) {
+	header, payload, err := VerifyJWS(token, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrJWSMalformed
+	}
+	return header, nil
+}
+
+// ParseUnverifiedJWT decodes the claims of token without verifying the signature,
+// e.g. to find the issuer whose keys verify it. The claims must not be trusted until token is verified.
+func ParseUnverifiedJWT(token string, claims any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrJWSMalformed
+	}
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return ErrJWSMalformed
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrJWSMalformed
+	}
+	return nil
+}
+
+// Audience is the aud claim, which is either a single string or an array of strings.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = nil
+		if single != "" {
+			*a = Audience{single}
+		}
+		return nil
+	}
+
+	var multi []string
+	if err := json.Unmarshal(b, &multi); err != nil {
+		return err
+	}
+	*a = multi
+	return nil
+}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc7519#section-4.1
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// Validate checks the time-based claims and, when they are not empty, the issuer and the audience.
+func (c *RegisteredClaims) Validate(now time.Time, issuer, audience string) error {
+	if c.ExpiresAt != 0 && !now.Before(time.Unix(c.ExpiresAt, 0)) {
+		return ErrJWTExpired
+	}
+	if c.NotBefore != 0 && now.Before(time.Unix(c.NotBefore, 0)) {
+		return ErrJWTNotYetValid
+	}
+	if issuer != "" && c.Issuer != issuer {
+		return ErrJWTIssuerMismatch
+	}
+	if audience != "" && !slices.Contains(c.Audience, audience) {
+		return ErrJWTAudienceMissing
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestSignAndVerifyJWS(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		alg  string
+		key  crypto.Signer
+		kid  string
+		want error
+	}{
+		"ok: RS256":              {alg: AlgRS256, key: rsaKey, kid: "rsa"},
+		"ok: PS256":              {alg: AlgPS256, key: rsaKey, kid: "rsa"},
+		"ok: ES256":              {alg: AlgES256, key: ecKey, kid: "ec"},
+		"ok: EdDSA":              {alg: AlgEdDSA, key: edKey, kid: "ed"},
+		"ng: key of another kid": {alg: AlgRS256, key: rsaKey, kid: "ec", want: ErrJWKNotFound},
+		"ng: HS256 is not allowed": {
+			alg:  AlgHS256,
+			kid:  "rsa",
+			want: ErrJWSUnsupportedAlg,
+		},
+	}
+
+	set := &model.JWKSet{}
+	for kid, key := range map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey, "ed": edKey} {
+		jwk, err := NewJWK(key.Public(), kid, KeyUseSignature, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var signingKey any = []byte("secret")
+			if tt.key != nil {
+				signingKey = tt.key
+			}
+			token, err := SignJWS(&JWSHeader{Algorithm: tt.alg, KeyID: tt.kid}, signingKey, []byte(`{"sub":"user"}`))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			_, payload, err := VerifyJWS(token, JWKSetKeyFunc(set, KeyUseSignature, AsymmetricAlgs...))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want error %v, got %v", tt.want, err)
+			}
+			if err == nil && string(payload) != `{"sub":"user"}` {
+				t.Errorf("unexpected payload: %s", payload)
+			}
+		})
+	}
+}
+
+func TestVerifyJWSInvalidSignature(t *testing.T) {
+	t.Parallel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := SignJWS(&JWSHeader{Algorithm: AlgES256}, ecKey, []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = VerifyJWS(token, func(*JWSHeader) (any, error) { return &otherKey.PublicKey, nil })
+	if !errors.Is(err, ErrJWSInvalidSignature) {
+		t.Errorf("want error %v, got %v", ErrJWSInvalidSignature, err)
+	}
+}
+
+func TestFindKey(t *testing.T) {
+	t.Parallel()
+
+	set := &model.JWKSet{}
+	for _, kid := range []string{"key-1", "key-2"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwk, err := NewJWK(&key.PublicKey, kid, KeyUseSignature, AlgES256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	tests := map[string]struct {
+		kid  string
+		use  string
+		alg  string
+		want error
+	}{
+		"ok":                        {kid: "key-2", use: KeyUseSignature, alg: AlgES256},
+		"ng: kid is required":       {use: KeyUseSignature, alg: AlgES256, want: ErrJWKNotFound},
+		"ng: use is mismatched":     {kid: "key-1", use: KeyUseEncryption, alg: AlgES256, want: ErrJWKNotFound},
+		"ng: alg is mismatched":     {kid: "key-1", use: KeyUseSignature, alg: AlgRS256, want: ErrJWKNotFound},
+		"ng: kid is not in the set": {kid: "key-3", use: KeyUseSignature, alg: AlgES256, want: ErrJWKNotFound},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := FindKey(set, tt.kid, tt.use, tt.alg)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("want error %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// PrivateKey is a key of the authorization server. Its public key is published as a JWK.
+type PrivateKey struct {
+	ID        string
+	Use       string
+	Algorithm string
+	Key       crypto.Signer // *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey
+}
+
+// GenerateSigningKey generates an RSA key with which the authorization server signs JWTs.
+func GenerateSigningKey() (*PrivateKey, error) {
+	key, err := GenerateKey(KeyUseSignature, AlgRS256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return key, nil
+}
+
+// GenerateEncryptionKey generates an RSA key with which clients encrypt JWTs for the authorization server.
+func GenerateEncryptionKey() (*PrivateKey, error) {
+	key, err := GenerateKey(KeyUseEncryption, AlgRSAOAEP256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// GenerateKey generates a key of alg, which is an RSA key for RS256, PS256 and RSA-OAEP, a P-256 key for ES256,
+// and an Ed25519 key for EdDSA.
+func GenerateKey(use, alg string) (*PrivateKey, error) {
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case AlgRS256, AlgPS256, AlgRSAOAEP, AlgRSAOAEP256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrJWSUnsupportedAlg, alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateKey{
+		ID:        uuid.NewString(),
+		Use:       use,
+		Algorithm: alg,
+		Key:       key,
+	}, nil
+}
+
+// JWK returns the public JWK of the key.
+func (k *PrivateKey) JWK() (*model.JWK, error) {
+	return NewJWK(k.Key.Public(), k.ID, k.Use, k.Algorithm)
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/repository"
+)
+
+var (
+	ErrKeyNotFound         = errors.New("key not found")
+	ErrNoActiveKey         = errors.New("no key is active")
+	ErrKeyEncryptionKey    = errors.New("key-encryption key must be 32 bytes")
+	ErrKeyDecryptionFailed = errors.New("failed to decrypt the key")
+	ErrKeyRotationPolicy   = errors.New("key rotation policy is invalid")
+)
+
+// KeyManager generates the signing keys of the authorization server and rotates them on the schedule of the policy.
+// The next key is published in JWKS before it signs, and the retired keys are published until the JWTs signed by them expire.
+// The manager built by NewEncryptionKeyManager rotates the encryption keys in the same way, whose retired keys decrypt
+// the JWTs encrypted before the rotation until they expire.
+// The private keys are sealed with the key-encryption key before they are stored.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#RotateSigKeys
+type KeyManager struct {
+	storage repository.KeyStorage
+	aead    cipher.AEAD
+	policy  model.KeyRotationPolicy
+	// use is sig or enc, which is the use of the generated keys.
+	use string
+
+	// Logger writes the rotations, which is slog.Default() if it is nil.
+	Logger *slog.Logger
+
+	now func() time.Time
+
+	// mu serializes the rotations, and guards the decrypted keys.
+	mu   sync.Mutex
+	keys map[string]*PrivateKey
+}
+
+// NewKeyManager returns the manager of the keys in storage. kek is the 32-byte key-encryption key, with which
+// the private keys are sealed in AES-256-GCM.
+func NewKeyManager(storage repository.KeyStorage, kek []byte, policy model.KeyRotationPolicy) (*KeyManager, error) {
+	if err := ValidateKeyRotationPolicy(policy); err != nil {
+		return nil, err
+	}
+	return newKeyManager(storage, kek, policy, KeyUseSignature)
+}
+
+// NewEncryptionKeyManager returns the manager of the keys in storage to which clients encrypt JWTs,
+// whose algorithm is one of EncryptionAlgs.
+func NewEncryptionKeyManager(storage repository.KeyStorage, kek []byte, policy model.KeyRotationPolicy) (*KeyManager, error) {
+	if err := validateKeyRotationPeriods(policy); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(EncryptionAlgs, policy.Algorithm) {
+		return nil, fmt.Errorf("%w: %w: %s", ErrKeyRotationPolicy, ErrJWEUnsupportedAlg, policy.Algorithm)
+	}
+	return newKeyManager(storage, kek, policy, KeyUseEncryption)
+}
+
+func newKeyManager(storage repository.KeyStorage, kek []byte, policy model.KeyRotationPolicy, use string) (*KeyManager, error) {
+	if len(kek) != 32 {
+		return nil, ErrKeyEncryptionKey
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &KeyManager{
+		storage: storage,
+		aead:    aead,
+		policy:  policy,
+		use:     use,
+		now:     time.Now,
+		keys:    make(map[string]*PrivateKey),
+	}, nil
+}
+
+// ValidateKeyRotationPolicy reports whether the signing keys are rotated with policy.
+func ValidateKeyRotationPolicy(policy model.KeyRotationPolicy) error {
+	if err := validateKeyRotationPeriods(policy); err != nil {
+		return err
+	}
+	if !slices.Contains(AsymmetricAlgs, policy.Algorithm) {
+		return fmt.Errorf("%w: %w: %s", ErrKeyRotationPolicy, ErrJWSUnsupportedAlg, policy.Algorithm)
+	}
+	return nil
+}
+
+func validateKeyRotationPeriods(policy model.KeyRotationPolicy) error {
+	if policy.RotationPeriod <= 0 || policy.PublishBefore < 0 || policy.PublishBefore >= policy.RotationPeriod || policy.RetireAfter <= 0 {
+		return fmt.Errorf("%w: periods must be positive and the next key must be published within the rotation period", ErrKeyRotationPolicy)
+	}
+	return nil
+}
+
+func (m *KeyManager) logger() *slog.Logger {
+	if m.Logger == nil {
+		return slog.Default()
+	}
+	return m.Logger
+}
+
+// Run rotates the keys on every interval until ctx is done. The keys are also rotated on the start,
+// so that a key is active before the first JWT is signed.
+func (m *KeyManager) Run(ctx context.Context, interval time.Duration) error {
+	if err := m.Rotate(ctx); err != nil {
+		return err
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			// the rotation is retried on the next tick, while the active key keeps signing.
+			if err := m.Rotate(ctx); err != nil {
+				m.logger().Error("failed to rotate signing keys", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// Rotate brings the keys up to the schedule. It activates a key if none is active, activates the next key
+// once the active key has signed for the rotation period, publishes a new next key, and deletes the expired keys.
+func (m *KeyManager) Rotate(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.rotate(ctx, false)
+}
+
+// RotateNow activates the next key at once, which is published for less than PublishBefore, e.g. when the algorithm is changed.
+func (m *KeyManager) RotateNow(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.rotate(ctx, true)
+}
+
+// Revoke unpublishes the key of kid at once, so that the JWTs signed by it are rejected. If it is active,
+// the next key is activated in place of it.
+func (m *KeyManager) Revoke(ctx context.Context, kid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.storage.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+	var record *model.KeyRecord
+	for _, r := range records {
+		if r.ID == kid {
+			record = r
+		}
+	}
+	if record == nil {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	if record.State == model.KeyStateRevoked {
+		return nil
+	}
+
+	wasActive := record.State == model.KeyStateActive
+	now := m.now()
+	record.State = model.KeyStateRevoked
+	record.RetiredAt = now
+	// the revoked key is kept for the audit until it would have expired.
+	record.ExpiresAt = now.Add(m.policy.RetireAfter)
+	if err := m.storage.SaveKey(ctx, record); err != nil {
+		return err
+	}
+	delete(m.keys, kid)
+	m.logger().Warn("signing key revoked", slog.String("kid", kid), slog.Bool("active", wasActive))
+
+	return m.rotate(ctx, false)
+}
+
+// rotate is Rotate, which activates the next key regardless of the schedule if force is true.
+func (m *KeyManager) rotate(ctx context.Context, force bool) error {
+	now := m.now()
+	records, err := m.storage.ListKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	var active, next *model.KeyRecord
+	for _, r := range records {
+		switch {
+		case (r.State == model.KeyStateRetired || r.State == model.KeyStateRevoked) && r.IsExpired(now):
+			if err := m.storage.DeleteKey(ctx, r.ID); err != nil {
+				return err
+			}
+			delete(m.keys, r.ID)
+			m.logger().Info("signing key deleted", slog.String("kid", r.ID))
+		case r.State == model.KeyStateActive:
+			active = r
+		case r.State == model.KeyStateNext:
+			next = r
+		}
+	}
+
+	// the next key of the previous algorithm is replaced, as it has never signed.
+	if next != nil && next.Algorithm != m.policy.Algorithm {
+		if err := m.storage.DeleteKey(ctx, next.ID); err != nil {
+			return err
+		}
+		next = nil
+	}
+
+	due := active == nil || force || !now.Before(active.ActivatedAt.Add(m.policy.RotationPeriod))
+	// the scheduled rotation waits until the clients caching JWKS know the next key.
+	if due && active != nil && !force && next != nil && now.Before(next.CreatedAt.Add(m.policy.PublishBefore)) {
+		due = false
+	}
+	if due {
+		if next == nil {
+			// no client knows the key yet, which is inevitable when no key is active.
+			if next, err = m.generate(now); err != nil {
+				return err
+			}
+		}
+		next.State = model.KeyStateActive
+		next.ActivatedAt = now
+		if err := m.storage.SaveKey(ctx, next); err != nil {
+			return err
+		}
+		if active != nil {
+			active.State = model.KeyStateRetired
+			active.RetiredAt = now
+			active.ExpiresAt = now.Add(m.policy.RetireAfter)
+			if err := m.storage.SaveKey(ctx, active); err != nil {
+				return err
+			}
+			m.logger().Info("signing key retired", slog.String("kid", active.ID), slog.Time("expires_at", active.ExpiresAt))
+		}
+		m.logger().Info("signing key activated", slog.String("kid", next.ID), slog.String("alg", next.Algorithm))
+		next = nil
+	}
+
+	// the next key is published as soon as a key is activated, so that it is known for the whole rotation period.
+	if next == nil {
+		next, err = m.generate(now)
+		if err != nil {
+			return err
+		}
+		if err := m.storage.SaveKey(ctx, next); err != nil {
+			return err
+		}
+		m.logger().Info("next signing key published", slog.String("kid", next.ID), slog.String("alg", next.Algorithm))
+	}
+	return nil
+}
+
+// generate returns the record of a new next key, whose private key is sealed.
+func (m *KeyManager) generate(now time.Time) (*model.KeyRecord, error) {
+	key, err := GenerateKey(m.use, m.policy.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	m.keys[key.ID] = key
+	return &model.KeyRecord{
+		ID:        key.ID,
+		Use:       key.Use,
+		Algorithm: key.Algorithm,
+		State:     model.KeyStateNext,
+		// kid is authenticated with the key, so that the sealed keys are not swapped in the storage.
+		EncryptedKey: m.aead.Seal(nonce, nonce, der, []byte(key.ID)),
+		CreatedAt:    now,
+	}, nil
+}
+
+// decrypt returns the private key of record.
+func (m *KeyManager) decrypt(record *model.KeyRecord) (*PrivateKey, error) {
+	if key, ok := m.keys[record.ID]; ok {
+		return key, nil
+	}
+	n := m.aead.NonceSize()
+	if len(record.EncryptedKey) < n {
+		return nil, fmt.Errorf("%w: %s", ErrKeyDecryptionFailed, record.ID)
+	}
+	der, err := m.aead.Open(nil, record.EncryptedKey[:n], record.EncryptedKey[n:], []byte(record.ID))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyDecryptionFailed, record.ID)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrKeyDecryptionFailed, record.ID, err)
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: unsupported key type %T", ErrKeyDecryptionFailed, record.ID, parsed)
+	}
+	key := &PrivateKey{ID: record.ID, Use: record.Use, Algorithm: record.Algorithm, Key: signer}
+	m.keys[record.ID] = key
+	return key, nil
+}
+
+// SigningKey returns the active key, which signs the JWTs.
+func (m *KeyManager) SigningKey(ctx context.Context) (*PrivateKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.storage.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.State == model.KeyStateActive {
+			return m.decrypt(r)
+		}
+	}
+	return nil, ErrNoActiveKey
+}
+
+// JWKS returns the public keys of the published keys, with which the JWTs signed by the authorization server are verified.
+func (m *KeyManager) JWKS(ctx context.Context) ([]model.JWK, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.storage.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	jwks := make([]model.JWK, 0, len(records))
+	for _, r := range records {
+		// the expired keys are unpublished even if the rotation is late to delete them.
+		if !r.IsPublished() || (r.State == model.KeyStateRetired && r.IsExpired(now)) {
+			continue
+		}
+		// the clients encrypt to the active key or the next key, while the retired keys still decrypt.
+		if m.use == KeyUseEncryption && r.State == model.KeyStateRetired {
+			continue
+		}
+		key, err := m.decrypt(r)
+		if err != nil {
+			return nil, err
+		}
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+		jwks = append(jwks, *jwk)
+	}
+	return jwks, nil
+}
+
+// DecryptionKey returns the published key of kid, or the active key if kid is empty, which decrypts the JWTs
+// encrypted to it. The retired keys are returned until they expire, so that the rotation does not break the JWTs
+// encrypted before it.
+func (m *KeyManager) DecryptionKey(ctx context.Context, kid string) (*PrivateKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	records, err := m.storage.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := m.now()
+	for _, r := range records {
+		if !r.IsPublished() || (r.State == model.KeyStateRetired && r.IsExpired(now)) {
+			continue
+		}
+		if r.ID == kid || (kid == "" && r.State == model.KeyStateActive) {
+			return m.decrypt(r)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+}
+
+// Keys returns the keys with their states, whose private keys are removed.
+func (m *KeyManager) Keys(ctx context.Context) ([]*model.KeyRecord, error) {
+	records, err := m.storage.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		r.EncryptedKey = nil
+	}
+	return records, nil
+}
@@ -0,0 +1,484 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// keyStorage is the in-memory storage of the tests, which the infra package cannot provide without the import cycle.
+type keyStorage map[string]model.KeyRecord
+
+func (s keyStorage) ListKeys(context.Context) ([]*model.KeyRecord, error) {
+	var keys []*model.KeyRecord
+	for _, k := range s {
+		keys = append(keys, &k)
+	}
+	slices.SortFunc(keys, func(a, b *model.KeyRecord) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return keys, nil
+}
+
+func (s keyStorage) SaveKey(_ context.Context, k *model.KeyRecord) error {
+	s[k.ID] = *k
+	return nil
+}
+
+func (s keyStorage) DeleteKey(_ context.Context, id string) error {
+	delete(s, id)
+	return nil
+}
+
+var testKeyEncryptionKey = bytes.Repeat([]byte{0x42}, 32)
+
+var testKeyRotationPolicy = model.KeyRotationPolicy{
+	Algorithm:      AlgES256,
+	RotationPeriod: 30 * 24 * time.Hour,
+	PublishBefore:  24 * time.Hour,
+	RetireAfter:    time.Hour,
+}
+
+// newTestKeyManager returns the manager whose clock is moved by the returned function.
+func newTestKeyManager(t *testing.T, storage keyStorage, policy model.KeyRotationPolicy) (*KeyManager, func(time.Duration)) {
+	t.Helper()
+	now := time.Unix(1_700_000_000, 0)
+	m, err := NewKeyManager(storage, testKeyEncryptionKey, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return now }
+	m.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+// keyStates returns the states of the keys in the alphabetical order, as the keys may be created at the same time.
+func keyStates(t *testing.T, storage keyStorage) []model.KeyState {
+	t.Helper()
+	states := make([]model.KeyState, 0, len(storage))
+	for _, k := range storage {
+		states = append(states, k.State)
+	}
+	slices.Sort(states)
+	return states
+}
+
+// keyID returns kid of the only key in state.
+func keyID(t *testing.T, storage keyStorage, state model.KeyState) string {
+	t.Helper()
+	var ids []string
+	for _, k := range storage {
+		if k.State == state {
+			ids = append(ids, k.ID)
+		}
+	}
+	if len(ids) != 1 {
+		t.Fatalf("unexpected keys in %s: %v", state, ids)
+	}
+	return ids[0]
+}
+
+func jwkIDs(t *testing.T, m *KeyManager) []string {
+	t.Helper()
+	jwks, err := m.JWKS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(jwks))
+	for _, jwk := range jwks {
+		ids = append(ids, jwk.KeyID)
+	}
+	return ids
+}
+
+func TestKeyManagerRotate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := keyStorage{}
+	m, advance := newTestKeyManager(t, storage, testKeyRotationPolicy)
+
+	// the first key is activated at once, and the next key is published.
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := keyStates(t, storage); !slices.Equal(got, []model.KeyState{model.KeyStateActive, model.KeyStateNext}) {
+		t.Fatalf("unexpected states on the start: %v", got)
+	}
+	first, err := m.SigningKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := keyID(t, storage, model.KeyStateNext)
+	if got := jwkIDs(t, m); len(got) != 2 || !slices.Contains(got, first.ID) || !slices.Contains(got, next) {
+		t.Fatalf("unexpected JWKS on the start: %v", got)
+	}
+
+	// nothing changes within the rotation period.
+	advance(testKeyRotationPolicy.RotationPeriod - time.Second)
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := keyStates(t, storage); !slices.Equal(got, []model.KeyState{model.KeyStateActive, model.KeyStateNext}) {
+		t.Fatalf("unexpected states within the rotation period: %v", got)
+	}
+
+	// the published next key signs, and the retired key is published until the signed JWTs expire.
+	advance(time.Second)
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := keyStates(t, storage); !slices.Equal(got, []model.KeyState{model.KeyStateActive, model.KeyStateNext, model.KeyStateRetired}) {
+		t.Fatalf("unexpected states on the rotation: %v", got)
+	}
+	second, err := m.SigningKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != next {
+		t.Errorf("the published next key must be activated: want %s, got %s", next, second.ID)
+	}
+	if got := jwkIDs(t, m); len(got) != 3 || !slices.Contains(got, first.ID) {
+		t.Errorf("the retired key must be published: %v", got)
+	}
+
+	// the retired key is deleted after RetireAfter.
+	advance(testKeyRotationPolicy.RetireAfter)
+	if got := jwkIDs(t, m); slices.Contains(got, first.ID) {
+		t.Errorf("the expired key must not be published: %v", got)
+	}
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := keyStates(t, storage); !slices.Equal(got, []model.KeyState{model.KeyStateActive, model.KeyStateNext}) {
+		t.Errorf("unexpected states after the retirement: %v", got)
+	}
+}
+
+func TestKeyManagerPublishBefore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := keyStorage{}
+	m, advance := newTestKeyManager(t, storage, testKeyRotationPolicy)
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := m.SigningKey(ctx)
+
+	// the next key replaced just before the rotation is not known by the clients caching JWKS yet.
+	advance(testKeyRotationPolicy.RotationPeriod - time.Hour)
+	if err := m.Revoke(ctx, keyID(t, storage, model.KeyStateNext)); err != nil {
+		t.Fatal(err)
+	}
+	advance(time.Hour)
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.SigningKey(ctx); got.ID != active.ID {
+		t.Fatalf("the rotation must wait for PublishBefore: want %s, got %s", active.ID, got.ID)
+	}
+
+	advance(testKeyRotationPolicy.PublishBefore - time.Hour)
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := m.SigningKey(ctx); got.ID == active.ID {
+		t.Errorf("the key must be rotated after PublishBefore")
+	}
+}
+
+func TestKeyManagerAdminOperations(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		operate    func(ctx context.Context, m *KeyManager, active, next string) error
+		wantStates []model.KeyState
+		wantErr    error
+	}{
+		"ok: rotate now": {
+			operate: func(ctx context.Context, m *KeyManager, _, _ string) error {
+				return m.RotateNow(ctx)
+			},
+			wantStates: []model.KeyState{model.KeyStateActive, model.KeyStateNext, model.KeyStateRetired},
+		},
+		"ok: revoke the active key": {
+			operate: func(ctx context.Context, m *KeyManager, active, _ string) error {
+				return m.Revoke(ctx, active)
+			},
+			wantStates: []model.KeyState{model.KeyStateActive, model.KeyStateNext, model.KeyStateRevoked},
+		},
+		"ok: revoke the next key": {
+			operate: func(ctx context.Context, m *KeyManager, _, next string) error {
+				return m.Revoke(ctx, next)
+			},
+			wantStates: []model.KeyState{model.KeyStateActive, model.KeyStateNext, model.KeyStateRevoked},
+		},
+		"ok: revoke the revoked key again": {
+			operate: func(ctx context.Context, m *KeyManager, active, _ string) error {
+				if err := m.Revoke(ctx, active); err != nil {
+					return err
+				}
+				return m.Revoke(ctx, active)
+			},
+			wantStates: []model.KeyState{model.KeyStateActive, model.KeyStateNext, model.KeyStateRevoked},
+		},
+		"ng: revoke an unknown key": {
+			operate: func(ctx context.Context, m *KeyManager, _, _ string) error {
+				return m.Revoke(ctx, "unknown-kid")
+			},
+			wantStates: []model.KeyState{model.KeyStateActive, model.KeyStateNext},
+			wantErr:    ErrKeyNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			storage := keyStorage{}
+			m, advance := newTestKeyManager(t, storage, testKeyRotationPolicy)
+			if err := m.Rotate(ctx); err != nil {
+				t.Fatal(err)
+			}
+			active, next := keyID(t, storage, model.KeyStateActive), keyID(t, storage, model.KeyStateNext)
+			advance(time.Minute)
+
+			err := tt.operate(ctx, m, active, next)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want %v, got %v", tt.wantErr, err)
+			}
+			if got := keyStates(t, storage); !slices.Equal(got, tt.wantStates) {
+				t.Fatalf("unexpected states: want %v, got %v", tt.wantStates, got)
+			}
+
+			// the revoked keys are never published nor sign.
+			key, err := m.SigningKey(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			published := jwkIDs(t, m)
+			for _, k := range storage {
+				if k.State != model.KeyStateRevoked {
+					continue
+				}
+				if slices.Contains(published, k.ID) || key.ID == k.ID {
+					t.Errorf("the revoked key %s must not be used", k.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyManagerAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	for _, alg := range AsymmetricAlgs {
+		t.Run(alg, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			policy := testKeyRotationPolicy
+			policy.Algorithm = alg
+			m, _ := newTestKeyManager(t, keyStorage{}, policy)
+			if err := m.Rotate(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := m.SigningKey(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := SignJWT(&JWSHeader{Algorithm: key.Algorithm, KeyID: key.ID}, key.Key, &RegisteredClaims{Subject: "dummy-user-id"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			jwks, err := m.JWKS(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := &RegisteredClaims{}
+			if _, err := ParseJWT(token, JWKSetKeyFunc(&model.JWKSet{Keys: jwks}, KeyUseSignature, alg), claims); err != nil {
+				t.Fatalf("the JWT must be verified with JWKS: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyManagerEncryptsKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage := keyStorage{}
+	m, _ := newTestKeyManager(t, storage, testKeyRotationPolicy)
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	key, err := m.SigningKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(storage[key.ID].EncryptedKey, der) {
+		t.Fatal("the private key must not be stored in plaintext")
+	}
+
+	// the keys are restored from the storage with the same key-encryption key, e.g. after restarting.
+	restarted, _ := newTestKeyManager(t, storage, testKeyRotationPolicy)
+	restored, err := restarted.SigningKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != key.ID || !restored.Key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(key.Key.Public()) {
+		t.Errorf("the key must be restored: want %s, got %s", key.ID, restored.ID)
+	}
+
+	other, err := NewKeyManager(storage, bytes.Repeat([]byte{0x24}, 32), testKeyRotationPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.SigningKey(ctx); !errors.Is(err, ErrKeyDecryptionFailed) {
+		t.Errorf("unexpected error with another key-encryption key: want %v, got %v", ErrKeyDecryptionFailed, err)
+	}
+
+	// the sealed keys are bound to their kid.
+	swapped := storage[key.ID]
+	swapped.ID = "swapped-kid"
+	swapped.State = model.KeyStateActive
+	tampered := keyStorage{swapped.ID: swapped}
+	m2, _ := newTestKeyManager(t, tampered, testKeyRotationPolicy)
+	if _, err := m2.SigningKey(ctx); !errors.Is(err, ErrKeyDecryptionFailed) {
+		t.Errorf("unexpected error with the swapped key: want %v, got %v", ErrKeyDecryptionFailed, err)
+	}
+}
+
+func TestNewKeyManager(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		kek     []byte
+		policy  func(p *model.KeyRotationPolicy)
+		wantErr error
+	}{
+		"ok": {
+			kek:    testKeyEncryptionKey,
+			policy: func(p *model.KeyRotationPolicy) {},
+		},
+		"ng: short key-encryption key": {
+			kek:     testKeyEncryptionKey[:16],
+			policy:  func(p *model.KeyRotationPolicy) {},
+			wantErr: ErrKeyEncryptionKey,
+		},
+		"ng: symmetric algorithm": {
+			kek:     testKeyEncryptionKey,
+			policy:  func(p *model.KeyRotationPolicy) { p.Algorithm = AlgHS256 },
+			wantErr: ErrKeyRotationPolicy,
+		},
+		"ng: next key published longer than the rotation period": {
+			kek:     testKeyEncryptionKey,
+			policy:  func(p *model.KeyRotationPolicy) { p.PublishBefore = p.RotationPeriod },
+			wantErr: ErrKeyRotationPolicy,
+		},
+		"ng: retired keys unpublished at once": {
+			kek:     testKeyEncryptionKey,
+			policy:  func(p *model.KeyRotationPolicy) { p.RetireAfter = 0 },
+			wantErr: ErrKeyRotationPolicy,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := testKeyRotationPolicy
+			tt.policy(&policy)
+			_, err := NewKeyManager(keyStorage{}, tt.kek, policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyManagerDecryptionKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	policy := testKeyRotationPolicy
+	policy.Algorithm = AlgRSAOAEP256
+	storage := keyStorage{}
+	m, err := NewEncryptionKeyManager(storage, testKeyEncryptionKey, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	m.now = func() time.Time { return now }
+	m.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := m.Rotate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	active, err := m.DecryptionKey(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.Use != KeyUseEncryption || active.ID != keyID(t, storage, model.KeyStateActive) {
+		t.Fatalf("unexpected key: %+v", active)
+	}
+
+	// the retired key decrypts until it expires, while it is unpublished at once.
+	if err := m.RotateNow(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.DecryptionKey(ctx, active.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ids := jwkIDs(t, m); slices.Contains(ids, active.ID) {
+		t.Errorf("retired key is published: %v", ids)
+	}
+	now = now.Add(policy.RetireAfter)
+	if _, err := m.DecryptionKey(ctx, active.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("want error %v, got %v", ErrKeyNotFound, err)
+	}
+}
+
+func TestNewEncryptionKeyManager(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		alg     string
+		wantErr error
+	}{
+		"ok": {
+			alg: AlgRSAOAEP256,
+		},
+		"ng: signing algorithm": {
+			alg:     AlgES256,
+			wantErr: ErrKeyRotationPolicy,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := testKeyRotationPolicy
+			policy.Algorithm = tt.alg
+			_, err := NewEncryptionKeyManager(keyStorage{}, testKeyEncryptionKey, policy)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/repository"
+)
+
+var ErrLockedOut = errors.New("too many failed attempts")
+
+// LockedOutError tells when the attempts of Key may be tried again.
+type LockedOutError struct {
+	Key        model.FailureKey
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrLockedOut, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedOutError) Unwrap() error {
+	return ErrLockedOut
+}
+
+// ref: https://cheatsheetseries.owasp.org/cheatsheets/Logging_Vocabulary_Cheat_Sheet.html#authentication-authn
+const (
+	SecurityEventFailure = "authn_login_fail"
+	SecurityEventBlocked = "authn_login_blocked"
+	SecurityEventLockout = "authn_login_lock"
+	SecurityEventUnlock  = "authn_login_unlock"
+)
+
+// Lockout throttles the attempts to guess passwords and client secrets with the failure counters.
+// A nil Lockout allows every attempt.
+type Lockout struct {
+	storage  repository.LockoutStorage
+	policies map[model.FailureKind]model.LockoutPolicy
+
+	// Logger writes the security events, which is slog.Default() if it is nil.
+	Logger *slog.Logger
+
+	now func() time.Time
+}
+
+func NewLockout(storage repository.LockoutStorage) *Lockout {
+	if storage == nil {
+		return nil
+	}
+	return &Lockout{
+		storage: storage,
+		policies: map[model.FailureKind]model.LockoutPolicy{
+			model.FailureKindUser:   model.DefaultLockoutPolicy,
+			model.FailureKindClient: model.DefaultLockoutPolicy,
+			model.FailureKindIP:     model.DefaultIPLockoutPolicy,
+			// ref: https://datatracker.ietf.org/doc/html/rfc6238#section-5.2
+			model.FailureKindOTP:               model.DefaultLockoutPolicy,
+			model.FailureKindSecondFactorToken: model.DefaultSecondFactorTokenPolicy,
+		},
+		now: time.Now,
+	}
+}
+
+// WithPolicy returns the lockout which counts the failures of kind with policy.
+func (l *Lockout) WithPolicy(kind model.FailureKind, policy model.LockoutPolicy) *Lockout {
+	v := *l
+	v.policies = make(map[model.FailureKind]model.LockoutPolicy, len(l.policies))
+	for k, p := range l.policies {
+		v.policies[k] = p
+	}
+	v.policies[kind] = policy
+	return &v
+}
+
+// Check returns *LockedOutError if any of keys has to wait before the next attempt.
+func (l *Lockout) Check(ctx context.Context, keys ...model.FailureKey) error {
+	if l == nil {
+		return nil
+	}
+
+	var locked *LockedOutError
+	for _, key := range keys {
+		c, err := l.storage.GetFailureCounter(ctx, key)
+		if err != nil {
+			return err
+		}
+		retryAfter := l.policies[key.Kind].RetryAfter(c, l.now())
+		if retryAfter > 0 && (locked == nil || retryAfter > locked.RetryAfter) {
+			locked = &LockedOutError{Key: key, RetryAfter: retryAfter}
+		}
+	}
+	if locked != nil {
+		l.logger().WarnContext(ctx, "security event",
+			slog.String("event", SecurityEventBlocked),
+			slog.String("kind", string(locked.Key.Kind)),
+			slog.String("key", locked.Key.Key),
+			slog.Duration("retry_after", locked.RetryAfter),
+		)
+		return locked
+	}
+	return nil
+}
+
+// Fail counts the failed attempt for each of keys.
+func (l *Lockout) Fail(ctx context.Context, keys ...model.FailureKey) error {
+	if l == nil {
+		return nil
+	}
+
+	now := l.now()
+	for _, key := range keys {
+		c, locked, err := l.storage.IncrementFailure(ctx, key, l.policies[key.Kind], now)
+		if err != nil {
+			return err
+		}
+
+		l.logger().InfoContext(ctx, "security event",
+			slog.String("event", SecurityEventFailure),
+			slog.String("kind", string(key.Kind)),
+			slog.String("key", key.Key),
+			slog.Int("failures", c.Failures),
+		)
+		if locked {
+			l.logger().WarnContext(ctx, "security event",
+				slog.String("event", SecurityEventLockout),
+				slog.String("kind", string(key.Kind)),
+				slog.String("key", key.Key),
+				slog.Time("locked_until", c.LockedUntil),
+			)
+		}
+	}
+	return nil
+}
+
+// Succeed forgets the failures of keys after the successful attempt.
+// The failures of addresses are not passed, as the attacker may succeed with its own account.
+func (l *Lockout) Succeed(ctx context.Context, keys ...model.FailureKey) error {
+	if l == nil {
+		return nil
+	}
+
+	for _, key := range keys {
+		// the lockout lasts even if the correct secret is found during it.
+		err := l.storage.ResetFailureCounter(ctx, key, l.now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unlock forgets the failures and the lockout of key, which administrators do for the users locked out.
+func (l *Lockout) Unlock(ctx context.Context, key model.FailureKey) error {
+	if l == nil {
+		return nil
+	}
+
+	err := l.storage.DeleteFailureCounter(ctx, key)
+	if err != nil {
+		return err
+	}
+	l.logger().WarnContext(ctx, "security event",
+		slog.String("event", SecurityEventUnlock),
+		slog.String("kind", string(key.Kind)),
+		slog.String("key", key.Key),
+	)
+	return nil
+}
+
+// Counters returns the counters which have failures or are locked out.
+func (l *Lockout) Counters(ctx context.Context) ([]*model.FailureCounter, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return l.storage.ListFailureCounters(ctx)
+}
+
+func (l *Lockout) logger() *slog.Logger {
+	if l.Logger == nil {
+		return slog.Default()
+	}
+	return l.Logger
+}
+
+type remoteIPKey struct{}
+
+// ContextWithRemoteIP returns ctx with the address of the request, by which the failures are counted.
+func ContextWithRemoteIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, remoteIPKey{}, ip)
+}
+
+// RemoteIPFromContext returns the address of the request, which is set by ContextWithRemoteIP.
+func RemoteIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(remoteIPKey{}).(string)
+	return ip, ok && ip != ""
+}
+
+// FailureKeys returns the keys of the attempt for key, and the address of ctx if it is known.
+func FailureKeys(ctx context.Context, kind model.FailureKind, key string) []model.FailureKey {
+	keys := []model.FailureKey{{Kind: kind, Key: key}}
+	if ip, ok := RemoteIPFromContext(ctx); ok {
+		keys = append(keys, model.FailureKey{Kind: model.FailureKindIP, Key: ip})
+	}
+	return keys
+}
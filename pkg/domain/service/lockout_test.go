@@ -0,0 +1,262 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// lockoutStorage is the in-memory storage of the tests, which the infra package cannot provide without the import cycle.
+type lockoutStorage struct {
+	mu         sync.Mutex
+	counterKvs map[model.FailureKey]model.FailureCounter
+}
+
+func (s *lockoutStorage) GetFailureCounter(_ context.Context, key model.FailureKey) (*model.FailureCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counterKvs[key]
+	if !ok {
+		return &model.FailureCounter{FailureKey: key}, nil
+	}
+	return &c, nil
+}
+
+func (s *lockoutStorage) IncrementFailure(_ context.Context, key model.FailureKey, policy model.LockoutPolicy, now time.Time) (*model.FailureCounter, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counterKvs[key]
+	if !ok {
+		c = model.FailureCounter{FailureKey: key}
+	}
+	locked := policy.RecordFailure(&c, now)
+	s.counterKvs[key] = c
+	return &c, locked, nil
+}
+
+func (s *lockoutStorage) ResetFailureCounter(_ context.Context, key model.FailureKey, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.counterKvs[key]; ok && !c.IsLocked(now) {
+		delete(s.counterKvs, key)
+	}
+	return nil
+}
+
+func (s *lockoutStorage) DeleteFailureCounter(_ context.Context, key model.FailureKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counterKvs, key)
+	return nil
+}
+
+func (s *lockoutStorage) ListFailureCounters(context.Context) ([]*model.FailureCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var counters []*model.FailureCounter
+	for _, c := range s.counterKvs {
+		counters = append(counters, &c)
+	}
+	return counters, nil
+}
+
+// newTestLockout returns the lockout whose clock is moved by the returned function.
+func newTestLockout(events *bytes.Buffer) (*Lockout, func(time.Duration)) {
+	now := time.Unix(1_700_000_000, 0)
+	l := NewLockout(&lockoutStorage{counterKvs: map[model.FailureKey]model.FailureCounter{}})
+	l.now = func() time.Time { return now }
+	l.Logger = slog.New(slog.NewJSONHandler(events, nil))
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	key := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+	l, advance := newTestLockout(&bytes.Buffer{})
+
+	// the free attempts are not delayed.
+	for range model.DefaultLockoutPolicy.FreeAttempts {
+		if err := l.Fail(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Check(ctx, key); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// the delay doubles on each failure after them.
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if err := l.Fail(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+		var locked *LockedOutError
+		if err := l.Check(ctx, key); !errors.As(err, &locked) || locked.RetryAfter != want {
+			t.Fatalf("want retry after %s, got %v", want, err)
+		}
+		advance(want)
+		if err := l.Check(ctx, key); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// the delay is forgotten on success.
+	if err := l.Fail(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	advance(time.Minute)
+	if err := l.Succeed(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Fail(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLockoutLockout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		after   func(t *testing.T, l *Lockout, key model.FailureKey, advance func(time.Duration))
+		wantErr error
+	}{
+		"ng: locked out": {
+			after:   func(*testing.T, *Lockout, model.FailureKey, func(time.Duration)) {},
+			wantErr: ErrLockedOut,
+		},
+		"ng: success during the lockout": {
+			after: func(t *testing.T, l *Lockout, key model.FailureKey, _ func(time.Duration)) {
+				if err := l.Succeed(context.Background(), key); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrLockedOut,
+		},
+		"ok: lockout expired": {
+			after: func(_ *testing.T, _ *Lockout, _ model.FailureKey, advance func(time.Duration)) {
+				advance(model.DefaultLockoutPolicy.LockoutDuration)
+			},
+		},
+		"ok: unlocked by admin": {
+			after: func(t *testing.T, l *Lockout, key model.FailureKey, _ func(time.Duration)) {
+				if err := l.Unlock(context.Background(), key); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			key := model.FailureKey{Kind: model.FailureKindClient, Key: "dummy-client-id"}
+			events := &bytes.Buffer{}
+			l, advance := newTestLockout(events)
+			for range model.DefaultLockoutPolicy.LockoutThreshold {
+				if err := l.Fail(ctx, key); err != nil {
+					t.Fatal(err)
+				}
+				advance(model.DefaultLockoutPolicy.MaxDelay)
+			}
+			tt.after(t, l, key, advance)
+
+			err := l.Check(ctx, key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+
+			// the lockout is recorded as the security event.
+			var lockouts int
+			dec := json.NewDecoder(events)
+			for dec.More() {
+				var event struct {
+					Event string `json:"event"`
+					Kind  string `json:"kind"`
+					Key   string `json:"key"`
+				}
+				if err := dec.Decode(&event); err != nil {
+					t.Fatal(err)
+				}
+				if event.Event == SecurityEventLockout && event.Kind == "client" && event.Key == key.Key {
+					lockouts++
+				}
+			}
+			if lockouts != 1 {
+				t.Errorf("want 1 lockout event, got %d", lockouts)
+			}
+		})
+	}
+}
+
+func TestLockoutResetAfter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	key := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+	l, advance := newTestLockout(&bytes.Buffer{})
+	for range model.DefaultLockoutPolicy.LockoutThreshold - 1 {
+		if err := l.Fail(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the failures long ago are forgotten, so that the next failure does not lock the user out.
+	advance(model.DefaultLockoutPolicy.ResetAfter)
+	if err := l.Fail(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Check(ctx, key); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestFailureKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ctx  context.Context
+		want []model.FailureKey
+	}{
+		"ok: with address": {
+			ctx: ContextWithRemoteIP(context.Background(), "192.0.2.1"),
+			want: []model.FailureKey{
+				{Kind: model.FailureKindUser, Key: "dummy-user"},
+				{Kind: model.FailureKindIP, Key: "192.0.2.1"},
+			},
+		},
+		"ok: without address": {
+			ctx:  context.Background(),
+			want: []model.FailureKey{{Kind: model.FailureKindUser, Key: "dummy-user"}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := FailureKeys(tt.ctx, model.FailureKindUser, "dummy-user")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+const (
+	loginTokenType     = "login+jwt"
+	loginTokenLifetime = time.Minute
+)
+
+var ErrLoginTokenInvalid = errors.New("login token is invalid")
+
+type loginTokenClaims struct {
+	RegisteredClaims
+	AuthTime int64    `json:"auth_time"`
+	ACR      string   `json:"acr,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	SID      string   `json:"sid,omitempty"`
+}
+
+// LoginTokenCodec hands the result of a user authentication over from the authentication
+// server to the authorization server through the user agent.
+// The token is bound to an authorization request and signed with a key shared by both servers.
+type LoginTokenCodec struct {
+	key      []byte
+	lifetime time.Duration
+	now      func() time.Time
+}
+
+func NewLoginTokenCodec(key []byte) *LoginTokenCodec {
+	return &LoginTokenCodec{
+		key:      key,
+		lifetime: loginTokenLifetime,
+		now:      time.Now,
+	}
+}
+
+// WithLifetime returns the codec whose tokens expire after lifetime, e.g. to keep the user
+// authenticated while the authentication server asks for another factor.
+func (c *LoginTokenCodec) WithLifetime(lifetime time.Duration) *LoginTokenCodec {
+	return &LoginTokenCodec{
+		key:      c.key,
+		lifetime: lifetime,
+		now:      c.now,
+	}
+}
+
+func (c *LoginTokenCodec) Encode(authReqID string, authn *model.Authentication) (string, error) {
+	now := c.now()
+	claims := &loginTokenClaims{
+		RegisteredClaims: RegisteredClaims{
+			Subject:   authn.Subject,
+			Audience:  Audience{authReqID},
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(c.lifetime).Unix(),
+			ID:        uuid.NewString(),
+		},
+		AuthTime: authn.AuthTime.Unix(),
+		ACR:      authn.ACR,
+		AMR:      authn.AMR,
+		SID:      authn.SessionID,
+	}
+	return SignJWT(&JWSHeader{Algorithm: AlgHS256, Type: loginTokenType}, c.key, claims)
+}
+
+func (c *LoginTokenCodec) Decode(token string, authReqID string) (*model.Authentication, error) {
+	claims := &loginTokenClaims{}
+	header, err := ParseJWT(token, func(*JWSHeader) (any, error) { return c.key, nil }, claims)
+	if err != nil {
+		return nil, errors.Join(ErrLoginTokenInvalid, err)
+	}
+	if header.Type != loginTokenType {
+		return nil, ErrLoginTokenInvalid
+	}
+	if err := claims.Validate(c.now(), "", authReqID); err != nil {
+		return nil, errors.Join(ErrLoginTokenInvalid, err)
+	}
+	if claims.Subject == "" {
+		return nil, ErrLoginTokenInvalid
+	}
+
+	return &model.Authentication{
+		Subject:   claims.Subject,
+		AuthTime:  time.Unix(claims.AuthTime, 0),
+		ACR:       claims.ACR,
+		AMR:       claims.AMR,
+		SessionID: claims.SID,
+	}, nil
+}
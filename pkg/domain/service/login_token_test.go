@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestLoginTokenCodec(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	authn := &model.Authentication{
+		Subject:   "user-id",
+		AuthTime:  now,
+		ACR:       model.ACRBasic,
+		AMR:       []string{model.AMRPassword},
+		SessionID: "session-id",
+	}
+
+	tests := map[string]struct {
+		decodeKey []byte
+		authReqID string
+		elapsed   time.Duration
+		wantErr   bool
+	}{
+		"ok": {
+			decodeKey: []byte("key"),
+			authReqID: "auth-req-id",
+		},
+		"ng: key is not same": {
+			decodeKey: []byte("other-key"),
+			authReqID: "auth-req-id",
+			wantErr:   true,
+		},
+		"ng: bound to another request": {
+			decodeKey: []byte("key"),
+			authReqID: "other-auth-req-id",
+			wantErr:   true,
+		},
+		"ng: expired": {
+			decodeKey: []byte("key"),
+			authReqID: "auth-req-id",
+			elapsed:   2 * time.Minute,
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			encoder := &LoginTokenCodec{key: []byte("key"), lifetime: loginTokenLifetime, now: func() time.Time { return now }}
+			decoder := &LoginTokenCodec{key: tt.decodeKey, lifetime: loginTokenLifetime, now: func() time.Time { return now.Add(tt.elapsed) }}
+
+			token, err := encoder.Encode("auth-req-id", authn)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := decoder.Decode(token, tt.authReqID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrLoginTokenInvalid) {
+					t.Errorf("want %v, got %v", ErrLoginTokenInvalid, err)
+				}
+				return
+			}
+			if got.Subject != authn.Subject || !got.AuthTime.Equal(authn.AuthTime) || got.ACR != authn.ACR || got.SessionID != authn.SessionID {
+				t.Errorf("want %+v, got %+v", authn, got)
+			}
+		})
+	}
+}
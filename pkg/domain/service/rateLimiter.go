@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var ErrRateLimited = errors.New("too many requests")
+
+// RateLimitedError tells when the client over its rate limit may request again.
+type RateLimitedError struct {
+	ClientID   string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// rateLimiterSweepInterval is how often the buckets which are full again are removed to bound the memory.
+const rateLimiterSweepInterval = time.Minute
+
+// RateLimiter limits the requests with the token buckets of the keys.
+// ref: https://en.wikipedia.org/wiki/Token_bucket
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type tokenBucket struct {
+	limit     model.RateLimit
+	tokens    float64
+	updatedAt time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key, and returns how long the request has to wait if no token is left.
+func (l *RateLimiter) Allow(key string, limit model.RateLimit) (time.Duration, bool) {
+	if limit.IsUnlimited() {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &tokenBucket{limit: limit, tokens: float64(limit.Burst), updatedAt: now}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	b.tokens = min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.updatedAt = now
+}
+
+// sweep removes the buckets which are full, as they are the same as the new ones.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+type clientRateLimitKey struct{}
+
+// ContextWithClientRateLimit returns ctx with the limit of the endpoint, which is applied to the client
+// once it is authenticated so that the clients are not throttled by the requests claiming their client_id.
+func ContextWithClientRateLimit(ctx context.Context, allow func(clientID string) error) context.Context {
+	return context.WithValue(ctx, clientRateLimitKey{}, allow)
+}
+
+// AllowClient applies the limit of ctx to the authenticated client, which is allowed if ctx has no limit.
+func AllowClient(ctx context.Context, clientID string) error {
+	allow, ok := ctx.Value(clientRateLimitKey{}).(func(string) error)
+	if !ok {
+		return nil
+	}
+	return allow(clientID)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	t.Parallel()
+
+	limit := model.RateLimit{Rate: 2, Burst: 3}
+
+	tests := map[string]struct {
+		limit     model.RateLimit
+		requests  int
+		wait      time.Duration
+		want      bool
+		wantRetry time.Duration
+	}{
+		"ok: within the burst": {
+			limit:    limit,
+			requests: 2,
+			want:     true,
+		},
+		"ng: over the burst": {
+			limit:     limit,
+			requests:  3,
+			want:      false,
+			wantRetry: 500 * time.Millisecond,
+		},
+		"ng: partially refilled": {
+			limit:     limit,
+			requests:  3,
+			wait:      250 * time.Millisecond,
+			want:      false,
+			wantRetry: 250 * time.Millisecond,
+		},
+		"ok: refilled": {
+			limit:    limit,
+			requests: 3,
+			wait:     500 * time.Millisecond,
+			want:     true,
+		},
+		"ok: unlimited": {
+			limit:    model.RateLimit{},
+			requests: 100,
+			want:     true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Unix(1_700_000_000, 0)
+			l := NewRateLimiter()
+			l.now = func() time.Time { return now }
+			for range tt.requests {
+				if _, ok := l.Allow("dummy-client-id", tt.limit); !ok {
+					t.Fatal("unexpected limit")
+				}
+			}
+			now = now.Add(tt.wait)
+
+			retryAfter, ok := l.Allow("dummy-client-id", tt.limit)
+			if ok != tt.want || retryAfter != tt.wantRetry {
+				t.Errorf("want (%s, %t), got (%s, %t)", tt.wantRetry, tt.want, retryAfter, ok)
+			}
+			// the other keys have their own buckets.
+			if _, ok := l.Allow("other-client-id", tt.limit); !ok {
+				t.Error("other key is limited")
+			}
+		})
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	l := NewRateLimiter()
+	l.now = func() time.Time { return now }
+	limit := model.RateLimit{Rate: 1, Burst: 1}
+	l.Allow("dummy-client-id", limit)
+
+	// the bucket which is full again is removed.
+	now = now.Add(rateLimiterSweepInterval)
+	l.Allow("other-client-id", limit)
+	if _, ok := l.buckets["dummy-client-id"]; ok {
+		t.Error("full bucket is not removed")
+	}
+	if _, ok := l.buckets["other-client-id"]; !ok {
+		t.Error("used bucket is removed")
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ref: https://datatracker.ietf.org/doc/html/rfc6238#section-4
+const (
+	TOTPPeriod = 30 * time.Second
+	TOTPDigits = 6
+	// TOTPSkew is the number of periods before and after the current one in which codes are accepted,
+	// as the clock of the authenticator may drift.
+	// ref: https://datatracker.ietf.org/doc/html/rfc6238#section-6
+	TOTPSkew = 1
+
+	totpSecretSize = 20 // the size of the HMAC-SHA1 output, ref: https://datatracker.ietf.org/doc/html/rfc4226#section-4
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// EncodeTOTPSecret encodes secret in base32 so that the user can type it in the authenticator.
+func EncodeTOTPSecret(secret []byte) string {
+	return totpEncoding.EncodeToString(secret)
+}
+
+// TOTPKeyURI returns the URI with which authenticators register secret, which is usually shown as a QR code.
+// ref: https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func TOTPKeyURI(issuer, account string, secret []byte) string {
+	params := url.Values{}
+	params.Set("secret", EncodeTOTPSecret(secret))
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+
+	u := &url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// TOTPStep returns the number of periods since the Unix epoch at t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// TOTPCode returns the code of secret at step.
+// ref: https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
+func TOTPCode(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range TOTPDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, code%mod)
+}
+
+// VerifyTOTP checks code within the drift window at now, and returns the step of code.
+// Codes of lastUsedStep and earlier are rejected so that a used code cannot be replayed.
+// ref: https://datatracker.ietf.org/doc/html/rfc6238#section-5.2
+func VerifyTOTP(secret []byte, code string, now time.Time, lastUsedStep int64) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(TOTPCode(secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode(t *testing.T) {
+	t.Parallel()
+
+	// the SHA1 test vectors truncated to 6 digits, ref: https://datatracker.ietf.org/doc/html/rfc6238#appendix-B
+	secret := []byte("12345678901234567890")
+	tests := map[string]struct {
+		time time.Time
+		want string
+	}{
+		"ok: 59":         {time: time.Unix(59, 0), want: "287082"},
+		"ok: 1111111109": {time: time.Unix(1111111109, 0), want: "081804"},
+		"ok: 1234567890": {time: time.Unix(1234567890, 0), want: "005924"},
+		"ok: 2000000000": {time: time.Unix(2000000000, 0), want: "279037"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := TOTPCode(secret, TOTPStep(tt.time))
+			if got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1_700_000_000, 0)
+	current := TOTPStep(now)
+
+	tests := map[string]struct {
+		code         string
+		lastUsedStep int64
+		wantStep     int64
+		wantOK       bool
+	}{
+		"ok: current": {
+			code:     TOTPCode(secret, current),
+			wantStep: current,
+			wantOK:   true,
+		},
+		"ok: previous period by drift": {
+			code:     TOTPCode(secret, current-1),
+			wantStep: current - 1,
+			wantOK:   true,
+		},
+		"ok: next period by drift": {
+			code:     TOTPCode(secret, current+1),
+			wantStep: current + 1,
+			wantOK:   true,
+		},
+		"ng: out of the drift window": {
+			code: TOTPCode(secret, current-2),
+		},
+		"ng: replayed": {
+			code:         TOTPCode(secret, current),
+			lastUsedStep: current,
+		},
+		"ng: wrong code": {
+			code: "000000",
+		},
+		"ng: wrong length": {
+			code: "12345",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			step, ok := VerifyTOTP(secret, tt.code, now, tt.lastUsedStep)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("want (%d, %v), got (%d, %v)", tt.wantStep, tt.wantOK, step, ok)
+			}
+		})
+	}
+}
+
+func TestTOTPKeyURI(t *testing.T) {
+	t.Parallel()
+
+	got, err := url.Parse(TOTPKeyURI("oauth-go", "dummy-user", []byte("12345678901234567890")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Scheme != "otpauth" || got.Host != "totp" || got.Path != "/oauth-go:dummy-user" {
+		t.Errorf("unexpected URI: %s", got)
+	}
+	if q := got.Query(); q.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" || q.Get("issuer") != "oauth-go" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected parameters: %s", got.RawQuery)
+	}
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	ErrWebAuthnMalformed        = errors.New("webauthn data is malformed")
+	ErrWebAuthnInvalid          = errors.New("webauthn data is invalid")
+	ErrWebAuthnInvalidSignature = errors.New("webauthn signature is invalid")
+	ErrWebAuthnUnsupported      = errors.New("webauthn data is unsupported")
+)
+
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-authenticator-data
+const (
+	WebAuthnFlagUserPresent            byte = 0x01
+	WebAuthnFlagUserVerified           byte = 0x04
+	WebAuthnFlagAttestedCredentialData byte = 0x40
+	WebAuthnFlagExtensionData          byte = 0x80
+)
+
+// ref: https://www.w3.org/TR/webauthn-3/#dom-collectedclientdata-type
+const (
+	WebAuthnTypeCreate = "webauthn.create"
+	WebAuthnTypeGet    = "webauthn.get"
+)
+
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-defined-attestation-formats
+const (
+	AttestationFormatNone   = "none"
+	AttestationFormatPacked = "packed"
+)
+
+// ref: https://www.iana.org/assignments/cose/cose.xhtml#algorithms
+const (
+	COSEAlgES256 int64 = -7
+	COSEAlgEdDSA int64 = -8
+	COSEAlgRS256 int64 = -257
+)
+
+// WebAuthnAlgs are the algorithms of the credential keys, in the order of preference.
+var WebAuthnAlgs = []int64{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+// ref: https://www.iana.org/assignments/cose/cose.xhtml#key-common-parameters
+const (
+	coseKeyType      int64 = 1
+	coseKeyAlg       int64 = 3
+	coseKeyCurve     int64 = -1 // n for RSA
+	coseKeyX         int64 = -2 // e for RSA
+	coseKeyY         int64 = -3
+	coseKeyTypeOKP         = 1
+	coseKeyTypeEC2         = 2
+	coseKeyTypeRSA         = 3
+	coseCurveP256          = 1
+	coseCurveEd25519       = 6
+)
+
+// idFidoGenCeAAGUID is the extension of attestation certificates which contains the AAGUID of the authenticator.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-packed-attestation-cert-requirements
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// AuthenticatorData is the data which the authenticator signs in the ceremonies.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-authenticator-data
+type AuthenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+
+	// the attested credential data, which is set on registration.
+	// ref: https://www.w3.org/TR/webauthn-3/#sctn-attested-credential-data
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte // COSE_Key
+}
+
+func ParseAuthenticatorData(b []byte) (*AuthenticatorData, error) {
+	if len(b) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data is too short", ErrWebAuthnMalformed)
+	}
+	d := &AuthenticatorData{
+		RPIDHash:  b[:32],
+		Flags:     b[32],
+		SignCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[37:]
+
+	if d.HasFlag(WebAuthnFlagAttestedCredentialData) {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data is too short", ErrWebAuthnMalformed)
+		}
+		d.AAGUID = rest[:16]
+		n := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < n {
+			return nil, fmt.Errorf("%w: credential ID is too short", ErrWebAuthnMalformed)
+		}
+		d.CredentialID = rest[:n]
+		rest = rest[n:]
+
+		_, after, err := DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %w", ErrWebAuthnMalformed, err)
+		}
+		d.PublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if d.HasFlag(WebAuthnFlagExtensionData) {
+		var err error
+		_, rest, err = DecodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: extensions: %w", ErrWebAuthnMalformed, err)
+		}
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrWebAuthnMalformed)
+	}
+	return d, nil
+}
+
+func (d *AuthenticatorData) HasFlag(flag byte) bool {
+	return d.Flags&flag != 0
+}
+
+// VerifyRPID checks that the credential is scoped to the relying party.
+func (d *AuthenticatorData) VerifyRPID(rpID string) error {
+	sum := sha256.Sum256([]byte(rpID))
+	if subtle.ConstantTimeCompare(d.RPIDHash, sum[:]) != 1 {
+		return fmt.Errorf("%w: rpIdHash is mismatched", ErrWebAuthnInvalid)
+	}
+	return nil
+}
+
+// ref: https://www.w3.org/TR/webauthn-3/#dictionary-client-data
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"` // base64url-encoded
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// VerifyClientData checks that the client data is collected for the ceremony of typ with challenge at origin,
+// and returns its hash which the authenticator signs.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-registering-a-new-credential
+func VerifyClientData(clientDataJSON []byte, typ string, challenge []byte, origin string) ([]byte, error) {
+	c := &CollectedClientData{}
+	if err := json.Unmarshal(clientDataJSON, c); err != nil {
+		return nil, fmt.Errorf("%w: client data: %w", ErrWebAuthnMalformed, err)
+	}
+	if c.Type != typ {
+		return nil, fmt.Errorf("%w: type is mismatched", ErrWebAuthnInvalid)
+	}
+	got, err := b64.DecodeString(c.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return nil, fmt.Errorf("%w: challenge is mismatched", ErrWebAuthnInvalid)
+	}
+	if c.Origin != origin || c.CrossOrigin {
+		return nil, fmt.Errorf("%w: origin is mismatched", ErrWebAuthnInvalid)
+	}
+
+	sum := sha256.Sum256(clientDataJSON)
+	return sum[:], nil
+}
+
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-attestation
+type AttestationObject struct {
+	Format   string
+	AttStmt  map[any]any
+	AuthData []byte
+}
+
+func ParseAttestationObject(b []byte) (*AttestationObject, error) {
+	v, rest, err := DecodeCBOR(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation object: %w", ErrWebAuthnMalformed, err)
+	}
+	m, ok := v.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return nil, fmt.Errorf("%w: attestation object is not a map", ErrWebAuthnMalformed)
+	}
+
+	obj := &AttestationObject{}
+	obj.Format, _ = m["fmt"].(string)
+	obj.AttStmt, _ = m["attStmt"].(map[any]any)
+	obj.AuthData, _ = m["authData"].([]byte)
+	if obj.Format == "" || obj.AttStmt == nil || obj.AuthData == nil {
+		return nil, fmt.Errorf("%w: fmt, attStmt and authData are required", ErrWebAuthnMalformed)
+	}
+	return obj, nil
+}
+
+// VerifyAttestation verifies the attestation statement in the none or packed formats, and returns the
+// authenticator data with the new credential.
+// Attestation certificates are not validated against trust anchors, as the authentication server does not
+// restrict the authenticators of users.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-registering-a-new-credential
+func VerifyAttestation(obj *AttestationObject, clientDataHash []byte) (*AuthenticatorData, error) {
+	authData, err := ParseAuthenticatorData(obj.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if !authData.HasFlag(WebAuthnFlagAttestedCredentialData) {
+		return nil, fmt.Errorf("%w: attested credential data is missing", ErrWebAuthnInvalid)
+	}
+	pub, alg, err := ParseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch obj.Format {
+	case AttestationFormatNone:
+		// ref: https://www.w3.org/TR/webauthn-3/#sctn-none-attestation
+		if len(obj.AttStmt) != 0 {
+			return nil, fmt.Errorf("%w: attStmt of none must be empty", ErrWebAuthnInvalid)
+		}
+	case AttestationFormatPacked:
+		err := verifyPackedAttestation(obj, authData, clientDataHash, pub, alg)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: attestation format %s", ErrWebAuthnUnsupported, obj.Format)
+	}
+	return authData, nil
+}
+
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-packed-attestation
+func verifyPackedAttestation(obj *AttestationObject, authData *AuthenticatorData, clientDataHash []byte, credentialKey crypto.PublicKey, credentialAlg int64) error {
+	alg, ok := obj.AttStmt["alg"].(int64)
+	if !ok {
+		return fmt.Errorf("%w: alg of packed is required", ErrWebAuthnMalformed)
+	}
+	sig, ok := obj.AttStmt["sig"].([]byte)
+	if !ok {
+		return fmt.Errorf("%w: sig of packed is required", ErrWebAuthnMalformed)
+	}
+	signed := append(append([]byte{}, obj.AuthData...), clientDataHash...)
+
+	x5c, ok := obj.AttStmt["x5c"].([]any)
+	if !ok {
+		// self attestation is signed with the credential key.
+		if alg != credentialAlg {
+			return fmt.Errorf("%w: alg is mismatched with the credential key", ErrWebAuthnInvalid)
+		}
+		return VerifyWebAuthnSignature(credentialKey, alg, signed, sig)
+	}
+
+	if len(x5c) == 0 {
+		return fmt.Errorf("%w: x5c is empty", ErrWebAuthnMalformed)
+	}
+	der, ok := x5c[0].([]byte)
+	if !ok {
+		return fmt.Errorf("%w: x5c must contain certificates", ErrWebAuthnMalformed)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("%w: attestation certificate: %w", ErrWebAuthnMalformed, err)
+	}
+	err = VerifyWebAuthnSignature(cert.PublicKey, alg, signed, sig)
+	if err != nil {
+		return err
+	}
+
+	// ref: https://www.w3.org/TR/webauthn-3/#sctn-packed-attestation-cert-requirements
+	if cert.Version != 3 || (cert.BasicConstraintsValid && cert.IsCA) {
+		return fmt.Errorf("%w: attestation certificate does not meet the requirements", ErrWebAuthnInvalid)
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(ext.Value, &aaguid); err != nil || subtle.ConstantTimeCompare(aaguid, authData.AAGUID) != 1 {
+			return fmt.Errorf("%w: AAGUID of the attestation certificate is mismatched", ErrWebAuthnInvalid)
+		}
+	}
+	return nil
+}
+
+// ParseCOSEKey returns the public key in the COSE_Key format and its algorithm.
+// ref: https://www.rfc-editor.org/rfc/rfc9053#section-7
+func ParseCOSEKey(b []byte) (crypto.PublicKey, int64, error) {
+	v, rest, err := DecodeCBOR(b)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: COSE key: %w", ErrWebAuthnMalformed, err)
+	}
+	m, ok := v.(map[any]any)
+	if !ok || len(rest) != 0 {
+		return nil, 0, fmt.Errorf("%w: COSE key is not a map", ErrWebAuthnMalformed)
+	}
+	kty, _ := m[coseKeyType].(int64)
+	alg, _ := m[coseKeyAlg].(int64)
+
+	switch {
+	case kty == coseKeyTypeEC2 && alg == COSEAlgES256:
+		crv, _ := m[coseKeyCurve].(int64)
+		x, _ := m[coseKeyX].([]byte)
+		y, _ := m[coseKeyY].([]byte)
+		if crv != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, fmt.Errorf("%w: EC2 key", ErrWebAuthnMalformed)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, 0, fmt.Errorf("%w: point is not on the curve", ErrWebAuthnInvalid)
+		}
+		return pub, alg, nil
+	case kty == coseKeyTypeOKP && alg == COSEAlgEdDSA:
+		crv, _ := m[coseKeyCurve].(int64)
+		x, _ := m[coseKeyX].([]byte)
+		if crv != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, fmt.Errorf("%w: OKP key", ErrWebAuthnMalformed)
+		}
+		return ed25519.PublicKey(x), alg, nil
+	case kty == coseKeyTypeRSA && alg == COSEAlgRS256:
+		n, _ := m[coseKeyCurve].([]byte)
+		e, _ := m[coseKeyX].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, fmt.Errorf("%w: RSA key", ErrWebAuthnMalformed)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, alg, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: COSE key type %d with alg %d", ErrWebAuthnUnsupported, kty, alg)
+	}
+}
+
+// EncodeCOSEKey encodes the public key in the COSE_Key format.
+// ref: https://www.rfc-editor.org/rfc/rfc9053#section-7
+func EncodeCOSEKey(pub crypto.PublicKey) ([]byte, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: curve %s", ErrWebAuthnUnsupported, pub.Curve.Params().Name)
+		}
+		return EncodeCBOR(map[any]any{
+			coseKeyType:  coseKeyTypeEC2,
+			coseKeyAlg:   COSEAlgES256,
+			coseKeyCurve: coseCurveP256,
+			coseKeyX:     pub.X.FillBytes(make([]byte, 32)),
+			coseKeyY:     pub.Y.FillBytes(make([]byte, 32)),
+		})
+	case ed25519.PublicKey:
+		return EncodeCBOR(map[any]any{
+			coseKeyType:  coseKeyTypeOKP,
+			coseKeyAlg:   COSEAlgEdDSA,
+			coseKeyCurve: coseCurveEd25519,
+			coseKeyX:     []byte(pub),
+		})
+	case *rsa.PublicKey:
+		return EncodeCBOR(map[any]any{
+			coseKeyType:  coseKeyTypeRSA,
+			coseKeyAlg:   COSEAlgRS256,
+			coseKeyCurve: pub.N.Bytes(),
+			coseKeyX:     big.NewInt(int64(pub.E)).Bytes(),
+		})
+	default:
+		return nil, fmt.Errorf("%w: key type %T", ErrWebAuthnUnsupported, pub)
+	}
+}
+
+// VerifyWebAuthnSignature verifies the signature of the authenticator. ECDSA signatures are DER-encoded unlike JWS.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-signature-attestation-types
+func VerifyWebAuthnSignature(pub crypto.PublicKey, alg int64, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	switch alg {
+	case COSEAlgES256:
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok || !ecdsa.VerifyASN1(k, digest[:], sig) {
+			return ErrWebAuthnInvalidSignature
+		}
+	case COSEAlgEdDSA:
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok || !ed25519.Verify(k, data, sig) {
+			return ErrWebAuthnInvalidSignature
+		}
+	case COSEAlgRS256:
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+			return ErrWebAuthnInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: alg %d", ErrWebAuthnUnsupported, alg)
+	}
+	return nil
+}
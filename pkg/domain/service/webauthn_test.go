@@ -0,0 +1,344 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:9002"
+)
+
+var testAAGUID = []byte("0123456789abcdef")
+
+// testAuthData returns the authenticator data with the attested credential of pub.
+func testAuthData(t *testing.T, pub crypto.PublicKey) []byte {
+	t.Helper()
+
+	coseKey, err := EncodeCOSEKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	b := append(rpIDHash[:], WebAuthnFlagUserPresent|WebAuthnFlagAttestedCredentialData)
+	b = binary.BigEndian.AppendUint32(b, 0)
+	b = append(b, testAAGUID...)
+	b = binary.BigEndian.AppendUint16(b, 4)
+	b = append(b, "cred"...)
+	return append(b, coseKey...)
+}
+
+func testSignES256(t *testing.T, key *ecdsa.PrivateKey, data ...[]byte) []byte {
+	t.Helper()
+
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	sig, err := ecdsa.SignASN1(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// testAttestationCertificate returns the certificate of the attestation key, which is self-signed for the test.
+func testAttestationCertificate(t *testing.T, key *ecdsa.PrivateKey, isCA bool, aaguid []byte) []byte {
+	t.Helper()
+
+	ext, err := asn1.Marshal(aaguid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"JP"},
+			Organization:       []string{"oauth-go"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "test authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		ExtraExtensions:       []pkix.Extension{{Id: idFidoGenCeAAGUID, Value: ext}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestVerifyAttestation(t *testing.T) {
+	t.Parallel()
+
+	credentialKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestationKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData := testAuthData(t, &credentialKey.PublicKey)
+	clientDataHash := sha256.Sum256([]byte("client data"))
+
+	tests := map[string]struct {
+		format  string
+		attStmt map[any]any
+		wantErr error
+	}{
+		"ok: none": {
+			format:  AttestationFormatNone,
+			attStmt: map[any]any{},
+		},
+		"ok: packed self attestation": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgES256,
+				"sig": testSignES256(t, credentialKey, authData, clientDataHash[:]),
+			},
+		},
+		"ok: packed with x5c": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgES256,
+				"sig": testSignES256(t, attestationKey, authData, clientDataHash[:]),
+				"x5c": []any{testAttestationCertificate(t, attestationKey, false, testAAGUID)},
+			},
+		},
+		"ng: none with statement": {
+			format:  AttestationFormatNone,
+			attStmt: map[any]any{"alg": COSEAlgES256},
+			wantErr: ErrWebAuthnInvalid,
+		},
+		"ng: packed self attestation with other alg": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgRS256,
+				"sig": testSignES256(t, credentialKey, authData, clientDataHash[:]),
+			},
+			wantErr: ErrWebAuthnInvalid,
+		},
+		"ng: packed self attestation for other client data": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgES256,
+				"sig": testSignES256(t, credentialKey, authData, []byte("other")),
+			},
+			wantErr: ErrWebAuthnInvalidSignature,
+		},
+		"ng: packed without sig": {
+			format:  AttestationFormatPacked,
+			attStmt: map[any]any{"alg": COSEAlgES256},
+			wantErr: ErrWebAuthnMalformed,
+		},
+		"ng: packed signed with credential key instead of x5c": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgES256,
+				"sig": testSignES256(t, credentialKey, authData, clientDataHash[:]),
+				"x5c": []any{testAttestationCertificate(t, attestationKey, false, testAAGUID)},
+			},
+			wantErr: ErrWebAuthnInvalidSignature,
+		},
+		"ng: packed with CA certificate": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgES256,
+				"sig": testSignES256(t, attestationKey, authData, clientDataHash[:]),
+				"x5c": []any{testAttestationCertificate(t, attestationKey, true, testAAGUID)},
+			},
+			wantErr: ErrWebAuthnInvalid,
+		},
+		"ng: packed with other AAGUID": {
+			format: AttestationFormatPacked,
+			attStmt: map[any]any{
+				"alg": COSEAlgES256,
+				"sig": testSignES256(t, attestationKey, authData, clientDataHash[:]),
+				"x5c": []any{testAttestationCertificate(t, attestationKey, false, []byte("fedcba9876543210"))},
+			},
+			wantErr: ErrWebAuthnInvalid,
+		},
+		"ng: unsupported format": {
+			format:  "fido-u2f",
+			attStmt: map[any]any{},
+			wantErr: ErrWebAuthnUnsupported,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := EncodeCBOR(map[any]any{"fmt": tt.format, "attStmt": tt.attStmt, "authData": authData})
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj, err := ParseAttestationObject(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := VerifyAttestation(obj, clientDataHash[:])
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if string(got.CredentialID) != "cred" || string(got.AAGUID) != string(testAAGUID) {
+				t.Errorf("unexpected authenticator data: %+v", got)
+			}
+			if err := got.VerifyRPID(testRPID); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestParseAuthenticatorData(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData := testAuthData(t, &key.PublicKey)
+
+	tests := map[string]struct {
+		in      []byte
+		wantErr error
+	}{
+		"ok: attested credential data": {in: authData},
+		"ok: assertion":                {in: append(append([]byte{}, authData[:32]...), WebAuthnFlagUserPresent, 0, 0, 0, 1)},
+		"ng: too short":                {in: authData[:36], wantErr: ErrWebAuthnMalformed},
+		"ng: truncated public key":     {in: authData[:len(authData)-1], wantErr: ErrWebAuthnMalformed},
+		"ng: trailing data":            {in: append(append([]byte{}, authData...), 0), wantErr: ErrWebAuthnMalformed},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseAuthenticatorData(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyClientData(t *testing.T) {
+	t.Parallel()
+
+	challenge := []byte("challenge")
+	clientData := func(typ, challenge, origin string) []byte {
+		b, err := json.Marshal(&CollectedClientData{Type: typ, Challenge: challenge, Origin: origin})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	tests := map[string]struct {
+		in      []byte
+		wantErr error
+	}{
+		"ok":                  {in: clientData(WebAuthnTypeGet, b64.EncodeToString(challenge), testOrigin)},
+		"ng: other type":      {in: clientData(WebAuthnTypeCreate, b64.EncodeToString(challenge), testOrigin), wantErr: ErrWebAuthnInvalid},
+		"ng: other challenge": {in: clientData(WebAuthnTypeGet, b64.EncodeToString([]byte("other")), testOrigin), wantErr: ErrWebAuthnInvalid},
+		"ng: other origin":    {in: clientData(WebAuthnTypeGet, b64.EncodeToString(challenge), "http://localhost:9000"), wantErr: ErrWebAuthnInvalid},
+		"ng: not JSON":        {in: []byte("challenge"), wantErr: ErrWebAuthnMalformed},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := VerifyClientData(tt.in, WebAuthnTypeGet, challenge, testOrigin)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && len(got) != sha256.Size {
+				t.Errorf("unexpected hash: %x", got)
+			}
+		})
+	}
+}
+
+func TestVerifyWebAuthnSignature(t *testing.T) {
+	t.Parallel()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("signed data")
+	digest := sha256.Sum256(data)
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		pub     crypto.PublicKey
+		alg     int64
+		sig     []byte
+		wantErr error
+	}{
+		"ok: ES256":         {pub: &ecKey.PublicKey, alg: COSEAlgES256, sig: testSignES256(t, ecKey, data)},
+		"ok: EdDSA":         {pub: edPub, alg: COSEAlgEdDSA, sig: ed25519.Sign(edKey, data)},
+		"ok: RS256":         {pub: &rsaKey.PublicKey, alg: COSEAlgRS256, sig: rsaSig},
+		"ng: ES256 invalid": {pub: &ecKey.PublicKey, alg: COSEAlgES256, sig: testSignES256(t, ecKey, []byte("other")), wantErr: ErrWebAuthnInvalidSignature},
+		"ng: RS256 invalid": {pub: &rsaKey.PublicKey, alg: COSEAlgRS256, sig: rsaSig[1:], wantErr: ErrWebAuthnInvalidSignature},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// the key is passed through the COSE_Key format as it is stored.
+			b, err := EncodeCOSEKey(tt.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pub, alg, err := ParseCOSEKey(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if alg != tt.alg {
+				t.Fatalf("want %d, got %d", tt.alg, alg)
+			}
+
+			err = VerifyWebAuthnSignature(pub, alg, data, tt.sig)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package infra
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+var ErrNoWebAuthnCredential = errors.New("authenticator has no credential")
+
+// SoftwareAuthenticator stands in for the WebAuthn authenticator and the browser in process, so that
+// the ceremonies are exercised without hardware. The credentials are ES256 keys kept in memory, and
+// the attestation is none or packed self attestation.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-authenticator-model
+type SoftwareAuthenticator struct {
+	rpID              string
+	attestationFormat string
+
+	// Origin is where the browser calls the authenticator from, which differs from the relying party on phishing sites.
+	Origin string
+	// UserVerification sets the UV flag as if the user unlocked the authenticator with biometrics or PIN.
+	UserVerification bool
+
+	mu          sync.Mutex
+	credentials map[string]*softwareCredential
+}
+
+type softwareCredential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// NewSoftwareAuthenticator returns the authenticator for the relying party, which attests the credentials in attestationFormat.
+func NewSoftwareAuthenticator(rpID, origin, attestationFormat string) *SoftwareAuthenticator {
+	return &SoftwareAuthenticator{
+		rpID:              rpID,
+		Origin:            origin,
+		attestationFormat: attestationFormat,
+		credentials:       make(map[string]*softwareCredential),
+	}
+}
+
+// Create generates the discoverable credential of the user for the challenge of the registration ceremony.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-op-make-cred
+func (a *SoftwareAuthenticator) Create(challenge, userHandle []byte) (*model.WebAuthnAttestationResponse, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	cred := &softwareCredential{
+		id:         make([]byte, 16),
+		key:        key,
+		userHandle: userHandle,
+	}
+	if _, err := rand.Read(cred.id); err != nil {
+		return nil, err
+	}
+	publicKey, err := service.EncodeCOSEKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataJSON, err := a.clientData(service.WebAuthnTypeCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := a.authenticatorData(service.WebAuthnFlagAttestedCredentialData, cred.signCount)
+	authData = append(authData, make([]byte, 16)...) // AAGUID of software authenticators is zero
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(cred.id)))
+	authData = append(authData, cred.id...)
+	authData = append(authData, publicKey...)
+
+	attStmt := map[any]any{}
+	if a.attestationFormat == service.AttestationFormatPacked {
+		sig, err := signWithCredential(key, authData, clientDataJSON)
+		if err != nil {
+			return nil, err
+		}
+		attStmt["alg"] = service.COSEAlgES256
+		attStmt["sig"] = sig
+	}
+	attestationObject, err := service.EncodeCBOR(map[any]any{
+		"fmt":      a.attestationFormat,
+		"attStmt":  attStmt,
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.credentials[string(cred.id)] = cred
+	return &model.WebAuthnAttestationResponse{
+		CredentialID:      cred.id,
+		ClientDataJSON:    clientDataJSON,
+		AttestationObject: attestationObject,
+	}, nil
+}
+
+// Get signs the challenge of the authentication ceremony with one of the allowed credentials, or any
+// discoverable credential if allowCredentials is empty.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-op-get-assertion
+func (a *SoftwareAuthenticator) Get(challenge []byte, allowCredentials ...[]byte) (*model.WebAuthnAssertionResponse, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var cred *softwareCredential
+	for _, c := range a.credentials {
+		if len(allowCredentials) == 0 {
+			cred = c
+			break
+		}
+		for _, id := range allowCredentials {
+			if string(id) == string(c.id) {
+				cred = c
+			}
+		}
+	}
+	if cred == nil {
+		return nil, ErrNoWebAuthnCredential
+	}
+	cred.signCount++
+
+	clientDataJSON, err := a.clientData(service.WebAuthnTypeGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := a.authenticatorData(0, cred.signCount)
+	sig, err := signWithCredential(cred.key, authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebAuthnAssertionResponse{
+		CredentialID:      cred.id,
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         sig,
+		UserHandle:        cred.userHandle,
+	}, nil
+}
+
+// Clone returns the authenticator with the copies of the credentials, whose sign counters diverge
+// from the original as if the keys were extracted from it.
+func (a *SoftwareAuthenticator) Clone() *SoftwareAuthenticator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	clone := NewSoftwareAuthenticator(a.rpID, a.Origin, a.attestationFormat)
+	clone.UserVerification = a.UserVerification
+	for id, c := range a.credentials {
+		v := *c
+		clone.credentials[id] = &v
+	}
+	return clone
+}
+
+// ref: https://www.w3.org/TR/webauthn-3/#clientdatajson-serialization
+func (a *SoftwareAuthenticator) clientData(typ string, challenge []byte) ([]byte, error) {
+	return json.Marshal(&service.CollectedClientData{
+		Type:      typ,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    a.Origin,
+	})
+}
+
+func (a *SoftwareAuthenticator) authenticatorData(flags byte, signCount uint32) []byte {
+	flags |= service.WebAuthnFlagUserPresent
+	if a.UserVerification {
+		flags |= service.WebAuthnFlagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+
+	b := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(b, signCount)
+}
+
+// signWithCredential signs the authenticator data and the hash of the client data.
+// ref: https://www.w3.org/TR/webauthn-3/#fig-signature
+func signWithCredential(key *ecdsa.PrivateKey, authData, clientDataJSON []byte) ([]byte, error) {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
@@ -0,0 +1,54 @@
+package infra
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// AuthenticationDeviceSimulator stands in for the authentication devices of users in process.
+// It records the notified backchannel authentication requests and logs the URL of the page
+// on which the user approves them instead of sending push notifications.
+type AuthenticationDeviceSimulator struct {
+	deviceURL string
+
+	mu       sync.Mutex
+	notified []*model.BackchannelAuthRequest
+}
+
+// NewAuthenticationDeviceSimulator returns the simulator which shows the requests on deviceURL.
+func NewAuthenticationDeviceSimulator(deviceURL string) *AuthenticationDeviceSimulator {
+	return &AuthenticationDeviceSimulator{
+		deviceURL: deviceURL,
+	}
+}
+
+func (d *AuthenticationDeviceSimulator) Notify(ctx context.Context, req *model.BackchannelAuthRequest) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.notified = append(d.notified, req)
+	slog.Info("authentication device is notified",
+		slog.String("subject", req.Subject),
+		slog.String("binding_message", req.BindingMessage),
+		slog.String("url", d.deviceURL+"?"+url.Values{"id": {req.AuthReqID}}.Encode()),
+	)
+	return nil
+}
+
+// Notified returns the requests notified to the device of the user.
+func (d *AuthenticationDeviceSimulator) Notified(subject string) []*model.BackchannelAuthRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var reqs []*model.BackchannelAuthRequest
+	for _, req := range d.notified {
+		if req.Subject == subject {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
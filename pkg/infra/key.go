@@ -0,0 +1,123 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var ErrKeyInvalid = errors.New("key is invalid")
+
+// KeyStorage keeps the signing keys in memory, and writes them to the file if its path is given
+// so that restarting the server neither invalidates the signed JWTs nor rotates the keys.
+// The private keys in the file are encrypted by the key manager.
+type KeyStorage struct {
+	path string
+
+	mu     sync.Mutex
+	keyKvs map[string]*model.KeyRecord
+}
+
+// NewKeyStorage returns the storage which loads the keys from path and saves them to it.
+// The keys are kept only in memory if path is empty.
+func NewKeyStorage(path string) (*KeyStorage, error) {
+	s := &KeyStorage{
+		path:   path,
+		keyKvs: make(map[string]*model.KeyRecord),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []*model.KeyRecord
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, k := range keys {
+		s.keyKvs[k.ID] = k
+	}
+	return s, nil
+}
+
+func (s *KeyStorage) ListKeys(ctx context.Context) ([]*model.KeyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.keys(), nil
+}
+
+func (s *KeyStorage) SaveKey(ctx context.Context, key *model.KeyRecord) error {
+	if key == nil || key.ID == "" {
+		return ErrKeyInvalid
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := *key
+	s.keyKvs[k.ID] = &k
+	return s.flush()
+}
+
+func (s *KeyStorage) DeleteKey(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keyKvs[id]; !ok {
+		return nil
+	}
+	delete(s.keyKvs, id)
+	return s.flush()
+}
+
+// keys returns the copies of the keys sorted by their creation.
+func (s *KeyStorage) keys() []*model.KeyRecord {
+	keys := make([]*model.KeyRecord, 0, len(s.keyKvs))
+	for _, v := range s.keyKvs {
+		k := *v
+		keys = append(keys, &k)
+	}
+	slices.SortFunc(keys, func(a, b *model.KeyRecord) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return keys
+}
+
+// flush writes the keys to the file through a temporary file, so that the file is not broken by a crash.
+// The file is readable only by the owner, although the private keys are encrypted.
+func (s *KeyStorage) flush() error {
+	if s.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(s.keys())
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,58 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestKeyStoragePersistence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	createdAt := time.Unix(1_700_000_000, 0).UTC()
+	sealed := []byte("dummy-sealed-key")
+
+	s, err := NewKeyStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SaveKey(ctx, &model.KeyRecord{ID: "dummy-kid", Algorithm: "ES256", State: model.KeyStateActive, EncryptedKey: sealed, CreatedAt: createdAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key is loaded again as if the server restarted.
+	restarted, err := NewKeyStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := restarted.ListKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].State != model.KeyStateActive || !bytes.Equal(keys[0].EncryptedKey, sealed) || !keys[0].CreatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+
+	err = restarted.DeleteKey(ctx, "dummy-kid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err = NewKeyStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err = restarted.ListKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("unexpected keys: %+v", keys)
+	}
+}
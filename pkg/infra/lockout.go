@@ -0,0 +1,290 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var ErrFailureCounterInvalid = errors.New("failure counter is invalid")
+
+const (
+	// maxFailureCounters caps the counters, so that the attempts with random usernames do not exhaust the memory.
+	maxFailureCounters = 100_000
+	// minJournalRecords is how many records the journal has at least before it is compacted.
+	minJournalRecords = 1_000
+)
+
+// lockoutRecord is a line of the journal, which either saves the counter or deletes the key.
+type lockoutRecord struct {
+	Counter *model.FailureCounter `json:"counter,omitempty"`
+	Deleted *model.FailureKey     `json:"deleted,omitempty"`
+}
+
+// LockoutStorage keeps the failure counters in memory, and writes them to the file if its path is given
+// so that restarting the server does not unlock the attackers.
+// Each change is appended to the file as a record, and the file is compacted once most of the records are stale.
+type LockoutStorage struct {
+	path string
+	// maxCounters is maxFailureCounters except in the tests.
+	maxCounters int
+
+	mu         sync.Mutex
+	counterKvs map[string]*model.FailureCounter
+	journal    *os.File
+	records    int
+}
+
+// NewLockoutStorage returns the storage which loads the counters from path and saves them to it.
+// The counters are kept only in memory if path is empty.
+func NewLockoutStorage(path string) (*LockoutStorage, error) {
+	s := &LockoutStorage{
+		path:        path,
+		maxCounters: maxFailureCounters,
+		counterKvs:  make(map[string]*model.FailureCounter),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := s.load(b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	now := time.Now()
+	for k, c := range s.counterKvs {
+		if c.IsExpired(now) {
+			delete(s.counterKvs, k)
+		}
+	}
+	// the file starts with the live counters only.
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load replays the records of the journal. The file written as a JSON array of the counters is read too.
+func (s *LockoutStorage) load(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil
+	}
+	if b[0] == '[' {
+		var counters []*model.FailureCounter
+		if err := json.Unmarshal(b, &counters); err != nil {
+			return err
+		}
+		for _, c := range counters {
+			s.counterKvs[c.FailureKey.String()] = c
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		var r lockoutRecord
+		err := dec.Decode(&r)
+		// the last record may be cut off by a crash while it is written.
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case r.Counter != nil:
+			s.counterKvs[r.Counter.FailureKey.String()] = r.Counter
+		case r.Deleted != nil:
+			delete(s.counterKvs, r.Deleted.String())
+		}
+	}
+}
+
+func (s *LockoutStorage) GetFailureCounter(ctx context.Context, key model.FailureKey) (*model.FailureCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.counterKvs[key.String()]
+	if !ok {
+		return &model.FailureCounter{FailureKey: key}, nil
+	}
+	c := *v
+	return &c, nil
+}
+
+func (s *LockoutStorage) IncrementFailure(ctx context.Context, key model.FailureKey, policy model.LockoutPolicy, now time.Time) (*model.FailureCounter, bool, error) {
+	if key.Kind == "" {
+		return nil, false, ErrFailureCounterInvalid
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.counterKvs[key.String()]
+	if !ok {
+		if len(s.counterKvs) >= s.maxCounters {
+			if err := s.evict(now); err != nil {
+				return nil, false, err
+			}
+		}
+		v = &model.FailureCounter{FailureKey: key}
+		s.counterKvs[key.String()] = v
+	}
+	locked := policy.RecordFailure(v, now)
+	c := *v
+	if err := s.append(lockoutRecord{Counter: &c}); err != nil {
+		return nil, false, err
+	}
+	return &c, locked, nil
+}
+
+func (s *LockoutStorage) ResetFailureCounter(ctx context.Context, key model.FailureKey, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.counterKvs[key.String()]
+	if !ok || v.IsLocked(now) {
+		return nil
+	}
+	return s.delete(key)
+}
+
+func (s *LockoutStorage) DeleteFailureCounter(ctx context.Context, key model.FailureKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.counterKvs[key.String()]; !ok {
+		return nil
+	}
+	return s.delete(key)
+}
+
+func (s *LockoutStorage) ListFailureCounters(ctx context.Context) ([]*model.FailureCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counters(), nil
+}
+
+func (s *LockoutStorage) delete(key model.FailureKey) error {
+	delete(s.counterKvs, key.String())
+	return s.append(lockoutRecord{Deleted: &key})
+}
+
+// evict forgets the expired counters, and then the counters which failed the earliest if most of them are live,
+// so that a tenth of the capacity is left. The locked counters are kept as long as the others are left.
+func (s *LockoutStorage) evict(now time.Time) error {
+	for k, c := range s.counterKvs {
+		if c.IsExpired(now) {
+			delete(s.counterKvs, k)
+		}
+	}
+	if excess := len(s.counterKvs) - s.maxCounters*9/10; excess > 0 {
+		counters := s.counters()
+		slices.SortFunc(counters, func(a, b *model.FailureCounter) int {
+			if a.IsLocked(now) != b.IsLocked(now) {
+				if a.IsLocked(now) {
+					return 1
+				}
+				return -1
+			}
+			return a.LastFailureAt.Compare(b.LastFailureAt)
+		})
+		for _, c := range counters[:excess] {
+			delete(s.counterKvs, c.FailureKey.String())
+		}
+	}
+	return s.compact()
+}
+
+// counters returns the copies of the counters sorted by their keys.
+func (s *LockoutStorage) counters() []*model.FailureCounter {
+	counters := make([]*model.FailureCounter, 0, len(s.counterKvs))
+	for _, v := range s.counterKvs {
+		c := *v
+		counters = append(counters, &c)
+	}
+	slices.SortFunc(counters, func(a, b *model.FailureCounter) int {
+		return strings.Compare(a.FailureKey.String(), b.FailureKey.String())
+	})
+	return counters
+}
+
+// append writes the record at the end of the file, which is compacted instead once most of its records are stale,
+// as the counters in memory already have the change.
+func (s *LockoutStorage) append(r lockoutRecord) error {
+	if s.path == "" {
+		return nil
+	}
+	if s.records >= minJournalRecords && s.records >= 2*len(s.counterKvs) {
+		return s.compact()
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.journal.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.records++
+	return nil
+}
+
+// compact rewrites the file with the live counters through a temporary file, so that the file is not broken by a crash,
+// and appends the records to it after that.
+func (s *LockoutStorage) compact() error {
+	if s.path == "" {
+		return nil
+	}
+	var b strings.Builder
+	counters := s.counters()
+	for _, c := range counters {
+		line, err := json.Marshal(lockoutRecord{Counter: c})
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		return err
+	}
+
+	if s.journal != nil {
+		s.journal.Close()
+	}
+	s.journal, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	s.records = len(counters)
+	return nil
+}
@@ -0,0 +1,273 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var testLockoutPolicy = model.LockoutPolicy{
+	FreeAttempts:     3,
+	LockoutThreshold: 3,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+}
+
+func TestLockoutStoragePersistence(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lockout.json")
+	key := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+	now := time.Now().Truncate(time.Second)
+
+	s, err := NewLockoutStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		_, _, err = s.IncrementFailure(ctx, key, testLockoutPolicy, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the counter is loaded again as if the server restarted.
+	restarted, err := NewLockoutStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := restarted.GetFailureCounter(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.LockedUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected counter: %+v", got)
+	}
+
+	err = restarted.DeleteFailureCounter(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted, err = NewLockoutStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counters, err := restarted.ListFailureCounters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counters) != 0 {
+		t.Errorf("unexpected counters: %+v", counters)
+	}
+}
+
+func TestLockoutStorageIncrementFailureConcurrently(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s, err := NewLockoutStorage(filepath.Join(t.TempDir(), "lockout.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+	policy := model.LockoutPolicy{FreeAttempts: 1000, ResetAfter: time.Hour}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := s.IncrementFailure(ctx, key, policy, now); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// none of the concurrent failures is lost.
+	got, err := s.GetFailureCounter(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Failures != 100 {
+		t.Errorf("want 100 failures, got %d", got.Failures)
+	}
+}
+
+func TestLockoutStorageResetFailureCounter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Now()
+	tests := map[string]struct {
+		failures  int
+		wantReset bool
+	}{
+		"ok: failures are forgotten": {
+			failures:  2,
+			wantReset: true,
+		},
+		"ok: lockout lasts": {
+			failures:  3,
+			wantReset: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s, err := NewLockoutStorage("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			key := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+			for range tt.failures {
+				if _, _, err := s.IncrementFailure(ctx, key, testLockoutPolicy, now); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = s.ResetFailureCounter(ctx, key, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			counters, err := s.ListFailureCounters(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(counters) == 0) != tt.wantReset {
+				t.Errorf("unexpected counters: %+v", counters)
+			}
+		})
+	}
+}
+
+func TestLockoutStorageJournal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lockout.json")
+	s, err := NewLockoutStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+	policy := model.LockoutPolicy{FreeAttempts: 100, ResetAfter: time.Hour}
+	now := time.Now()
+
+	// each failure is appended to the file instead of rewriting it.
+	for i := range 5 {
+		if _, _, err := s.IncrementFailure(ctx, key, policy, now); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := bytes.Count(b, []byte("\n")); lines != i+1 {
+			t.Fatalf("want %d records, got %d", i+1, lines)
+		}
+	}
+
+	// the file is compacted once most of the records are stale.
+	for range minJournalRecords {
+		if _, _, err := s.IncrementFailure(ctx, key, policy, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(b, []byte("\n")); lines >= minJournalRecords {
+		t.Errorf("the file is not compacted: %d records", lines)
+	}
+
+	// the last record cut off by a crash is ignored.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"counter":{"kind":"user","key":"dum`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	restarted, err := NewLockoutStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := restarted.GetFailureCounter(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Failures != 5+minJournalRecords {
+		t.Errorf("want %d failures, got %d", 5+minJournalRecords, got.Failures)
+	}
+}
+
+func TestLockoutStorageExpiration(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lockout.json")
+	s, err := NewLockoutStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.maxCounters = 10
+	now := time.Now()
+	locked := model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"}
+	for range 3 {
+		if _, _, err := s.IncrementFailure(ctx, locked, testLockoutPolicy, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the counters of random usernames are capped, and the oldest ones are evicted before the locked one.
+	for i := range 20 {
+		key := model.FailureKey{Kind: model.FailureKindUser, Key: fmt.Sprintf("random-user-%d", i)}
+		if _, _, err := s.IncrementFailure(ctx, key, testLockoutPolicy, now.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	counters, err := s.ListFailureCounters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counters) > s.maxCounters {
+		t.Errorf("want %d counters at most, got %d", s.maxCounters, len(counters))
+	}
+	got, err := s.GetFailureCounter(ctx, locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsLocked(now) {
+		t.Errorf("the locked counter is evicted: %+v", got)
+	}
+
+	// the expired counters are forgotten once the capacity is reached.
+	later := now.Add(2 * time.Hour)
+	for i := range s.maxCounters {
+		key := model.FailureKey{Kind: model.FailureKindUser, Key: fmt.Sprintf("later-user-%d", i)}
+		if _, _, err := s.IncrementFailure(ctx, key, testLockoutPolicy, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = s.GetFailureCounter(ctx, locked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Failures != 0 || !got.LockedUntil.IsZero() {
+		t.Errorf("the expired counter is kept: %+v", got)
+	}
+}
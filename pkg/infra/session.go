@@ -0,0 +1,61 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var (
+	ErrSessionInvalid  = errors.New("session is invalid")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+type SessionStorage struct {
+	// mu guards the sessions, as the storage is shared by the concurrent requests.
+	mu         sync.Mutex
+	sessionKvs map[string]*model.Session
+}
+
+func NewSessionStorage() *SessionStorage {
+	return &SessionStorage{
+		sessionKvs: make(map[string]*model.Session),
+	}
+}
+
+func (s *SessionStorage) CreateSession(ctx context.Context, session *model.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session == nil || session.ID == "" {
+		return ErrSessionInvalid
+	}
+
+	s.sessionKvs[session.ID] = clone(session)
+	return nil
+}
+
+func (s *SessionStorage) GetSession(ctx context.Context, id string) (*model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.sessionKvs[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return clone(v), nil
+}
+
+func (s *SessionStorage) DeleteSession(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessionKvs[id]; !ok {
+		return ErrSessionNotFound
+	}
+
+	delete(s.sessionKvs, id)
+	return nil
+}
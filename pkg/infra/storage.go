@@ -4,6 +4,8 @@ import (
 	"context"
 	"crypto/sha256"
 	"errors"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,114 +15,551 @@ import (
 var (
 	ErrAuthReqInvalid  = errors.New("authorization request is invalid")
 	ErrAuthReqNotFound = errors.New("authorization request not found")
+	ErrAuthReqDisabled = errors.New("authorization request is already disabled")
 
-	ErrAccessTokenInvalid = errors.New("access token is invalid")
+	ErrPushedAuthReqInvalid  = errors.New("pushed authorization request is invalid")
+	ErrPushedAuthReqNotFound = errors.New("pushed authorization request not found")
+
+	ErrBackchannelAuthReqInvalid  = errors.New("backchannel authentication request is invalid")
+	ErrBackchannelAuthReqNotFound = errors.New("backchannel authentication request not found")
+	ErrBackchannelAuthReqStatus   = errors.New("backchannel authentication request is in another status")
+
+	ErrLogoutReqInvalid  = errors.New("logout request is invalid")
+	ErrLogoutReqNotFound = errors.New("logout request not found")
+
+	ErrSessionClientInvalid = errors.New("client of the session is invalid")
+
+	ErrAccessTokenInvalid  = errors.New("access token is invalid")
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid")
 
 	ErrClientInvalid  = errors.New("client is invalid")
 	ErrClientNotFound = errors.New("client not found")
+	ErrClientExists   = errors.New("client already exists")
+
+	ErrProtectedResourceInvalid  = errors.New("protected resource is invalid")
+	ErrProtectedResourceNotFound = errors.New("protected resource not found")
+	ErrProtectedResourceExists   = errors.New("protected resource already exists")
+
+	ErrAuthorizationDetailTypeNotFound = errors.New("authorization detail type not found")
+
+	ErrTrustedIssuerInvalid  = errors.New("trusted issuer is invalid")
+	ErrTrustedIssuerNotFound = errors.New("trusted issuer not found")
+	ErrAssertionIDReplayed   = errors.New("assertion id is already used")
 )
 
+// AuthorizationStorage keeps the copies of the values, and returns the copies of them,
+// so that the values are changed only through the storage under the lock.
 type AuthorizationStorage struct {
-	authReqKvs     map[string]*model.AuthRequest
-	accessTokenKvs map[string]*model.AccessToken
-	clientKvs      map[string]model.Client
+	// mu guards the maps below, as the storage is shared by the concurrent requests.
+	mu sync.Mutex
+
+	authReqKvs           map[string]*model.AuthRequest
+	pushedAuthReqKvs     map[string]*model.PushedAuthRequest
+	bcAuthReqKvs         map[string]*model.BackchannelAuthRequest
+	logoutReqKvs         map[string]*model.LogoutRequest
+	sessionClientKvs     map[string][]string
+	accessTokenKvs       map[string]*model.AccessToken
+	refreshTokenKvs      map[string]*model.RefreshToken
+	clientKvs            map[string]model.Client
+	protectedResourceKvs map[string]*model.ProtectedResource
+	authzDetailTypeKvs   map[string]*model.AuthorizationDetailType
+	trustedIssuerKvs     map[string]*model.TrustedIssuer
+	assertionIDKvs       map[string]time.Time // issuer and jti to the expiration
 }
 
+// NewAuthorizationStorage returns the storage with the dummy client of the sample app and the dummy resource.
 func NewAuthorizationStorage(clientSecretFixedKey string) *AuthorizationStorage {
 	clientSecret := "dummy-client-secret"
 	clientSecretHash := sha256.Sum256([]byte(clientSecret + clientSecretFixedKey))
+	resourceSecret := "dummy-resource-secret"
+	resourceSecretHash := sha256.Sum256([]byte(resourceSecret + clientSecretFixedKey))
+
+	s := NewEmptyAuthorizationStorage()
+	s.clientKvs["dummy-client-id"] = model.NewConfidentialClient(
+		model.AuthMethodBasic,
+		"dummy-client-id",
+		string(clientSecretHash[:]), // should be hashed with sha256
+		[]string{
+			"http://localhost:9000/auth/callback",
+		},
+		model.WithBackchannelTokenDelivery(model.BackchannelTokenDeliveryModePoll, ""),
+		model.WithPostLogoutRedirectURIs("http://localhost:9000/"),
+		model.WithFrontchannelLogoutURI("http://localhost:9000/frontchannel_logout"),
+	)
+	s.protectedResourceKvs["http://localhost:9003"] = &model.ProtectedResource{
+		URI:        "http://localhost:9003",
+		Scopes:     []string{"profile", "email"},
+		ID:         "dummy-resource-id",
+		SecretHash: string(resourceSecretHash[:]), // should be hashed with sha256
+	}
+	return s
+}
 
+// NewEmptyAuthorizationStorage returns the storage without clients nor resources, which are registered by AddClient and AddProtectedResource.
+func NewEmptyAuthorizationStorage() *AuthorizationStorage {
 	return &AuthorizationStorage{
-		authReqKvs:     make(map[string]*model.AuthRequest),
-		accessTokenKvs: make(map[string]*model.AccessToken),
-		clientKvs: map[string]model.Client{
-			"dummy-client-id": model.NewConfidentialClient(
-				model.AuthMethodBasic,
-				"dummy-client-id",
-				string(clientSecretHash[:]), // should be hashed with sha256
-				[]string{
-					"http://localhost:9000/auth/callback",
-				},
-			),
+		authReqKvs:           make(map[string]*model.AuthRequest),
+		pushedAuthReqKvs:     make(map[string]*model.PushedAuthRequest),
+		bcAuthReqKvs:         make(map[string]*model.BackchannelAuthRequest),
+		logoutReqKvs:         make(map[string]*model.LogoutRequest),
+		sessionClientKvs:     make(map[string][]string),
+		accessTokenKvs:       make(map[string]*model.AccessToken),
+		refreshTokenKvs:      make(map[string]*model.RefreshToken),
+		clientKvs:            make(map[string]model.Client),
+		protectedResourceKvs: make(map[string]*model.ProtectedResource),
+		authzDetailTypeKvs: map[string]*model.AuthorizationDetailType{
+			"payment_initiation": paymentInitiationType,
 		},
+		trustedIssuerKvs: make(map[string]*model.TrustedIssuer),
+		assertionIDKvs:   make(map[string]time.Time),
 	}
 }
 
+// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-2
+var paymentInitiationType = &model.AuthorizationDetailType{
+	Type:        "payment_initiation",
+	Description: "Initiate a payment on your behalf",
+	Schema: &model.Schema{
+		Type:     "object",
+		Required: []string{"type", "instructedAmount", "creditorName", "creditorAccount"},
+		Properties: map[string]*model.Schema{
+			"type": {Type: "string"},
+			"actions": {
+				Type:  "array",
+				Items: &model.Schema{Type: "string", Enum: []any{"initiate", "status", "cancel"}},
+			},
+			"locations": {
+				Type:  "array",
+				Items: &model.Schema{Type: "string"},
+			},
+			"instructedAmount": {
+				Type:     "object",
+				Required: []string{"currency", "amount"},
+				Properties: map[string]*model.Schema{
+					"currency": {Type: "string", Pattern: "^[A-Z]{3}$"},
+					"amount":   {Type: "string", Pattern: `^[0-9]+(\.[0-9]+)?$`},
+				},
+			},
+			"creditorName": {Type: "string"},
+			"creditorAccount": {
+				Type:     "object",
+				Required: []string{"iban"},
+				Properties: map[string]*model.Schema{
+					"iban": {Type: "string"},
+				},
+			},
+			"remittanceInformationUnstructured": {Type: "string"},
+		},
+	},
+}
+
 func (s *AuthorizationStorage) GetAuthorizationRequest(ctx context.Context, id string) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	v, ok := s.authReqKvs[id]
 	if !ok {
 		return nil, ErrAuthReqNotFound
 	}
-	return v, nil
-}
-
-func (s *AuthorizationStorage) GetAuthorizationRequestByCode(ctx context.Context, code string) (*model.AuthRequest, error) {
-	for _, v := range s.authReqKvs {
-		if v.Code == code {
-			return v, nil
-		}
-	}
-
-	return nil, ErrAuthReqNotFound
+	return clone(v), nil
 }
 
 func (s *AuthorizationStorage) CreateAuthorizationRequest(ctx context.Context, req *model.AuthRequest) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if req == nil {
 		return nil, ErrAuthReqInvalid
 	}
 
 	req.ID = uuid.NewString()
-	s.authReqKvs[req.ID] = req
+	s.authReqKvs[req.ID] = clone(req)
 	return req, nil
 }
 
+func (s *AuthorizationStorage) UpdateAuthorizationRequest(ctx context.Context, req *model.AuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req == nil {
+		return ErrAuthReqInvalid
+	}
+	if _, ok := s.authReqKvs[req.ID]; !ok {
+		return ErrAuthReqNotFound
+	}
+
+	s.authReqKvs[req.ID] = clone(req)
+	return nil
+}
+
 func (s *AuthorizationStorage) GenerateAuthorizationCode(ctx context.Context, req *model.AuthRequest) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if req == nil {
 		return nil, ErrAuthReqInvalid
 	}
+	v, ok := s.authReqKvs[req.ID]
+	if !ok {
+		return nil, ErrAuthReqNotFound
+	}
+	// the code is generated only once even if the user consents concurrently.
+	if v.Code != "" || !v.DisabledAt.IsZero() {
+		return nil, ErrAuthReqDisabled
+	}
 
-	req.Code = uuid.NewString()
-	s.authReqKvs[req.ID] = req
-	return req, nil
+	v.Code = uuid.NewString()
+	return clone(v), nil
 }
 
 func (s *AuthorizationStorage) DisableAuthorizationRequest(ctx context.Context, id string) error {
-	_, ok := s.authReqKvs[id]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.authReqKvs[id]
 	if !ok {
 		return ErrAuthReqNotFound
 	}
 
-	s.authReqKvs[id].DisabledAt = time.Now()
+	v.DisabledAt = time.Now()
+	return nil
+}
+
+func (s *AuthorizationStorage) ConsumeAuthorizationCode(ctx context.Context, code string) (*model.AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code == "" {
+		return nil, ErrAuthReqNotFound
+	}
+	for _, v := range s.authReqKvs {
+		if v.Code != code {
+			continue
+		}
+		if !v.DisabledAt.IsZero() {
+			return nil, ErrAuthReqDisabled
+		}
+		v.DisabledAt = time.Now()
+		return clone(v), nil
+	}
+	return nil, ErrAuthReqNotFound
+}
+
+func (s *AuthorizationStorage) CreatePushedAuthRequest(ctx context.Context, req *model.PushedAuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req == nil {
+		return ErrPushedAuthReqInvalid
+	}
+
+	s.pushedAuthReqKvs[req.RequestURI] = clone(req)
+	return nil
+}
+
+func (s *AuthorizationStorage) ConsumePushedAuthRequest(ctx context.Context, requestURI string) (*model.PushedAuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.pushedAuthReqKvs[requestURI]
+	if !ok {
+		return nil, ErrPushedAuthReqNotFound
+	}
+
+	delete(s.pushedAuthReqKvs, requestURI)
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) CreateBackchannelAuthRequest(ctx context.Context, req *model.BackchannelAuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req == nil || req.AuthReqID == "" {
+		return ErrBackchannelAuthReqInvalid
+	}
+
+	s.bcAuthReqKvs[req.AuthReqID] = clone(req)
+	return nil
+}
+
+func (s *AuthorizationStorage) GetBackchannelAuthRequest(ctx context.Context, authReqID string) (*model.BackchannelAuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.bcAuthReqKvs[authReqID]
+	if !ok {
+		return nil, ErrBackchannelAuthReqNotFound
+	}
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) UpdateBackchannelAuthRequest(ctx context.Context, req *model.BackchannelAuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req == nil {
+		return ErrBackchannelAuthReqInvalid
+	}
+	v, ok := s.bcAuthReqKvs[req.AuthReqID]
+	if !ok {
+		return ErrBackchannelAuthReqNotFound
+	}
+	if v.Status != model.BackchannelAuthStatusPending {
+		return ErrBackchannelAuthReqStatus
+	}
+
+	s.bcAuthReqKvs[req.AuthReqID] = clone(req)
+	return nil
+}
+
+func (s *AuthorizationStorage) ConsumeBackchannelAuthRequest(ctx context.Context, authReqID string, from, to model.BackchannelAuthStatus) (*model.BackchannelAuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.bcAuthReqKvs[authReqID]
+	if !ok {
+		return nil, ErrBackchannelAuthReqNotFound
+	}
+	if v.Status != from {
+		return nil, ErrBackchannelAuthReqStatus
+	}
+
+	v.Status = to
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) CreateLogoutRequest(ctx context.Context, req *model.LogoutRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req == nil || req.ID == "" {
+		return ErrLogoutReqInvalid
+	}
+
+	s.logoutReqKvs[req.ID] = clone(req)
+	return nil
+}
+
+func (s *AuthorizationStorage) ConsumeLogoutRequest(ctx context.Context, id string) (*model.LogoutRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.logoutReqKvs[id]
+	if !ok {
+		return nil, ErrLogoutReqNotFound
+	}
+
+	delete(s.logoutReqKvs, id)
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) AddSessionClient(ctx context.Context, sessionID, clientID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sessionID == "" || clientID == "" {
+		return ErrSessionClientInvalid
+	}
+
+	if !slices.Contains(s.sessionClientKvs[sessionID], clientID) {
+		s.sessionClientKvs[sessionID] = append(s.sessionClientKvs[sessionID], clientID)
+	}
 	return nil
 }
 
+func (s *AuthorizationStorage) ConsumeSessionClients(ctx context.Context, sessionID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := s.sessionClientKvs[sessionID]
+	delete(s.sessionClientKvs, sessionID)
+	return v, nil
+}
+
 func (s *AuthorizationStorage) CreateAccessToken(ctx context.Context, token *model.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if token == nil {
 		return ErrAccessTokenInvalid
 	}
 
-	s.accessTokenKvs[token.AccessToken] = token
+	s.accessTokenKvs[token.AccessToken] = clone(token)
 	return nil
 }
 
 func (s *AuthorizationStorage) GetAccessToken(ctx context.Context, token string) (*model.AccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	v, ok := s.accessTokenKvs[token]
 	if !ok {
 		return nil, ErrAccessTokenInvalid
 	}
-	return v, nil
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token == nil {
+		return ErrRefreshTokenInvalid
+	}
+
+	s.refreshTokenKvs[token.RefreshToken] = clone(token)
+	return nil
+}
+
+func (s *AuthorizationStorage) GetRefreshToken(ctx context.Context, token string) (*model.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.refreshTokenKvs[token]
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return clone(v), nil
 }
 
 func (s *AuthorizationStorage) GetClient(ctx context.Context, clientID string) (model.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	client, ok := s.clientKvs[clientID]
 	if !ok {
 		return nil, ErrClientNotFound
 	}
 
-	_, ok = client.(*model.ConfidentialClient)
+	v, ok := client.(*model.ConfidentialClient)
 	if !ok {
 		return nil, ErrClientNotFound
 	}
 
-	return client, nil
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) GetProtectedResource(ctx context.Context, uri string) (*model.ProtectedResource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.protectedResourceKvs[uri]
+	if !ok {
+		return nil, ErrProtectedResourceNotFound
+	}
+	return clone(v), nil
+}
+
+func (s *AuthorizationStorage) GetProtectedResourceByID(ctx context.Context, id string) (*model.ProtectedResource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.protectedResourceKvs {
+		if v.ID == id {
+			return clone(v), nil
+		}
+	}
+	return nil, ErrProtectedResourceNotFound
+}
+
+func (s *AuthorizationStorage) GetAuthorizationDetailType(ctx context.Context, typ string) (*model.AuthorizationDetailType, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.authzDetailTypeKvs[typ]
+	if !ok {
+		return nil, ErrAuthorizationDetailTypeNotFound
+	}
+	return clone(v), nil
+}
+
+// AddClient registers the client, whose secret must be hashed.
+func (s *AuthorizationStorage) AddClient(client model.Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client == nil || client.GetID() == "" {
+		return ErrClientInvalid
+	}
+	if _, ok := s.clientKvs[client.GetID()]; ok {
+		return ErrClientExists
+	}
+
+	if v, ok := client.(*model.ConfidentialClient); ok {
+		client = clone(v)
+	}
+	s.clientKvs[client.GetID()] = client
+	return nil
+}
+
+// AddProtectedResource registers the resource server, whose secret must be hashed.
+func (s *AuthorizationStorage) AddProtectedResource(resource *model.ProtectedResource) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resource == nil || resource.URI == "" || resource.ID == "" {
+		return ErrProtectedResourceInvalid
+	}
+	if _, ok := s.protectedResourceKvs[resource.URI]; ok {
+		return ErrProtectedResourceExists
+	}
+	for _, v := range s.protectedResourceKvs {
+		if v.ID == resource.ID {
+			return ErrProtectedResourceExists
+		}
+	}
+
+	s.protectedResourceKvs[resource.URI] = clone(resource)
+	return nil
+}
+
+// AddTrustedIssuer registers the issuer of JWT assertions.
+func (s *AuthorizationStorage) AddTrustedIssuer(issuer *model.TrustedIssuer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if issuer == nil || issuer.Issuer == "" {
+		return ErrTrustedIssuerInvalid
+	}
+
+	s.trustedIssuerKvs[issuer.Issuer] = clone(issuer)
+	return nil
+}
+
+func (s *AuthorizationStorage) GetTrustedIssuer(ctx context.Context, issuer string) (*model.TrustedIssuer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.trustedIssuerKvs[issuer]
+	if !ok {
+		return nil, ErrTrustedIssuerNotFound
+	}
+	return clone(v), nil
+}
+
+// RecordAssertionID records the jti of the issuer until it expires, or returns ErrAssertionIDReplayed if it is recorded.
+// The check and the record are done under the lock, so that only one of the concurrent assertions with the jti is accepted.
+func (s *AuthorizationStorage) RecordAssertionID(ctx context.Context, issuer, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range s.assertionIDKvs {
+		if !now.Before(exp) {
+			delete(s.assertionIDKvs, k)
+		}
+	}
+
+	key := issuer + " " + jti
+	if _, ok := s.assertionIDKvs[key]; ok {
+		return ErrAssertionIDReplayed
+	}
+	s.assertionIDKvs[key] = expiresAt
+	return nil
+}
+
+// clone returns a shallow copy of v, so that the callers do not change the stored value without the lock.
+func clone[T any](v *T) *T {
+	c := *v
+	return &c
 }
@@ -0,0 +1,87 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestAuthorizationStorageRecordAssertionIDConcurrently(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewEmptyAuthorizationStorage()
+	expiresAt := time.Now().Add(time.Minute)
+
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.RecordAssertionID(ctx, "https://issuer.example.com", "dummy-jti", expiresAt)
+			switch {
+			case err == nil:
+				accepted.Add(1)
+			case !errors.Is(err, ErrAssertionIDReplayed):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// only one of the concurrent assertions with the jti is accepted.
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("want 1 accepted assertion, got %d", got)
+	}
+}
+
+func TestAuthorizationStorageReturnsCopies(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewEmptyAuthorizationStorage()
+	req, err := s.CreateAuthorizationRequest(ctx, &model.AuthRequest{ClientID: "dummy-client-id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetAuthorizationRequest(ctx, req.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.Authentication = &model.Authentication{Subject: "dummy-user-id"}
+	req.ClientID = "other-client-id"
+
+	// the stored request is changed only through the storage.
+	stored, err := s.GetAuthorizationRequest(ctx, req.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Authentication != nil || stored.ClientID != "dummy-client-id" {
+		t.Errorf("stored request is changed: %+v", stored)
+	}
+}
+
+func TestAuthorizationStorageGenerateAuthorizationCodeOnce(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewEmptyAuthorizationStorage()
+	req, err := s.CreateAuthorizationRequest(ctx, &model.AuthRequest{ClientID: "dummy-client-id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GenerateAuthorizationCode(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GenerateAuthorizationCode(ctx, req); !errors.Is(err, ErrAuthReqDisabled) {
+		t.Errorf("want error %v, got %v", ErrAuthReqDisabled, err)
+	}
+}
@@ -0,0 +1,51 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var (
+	ErrUpstreamLoginRequestInvalid  = errors.New("upstream login request is invalid")
+	ErrUpstreamLoginRequestNotFound = errors.New("upstream login request not found")
+)
+
+type UpstreamLoginStorage struct {
+	// mu guards the requests, which are consumed only once even by the concurrent requests.
+	mu         sync.Mutex
+	requestKvs map[string]*model.UpstreamLoginRequest
+}
+
+func NewUpstreamLoginStorage() *UpstreamLoginStorage {
+	return &UpstreamLoginStorage{
+		requestKvs: make(map[string]*model.UpstreamLoginRequest),
+	}
+}
+
+func (s *UpstreamLoginStorage) CreateUpstreamLoginRequest(ctx context.Context, req *model.UpstreamLoginRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req == nil || req.State == "" {
+		return ErrUpstreamLoginRequestInvalid
+	}
+
+	s.requestKvs[req.State] = clone(req)
+	return nil
+}
+
+func (s *UpstreamLoginStorage) ConsumeUpstreamLoginRequest(ctx context.Context, state string) (*model.UpstreamLoginRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.requestKvs[state]
+	if !ok {
+		return nil, ErrUpstreamLoginRequestNotFound
+	}
+
+	delete(s.requestKvs, state)
+	return v, nil
+}
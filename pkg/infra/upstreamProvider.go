@@ -0,0 +1,216 @@
+package infra
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+const (
+	upstreamSimulatorKeyID     = "upstream-key"
+	upstreamSimulatorTokenLife = 5 * time.Minute
+)
+
+// UpstreamProviderSimulator stands in for the upstream OpenID provider in process, so that the federation is exercised
+// without the corporate provider. It serves the discovery, the authorization, the token and the JWKS endpoints, and
+// the authorization endpoint approves the requests at once as the user of the claims set by SetClaims.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth
+type UpstreamProviderSimulator struct {
+	clientID     string
+	clientSecret string
+	key          *ecdsa.PrivateKey
+
+	// Issuer is the URL at which the simulator is served, which is known after the server starts.
+	Issuer string
+	// ModifyIDToken tampers the claims of the ID tokens to simulate the misbehaving providers.
+	ModifyIDToken func(claims map[string]any)
+
+	mu     sync.Mutex
+	claims map[string]any
+	codes  map[string]*simulatedAuthorizationCode
+}
+
+type simulatedAuthorizationCode struct {
+	claims        map[string]any
+	nonce         string
+	redirectURI   string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// NewUpstreamProviderSimulator returns the provider which has registered the client with clientID and clientSecret.
+func NewUpstreamProviderSimulator(clientID, clientSecret string) (*UpstreamProviderSimulator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &UpstreamProviderSimulator{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]*simulatedAuthorizationCode),
+	}, nil
+}
+
+// SetClaims logs the user of claims in, which must contain sub. The requests are denied while no user is logged in.
+func (p *UpstreamProviderSimulator) SetClaims(claims map[string]any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.claims = maps.Clone(claims)
+}
+
+func (p *UpstreamProviderSimulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		p.discovery(w)
+	case "/authorize":
+		p.authorize(w, r)
+	case "/token":
+		p.token(w, r)
+	case "/jwks":
+		p.jwks(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// ref: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+func (p *UpstreamProviderSimulator) discovery(w http.ResponseWriter) {
+	writeSimulatorJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                p.Issuer,
+		"authorization_endpoint":                p.Issuer + "/authorize",
+		"token_endpoint":                        p.Issuer + "/token",
+		"jwks_uri":                              p.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{service.AlgES256},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (p *UpstreamProviderSimulator) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("client_id") != p.clientID || redirectURI.Scheme == "" {
+		http.Error(w, "invalid client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := url.Values{}
+	params.Set("state", q.Get("state"))
+	p.mu.Lock()
+	claims := p.claims
+	p.mu.Unlock()
+	switch {
+	case q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		params.Set("error", "invalid_request")
+	case claims == nil:
+		params.Set("error", "access_denied")
+	default:
+		code := uuid.NewString()
+		p.mu.Lock()
+		p.codes[code] = &simulatedAuthorizationCode{
+			claims:        claims,
+			nonce:         q.Get("nonce"),
+			redirectURI:   redirectURI.String(),
+			codeChallenge: q.Get("code_challenge"),
+			expiresAt:     time.Now().Add(upstreamSimulatorTokenLife),
+		}
+		p.mu.Unlock()
+		params.Set("code", code)
+	}
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#TokenRequest
+func (p *UpstreamProviderSimulator) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != p.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(p.clientSecret)) != 1 {
+		writeSimulatorJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeSimulatorJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	p.mu.Lock()
+	code, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code"))
+	p.mu.Unlock()
+	// ref: https://datatracker.ietf.org/doc/html/rfc7636#section-4.6
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || time.Now().After(code.expiresAt) || code.redirectURI != r.PostFormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != code.codeChallenge {
+		writeSimulatorJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := maps.Clone(code.claims)
+	claims["iss"] = p.Issuer
+	claims["aud"] = p.clientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(upstreamSimulatorTokenLife).Unix()
+	claims["auth_time"] = now.Unix()
+	if code.nonce != "" {
+		claims["nonce"] = code.nonce
+	}
+	if p.ModifyIDToken != nil {
+		p.ModifyIDToken(claims)
+	}
+	idToken, err := service.SignJWT(&service.JWSHeader{Algorithm: service.AlgES256, KeyID: upstreamSimulatorKeyID, Type: "JWT"}, p.key, claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeSimulatorJSON(w, http.StatusOK, map[string]any{
+		"access_token": uuid.NewString(),
+		"token_type":   "Bearer",
+		"expires_in":   int64(upstreamSimulatorTokenLife.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (p *UpstreamProviderSimulator) jwks(w http.ResponseWriter) {
+	jwk, err := service.NewJWK(&p.key.PublicKey, upstreamSimulatorKeyID, service.KeyUseSignature, service.AlgES256)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeSimulatorJSON(w, http.StatusOK, &model.JWKSet{Keys: []model.JWK{*jwk}})
+}
+
+func writeSimulatorJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
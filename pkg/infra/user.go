@@ -0,0 +1,207 @@
+package infra
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var (
+	ErrUserInvalid  = errors.New("user is invalid")
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+	// ErrTOTPNotEnrolled is returned when the TOTP of the user is not enrolled, or is already confirmed on ConfirmTOTP.
+	ErrTOTPNotEnrolled  = errors.New("TOTP is not enrolled")
+	ErrTOTPStepUsed     = errors.New("TOTP code is already used")
+	ErrRecoveryCodeUsed = errors.New("recovery code is already used")
+)
+
+// UserStorage keeps the copies of the users, and returns the copies of them,
+// so that the users are changed only through the storage under the lock.
+type UserStorage struct {
+	// mu guards the users, as the storage is shared by the concurrent requests and their credentials are checked and changed in a single step.
+	mu      sync.Mutex
+	userKvs map[string]*model.User
+}
+
+// NewUserStorage returns the storage with the dummy user.
+func NewUserStorage(passwordFixedKey string) *UserStorage {
+	password := "dummy-password"
+	passwordHash := sha256.Sum256([]byte(password + passwordFixedKey))
+
+	s := NewEmptyUserStorage()
+	s.userKvs["dummy-user-id"] = &model.User{
+		ID:           "dummy-user-id",
+		Username:     "dummy-user",
+		PasswordHash: string(passwordHash[:]), // should be hashed with sha256
+	}
+	return s
+}
+
+// NewEmptyUserStorage returns the storage without users, who are registered by CreateUser.
+func NewEmptyUserStorage() *UserStorage {
+	return &UserStorage{
+		userKvs: make(map[string]*model.User),
+	}
+}
+
+func (s *UserStorage) CreateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user == nil || user.ID == "" || user.Username == "" {
+		return ErrUserInvalid
+	}
+	if _, ok := s.userKvs[user.ID]; ok {
+		return ErrUserExists
+	}
+	if _, ok := s.userByUsername(user.Username); ok {
+		return ErrUserExists
+	}
+
+	s.userKvs[user.ID] = cloneUser(user)
+	return nil
+}
+
+func (s *UserStorage) GetUser(ctx context.Context, id string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.userKvs[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(v), nil
+}
+
+func (s *UserStorage) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.userByUsername(username)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(v), nil
+}
+
+func (s *UserStorage) userByUsername(username string) (*model.User, bool) {
+	for _, v := range s.userKvs {
+		if v.Username == username {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (s *UserStorage) GetUserByFederatedIdentity(ctx context.Context, issuer, subject string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, v := range s.userKvs {
+		if _, ok := v.FederatedIdentity(issuer, subject); ok {
+			return cloneUser(v), nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (s *UserStorage) UpdateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user == nil {
+		return ErrUserInvalid
+	}
+	if _, ok := s.userKvs[user.ID]; !ok {
+		return ErrUserNotFound
+	}
+
+	s.userKvs[user.ID] = cloneUser(user)
+	return nil
+}
+
+func (s *UserStorage) ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.userKvs[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if v.TOTP == nil || v.TOTP.Confirmed {
+		return ErrTOTPNotEnrolled
+	}
+	if v.TOTP.LastUsedStep >= step {
+		return ErrTOTPStepUsed
+	}
+
+	v.TOTP.Confirmed = true
+	v.TOTP.LastUsedStep = step
+	v.TOTP.RecoveryCodeHashes = slices.Clone(recoveryCodeHashes)
+	return nil
+}
+
+func (s *UserStorage) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.userKvs[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if v.TOTP == nil {
+		return ErrTOTPNotEnrolled
+	}
+	if v.TOTP.LastUsedStep >= step {
+		return ErrTOTPStepUsed
+	}
+
+	v.TOTP.LastUsedStep = step
+	return nil
+}
+
+func (s *UserStorage) UseRecoveryCode(ctx context.Context, userID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.userKvs[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if v.TOTP == nil {
+		return ErrTOTPNotEnrolled
+	}
+	i := slices.Index(v.TOTP.RecoveryCodeHashes, hash)
+	if i < 0 {
+		return ErrRecoveryCodeUsed
+	}
+
+	v.TOTP.RecoveryCodeHashes = slices.Delete(slices.Clone(v.TOTP.RecoveryCodeHashes), i, i+1)
+	return nil
+}
+
+// cloneUser returns a copy of v with the copies of the credentials, so that the callers do not change them without the lock.
+func cloneUser(v *model.User) *model.User {
+	user := *v
+	if v.TOTP != nil {
+		totp := *v.TOTP
+		totp.RecoveryCodeHashes = slices.Clone(v.TOTP.RecoveryCodeHashes)
+		user.TOTP = &totp
+	}
+	user.WebAuthnCredentials = slices.Clone(v.WebAuthnCredentials)
+	for i, c := range user.WebAuthnCredentials {
+		credential := *c
+		user.WebAuthnCredentials[i] = &credential
+	}
+	user.FederatedIdentities = slices.Clone(v.FederatedIdentities)
+	for i, f := range user.FederatedIdentities {
+		identity := *f
+		user.FederatedIdentities[i] = &identity
+	}
+	return &user
+}
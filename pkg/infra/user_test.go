@@ -0,0 +1,49 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestUserStorageConcurrently(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	s := NewUserStorage("fixed-key")
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("user-%d", i)
+			if err := s.CreateUser(ctx, &model.User{ID: id, Username: id}); err != nil {
+				t.Error(err)
+				return
+			}
+			user, err := s.GetUser(ctx, "dummy-user-id")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			user.Username = id
+			if _, err := s.GetUserByUsername(ctx, id); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the users returned by the storage are copies, which do not change the stored ones.
+	user, err := s.GetUser(ctx, "dummy-user-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "dummy-user" {
+		t.Errorf("stored user is changed: %+v", user)
+	}
+}
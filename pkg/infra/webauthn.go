@@ -0,0 +1,62 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var (
+	ErrWebAuthnCeremonyInvalid  = errors.New("webauthn ceremony is invalid")
+	ErrWebAuthnCeremonyNotFound = errors.New("webauthn ceremony not found")
+	ErrWebAuthnCeremonyExpired  = errors.New("webauthn ceremony is expired")
+)
+
+type WebAuthnStorage struct {
+	// mu guards the ceremonies, which are consumed only once even by the concurrent requests.
+	mu          sync.Mutex
+	ceremonyKvs map[string]*model.WebAuthnCeremony
+}
+
+func NewWebAuthnStorage() *WebAuthnStorage {
+	return &WebAuthnStorage{
+		ceremonyKvs: make(map[string]*model.WebAuthnCeremony),
+	}
+}
+
+func (s *WebAuthnStorage) CreateWebAuthnCeremony(ctx context.Context, ceremony *model.WebAuthnCeremony) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ceremony == nil || ceremony.ID == "" {
+		return ErrWebAuthnCeremonyInvalid
+	}
+
+	// the ceremonies which are started and never finished are swept, so that they do not pile up.
+	for id, v := range s.ceremonyKvs {
+		if v.IsExpired() {
+			delete(s.ceremonyKvs, id)
+		}
+	}
+
+	s.ceremonyKvs[ceremony.ID] = clone(ceremony)
+	return nil
+}
+
+func (s *WebAuthnStorage) ConsumeWebAuthnCeremony(ctx context.Context, id string) (*model.WebAuthnCeremony, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.ceremonyKvs[id]
+	if !ok {
+		return nil, ErrWebAuthnCeremonyNotFound
+	}
+
+	delete(s.ceremonyKvs, id)
+	if v.IsExpired() {
+		return nil, ErrWebAuthnCeremonyExpired
+	}
+	return v, nil
+}
@@ -0,0 +1,67 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestWebAuthnStorageConsumeWebAuthnCeremony(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		expiresAt time.Time
+		wantErr   error
+	}{
+		"ok": {
+			expiresAt: time.Now().Add(time.Minute),
+		},
+		"ng: expired": {
+			expiresAt: time.Now().Add(-time.Second),
+			wantErr:   ErrWebAuthnCeremonyExpired,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			s := NewWebAuthnStorage()
+			ceremony := &model.WebAuthnCeremony{ID: "dummy-ceremony-id", ExpiresAt: tt.expiresAt}
+			s.ceremonyKvs[ceremony.ID] = ceremony
+
+			_, err := s.ConsumeWebAuthnCeremony(ctx, ceremony.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+
+			// the ceremony is consumed only once.
+			if _, err := s.ConsumeWebAuthnCeremony(ctx, ceremony.ID); !errors.Is(err, ErrWebAuthnCeremonyNotFound) {
+				t.Errorf("want error %v, got %v", ErrWebAuthnCeremonyNotFound, err)
+			}
+		})
+	}
+}
+
+func TestWebAuthnStorageSweepsExpiredCeremonies(t *testing.T) {
+	t.Parallel()
+
+	s := NewWebAuthnStorage()
+	s.ceremonyKvs["expired-ceremony-id"] = &model.WebAuthnCeremony{ID: "expired-ceremony-id", ExpiresAt: time.Now().Add(-time.Second)}
+
+	ceremony, err := model.NewWebAuthnCeremony(model.WebAuthnCeremonyAuthentication, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateWebAuthnCeremony(context.Background(), ceremony); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.ceremonyKvs["expired-ceremony-id"]; ok || len(s.ceremonyKvs) != 1 {
+		t.Errorf("expired ceremony is not swept: %v", s.ceremonyKvs)
+	}
+}
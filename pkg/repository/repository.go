@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/task4233/oauth/pkg/domain/model"
 )
@@ -12,15 +13,110 @@ type Storage interface {
 
 type AuthorizationStorage interface {
 	GetAuthorizationRequest(context.Context, string) (*model.AuthRequest, error)
-	GetAuthorizationRequestByCode(context.Context, string) (*model.AuthRequest, error)
 	GenerateAuthorizationCode(context.Context, *model.AuthRequest) (*model.AuthRequest, error)
 	CreateAuthorizationRequest(context.Context, *model.AuthRequest) (*model.AuthRequest, error)
+	UpdateAuthorizationRequest(context.Context, *model.AuthRequest) error
 	DisableAuthorizationRequest(context.Context, string) error
+	// ConsumeAuthorizationCode disables the authorization request of the code and returns it in a single step,
+	// and fails if it is already disabled, so that the code is redeemed only once.
+	ConsumeAuthorizationCode(ctx context.Context, code string) (*model.AuthRequest, error)
+
+	CreatePushedAuthRequest(context.Context, *model.PushedAuthRequest) error
+	// ConsumePushedAuthRequest returns the pushed request and removes it, as request_uri is one-time use.
+	ConsumePushedAuthRequest(context.Context, string) (*model.PushedAuthRequest, error)
 
 	CreateAccessToken(context.Context, *model.AccessToken) error
 	GetAccessToken(context.Context, string) (*model.AccessToken, error)
 
+	CreateRefreshToken(context.Context, *model.RefreshToken) error
+	GetRefreshToken(context.Context, string) (*model.RefreshToken, error)
+
 	GetClient(context.Context, string) (model.Client, error)
+
+	GetProtectedResource(context.Context, string) (*model.ProtectedResource, error)
+	GetProtectedResourceByID(context.Context, string) (*model.ProtectedResource, error)
+
+	GetAuthorizationDetailType(context.Context, string) (*model.AuthorizationDetailType, error)
+
+	CreateBackchannelAuthRequest(context.Context, *model.BackchannelAuthRequest) error
+	GetBackchannelAuthRequest(context.Context, string) (*model.BackchannelAuthRequest, error)
+	// UpdateBackchannelAuthRequest saves the pending request, and fails if it is completed in the meantime.
+	UpdateBackchannelAuthRequest(context.Context, *model.BackchannelAuthRequest) error
+	// ConsumeBackchannelAuthRequest changes the status of the request from `from` to `to` in a single step and returns it,
+	// and fails if the request is not in `from`, so that the tokens of the approved request are issued only once.
+	ConsumeBackchannelAuthRequest(ctx context.Context, authReqID string, from, to model.BackchannelAuthStatus) (*model.BackchannelAuthRequest, error)
+
+	CreateLogoutRequest(context.Context, *model.LogoutRequest) error
+	// ConsumeLogoutRequest returns the logout request and removes it, as it is completed only once.
+	ConsumeLogoutRequest(context.Context, string) (*model.LogoutRequest, error)
+	// AddSessionClient records that an ID token of the session is issued to the client.
+	AddSessionClient(ctx context.Context, sessionID, clientID string) error
+	// ConsumeSessionClients returns the clients of the session and forgets them, as the session is ended.
+	ConsumeSessionClients(ctx context.Context, sessionID string) ([]string, error)
+
+	GetTrustedIssuer(context.Context, string) (*model.TrustedIssuer, error)
+	// RecordAssertionID records jti of the issuer until expiresAt, and fails if it is already recorded to prevent replays.
+	RecordAssertionID(ctx context.Context, issuer, jti string, expiresAt time.Time) error
+}
+
+type SessionStorage interface {
+	CreateSession(context.Context, *model.Session) error
+	GetSession(context.Context, string) (*model.Session, error)
+	DeleteSession(context.Context, string) error
+}
+
+type WebAuthnStorage interface {
+	CreateWebAuthnCeremony(context.Context, *model.WebAuthnCeremony) error
+	// ConsumeWebAuthnCeremony returns the ceremony and removes it in a single step, as its challenge is one-time use.
+	// It fails if the ceremony is expired.
+	ConsumeWebAuthnCeremony(context.Context, string) (*model.WebAuthnCeremony, error)
+}
+
+// UpstreamLoginStorage keeps the logins started at the upstream providers until their callbacks.
+type UpstreamLoginStorage interface {
+	CreateUpstreamLoginRequest(context.Context, *model.UpstreamLoginRequest) error
+	// ConsumeUpstreamLoginRequest returns the request of the state and removes it, as the state is one-time use.
+	ConsumeUpstreamLoginRequest(context.Context, string) (*model.UpstreamLoginRequest, error)
+}
+
+type UserStorage interface {
+	CreateUser(context.Context, *model.User) error
+	GetUser(context.Context, string) (*model.User, error)
+	GetUserByUsername(context.Context, string) (*model.User, error)
+	// GetUserByFederatedIdentity returns the user to whom the account of the issuer with the subject is linked.
+	GetUserByFederatedIdentity(ctx context.Context, issuer, subject string) (*model.User, error)
+	UpdateUser(context.Context, *model.User) error
+	// ConfirmTOTP confirms the TOTP of the user with the step of the code and the recovery codes in a single step,
+	// and fails if it is already confirmed, so that the enrollment is confirmed only once.
+	ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+	// UseTOTPStep sets the last used step of the TOTP of the user only if it is lower than step,
+	// and fails otherwise, so that the code is accepted only once even on the concurrent requests.
+	UseTOTPStep(ctx context.Context, userID string, step int64) error
+	// UseRecoveryCode removes the hash of the recovery code of the user only if it is present,
+	// and fails otherwise, so that the recovery code is accepted only once even on the concurrent requests.
+	UseRecoveryCode(ctx context.Context, userID, hash string) error
+}
+
+// LockoutStorage keeps the counters of failed attempts, which should survive restarts so that they are not reset by them.
+type LockoutStorage interface {
+	// GetFailureCounter returns the counter of key, which has no failures if none is recorded.
+	GetFailureCounter(context.Context, model.FailureKey) (*model.FailureCounter, error)
+	// IncrementFailure records the failed attempt of key with the policy at the time in a single step, so that none of
+	// the concurrent attempts is lost, and returns the counter and whether the attempts are locked out by it.
+	IncrementFailure(context.Context, model.FailureKey, model.LockoutPolicy, time.Time) (*model.FailureCounter, bool, error)
+	// ResetFailureCounter forgets the failures of key unless the attempts are locked out at the time.
+	ResetFailureCounter(context.Context, model.FailureKey, time.Time) error
+	DeleteFailureCounter(context.Context, model.FailureKey) error
+	ListFailureCounters(context.Context) ([]*model.FailureCounter, error)
+}
+
+// KeyStorage keeps the signing keys, which should survive restarts so that the JWTs signed before them are verified.
+// The private keys are encrypted before they are stored.
+type KeyStorage interface {
+	ListKeys(context.Context) ([]*model.KeyRecord, error)
+	// SaveKey creates the key or replaces the one of the same kid.
+	SaveKey(context.Context, *model.KeyRecord) error
+	DeleteKey(context.Context, string) error
 }
 
 type Hasher interface {
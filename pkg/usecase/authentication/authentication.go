@@ -0,0 +1,151 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/repository"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("username or password is invalid")
+	ErrNoSession          = errors.New("user has no session to reuse")
+)
+
+const defaultTOTPIssuer = "oauth-go"
+
+type AuthNUseCase struct {
+	Users       repository.UserStorage
+	Hasher      repository.Hasher
+	LoginTokens *service.LoginTokenCodec
+
+	// Lockout throttles guessing the passwords of users. Login attempts are not throttled if it is nil.
+	Lockout *service.Lockout
+
+	// Sessions keeps users logged in for single sign-on. Users log in every time if it is nil.
+	Sessions repository.SessionStorage
+
+	// TOTPIssuer is the name of the service shown in the authenticators of users.
+	TOTPIssuer string
+
+	// WebAuthn keeps the challenges of the passkey ceremonies. Users cannot use passkeys if it is nil.
+	WebAuthn     repository.WebAuthnStorage
+	RelyingParty WebAuthnRelyingParty
+
+	// UpstreamProviders are the OpenID providers with which users log in instead of the passwords.
+	// UpstreamLogins keeps the logins started at them, and users cannot use them if it is nil.
+	UpstreamProviders []*model.UpstreamProvider
+	UpstreamLogins    repository.UpstreamLoginStorage
+	// UpstreamRedirectURI is the callback of the authentication server registered at the providers.
+	UpstreamRedirectURI string
+
+	// HTTPClient calls the upstream providers. http.DefaultClient is used if it is nil.
+	HTTPClient *http.Client
+
+	// upstreamMu guards the endpoints of UpstreamProviders, which are discovered on their first use.
+	upstreamMu sync.Mutex
+}
+
+func NewAuthNUseCase(users repository.UserStorage, hasher repository.Hasher, loginTokens *service.LoginTokenCodec) *AuthNUseCase {
+	if hasher == nil || loginTokens == nil {
+		return nil
+	}
+	return &AuthNUseCase{
+		Users:       users,
+		Hasher:      hasher,
+		LoginTokens: loginTokens,
+		TOTPIssuer:  defaultTOTPIssuer,
+	}
+}
+
+// Login authenticates the user with the password.
+// The failures are counted by the username and the address, and *service.LockedOutError is returned while they are throttled.
+func (s *AuthNUseCase) Login(ctx context.Context, username, password string) (*model.Authentication, error) {
+	keys := service.FailureKeys(ctx, model.FailureKindUser, username)
+	if err := s.Lockout.Check(ctx, keys...); err != nil {
+		return nil, err
+	}
+
+	user, err := s.Users.GetUserByUsername(ctx, username)
+	if err != nil {
+		// the unknown usernames are counted too, so that they are not distinguished from the known ones.
+		return nil, errors.Join(ErrInvalidCredentials, s.Lockout.Fail(ctx, keys...))
+	}
+
+	ok, err := s.Hasher.Compare(ctx, []byte(user.PasswordHash), []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.Join(ErrInvalidCredentials, s.Lockout.Fail(ctx, keys...))
+	}
+	err = s.Lockout.Succeed(ctx, keys[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Authentication{
+		Subject:  user.ID,
+		AuthTime: time.Now(),
+		ACR:      model.ACRBasic,
+		AMR:      []string{model.AMRPassword},
+	}, nil
+}
+
+// LoginToken binds authn to the authorization request so that the authorization server can continue it.
+func (s *AuthNUseCase) LoginToken(authReqID string, authn *model.Authentication) (string, error) {
+	return s.LoginTokens.Encode(authReqID, authn)
+}
+
+// StartSession starts the single sign-on session of the authenticated user, and returns authn with the session ID.
+// ref: https://openid.net/specs/openid-connect-session-1_0.html
+func (s *AuthNUseCase) StartSession(ctx context.Context, authn *model.Authentication) (*model.Session, error) {
+	if s.Sessions == nil {
+		return nil, ErrNoSession
+	}
+
+	session := model.NewSession(authn)
+	err := s.Sessions.CreateSession(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ReuseSession returns the authentication of the session if it satisfies acrValues and maxAge of the authorization request.
+func (s *AuthNUseCase) ReuseSession(ctx context.Context, sessionID string, acrValues []string, maxAge *int64) (*model.Authentication, error) {
+	if s.Sessions == nil || sessionID == "" {
+		return nil, ErrNoSession
+	}
+
+	session, err := s.Sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, errors.Join(ErrNoSession, err)
+	}
+	if !session.Satisfies(acrValues, maxAge) {
+		return nil, ErrNoSession
+	}
+	return session.Authentication, nil
+}
+
+// EndSession logs the user out, and returns the authentication of the ended session.
+func (s *AuthNUseCase) EndSession(ctx context.Context, sessionID string) (*model.Authentication, error) {
+	if s.Sessions == nil || sessionID == "" {
+		return nil, ErrNoSession
+	}
+
+	session, err := s.Sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, errors.Join(ErrNoSession, err)
+	}
+	err = s.Sessions.DeleteSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return session.Authentication, nil
+}
@@ -0,0 +1,103 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+func TestAuthNUseCaseLoginLockout(t *testing.T) {
+	t.Parallel()
+
+	policy := model.LockoutPolicy{
+		FreeAttempts:     3,
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	}
+
+	tests := map[string]struct {
+		username  string
+		failures  int
+		password  string
+		wantErr   error
+		wantCount int
+	}{
+		"ok: below the threshold": {
+			username: "dummy-user",
+			failures: 2,
+			password: "dummy-password",
+			// the failures of the user are forgotten, but those of the address are not.
+			wantCount: 1,
+		},
+		"ng: locked out": {
+			username:  "dummy-user",
+			failures:  3,
+			password:  "dummy-password",
+			wantErr:   service.ErrLockedOut,
+			wantCount: 2,
+		},
+		"ng: unknown user is locked out too": {
+			username:  "unknown-user",
+			failures:  3,
+			password:  "dummy-password",
+			wantErr:   service.ErrLockedOut,
+			wantCount: 2,
+		},
+		"ng: wrong password": {
+			username:  "dummy-user",
+			password:  "wrong-password",
+			wantErr:   ErrInvalidCredentials,
+			wantCount: 2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			storage, err := infra.NewLockoutStorage("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			uc := newTestAuthNUseCase(t)
+			uc.Lockout = service.NewLockout(storage).WithPolicy(model.FailureKindUser, policy)
+			ctx := service.ContextWithRemoteIP(context.Background(), "192.0.2.1")
+
+			for range tt.failures {
+				_, err := uc.Login(ctx, tt.username, "wrong-password")
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("want %v, got %v", ErrInvalidCredentials, err)
+				}
+			}
+
+			authn, err := uc.Login(ctx, "dummy-user", tt.password)
+			if tt.username != "dummy-user" {
+				// the other user is not affected by the lockout.
+				if err != nil {
+					t.Fatal(err)
+				}
+				authn, err = uc.Login(ctx, tt.username, tt.password)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && authn.Subject != "dummy-user-id" {
+				t.Errorf("unexpected authentication: %+v", authn)
+			}
+
+			counters, err := storage.ListFailureCounters(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(counters) != tt.wantCount {
+				t.Errorf("want %d counters, got %+v", tt.wantCount, counters)
+			}
+		})
+	}
+}
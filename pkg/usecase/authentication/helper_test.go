@@ -0,0 +1,38 @@
+package authentication
+
+import (
+	"context"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+const testAuthReqID = "dummy-auth-req-id"
+
+// testAuthNOption customizes the use case built by newTestAuthNUseCase.
+type testAuthNOption func(t *testing.T, uc *AuthNUseCase)
+
+// newTestAuthNUseCase returns the use case with the dummy user of infra.NewUserStorage,
+// to which the features under test are added by opts.
+func newTestAuthNUseCase(t *testing.T, opts ...testAuthNOption) *AuthNUseCase {
+	t.Helper()
+
+	uc := NewAuthNUseCase(infra.NewUserStorage("fixed-key"), service.NewSha256Hasher("fixed-key"), service.NewLoginTokenCodec([]byte("login-token-key")))
+	for _, opt := range opts {
+		opt(t, uc)
+	}
+	return uc
+}
+
+// testLogin returns the authentication of the dummy user with the password.
+func testLogin(t *testing.T, uc *AuthNUseCase) *model.Authentication {
+	t.Helper()
+
+	authn, err := uc.Login(context.Background(), "dummy-user", "dummy-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authn
+}
@@ -0,0 +1,288 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+const (
+	// secondFactorLifetime is how long the user authenticated with the password may take to prove the second factor.
+	secondFactorLifetime = 5 * time.Minute
+	recoveryCodeCount    = 10
+	recoveryCodeSize     = 5 // 8 characters in base32
+)
+
+var (
+	ErrInvalidOTP          = errors.New("one-time password is invalid")
+	ErrTOTPAlreadyEnrolled = errors.New("TOTP is already enrolled")
+	ErrTOTPNotEnrolled     = errors.New("TOTP is not enrolled")
+	// ErrSecondFactorTokenInvalidated is returned with ErrInvalidOTP after too many wrong codes with the token,
+	// and the user has to log in with the password again.
+	ErrSecondFactorTokenInvalidated = errors.New("too many wrong codes, log in again")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// SecondFactor is what the user has to do after the password is verified.
+type SecondFactor int
+
+const (
+	SecondFactorNone SecondFactor = iota
+	SecondFactorVerify
+	SecondFactorEnroll
+)
+
+// TOTPEnrollment is the secret shown to the user to register it with the authenticator.
+type TOTPEnrollment struct {
+	Secret string // base32-encoded
+	KeyURI string
+}
+
+// SecondFactor returns whether the user authenticated with the first factor has to prove the second factor.
+// Users who have enrolled TOTP always prove it, and the others enroll it when the multi-factor authentication is requested.
+func (s *AuthNUseCase) SecondFactor(ctx context.Context, authn *model.Authentication, acrValues []string) (SecondFactor, error) {
+	user, err := s.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		return SecondFactorNone, err
+	}
+
+	switch {
+	case authn.ACR == model.ACRMultiFactor:
+		// the passkey verified with the authenticator is already multi-factor.
+		return SecondFactorNone, nil
+	case user.HasSecondFactor():
+		return SecondFactorVerify, nil
+	case slices.Contains(acrValues, model.ACRMultiFactor):
+		return SecondFactorEnroll, nil
+	default:
+		return SecondFactorNone, nil
+	}
+}
+
+// SecondFactorToken keeps the user authenticated while the user proves the second factor for the authorization request.
+func (s *AuthNUseCase) SecondFactorToken(authReqID string, authn *model.Authentication) (string, error) {
+	return s.LoginTokens.WithLifetime(secondFactorLifetime).Encode(secondFactorAudience(authReqID), authn)
+}
+
+func (s *AuthNUseCase) decodeSecondFactorToken(authReqID, token string) (*model.Authentication, error) {
+	authn, err := s.LoginTokens.WithLifetime(secondFactorLifetime).Decode(token, secondFactorAudience(authReqID))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidOTP, err)
+	}
+	return authn, nil
+}
+
+// secondFactorAudience distinguishes the tokens from the login tokens of the authorization request.
+func secondFactorAudience(authReqID string) string {
+	return "second-factor:" + authReqID
+}
+
+// checkSecondFactor returns the keys by which the wrong codes of the user with token are counted, or the error if
+// they have to wait. The codes are throttled by the user so that they are not guessed with new tokens, and the
+// token is invalidated after a few wrong codes.
+// ref: https://datatracker.ietf.org/doc/html/rfc6238#section-5.2
+func (s *AuthNUseCase) checkSecondFactor(ctx context.Context, authn *model.Authentication, token string) ([]model.FailureKey, error) {
+	digest := sha256.Sum256([]byte(token))
+	tokenKey := model.FailureKey{Kind: model.FailureKindSecondFactorToken, Key: base64.RawURLEncoding.EncodeToString(digest[:])}
+	if err := s.Lockout.Check(ctx, tokenKey); err != nil {
+		if errors.Is(err, service.ErrLockedOut) {
+			return nil, errors.Join(ErrInvalidOTP, ErrSecondFactorTokenInvalidated)
+		}
+		return nil, err
+	}
+
+	keys := service.FailureKeys(ctx, model.FailureKindOTP, authn.Subject)
+	if err := s.Lockout.Check(ctx, keys...); err != nil {
+		return nil, err
+	}
+	return append(keys, tokenKey), nil
+}
+
+// StartTOTPEnrollment generates the TOTP secret of the user, which is used once the user confirms it.
+func (s *AuthNUseCase) StartTOTPEnrollment(ctx context.Context, authReqID, token string) (*TOTPEnrollment, error) {
+	authn, err := s.decodeSecondFactorToken(authReqID, token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user.HasSecondFactor() {
+		return nil, ErrTOTPAlreadyEnrolled
+	}
+
+	secret, err := service.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTP = &model.TOTPCredential{Secret: secret}
+	err = s.Users.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret: service.EncodeTOTPSecret(secret),
+		KeyURI: service.TOTPKeyURI(s.TOTPIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTPEnrollment enables TOTP of the user with the code from the authenticator, and returns
+// the multi-factor authentication and the recovery codes, which are shown to the user only once.
+func (s *AuthNUseCase) ConfirmTOTPEnrollment(ctx context.Context, authReqID, token, code string) (*model.Authentication, []string, error) {
+	authn, err := s.decodeSecondFactorToken(authReqID, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.TOTP == nil {
+		return nil, nil, ErrTOTPNotEnrolled
+	}
+	if user.TOTP.Confirmed {
+		return nil, nil, ErrTOTPAlreadyEnrolled
+	}
+	keys, err := s.checkSecondFactor(ctx, authn, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	step, ok := service.VerifyTOTP(user.TOTP.Secret, code, time.Now(), user.TOTP.LastUsedStep)
+	if !ok {
+		return nil, nil, errors.Join(ErrInvalidOTP, s.Lockout.Fail(ctx, keys...))
+	}
+	err = s.Lockout.Succeed(ctx, keys[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recoveryCodes := make([]string, 0, recoveryCodeCount)
+	recoveryCodeHashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+		hash, err := s.Hasher.Hash(ctx, []byte(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return nil, nil, err
+		}
+		recoveryCodes = append(recoveryCodes, recoveryCode)
+		recoveryCodeHashes = append(recoveryCodeHashes, string(hash))
+	}
+
+	// the enrollment is confirmed in the storage, so that the concurrent requests do not confirm it twice with the code.
+	err = s.Users.ConfirmTOTP(ctx, user.ID, step, recoveryCodeHashes)
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidOTP, err)
+	}
+	return multiFactor(authn), recoveryCodes, nil
+}
+
+// VerifySecondFactor verifies the TOTP code or one of the recovery codes of the user, and returns the multi-factor authentication.
+// Each code is accepted only once.
+func (s *AuthNUseCase) VerifySecondFactor(ctx context.Context, authReqID, token, code string) (*model.Authentication, error) {
+	authn, err := s.decodeSecondFactorToken(authReqID, token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if !user.HasSecondFactor() {
+		return nil, ErrTOTPNotEnrolled
+	}
+	keys, err := s.checkSecondFactor(ctx, authn, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// the code is used in the storage only if it is not used yet, so that the concurrent requests with it are not accepted twice.
+	code = strings.TrimSpace(code)
+	if step, ok := service.VerifyTOTP(user.TOTP.Secret, code, time.Now(), user.TOTP.LastUsedStep); ok {
+		err = s.Users.UseTOTPStep(ctx, user.ID, step)
+	} else {
+		var i int
+		i, err = s.findRecoveryCode(ctx, user.TOTP.RecoveryCodeHashes, code)
+		if errors.Is(err, ErrInvalidOTP) {
+			return nil, errors.Join(err, s.Lockout.Fail(ctx, keys...))
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = s.Users.UseRecoveryCode(ctx, user.ID, user.TOTP.RecoveryCodeHashes[i])
+	}
+	if err != nil {
+		return nil, errors.Join(ErrInvalidOTP, err)
+	}
+	err = s.Lockout.Succeed(ctx, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	return multiFactor(authn), nil
+}
+
+// CompleteSecondFactor returns the authentication of the token if the user has proved the second factor with it.
+func (s *AuthNUseCase) CompleteSecondFactor(authReqID, token string) (*model.Authentication, error) {
+	authn, err := s.decodeSecondFactorToken(authReqID, token)
+	if err != nil {
+		return nil, err
+	}
+	if authn.ACR != model.ACRMultiFactor {
+		return nil, ErrInvalidOTP
+	}
+	return authn, nil
+}
+
+func (s *AuthNUseCase) findRecoveryCode(ctx context.Context, hashes []string, code string) (int, error) {
+	normalized := normalizeRecoveryCode(code)
+	for i, hash := range hashes {
+		ok, err := s.Hasher.Compare(ctx, []byte(hash), []byte(normalized))
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return 0, ErrInvalidOTP
+}
+
+// multiFactor returns authn elevated with the second factor.
+// ref: https://datatracker.ietf.org/doc/html/rfc8176#section-2
+func multiFactor(authn *model.Authentication) *model.Authentication {
+	v := *authn
+	v.AuthTime = time.Now()
+	v.ACR = model.ACRMultiFactor
+	v.AMR = append(slices.Clone(authn.AMR), model.AMROTP)
+	return &v
+}
+
+// generateRecoveryCode returns a random code formatted like "abcd-efgh" to be written down easily.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	return fmt.Sprintf("%s-%s", code[:4], code[4:]), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,397 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+// enrollTOTP enrolls TOTP of the user, and returns the credential and the recovery codes.
+func enrollTOTP(t *testing.T, uc *AuthNUseCase, authn *model.Authentication) (*model.TOTPCredential, []string) {
+	t.Helper()
+
+	ctx := context.Background()
+	token, err := uc.SecondFactorToken(testAuthReqID, authn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollment, err := uc.StartTOTPEnrollment(ctx, testAuthReqID, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := uc.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enrollment.Secret != service.EncodeTOTPSecret(user.TOTP.Secret) {
+		t.Fatalf("unexpected secret: %s", enrollment.Secret)
+	}
+
+	// the previous code is used so that the current one is left for the tests.
+	code := service.TOTPCode(user.TOTP.Secret, service.TOTPStep(time.Now())-1)
+	got, recoveryCodes, err := uc.ConfirmTOTPEnrollment(ctx, testAuthReqID, token, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ACR != model.ACRMultiFactor || !slices.Equal(got.AMR, append(slices.Clone(authn.AMR), model.AMROTP)) {
+		t.Fatalf("unexpected authentication: %+v", got)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("unexpected recovery codes: %v", recoveryCodes)
+	}
+
+	user, err = uc.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.TOTP, recoveryCodes
+}
+
+func TestAuthNUseCaseSecondFactor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		enrolled  bool
+		acrValues []string
+		want      SecondFactor
+	}{
+		"ok: not required": {
+			acrValues: []string{model.ACRBasic},
+			want:      SecondFactorNone,
+		},
+		"ok: enroll for mfa": {
+			acrValues: []string{model.ACRMultiFactor},
+			want:      SecondFactorEnroll,
+		},
+		"ok: always verify once enrolled": {
+			enrolled: true,
+			want:     SecondFactorVerify,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthNUseCase(t)
+			authn := testLogin(t, uc)
+			if tt.enrolled {
+				enrollTOTP(t, uc, authn)
+			}
+
+			got, err := uc.SecondFactor(context.Background(), authn, tt.acrValues)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseVerifySecondFactor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		code      func(totp *model.TOTPCredential, recoveryCodes []string) string
+		authReqID string
+		wantErr   error
+		// the code is verified twice to check that it is used only once.
+		wantReplayErr error
+	}{
+		"ok: TOTP": {
+			code: func(totp *model.TOTPCredential, _ []string) string {
+				return service.TOTPCode(totp.Secret, service.TOTPStep(time.Now()))
+			},
+			wantReplayErr: ErrInvalidOTP,
+		},
+		"ok: recovery code": {
+			code: func(_ *model.TOTPCredential, recoveryCodes []string) string {
+				return recoveryCodes[0]
+			},
+			wantReplayErr: ErrInvalidOTP,
+		},
+		"ok: recovery code without the hyphen in upper case": {
+			code: func(_ *model.TOTPCredential, recoveryCodes []string) string {
+				return strings.ToUpper(strings.ReplaceAll(recoveryCodes[1], "-", ""))
+			},
+			wantReplayErr: ErrInvalidOTP,
+		},
+		"ng: code used for the enrollment": {
+			code: func(totp *model.TOTPCredential, _ []string) string {
+				return service.TOTPCode(totp.Secret, totp.LastUsedStep)
+			},
+			wantErr: ErrInvalidOTP,
+		},
+		"ng: wrong code": {
+			code: func(*model.TOTPCredential, []string) string {
+				return "abcd-efgh"
+			},
+			wantErr: ErrInvalidOTP,
+		},
+		"ng: token for another request": {
+			code: func(totp *model.TOTPCredential, _ []string) string {
+				return service.TOTPCode(totp.Secret, service.TOTPStep(time.Now()))
+			},
+			authReqID: "another-auth-req-id",
+			wantErr:   ErrInvalidOTP,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthNUseCase(t)
+			authn := testLogin(t, uc)
+			totp, recoveryCodes := enrollTOTP(t, uc, authn)
+			token, err := uc.SecondFactorToken(testAuthReqID, authn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			authReqID := testAuthReqID
+			if tt.authReqID != "" {
+				authReqID = tt.authReqID
+			}
+
+			code := tt.code(totp, recoveryCodes)
+			got, err := uc.VerifySecondFactor(ctx, authReqID, token, code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got.Subject != authn.Subject || got.ACR != model.ACRMultiFactor || !slices.Equal(got.AMR, append(slices.Clone(authn.AMR), model.AMROTP)) {
+				t.Errorf("unexpected authentication: %+v", got)
+			}
+
+			_, err = uc.VerifySecondFactor(ctx, authReqID, token, code)
+			if !errors.Is(err, tt.wantReplayErr) {
+				t.Errorf("unexpected error of the replay: want=%v, got=%v", tt.wantReplayErr, err)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseVerifySecondFactorConcurrently(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		code func(totp *model.TOTPCredential, recoveryCodes []string) string
+	}{
+		"ok: TOTP": {
+			code: func(totp *model.TOTPCredential, _ []string) string {
+				return service.TOTPCode(totp.Secret, service.TOTPStep(time.Now()))
+			},
+		},
+		"ok: recovery code": {
+			code: func(_ *model.TOTPCredential, recoveryCodes []string) string {
+				return recoveryCodes[0]
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthNUseCase(t)
+			authn := testLogin(t, uc)
+			totp, recoveryCodes := enrollTOTP(t, uc, authn)
+			code := tt.code(totp, recoveryCodes)
+
+			var verified atomic.Int32
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					token, err := uc.SecondFactorToken(testAuthReqID, authn)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					_, err = uc.VerifySecondFactor(ctx, testAuthReqID, token, code)
+					switch {
+					case err == nil:
+						verified.Add(1)
+					case !errors.Is(err, ErrInvalidOTP):
+						t.Errorf("want %v, got %v", ErrInvalidOTP, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			// the code is accepted only once.
+			if got := verified.Load(); got != 1 {
+				t.Errorf("want the code accepted once, got %d", got)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseCompleteSecondFactor(t *testing.T) {
+	t.Parallel()
+
+	uc := newTestAuthNUseCase(t)
+	authn := testLogin(t, uc)
+
+	// the token of the password authentication does not complete the login.
+	token, err := uc.SecondFactorToken(testAuthReqID, authn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.CompleteSecondFactor(testAuthReqID, token); !errors.Is(err, ErrInvalidOTP) {
+		t.Errorf("want %v, got %v", ErrInvalidOTP, err)
+	}
+
+	token, err = uc.SecondFactorToken(testAuthReqID, multiFactor(authn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := uc.CompleteSecondFactor(testAuthReqID, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ACR != model.ACRMultiFactor {
+		t.Errorf("unexpected authentication: %+v", got)
+	}
+}
+
+func TestAuthNUseCaseSecondFactorLockout(t *testing.T) {
+	t.Parallel()
+
+	lenient := model.LockoutPolicy{FreeAttempts: 100, LockoutThreshold: 100, LockoutDuration: time.Hour, ResetAfter: time.Hour}
+	strict := model.LockoutPolicy{FreeAttempts: 3, LockoutThreshold: 3, LockoutDuration: time.Hour, ResetAfter: time.Hour}
+
+	tests := map[string]struct {
+		userPolicy  model.LockoutPolicy
+		tokenPolicy model.LockoutPolicy
+		// newToken logs in with the password again before each wrong code.
+		newToken bool
+		wantErr  error
+	}{
+		"ok: below the thresholds": {
+			userPolicy:  lenient,
+			tokenPolicy: lenient,
+		},
+		"ng: user is locked out with new tokens": {
+			userPolicy:  strict,
+			tokenPolicy: lenient,
+			newToken:    true,
+			wantErr:     service.ErrLockedOut,
+		},
+		"ng: token is invalidated": {
+			userPolicy:  lenient,
+			tokenPolicy: strict,
+			wantErr:     ErrSecondFactorTokenInvalidated,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := service.ContextWithRemoteIP(context.Background(), "192.0.2.1")
+			storage, err := infra.NewLockoutStorage("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			uc := newTestAuthNUseCase(t)
+			authn := testLogin(t, uc)
+			totp, _ := enrollTOTP(t, uc, authn)
+			uc.Lockout = service.NewLockout(storage).
+				WithPolicy(model.FailureKindOTP, tt.userPolicy).
+				WithPolicy(model.FailureKindSecondFactorToken, tt.tokenPolicy)
+
+			token, err := uc.SecondFactorToken(testAuthReqID, authn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range 3 {
+				if tt.newToken {
+					token, err = uc.SecondFactorToken(testAuthReqID, authn)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				_, err := uc.VerifySecondFactor(ctx, testAuthReqID, token, "000000")
+				if !errors.Is(err, ErrInvalidOTP) {
+					t.Fatalf("want %v, got %v", ErrInvalidOTP, err)
+				}
+			}
+
+			// the correct code is rejected while the user is locked out or once the token is invalidated.
+			code := service.TOTPCode(totp.Secret, service.TOTPStep(time.Now()))
+			_, err = uc.VerifySecondFactor(ctx, testAuthReqID, token, code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if !errors.Is(tt.wantErr, ErrSecondFactorTokenInvalidated) {
+				return
+			}
+
+			// the user proves the second factor with a new token after logging in again.
+			token, err = uc.SecondFactorToken(testAuthReqID, authn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := uc.VerifySecondFactor(ctx, testAuthReqID, token, code); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseConfirmTOTPEnrollmentLockout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	storage, err := infra.NewLockoutStorage("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := newTestAuthNUseCase(t)
+	authn := testLogin(t, uc)
+	uc.Lockout = service.NewLockout(storage).WithPolicy(model.FailureKindOTP, model.LockoutPolicy{
+		FreeAttempts:     3,
+		LockoutThreshold: 3,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	})
+	token, err := uc.SecondFactorToken(testAuthReqID, authn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.StartTOTPEnrollment(ctx, testAuthReqID, token); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 3 {
+		_, _, err := uc.ConfirmTOTPEnrollment(ctx, testAuthReqID, token, "000000")
+		if !errors.Is(err, ErrInvalidOTP) {
+			t.Fatalf("want %v, got %v", ErrInvalidOTP, err)
+		}
+	}
+	user, err := uc.Users.GetUser(ctx, authn.Subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := service.TOTPCode(user.TOTP.Secret, service.TOTPStep(time.Now()))
+	if _, _, err := uc.ConfirmTOTPEnrollment(ctx, testAuthReqID, token, code); !errors.Is(err, service.ErrLockedOut) {
+		t.Errorf("want %v, got %v", service.ErrLockedOut, err)
+	}
+}
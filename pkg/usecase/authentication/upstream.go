@@ -0,0 +1,349 @@
+package authentication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUpstreamDisabled           = errors.New("upstream login is disabled")
+	ErrUnknownUpstreamProvider    = errors.New("upstream provider is unknown")
+	ErrInvalidUpstreamLogin       = errors.New("upstream login is invalid")
+	ErrUpstreamAccountConflict    = errors.New("user of the same username exists; log in with it to link the upstream account")
+	ErrUpstreamIdentityLinked     = errors.New("upstream account or user is already linked")
+	ErrUpstreamUserNotProvisioned = errors.New("user of the upstream account is not provisioned")
+)
+
+// upstreamIDToken is the ID token issued by the upstream provider.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type upstreamIDToken struct {
+	service.RegisteredClaims
+	Nonce           string   `json:"nonce"`
+	AuthorizedParty string   `json:"azp"`
+	AuthTime        int64    `json:"auth_time"`
+	ACR             string   `json:"acr"`
+	AMR             []string `json:"amr"`
+
+	// claims are all the claims, from which the username is mapped.
+	claims map[string]any
+}
+
+// upstreamDiscovery is the part of the provider metadata which the relying party uses.
+// ref: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type upstreamDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// EnabledUpstreamProviders returns the providers shown on the login page, which are none if the upstream login is disabled.
+func (s *AuthNUseCase) EnabledUpstreamProviders() []*model.UpstreamProvider {
+	if s.UpstreamLogins == nil {
+		return nil
+	}
+	return s.UpstreamProviders
+}
+
+// StartUpstreamLogin starts the login at the upstream provider, and returns the URL of its authorization endpoint
+// to which the user is redirected. The request is bound to the callback with state, nonce and PKCE.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (s *AuthNUseCase) StartUpstreamLogin(ctx context.Context, providerID string, params model.UpstreamLoginParams) (string, error) {
+	provider, err := s.upstreamProvider(ctx, providerID)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := model.NewUpstreamLoginRequest(provider.ID, params)
+	if err != nil {
+		return "", err
+	}
+	err = s.UpstreamLogins.CreateUpstreamLoginRequest(ctx, req)
+	if err != nil {
+		return "", err
+	}
+
+	opts := []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(req.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", req.Nonce),
+	}
+	// the user authenticates again at the provider if the session there is older than max_age.
+	if params.MaxAge != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("max_age", params.MaxAge))
+	}
+	return s.upstreamOAuthConfig(provider).AuthCodeURL(req.State, opts...), nil
+}
+
+// FinishUpstreamLogin exchanges the code of the callback for the ID token, and returns the authentication of the local user
+// who the upstream account is linked to. The user is linked or provisioned on the first login as the provider allows.
+// The request of state is returned with the errors too, so that the login page is shown again for the authorization request.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth
+func (s *AuthNUseCase) FinishUpstreamLogin(ctx context.Context, state, code, upstreamError string) (*model.UpstreamLoginRequest, *model.Authentication, error) {
+	if s.UpstreamLogins == nil {
+		return nil, nil, ErrUpstreamDisabled
+	}
+	req, err := s.UpstreamLogins.ConsumeUpstreamLoginRequest(ctx, state)
+	if err != nil {
+		return nil, nil, errors.Join(ErrInvalidUpstreamLogin, err)
+	}
+	if req.IsExpired() {
+		return req, nil, fmt.Errorf("%w: expired", ErrInvalidUpstreamLogin)
+	}
+	// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+	if upstreamError != "" {
+		return req, nil, fmt.Errorf("%w: %s", ErrInvalidUpstreamLogin, upstreamError)
+	}
+	provider, err := s.upstreamProvider(ctx, req.ProviderID)
+	if err != nil {
+		return req, nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.httpClient())
+	token, err := s.upstreamOAuthConfig(provider).Exchange(ctx, code, oauth2.VerifierOption(req.CodeVerifier))
+	if err != nil {
+		return req, nil, errors.Join(ErrInvalidUpstreamLogin, err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := s.verifyUpstreamIDToken(ctx, provider, rawIDToken, req.Nonce)
+	if err != nil {
+		return req, nil, err
+	}
+
+	user, err := s.upstreamUser(ctx, provider, req, idToken)
+	if err != nil {
+		return req, nil, err
+	}
+	return req, upstreamAuthentication(provider, user, idToken), nil
+}
+
+// verifyUpstreamIDToken verifies the ID token with the keys of the provider and validates its claims.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+func (s *AuthNUseCase) verifyUpstreamIDToken(ctx context.Context, provider *model.UpstreamProvider, rawIDToken, nonce string) (*upstreamIDToken, error) {
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%w: id_token is missing", ErrInvalidUpstreamLogin)
+	}
+	jwks := &model.JWKSet{}
+	if err := s.getUpstreamJSON(ctx, provider.JWKSURI, jwks); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]any{}
+	_, err := service.ParseJWT(rawIDToken, service.JWKSetKeyFunc(jwks, service.KeyUseSignature, service.AsymmetricAlgs...), &claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: id_token: %w", ErrInvalidUpstreamLogin, err)
+	}
+	idToken := &upstreamIDToken{claims: claims}
+	if err := service.ParseUnverifiedJWT(rawIDToken, idToken); err != nil {
+		return nil, fmt.Errorf("%w: id_token: %w", ErrInvalidUpstreamLogin, err)
+	}
+
+	if err := idToken.Validate(time.Now(), provider.Issuer, provider.ClientID); err != nil {
+		return nil, fmt.Errorf("%w: id_token: %w", ErrInvalidUpstreamLogin, err)
+	}
+	switch {
+	case idToken.Subject == "":
+		return nil, fmt.Errorf("%w: id_token: sub is missing", ErrInvalidUpstreamLogin)
+	case idToken.ExpiresAt == 0 || idToken.IssuedAt == 0:
+		return nil, fmt.Errorf("%w: id_token: exp and iat are required", ErrInvalidUpstreamLogin)
+	case (len(idToken.Audience) > 1 || idToken.AuthorizedParty != "") && idToken.AuthorizedParty != provider.ClientID:
+		return nil, fmt.Errorf("%w: id_token: azp is mismatched", ErrInvalidUpstreamLogin)
+	case idToken.Nonce != nonce:
+		// the ID token is replayed from another login.
+		return nil, fmt.Errorf("%w: id_token: nonce is mismatched", ErrInvalidUpstreamLogin)
+	}
+	return idToken, nil
+}
+
+// username returns the username of the local user mapped from the claims of the ID token.
+func (t *upstreamIDToken) username(provider *model.UpstreamProvider) (string, error) {
+	username, _ := t.claims[provider.GetUsernameClaim()].(string)
+	if username == "" {
+		return "", fmt.Errorf("%w: id_token: %s is missing", ErrInvalidUpstreamLogin, provider.GetUsernameClaim())
+	}
+	// ref: https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+	if verified, _ := t.claims["email_verified"].(bool); provider.RequiresVerifiedEmail() && !verified {
+		return "", fmt.Errorf("%w: id_token: email is not verified", ErrInvalidUpstreamLogin)
+	}
+	return username, nil
+}
+
+// upstreamUser returns the local user of the upstream account. The account which is not linked yet is linked to
+// the logged-in user for req.LinkSubject, the user of the same username if the provider allows, or the new user
+// provisioned just in time.
+func (s *AuthNUseCase) upstreamUser(ctx context.Context, provider *model.UpstreamProvider, req *model.UpstreamLoginRequest, idToken *upstreamIDToken) (*model.User, error) {
+	user, err := s.Users.GetUserByFederatedIdentity(ctx, provider.Issuer, idToken.Subject)
+	if err == nil {
+		if req.LinkSubject != "" && req.LinkSubject != user.ID {
+			return nil, ErrUpstreamIdentityLinked
+		}
+		return user, nil
+	}
+
+	if req.LinkSubject != "" {
+		user, err := s.Users.GetUser(ctx, req.LinkSubject)
+		if err != nil {
+			return nil, err
+		}
+		return user, s.linkUpstreamIdentity(ctx, user, provider, idToken.Subject)
+	}
+
+	username, err := idToken.username(provider)
+	if err != nil {
+		return nil, err
+	}
+	user, err = s.Users.GetUserByUsername(ctx, username)
+	if err == nil {
+		// the provider may assert the username of another local user, so the users link the accounts by themselves
+		// unless the usernames are managed by the provider.
+		if !provider.LinkExistingUsers {
+			return nil, ErrUpstreamAccountConflict
+		}
+		return user, s.linkUpstreamIdentity(ctx, user, provider, idToken.Subject)
+	}
+
+	if !provider.JITProvisioning {
+		return nil, ErrUpstreamUserNotProvisioned
+	}
+	// the provisioned user has no password, and logs in only with the upstream account until the user sets up a passkey.
+	user = &model.User{
+		ID:       uuid.NewString(),
+		Username: username,
+		FederatedIdentities: []*model.FederatedIdentity{
+			newFederatedIdentity(provider, idToken.Subject),
+		},
+	}
+	err = s.Users.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "user is provisioned", slog.String("provider", provider.ID), slog.String("subject", user.ID))
+	return user, nil
+}
+
+func (s *AuthNUseCase) linkUpstreamIdentity(ctx context.Context, user *model.User, provider *model.UpstreamProvider, subject string) error {
+	// a user has one account of each provider.
+	if slices.ContainsFunc(user.FederatedIdentities, func(v *model.FederatedIdentity) bool { return v.Issuer == provider.Issuer }) {
+		return ErrUpstreamIdentityLinked
+	}
+
+	user.FederatedIdentities = append(user.FederatedIdentities, newFederatedIdentity(provider, subject))
+	err := s.Users.UpdateUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "upstream account is linked", slog.String("provider", provider.ID), slog.String("subject", user.ID))
+	return nil
+}
+
+func newFederatedIdentity(provider *model.UpstreamProvider, subject string) *model.FederatedIdentity {
+	return &model.FederatedIdentity{
+		ProviderID: provider.ID,
+		Issuer:     provider.Issuer,
+		Subject:    subject,
+		LinkedAt:   time.Now(),
+	}
+}
+
+// upstreamAuthentication returns the authentication of the user, which is multi-factor only if the provider is trusted for it.
+// ref: https://datatracker.ietf.org/doc/html/rfc8176#section-2
+func upstreamAuthentication(provider *model.UpstreamProvider, user *model.User, idToken *upstreamIDToken) *model.Authentication {
+	authn := &model.Authentication{
+		Subject:  user.ID,
+		AuthTime: time.Now(),
+		ACR:      model.ACRBasic,
+		AMR:      slices.DeleteFunc(slices.Clone(idToken.AMR), func(v string) bool { return v == model.AMRMultiFactor }),
+	}
+	if idToken.AuthTime != 0 {
+		authn.AuthTime = time.Unix(idToken.AuthTime, 0)
+	}
+	if provider.TrustMultiFactor && (idToken.ACR == model.ACRMultiFactor || slices.Contains(idToken.AMR, model.AMRMultiFactor)) {
+		authn.ACR = model.ACRMultiFactor
+		authn.AMR = append(authn.AMR, model.AMRMultiFactor)
+	}
+	return authn
+}
+
+// upstreamProvider returns the provider of id, whose endpoints are discovered on its first use.
+func (s *AuthNUseCase) upstreamProvider(ctx context.Context, id string) (*model.UpstreamProvider, error) {
+	if s.UpstreamLogins == nil {
+		return nil, ErrUpstreamDisabled
+	}
+	i := slices.IndexFunc(s.UpstreamProviders, func(p *model.UpstreamProvider) bool { return p.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownUpstreamProvider, id)
+	}
+	provider := s.UpstreamProviders[i]
+
+	s.upstreamMu.Lock()
+	defer s.upstreamMu.Unlock()
+	if provider.AuthorizationEndpoint != "" && provider.TokenEndpoint != "" && provider.JWKSURI != "" {
+		return provider, nil
+	}
+
+	// ref: https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	discovery := &upstreamDiscovery{}
+	err := s.getUpstreamJSON(ctx, provider.Issuer+"/.well-known/openid-configuration", discovery)
+	if err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != provider.Issuer {
+		return nil, fmt.Errorf("%w: issuer of %s is mismatched", ErrInvalidUpstreamLogin, provider.ID)
+	}
+	if provider.AuthorizationEndpoint == "" {
+		provider.AuthorizationEndpoint = discovery.AuthorizationEndpoint
+	}
+	if provider.TokenEndpoint == "" {
+		provider.TokenEndpoint = discovery.TokenEndpoint
+	}
+	if provider.JWKSURI == "" {
+		provider.JWKSURI = discovery.JWKSURI
+	}
+	return provider, nil
+}
+
+func (s *AuthNUseCase) upstreamOAuthConfig(provider *model.UpstreamProvider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.AuthorizationEndpoint,
+			TokenURL: provider.TokenEndpoint,
+		},
+		RedirectURL: s.UpstreamRedirectURI,
+		Scopes:      provider.GetScopes(),
+	}
+}
+
+func (s *AuthNUseCase) getUpstreamJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s responded %d", ErrInvalidUpstreamLogin, url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (s *AuthNUseCase) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return s.HTTPClient
+}
@@ -0,0 +1,321 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+const (
+	testUpstreamProviderID = "corp"
+	testUpstreamClientID   = "upstream-client-id"
+	testUpstreamSecret     = "upstream-client-secret"
+	testUpstreamRedirect   = "http://localhost:9002/login/upstream/callback"
+)
+
+// newUpstreamSimulator serves the simulator in process, and fills provider with it.
+func newUpstreamSimulator(t *testing.T, provider *model.UpstreamProvider) *infra.UpstreamProviderSimulator {
+	t.Helper()
+
+	simulator, err := infra.NewUpstreamProviderSimulator(testUpstreamClientID, testUpstreamSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(simulator)
+	t.Cleanup(srv.Close)
+	simulator.Issuer = srv.URL
+
+	provider.ID = testUpstreamProviderID
+	provider.Issuer = srv.URL
+	provider.ClientID = testUpstreamClientID
+	provider.ClientSecret = testUpstreamSecret
+	return simulator
+}
+
+// withUpstreamProvider federates the use case with provider.
+func withUpstreamProvider(provider *model.UpstreamProvider) testAuthNOption {
+	return func(_ *testing.T, uc *AuthNUseCase) {
+		uc.UpstreamProviders = []*model.UpstreamProvider{provider}
+		uc.UpstreamLogins = infra.NewUpstreamLoginStorage()
+		uc.UpstreamRedirectURI = testUpstreamRedirect
+	}
+}
+
+// upstreamCallback starts the login, and returns the query of the callback to which the provider redirects the user.
+func upstreamCallback(t *testing.T, uc *AuthNUseCase, params model.UpstreamLoginParams) url.Values {
+	t.Helper()
+
+	authURL, err := uc.StartUpstreamLogin(context.Background(), testUpstreamProviderID, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Scheme + "://" + location.Host + location.Path; got != testUpstreamRedirect {
+		t.Fatalf("unexpected redirect: %s", location)
+	}
+	return location.Query()
+}
+
+func upstreamLogin(t *testing.T, uc *AuthNUseCase, params model.UpstreamLoginParams) (*model.UpstreamLoginRequest, *model.Authentication, error) {
+	t.Helper()
+
+	q := upstreamCallback(t, uc, params)
+	return uc.FinishUpstreamLogin(context.Background(), q.Get("state"), q.Get("code"), q.Get("error"))
+}
+
+func TestAuthNUseCaseUpstreamLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		provider model.UpstreamProvider
+		claims   map[string]any
+		modify   func(claims map[string]any)
+		// wantUsername is the user logged in, and wantACR and wantAMR are the authentication.
+		wantUsername string
+		wantACR      string
+		wantAMR      []string
+		wantErr      error
+	}{
+		"ok: provisioned just in time": {
+			provider:     model.UpstreamProvider{JITProvisioning: true},
+			claims:       map[string]any{"sub": "upstream-alice", "preferred_username": "alice", "amr": []string{"pwd"}},
+			wantUsername: "alice",
+			wantACR:      model.ACRBasic,
+			wantAMR:      []string{"pwd"},
+		},
+		"ok: linked to the existing user": {
+			provider:     model.UpstreamProvider{LinkExistingUsers: true},
+			claims:       map[string]any{"sub": "upstream-dummy", "preferred_username": "dummy-user"},
+			wantUsername: "dummy-user",
+			wantACR:      model.ACRBasic,
+		},
+		"ok: verified email": {
+			provider:     model.UpstreamProvider{UsernameClaim: "email", JITProvisioning: true},
+			claims:       map[string]any{"sub": "upstream-bob", "email": "bob@example.com", "email_verified": true},
+			wantUsername: "bob@example.com",
+			wantACR:      model.ACRBasic,
+		},
+		"ok: trusted multi-factor": {
+			provider:     model.UpstreamProvider{JITProvisioning: true, TrustMultiFactor: true},
+			claims:       map[string]any{"sub": "upstream-alice", "preferred_username": "alice", "amr": []string{"pwd", "otp", "mfa"}},
+			wantUsername: "alice",
+			wantACR:      model.ACRMultiFactor,
+			wantAMR:      []string{"pwd", "otp", "mfa"},
+		},
+		"ok: untrusted multi-factor": {
+			provider:     model.UpstreamProvider{JITProvisioning: true},
+			claims:       map[string]any{"sub": "upstream-alice", "preferred_username": "alice", "acr": "mfa", "amr": []string{"pwd", "otp", "mfa"}},
+			wantUsername: "alice",
+			wantACR:      model.ACRBasic,
+			wantAMR:      []string{"pwd", "otp"},
+		},
+		"ng: existing user is not linked": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-dummy", "preferred_username": "dummy-user"},
+			wantErr:  ErrUpstreamAccountConflict,
+		},
+		"ng: not provisioned": {
+			provider: model.UpstreamProvider{},
+			claims:   map[string]any{"sub": "upstream-alice", "preferred_username": "alice"},
+			wantErr:  ErrUpstreamUserNotProvisioned,
+		},
+		"ng: unverified email": {
+			provider: model.UpstreamProvider{UsernameClaim: "email", JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-bob", "email": "bob@example.com", "email_verified": false},
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: username claim is missing": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-alice"},
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: access denied": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: nonce is mismatched": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-alice", "preferred_username": "alice"},
+			modify:   func(claims map[string]any) { claims["nonce"] = "replayed" },
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: audience is mismatched": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-alice", "preferred_username": "alice"},
+			modify:   func(claims map[string]any) { claims["aud"] = "another-client" },
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: azp is mismatched": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-alice", "preferred_username": "alice"},
+			modify:   func(claims map[string]any) { claims["aud"] = []string{testUpstreamClientID, "another-client"} },
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: issuer is mismatched": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-alice", "preferred_username": "alice"},
+			modify:   func(claims map[string]any) { claims["iss"] = "https://evil.example.com" },
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+		"ng: expired": {
+			provider: model.UpstreamProvider{JITProvisioning: true},
+			claims:   map[string]any{"sub": "upstream-alice", "preferred_username": "alice"},
+			modify:   func(claims map[string]any) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+			wantErr:  ErrInvalidUpstreamLogin,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			provider := tt.provider
+			simulator := newUpstreamSimulator(t, &provider)
+			uc := newTestAuthNUseCase(t, withUpstreamProvider(&provider))
+			if tt.claims != nil {
+				simulator.SetClaims(tt.claims)
+			}
+			simulator.ModifyIDToken = tt.modify
+
+			params := model.UpstreamLoginParams{AuthReqID: "dummy-auth-req-id", ACRValues: "mfa"}
+			req, authn, err := upstreamLogin(t, uc, params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want %v, got %v", tt.wantErr, err)
+			}
+			if req == nil || req.UpstreamLoginParams != params {
+				t.Fatalf("params are not taken over: %+v", req)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			user, err := uc.Users.GetUser(context.Background(), authn.Subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Username != tt.wantUsername {
+				t.Errorf("unexpected username: want %s, got %s", tt.wantUsername, user.Username)
+			}
+			if _, ok := user.FederatedIdentity(provider.Issuer, tt.claims["sub"].(string)); !ok {
+				t.Errorf("upstream account is not linked: %+v", user.FederatedIdentities)
+			}
+			if authn.ACR != tt.wantACR || !slices.Equal(authn.AMR, tt.wantAMR) {
+				t.Errorf("unexpected authentication: want %s %v, got %s %v", tt.wantACR, tt.wantAMR, authn.ACR, authn.AMR)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseUpstreamLoginReplay(t *testing.T) {
+	t.Parallel()
+
+	provider := &model.UpstreamProvider{JITProvisioning: true}
+	simulator := newUpstreamSimulator(t, provider)
+	uc := newTestAuthNUseCase(t, withUpstreamProvider(provider))
+	simulator.SetClaims(map[string]any{"sub": "upstream-alice", "preferred_username": "alice"})
+
+	q := upstreamCallback(t, uc, model.UpstreamLoginParams{})
+	ctx := context.Background()
+	_, _, err := uc.FinishUpstreamLogin(ctx, q.Get("state"), q.Get("code"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = uc.FinishUpstreamLogin(ctx, q.Get("state"), q.Get("code"), "")
+	if !errors.Is(err, ErrInvalidUpstreamLogin) {
+		t.Fatalf("replayed state is accepted: %v", err)
+	}
+
+	// the second login finds the provisioned user by the linked account.
+	_, authn, err := upstreamLogin(t, uc, model.UpstreamLoginParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := uc.Users.GetUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authn.Subject != user.ID {
+		t.Errorf("unexpected subject: want %s, got %s", user.ID, authn.Subject)
+	}
+}
+
+func TestAuthNUseCaseUpstreamLink(t *testing.T) {
+	t.Parallel()
+
+	provider := &model.UpstreamProvider{JITProvisioning: true}
+	simulator := newUpstreamSimulator(t, provider)
+	uc := newTestAuthNUseCase(t, withUpstreamProvider(provider))
+	simulator.SetClaims(map[string]any{"sub": "upstream-dummy", "preferred_username": "dummy"})
+
+	// the logged-in user links the account whose username differs from the local one.
+	_, authn, err := upstreamLogin(t, uc, model.UpstreamLoginParams{LinkSubject: "dummy-user-id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authn.Subject != "dummy-user-id" {
+		t.Fatalf("unexpected subject: %s", authn.Subject)
+	}
+	_, authn, err = upstreamLogin(t, uc, model.UpstreamLoginParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authn.Subject != "dummy-user-id" {
+		t.Fatalf("linked account logged in to another user: %s", authn.Subject)
+	}
+
+	// another account of the provider cannot be linked to the user.
+	simulator.SetClaims(map[string]any{"sub": "upstream-another", "preferred_username": "another"})
+	_, _, err = upstreamLogin(t, uc, model.UpstreamLoginParams{LinkSubject: "dummy-user-id"})
+	if !errors.Is(err, ErrUpstreamIdentityLinked) {
+		t.Fatalf("unexpected error: want %v, got %v", ErrUpstreamIdentityLinked, err)
+	}
+}
+
+func TestAuthNUseCaseStartUpstreamLogin(t *testing.T) {
+	t.Parallel()
+
+	provider := &model.UpstreamProvider{}
+	newUpstreamSimulator(t, provider)
+	uc := newTestAuthNUseCase(t, withUpstreamProvider(provider))
+	authURL, err := uc.StartUpstreamLogin(context.Background(), testUpstreamProviderID, model.UpstreamLoginParams{MaxAge: "60"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	for _, name := range []string{"state", "nonce", "code_challenge"} {
+		if q.Get(name) == "" {
+			t.Errorf("%s is missing: %s", name, authURL)
+		}
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("scope") != "openid" || q.Get("max_age") != "60" {
+		t.Errorf("unexpected authorization request: %s", authURL)
+	}
+
+	_, err = uc.StartUpstreamLogin(context.Background(), "unknown", model.UpstreamLoginParams{})
+	if !errors.Is(err, ErrUnknownUpstreamProvider) {
+		t.Errorf("unexpected error: want %v, got %v", ErrUnknownUpstreamProvider, err)
+	}
+}
@@ -0,0 +1,300 @@
+package authentication
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+var (
+	ErrWebAuthnDisabled           = errors.New("webauthn is disabled")
+	ErrInvalidWebAuthn            = errors.New("webauthn response is invalid")
+	ErrWebAuthnCredentialExists   = errors.New("webauthn credential is already registered")
+	ErrWebAuthnSignCountRegressed = errors.New("sign count of the authenticator did not increase")
+)
+
+var b64 = base64.RawURLEncoding
+
+// WebAuthnRelyingParty is the authentication server as the relying party of the authenticators.
+// ref: https://www.w3.org/TR/webauthn-3/#relying-party
+type WebAuthnRelyingParty struct {
+	ID   string `json:"id"` // the domain of Origin
+	Name string `json:"name"`
+	// Origin is where the authenticators are used from.
+	Origin string `json:"-"`
+}
+
+// WebAuthnCreationOptions are passed to navigator.credentials.create() on the registration ceremony.
+// Binary values are base64url-encoded.
+// ref: https://www.w3.org/TR/webauthn-3/#dictdef-publickeycredentialcreationoptionsjson
+type WebAuthnCreationOptions struct {
+	RP                     WebAuthnRelyingParty           `json:"rp"`
+	User                   WebAuthnUserEntity             `json:"user"`
+	Challenge              string                         `json:"challenge"`
+	PubKeyCredParams       []WebAuthnCredentialParameters `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []WebAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection WebAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+}
+
+// WebAuthnRequestOptions are passed to navigator.credentials.get() on the authentication ceremony.
+// ref: https://www.w3.org/TR/webauthn-3/#dictdef-publickeycredentialrequestoptionsjson
+type WebAuthnRequestOptions struct {
+	Challenge        string `json:"challenge"`
+	Timeout          int64  `json:"timeout"`
+	RPID             string `json:"rpId"`
+	UserVerification string `json:"userVerification"`
+}
+
+type WebAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type WebAuthnCredentialParameters struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type WebAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type WebAuthnAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+const webAuthnCredentialType = "public-key"
+
+// BeginWebAuthnRegistration starts the registration of a passkey of the logged-in user.
+// The credentials are discoverable so that the user logs in without entering the username.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-registering-a-new-credential
+func (s *AuthNUseCase) BeginWebAuthnRegistration(ctx context.Context, subject string) (*model.WebAuthnCeremony, *WebAuthnCreationOptions, error) {
+	if s.WebAuthn == nil {
+		return nil, nil, ErrWebAuthnDisabled
+	}
+	user, err := s.Users.GetUser(ctx, subject)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ceremony, err := model.NewWebAuthnCeremony(model.WebAuthnCeremonyRegistration, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.WebAuthn.CreateWebAuthnCeremony(ctx, ceremony)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	params := make([]WebAuthnCredentialParameters, 0, len(service.WebAuthnAlgs))
+	for _, alg := range service.WebAuthnAlgs {
+		params = append(params, WebAuthnCredentialParameters{Type: webAuthnCredentialType, Alg: alg})
+	}
+	// the authenticators which already have a credential of the user do not create another one.
+	exclude := make([]WebAuthnCredentialDescriptor, 0, len(user.WebAuthnCredentials))
+	for _, c := range user.WebAuthnCredentials {
+		exclude = append(exclude, WebAuthnCredentialDescriptor{Type: webAuthnCredentialType, ID: b64.EncodeToString(c.ID)})
+	}
+
+	return ceremony, &WebAuthnCreationOptions{
+		RP: s.RelyingParty,
+		User: WebAuthnUserEntity{
+			ID:          b64.EncodeToString([]byte(user.ID)),
+			Name:        user.Username,
+			DisplayName: user.Username,
+		},
+		Challenge:          b64.EncodeToString(ceremony.Challenge),
+		PubKeyCredParams:   params,
+		Timeout:            model.WebAuthnCeremonyLifetime.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: WebAuthnAuthenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "preferred",
+		},
+		Attestation: "direct",
+	}, nil
+}
+
+// FinishWebAuthnRegistration verifies the attestation of the authenticator, and registers the credential to the user.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-registering-a-new-credential
+func (s *AuthNUseCase) FinishWebAuthnRegistration(ctx context.Context, subject, ceremonyID string, resp *model.WebAuthnAttestationResponse) (*model.WebAuthnCredential, error) {
+	ceremony, err := s.consumeWebAuthnCeremony(ctx, ceremonyID, model.WebAuthnCeremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID != subject {
+		return nil, ErrInvalidWebAuthn
+	}
+
+	clientDataHash, err := service.VerifyClientData(resp.ClientDataJSON, service.WebAuthnTypeCreate, ceremony.Challenge, s.RelyingParty.Origin)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	obj, err := service.ParseAttestationObject(resp.AttestationObject)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	authData, err := service.VerifyAttestation(obj, clientDataHash)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	if err := verifyAuthenticatorData(authData, s.RelyingParty.ID); err != nil {
+		return nil, err
+	}
+	if len(resp.CredentialID) != 0 && string(resp.CredentialID) != string(authData.CredentialID) {
+		return nil, ErrInvalidWebAuthn
+	}
+
+	user, err := s.Users.GetUser(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := user.WebAuthnCredential(authData.CredentialID); ok {
+		return nil, ErrWebAuthnCredentialExists
+	}
+
+	credential := &model.WebAuthnCredential{
+		ID:                authData.CredentialID,
+		PublicKey:         authData.PublicKey,
+		SignCount:         authData.SignCount,
+		AttestationFormat: obj.Format,
+		AAGUID:            authData.AAGUID,
+		CreatedAt:         time.Now(),
+	}
+	user.WebAuthnCredentials = append(user.WebAuthnCredentials, credential)
+	err = s.Users.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// WebAuthnEnabled reports whether the login page offers the passkey login.
+func (s *AuthNUseCase) WebAuthnEnabled() bool {
+	return s.WebAuthn != nil
+}
+
+// BeginWebAuthnLogin starts the authentication with a passkey. No credentials are allowed explicitly,
+// as the user is identified by the user handle of the discoverable credential.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-verifying-assertion
+func (s *AuthNUseCase) BeginWebAuthnLogin(ctx context.Context) (*model.WebAuthnCeremony, *WebAuthnRequestOptions, error) {
+	if s.WebAuthn == nil {
+		return nil, nil, ErrWebAuthnDisabled
+	}
+
+	ceremony, err := model.NewWebAuthnCeremony(model.WebAuthnCeremonyAuthentication, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	err = s.WebAuthn.CreateWebAuthnCeremony(ctx, ceremony)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ceremony, &WebAuthnRequestOptions{
+		Challenge:        b64.EncodeToString(ceremony.Challenge),
+		Timeout:          model.WebAuthnCeremonyLifetime.Milliseconds(),
+		RPID:             s.RelyingParty.ID,
+		UserVerification: "preferred",
+	}, nil
+}
+
+// FinishWebAuthnLogin verifies the assertion of the authenticator, and returns the authentication of the user.
+// The authentication is multi-factor if the authenticator has verified the user.
+// ref: https://www.w3.org/TR/webauthn-3/#sctn-verifying-assertion
+func (s *AuthNUseCase) FinishWebAuthnLogin(ctx context.Context, ceremonyID string, resp *model.WebAuthnAssertionResponse) (*model.Authentication, error) {
+	ceremony, err := s.consumeWebAuthnCeremony(ctx, ceremonyID, model.WebAuthnCeremonyAuthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.Users.GetUser(ctx, string(resp.UserHandle))
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	credential, ok := user.WebAuthnCredential(resp.CredentialID)
+	if !ok {
+		return nil, ErrInvalidWebAuthn
+	}
+
+	clientDataHash, err := service.VerifyClientData(resp.ClientDataJSON, service.WebAuthnTypeGet, ceremony.Challenge, s.RelyingParty.Origin)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	authData, err := service.ParseAuthenticatorData(resp.AuthenticatorData)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	if err := verifyAuthenticatorData(authData, s.RelyingParty.ID); err != nil {
+		return nil, err
+	}
+
+	publicKey, alg, err := service.ParseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	signed := append(append([]byte{}, resp.AuthenticatorData...), clientDataHash...)
+	err = service.VerifyWebAuthnSignature(publicKey, alg, signed, resp.Signature)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+
+	// the counter which does not increase means that the credential may be used by a cloned authenticator.
+	// Authenticators which do not implement the counter always return 0.
+	// ref: https://www.w3.org/TR/webauthn-3/#sctn-sign-counter
+	if (authData.SignCount != 0 || credential.SignCount != 0) && authData.SignCount <= credential.SignCount {
+		return nil, ErrWebAuthnSignCountRegressed
+	}
+	credential.SignCount = authData.SignCount
+	err = s.Users.UpdateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	authn := &model.Authentication{
+		Subject:  user.ID,
+		AuthTime: time.Now(),
+		ACR:      model.ACRBasic,
+		AMR:      []string{model.AMRHardwareKey, model.AMRUserPresence},
+	}
+	if authData.HasFlag(service.WebAuthnFlagUserVerified) {
+		// the possession of the key and the user verification on the authenticator are the two factors.
+		authn.ACR = model.ACRMultiFactor
+		authn.AMR = append(authn.AMR, model.AMRMultiFactor)
+	}
+	return authn, nil
+}
+
+func (s *AuthNUseCase) consumeWebAuthnCeremony(ctx context.Context, id string, typ model.WebAuthnCeremonyType) (*model.WebAuthnCeremony, error) {
+	if s.WebAuthn == nil {
+		return nil, ErrWebAuthnDisabled
+	}
+	ceremony, err := s.WebAuthn.ConsumeWebAuthnCeremony(ctx, id)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidWebAuthn, err)
+	}
+	if ceremony.Type != typ || ceremony.IsExpired() {
+		return nil, ErrInvalidWebAuthn
+	}
+	return ceremony, nil
+}
+
+// verifyAuthenticatorData checks that the credential is scoped to the relying party and the user is present.
+func verifyAuthenticatorData(authData *service.AuthenticatorData, rpID string) error {
+	if err := authData.VerifyRPID(rpID); err != nil {
+		return errors.Join(ErrInvalidWebAuthn, err)
+	}
+	if !authData.HasFlag(service.WebAuthnFlagUserPresent) {
+		return ErrInvalidWebAuthn
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:9002"
+)
+
+// withWebAuthn lets the users log in with the passkeys at the relying party of testOrigin.
+func withWebAuthn() testAuthNOption {
+	return func(_ *testing.T, uc *AuthNUseCase) {
+		uc.WebAuthn = infra.NewWebAuthnStorage()
+		uc.RelyingParty = WebAuthnRelyingParty{ID: testRPID, Name: "oauth-go", Origin: testOrigin}
+	}
+}
+
+// registerPasskey registers the credential of the authenticator to the dummy user.
+func registerPasskey(t *testing.T, uc *AuthNUseCase, authenticator *infra.SoftwareAuthenticator) *model.WebAuthnCredential {
+	t.Helper()
+
+	ctx := context.Background()
+	ceremony, options, err := uc.BeginWebAuthnRegistration(ctx, "dummy-user-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := authenticator.Create(ceremony.Challenge, []byte("dummy-user-id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := uc.FinishWebAuthnRegistration(ctx, "dummy-user-id", ceremony.ID, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.RP.ID != testRPID || options.User.Name != "dummy-user" {
+		t.Fatalf("unexpected options: %+v", options)
+	}
+	return credential
+}
+
+func TestAuthNUseCaseWebAuthnRegistration(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		format  string
+		modify  func(uc *AuthNUseCase, ceremony *model.WebAuthnCeremony, resp *model.WebAuthnAttestationResponse) string
+		subject string
+		wantErr error
+	}{
+		"ok: none": {
+			format: service.AttestationFormatNone,
+		},
+		"ok: packed": {
+			format: service.AttestationFormatPacked,
+		},
+		"ng: other user": {
+			format:  service.AttestationFormatNone,
+			subject: "other-user-id",
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: unknown ceremony": {
+			format: service.AttestationFormatNone,
+			modify: func(uc *AuthNUseCase, ceremony *model.WebAuthnCeremony, resp *model.WebAuthnAttestationResponse) string {
+				return "unknown"
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: authentication ceremony": {
+			format: service.AttestationFormatNone,
+			modify: func(uc *AuthNUseCase, ceremony *model.WebAuthnCeremony, resp *model.WebAuthnAttestationResponse) string {
+				login, _, err := uc.BeginWebAuthnLogin(context.Background())
+				if err != nil {
+					panic(err)
+				}
+				return login.ID
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: other credential ID": {
+			format: service.AttestationFormatNone,
+			modify: func(uc *AuthNUseCase, ceremony *model.WebAuthnCeremony, resp *model.WebAuthnAttestationResponse) string {
+				resp.CredentialID = []byte("other")
+				return ceremony.ID
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: tampered attestation": {
+			format: service.AttestationFormatPacked,
+			modify: func(uc *AuthNUseCase, ceremony *model.WebAuthnCeremony, resp *model.WebAuthnAttestationResponse) string {
+				resp.AttestationObject[len(resp.AttestationObject)-1] ^= 0xff
+				return ceremony.ID
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthNUseCase(t, withWebAuthn())
+			authenticator := infra.NewSoftwareAuthenticator(testRPID, testOrigin, tt.format)
+
+			ceremony, _, err := uc.BeginWebAuthnRegistration(ctx, "dummy-user-id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := authenticator.Create(ceremony.Challenge, []byte("dummy-user-id"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ceremonyID := ceremony.ID
+			if tt.modify != nil {
+				ceremonyID = tt.modify(uc, ceremony, resp)
+			}
+			subject := "dummy-user-id"
+			if tt.subject != "" {
+				subject = tt.subject
+			}
+
+			got, err := uc.FinishWebAuthnRegistration(ctx, subject, ceremonyID, resp)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.AttestationFormat != tt.format || string(got.ID) != string(resp.CredentialID) {
+				t.Errorf("unexpected credential: %+v", got)
+			}
+			user, err := uc.Users.GetUser(ctx, "dummy-user-id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := user.WebAuthnCredential(got.ID); !ok {
+				t.Errorf("credential is not registered: %+v", user.WebAuthnCredentials)
+			}
+
+			// the challenge is not accepted twice.
+			_, err = uc.FinishWebAuthnRegistration(ctx, subject, ceremonyID, resp)
+			if !errors.Is(err, ErrInvalidWebAuthn) {
+				t.Errorf("want %v, got %v", ErrInvalidWebAuthn, err)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseWebAuthnLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		userVerification bool
+		modify           func(resp *model.WebAuthnAssertionResponse)
+		origin           string
+		wantACR          string
+		wantAMR          []string
+		wantErr          error
+	}{
+		"ok: user presence": {
+			wantACR: model.ACRBasic,
+			wantAMR: []string{model.AMRHardwareKey, model.AMRUserPresence},
+		},
+		"ok: user verification": {
+			userVerification: true,
+			wantACR:          model.ACRMultiFactor,
+			wantAMR:          []string{model.AMRHardwareKey, model.AMRUserPresence, model.AMRMultiFactor},
+		},
+		"ng: wrong origin": {
+			origin:  "http://localhost:9000",
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: unknown user": {
+			modify: func(resp *model.WebAuthnAssertionResponse) {
+				resp.UserHandle = []byte("other-user-id")
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: unknown credential": {
+			modify: func(resp *model.WebAuthnAssertionResponse) {
+				resp.CredentialID = []byte("other")
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: invalid signature": {
+			modify: func(resp *model.WebAuthnAssertionResponse) {
+				resp.Signature[len(resp.Signature)-1] ^= 0xff
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+		"ng: user not present": {
+			modify: func(resp *model.WebAuthnAssertionResponse) {
+				resp.AuthenticatorData[32] &^= service.WebAuthnFlagUserPresent
+			},
+			wantErr: ErrInvalidWebAuthn,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthNUseCase(t, withWebAuthn())
+			authenticator := infra.NewSoftwareAuthenticator(testRPID, testOrigin, service.AttestationFormatNone)
+			authenticator.UserVerification = tt.userVerification
+			credential := registerPasskey(t, uc, authenticator)
+			if tt.origin != "" {
+				authenticator.Origin = tt.origin
+			}
+
+			ceremony, options, err := uc.BeginWebAuthnLogin(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if options.RPID != testRPID {
+				t.Fatalf("unexpected options: %+v", options)
+			}
+			resp, err := authenticator.Get(ceremony.Challenge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.modify != nil {
+				tt.modify(resp)
+			}
+
+			got, err := uc.FinishWebAuthnLogin(ctx, ceremony.ID, resp)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Subject != "dummy-user-id" || got.ACR != tt.wantACR || !slices.Equal(got.AMR, tt.wantAMR) {
+				t.Errorf("unexpected authentication: %+v", got)
+			}
+			user, err := uc.Users.GetUser(ctx, "dummy-user-id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored, ok := user.WebAuthnCredential(credential.ID); !ok || stored.SignCount != 1 {
+				t.Errorf("sign count is not updated: %+v", stored)
+			}
+
+			// the challenge is not accepted twice.
+			_, err = uc.FinishWebAuthnLogin(ctx, ceremony.ID, resp)
+			if !errors.Is(err, ErrInvalidWebAuthn) {
+				t.Errorf("want %v, got %v", ErrInvalidWebAuthn, err)
+			}
+		})
+	}
+}
+
+func TestAuthNUseCaseWebAuthnSignCount(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	uc := newTestAuthNUseCase(t, withWebAuthn())
+	authenticator := infra.NewSoftwareAuthenticator(testRPID, testOrigin, service.AttestationFormatPacked)
+	registerPasskey(t, uc, authenticator)
+	clone := authenticator.Clone()
+
+	login := func(a *infra.SoftwareAuthenticator) error {
+		ceremony, _, err := uc.BeginWebAuthnLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := a.Get(ceremony.Challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = uc.FinishWebAuthnLogin(ctx, ceremony.ID, resp)
+		return err
+	}
+
+	// the original authenticator logs in twice, and the clone is behind it.
+	for range 2 {
+		if err := login(authenticator); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := login(clone); !errors.Is(err, ErrWebAuthnSignCountRegressed) {
+		t.Fatalf("want %v, got %v", ErrWebAuthnSignCountRegressed, err)
+	}
+	if err := login(clone); !errors.Is(err, ErrWebAuthnSignCountRegressed) {
+		t.Fatalf("want %v, got %v", ErrWebAuthnSignCountRegressed, err)
+	}
+	if err := login(authenticator); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthNUseCaseWebAuthnSecondFactor(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	uc := newTestAuthNUseCase(t, withWebAuthn())
+	authenticator := infra.NewSoftwareAuthenticator(testRPID, testOrigin, service.AttestationFormatNone)
+	registerPasskey(t, uc, authenticator)
+
+	login := func() *model.Authentication {
+		ceremony, _, err := uc.BeginWebAuthnLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := authenticator.Get(ceremony.Challenge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authn, err := uc.FinishWebAuthnLogin(ctx, ceremony.ID, resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return authn
+	}
+
+	// the passkey without the user verification is the first factor, and the user with TOTP proves it too.
+	authn := login()
+	enrollTOTP(t, uc, authn)
+	factor, err := uc.SecondFactor(ctx, login(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if factor != SecondFactorVerify {
+		t.Errorf("want %v, got %v", SecondFactorVerify, factor)
+	}
+
+	authenticator.UserVerification = true
+	factor, err = uc.SecondFactor(ctx, login(), []string{model.ACRMultiFactor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if factor != SecondFactorNone {
+		t.Errorf("want %v, got %v", SecondFactorNone, factor)
+	}
+}
@@ -2,34 +2,102 @@ package authorization
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"time"
 
 	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
 	"github.com/task4233/oauth/pkg/repository"
 )
 
 type AuthUseCase struct {
-	Storage repository.Storage
-	Hasher  repository.Hasher
+	Issuer      string
+	Storage     repository.Storage
+	Hasher      repository.Hasher
+	LoginTokens *service.LoginTokenCodec
+
+	// the lifetimes of the issued tokens, which are the defaults unless they are changed after NewAuthUseCase.
+	AccessTokenLifetime  time.Duration
+	RefreshTokenLifetime time.Duration
+	IDTokenLifetime      time.Duration
+
+	// RequirePushedAuthRequests rejects authorization requests of every client unless they are pushed.
+	// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-6
+	RequirePushedAuthRequests bool
+
+	// SigningKey signs the JWTs issued by the authorization server, e.g. JWT-secured authorization responses.
+	SigningKey *service.PrivateKey
+
+	// SigningKeys rotates the keys which sign the JWTs, which are used instead of SigningKey if it is set.
+	SigningKeys *service.KeyManager
+
+	// EncryptionKey decrypts the JWTs which clients encrypt for the authorization server.
+	// Encrypted request objects are rejected if it is nil.
+	EncryptionKey *service.PrivateKey
+
+	// EncryptionKeys rotates the keys which decrypt the JWTs, which are used instead of EncryptionKey if it is set.
+	EncryptionKeys *service.KeyManager
+
+	// TokenExchangePolicy decides which clients may exchange which tokens.
+	// Token exchange is rejected if it is nil.
+	TokenExchangePolicy TokenExchangePolicy
+
+	// Users resolves the users of login_hint.
+	Users repository.UserStorage
+
+	// DeviceNotifier asks users to approve backchannel authentication requests.
+	// Backchannel authentication is rejected if it is nil.
+	DeviceNotifier AuthenticationDeviceNotifier
+
+	// Lockout throttles guessing the secrets of clients. Client authentication is not throttled if it is nil.
+	Lockout *service.Lockout
+
+	// HTTPClient fetches request objects from request_uri and notifies clients of backchannel authentication results and logouts.
+	// http.DefaultClient is used if it is nil.
+	HTTPClient *http.Client
+
+	// logoutRetryInterval is the base interval of the retries of the back-channel logout, which is shortened in tests.
+	logoutRetryInterval time.Duration
 }
 
-func NewAuthUseCase(storage repository.Storage, hasher repository.Hasher) *AuthUseCase {
-	if hasher == nil {
+func NewAuthUseCase(issuer string, storage repository.Storage, hasher repository.Hasher, loginTokens *service.LoginTokenCodec) *AuthUseCase {
+	if hasher == nil || loginTokens == nil {
 		return nil
 	}
 	return &AuthUseCase{
-		Storage: storage,
-		Hasher:  hasher,
+		Issuer:      issuer,
+		Storage:     storage,
+		Hasher:      hasher,
+		LoginTokens: loginTokens,
+
+		AccessTokenLifetime:  model.DefaultAccessTokenLifetime,
+		RefreshTokenLifetime: model.DefaultRefreshTokenLifetime,
+		IDTokenLifetime:      DefaultIDTokenLifetime,
+
+		logoutRetryInterval: backchannelLogoutRetryInterval,
 	}
 }
 
+func (s *AuthUseCase) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return s.HTTPClient
+}
+
 func (s *AuthUseCase) AuthorizeBeforeLogin(ctx context.Context, req *model.AuthRequest) (*model.AuthRequest, model.Client, error) {
-	authReq, err := s.Storage.CreateAuthorizationRequest(ctx, req)
+	client, err := s.ValidateRedirectURI(ctx, req.ClientID, req.RedirectURI)
 	if err != nil {
 		return nil, nil, err
 	}
+	if s.RequirePushedAuthRequests || client.RequiresPushedAuthRequest() {
+		return nil, nil, fmt.Errorf("%w: authorization request must be pushed", ErrInvalidRequest)
+	}
 
-	client, err := s.Storage.GetClient(ctx, req.ClientID)
+	authReq, err := s.startAuthorization(ctx, req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -37,17 +105,101 @@ func (s *AuthUseCase) AuthorizeBeforeLogin(ctx context.Context, req *model.AuthR
 	return authReq, client, nil
 }
 
-func (s *AuthUseCase) AuthorizeAfterLogin(ctx context.Context, req *model.AuthRequest) (*model.AuthRequest, model.Client, error) {
+// startAuthorization validates the authorization request and stores it until the user logs in.
+func (s *AuthUseCase) startAuthorization(ctx context.Context, req *model.AuthRequest) (*model.AuthRequest, error) {
+	if err := s.validateAuthorizationRequest(ctx, req); err != nil {
+		return nil, err
+	}
+
+	return s.Storage.CreateAuthorizationRequest(ctx, req)
+}
+
+// ValidateRedirectURI returns the client of the authorization request if the redirect_uri is registered by it.
+// The errors of the request are sent to the redirect_uri only after that, otherwise it is an open redirector.
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+func (s *AuthUseCase) ValidateRedirectURI(ctx context.Context, clientID, redirectURI string) (model.Client, error) {
+	client, err := s.Storage.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: client_id is unknown: %w", ErrInvalidRedirectURI, err)
+	}
+	if !client.IsValidRedirectURI(redirectURI) {
+		return nil, fmt.Errorf("%w: redirect_uri is not registered", ErrInvalidRedirectURI)
+	}
+	return client, nil
+}
+
+func (s *AuthUseCase) validateAuthorizationRequest(ctx context.Context, req *model.AuthRequest) error {
+	if _, err := s.ValidateRedirectURI(ctx, req.ClientID, req.RedirectURI); err != nil {
+		return err
+	}
+	if _, err := s.getProtectedResources(ctx, req.Resources); err != nil {
+		return err
+	}
+	return s.validateAuthorizationDetails(ctx, req.AuthorizationDetails)
+}
+
+// AuthorizeAfterLogin records the user authentication on the authorization request.
+// The request is completed by Consent.
+func (s *AuthUseCase) AuthorizeAfterLogin(ctx context.Context, req *model.AuthRequest, loginToken string) (*model.AuthRequest, model.Client, error) {
 	authReq, err := s.Storage.GetAuthorizationRequest(ctx, req.ID)
 	if err != nil {
 		return nil, nil, err
 	}
+	if authReq.Authentication != nil {
+		return nil, nil, fmt.Errorf("authorization request is already authenticated")
+	}
+
+	authn, err := s.LoginTokens.Decode(loginToken, authReq.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+	if authReq.MaxAge != nil && time.Since(authn.AuthTime) > time.Duration(*authReq.MaxAge)*time.Second {
+		return nil, nil, ErrLoginRequired
+	}
+	// the client asks for acr_values to step up the authentication, e.g. for a resource which requires it.
+	// ref: https://datatracker.ietf.org/doc/html/rfc9470#section-4
+	if !authn.SatisfiesACR(authReq.ACRValues) {
+		return nil, nil, fmt.Errorf("%w: acr %q does not satisfy acr_values", ErrAccessDenied, authn.ACR)
+	}
+	authReq.Authentication = authn
+
+	err = s.Storage.UpdateAuthorizationRequest(ctx, authReq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := s.Storage.GetClient(ctx, authReq.ClientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return authReq, client, nil
+}
+
+// Consent issues an authorization code if the user approves the authenticated authorization request.
+func (s *AuthUseCase) Consent(ctx context.Context, authReqID string, approved bool) (*model.AuthRequest, model.Client, error) {
+	authReq, err := s.Storage.GetAuthorizationRequest(ctx, authReqID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authReq.Authentication == nil || authReq.Code != "" || !authReq.DisabledAt.IsZero() {
+		return nil, nil, fmt.Errorf("authorization request is not waiting for consent")
+	}
 
 	client, err := s.Storage.GetClient(ctx, authReq.ClientID)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if !approved {
+		err = s.Storage.DisableAuthorizationRequest(ctx, authReq.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return authReq, client, ErrAccessDenied
+	}
+
 	authReq, err = s.Storage.GenerateAuthorizationCode(ctx, authReq)
 	if err != nil {
 		return nil, nil, err
@@ -57,17 +209,87 @@ func (s *AuthUseCase) AuthorizeAfterLogin(ctx context.Context, req *model.AuthRe
 }
 
 func (s *AuthUseCase) Token(ctx context.Context, req *model.TokenRequest) (*model.AccessToken, error) {
+	switch req.GrantType {
+	case model.GrantTypeAuthorizationCode:
+		return s.tokenByAuthorizationCode(ctx, req)
+	case model.GrantTypeRefreshToken:
+		return s.tokenByRefreshToken(ctx, req)
+	case model.GrantTypeTokenExchange:
+		return s.tokenByTokenExchange(ctx, req)
+	case model.GrantTypeJWTBearer:
+		return s.tokenByJWTBearer(ctx, req)
+	case model.GrantTypeCIBA:
+		return s.tokenByCIBA(ctx, req)
+	default:
+		return nil, ErrUnsupportedGrantType
+	}
+}
+
+func (s *AuthUseCase) tokenByAuthorizationCode(ctx context.Context, req *model.TokenRequest) (*model.AccessToken, error) {
 	authReq, _, err := s.ValidateTokenRequest(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
-	err = s.Storage.DisableAuthorizationRequest(ctx, authReq.ID)
+	grant := authReq.Grant()
+	accessToken, err := s.issueAccessToken(ctx, grant, req)
+	if err != nil {
+		return nil, err
+	}
+	err = s.issueIDToken(ctx, grant, accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := model.NewRefreshToken(grant, s.RefreshTokenLifetime)
+	err = s.Storage.CreateRefreshToken(ctx, refreshToken)
 	if err != nil {
 		return nil, err
 	}
+	accessToken.RefreshToken = refreshToken.RefreshToken
+
+	return accessToken, nil
+}
+
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-6
+func (s *AuthUseCase) tokenByRefreshToken(ctx context.Context, req *model.TokenRequest) (*model.AccessToken, error) {
+	client, err := s.Storage.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, req.ClientSecret)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
 
-	accessToken := model.NewAccessToken(authReq)
+	refreshToken, err := s.Storage.GetRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidGrant, err)
+	}
+	if refreshToken.Grant.ClientID != client.GetID() {
+		return nil, fmt.Errorf("%w: client_id is mismatched", ErrInvalidGrant)
+	}
+	if refreshToken.ExpiresAt <= time.Now().Unix() {
+		return nil, fmt.Errorf("%w: refresh token is expired", ErrInvalidGrant)
+	}
+
+	accessToken, err := s.issueAccessToken(ctx, refreshToken.Grant, req)
+	if err != nil {
+		return nil, err
+	}
+	accessToken.RefreshToken = refreshToken.RefreshToken
+
+	return accessToken, nil
+}
+
+// issueAccessToken issues a token for grant, down-scoped to the resources and the scope of req.
+func (s *AuthUseCase) issueAccessToken(ctx context.Context, grant *model.Grant, req *model.TokenRequest) (*model.AccessToken, error) {
+	grant, err := s.downScope(ctx, grant, req)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken := model.NewAccessToken(grant, s.AccessTokenLifetime)
 	err = s.Storage.CreateAccessToken(ctx, accessToken)
 	if err != nil {
 		return nil, err
@@ -76,49 +298,63 @@ func (s *AuthUseCase) Token(ctx context.Context, req *model.TokenRequest) (*mode
 	return accessToken, nil
 }
 
+// ValidateTokenRequest redeems the authorization code for the authenticated client, which is disabled even if the request is invalid.
 func (s *AuthUseCase) ValidateTokenRequest(ctx context.Context, req *model.TokenRequest) (*model.AuthRequest, model.Client, error) {
 	client, err := s.Storage.GetClient(ctx, req.ClientID)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, errors.Join(ErrInvalidClient, err)
 	}
 
-	err = s.AuthenteClient(ctx, client, req)
+	err = s.AuthenteClient(ctx, client, req.ClientSecret)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, errors.Join(ErrInvalidClient, err)
 	}
 
-	authReq, err := s.Storage.GetAuthorizationRequestByCode(ctx, req.Code)
+	// the code is disabled before the other checks, so that the concurrent requests with it do not get tokens twice.
+	authReq, err := s.Storage.ConsumeAuthorizationCode(ctx, req.Code)
 	if err != nil {
-		return nil, nil, fmt.Errorf("by code: %w", err)
+		return nil, nil, fmt.Errorf("%w: code is unknown or already used: %w", ErrInvalidGrant, err)
 	}
 
 	if client.GetID() != authReq.ClientID {
-		return nil, nil, fmt.Errorf("client_id is mismatched")
+		return nil, nil, fmt.Errorf("%w: client_id is mismatched", ErrInvalidGrant)
 	}
 
 	if !client.IsValidRedirectURI(authReq.RedirectURI) {
-		return nil, nil, fmt.Errorf("redirect_uri is mismatched")
+		return nil, nil, fmt.Errorf("%w: redirect_uri is mismatched", ErrInvalidGrant)
 	}
 
 	return authReq, client, nil
 }
 
-func (s *AuthUseCase) AuthenteClient(ctx context.Context, client model.Client, req *model.TokenRequest) error {
+// AuthenteClient authenticates the confidential client with the secret.
+// The failures are counted by the client and the address, and *service.LockedOutError is returned while they are throttled.
+// The authenticated client is rate-limited, and *service.RateLimitedError is returned over the limit of the endpoint.
+// The public clients, which have no secret, are rate-limited by their client_id.
+func (s *AuthUseCase) AuthenteClient(ctx context.Context, client model.Client, clientSecret string) error {
 	if client.IsPublic() {
-		return nil
+		return service.AllowClient(ctx, client.GetID())
 	}
 
 	switch client.GetAuthMethod() {
 	case model.AuthMethodBasic:
-		ok, err := s.getHasher().Compare(ctx, []byte(client.GetSecret()), []byte(req.ClientSecret))
+		keys := service.FailureKeys(ctx, model.FailureKindClient, client.GetID())
+		if err := s.Lockout.Check(ctx, keys...); err != nil {
+			return err
+		}
+
+		ok, err := s.getHasher().Compare(ctx, []byte(client.GetSecret()), []byte(clientSecret))
 		if err != nil {
 			return err
 		}
 		if ok {
-			return nil
+			if err := s.Lockout.Succeed(ctx, keys[0]); err != nil {
+				return err
+			}
+			return service.AllowClient(ctx, client.GetID())
 		}
 
-		return fmt.Errorf("client_secret is invalid")
+		return errors.Join(fmt.Errorf("client_secret is invalid"), s.Lockout.Fail(ctx, keys...))
 	default:
 		return fmt.Errorf("unsupported auth method: %v", client.GetAuthMethod())
 	}
@@ -128,26 +364,122 @@ func (s *AuthUseCase) getHasher() repository.Hasher {
 	return s.Hasher
 }
 
-func (s *AuthUseCase) Introspect(ctx context.Context, token string, hint model.TokenType) (*model.Introspect, error) {
-	switch hint {
-	case model.TokenTypeAccessToken:
-		return s.introspectAccessToken(ctx, token)
+// Introspect reports whether the token is active for the caller.
+// A protected resource only sees tokens issued for it, and a client only sees its own tokens.
+func (s *AuthUseCase) Introspect(ctx context.Context, req *model.IntrospectRequest) (*model.Introspect, error) {
+	audience, clientID, err := s.authenticateIntrospectionCaller(ctx, req.CallerID, req.CallerSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	var introspect *model.Introspect
+	switch req.TokenTypeHint {
+	case model.TokenTypeAccessToken, "":
+		introspect = s.introspectAccessToken(ctx, req.Token)
+		if !introspect.Active {
+			introspect = s.introspectRefreshToken(ctx, req.Token)
+		}
+	case model.TokenTypeRefreshToken:
+		introspect = s.introspectRefreshToken(ctx, req.Token)
+		if !introspect.Active {
+			introspect = s.introspectAccessToken(ctx, req.Token)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported token type")
 	}
+
+	// the token without audience is not issued for any resource, so that it is not active for all of them.
+	if audience != "" && !slices.Contains(introspect.Aud, audience) {
+		return &model.Introspect{Active: false}, nil
+	}
+	if clientID != "" && introspect.ClientID != clientID {
+		return &model.Introspect{Active: false}, nil
+	}
+	if introspect.Active {
+		// the resource server can tell the tokens of this server from those of the others.
+		introspect.Iss = s.Issuer
+	}
+	return introspect, nil
+}
+
+// authenticateIntrospectionCaller returns either the resource indicator of the protected resource
+// or the ID of the client which calls the introspection endpoint.
+// ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.1
+func (s *AuthUseCase) authenticateIntrospectionCaller(ctx context.Context, id, secret string) (string, string, error) {
+	if resource, err := s.Storage.GetProtectedResourceByID(ctx, id); err == nil {
+		ok, err := s.getHasher().Compare(ctx, []byte(resource.SecretHash), []byte(secret))
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			return "", "", ErrInvalidClient
+		}
+		if err := service.AllowClient(ctx, resource.ID); err != nil {
+			return "", "", errors.Join(ErrInvalidClient, err)
+		}
+		return resource.URI, "", nil
+	}
+
+	client, err := s.Storage.GetClient(ctx, id)
+	if err != nil {
+		return "", "", errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, secret)
+	if err != nil {
+		return "", "", errors.Join(ErrInvalidClient, err)
+	}
+	return "", client.GetID(), nil
 }
 
-func (s *AuthUseCase) introspectAccessToken(ctx context.Context, token string) (*model.Introspect, error) {
+func (s *AuthUseCase) introspectAccessToken(ctx context.Context, token string) *model.Introspect {
 	accessToken, err := s.Storage.GetAccessToken(ctx, token)
 	if err != nil {
-		return nil, err
+		// ref: https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+		// unknown tokens are reported as inactive so that resource servers can cache the result.
+		return &model.Introspect{Active: false}
+	}
+	if accessToken.ExpiresAt <= time.Now().Unix() {
+		return &model.Introspect{Active: false}
 	}
 
 	return &model.Introspect{
 		Active:    true,
 		Scope:     accessToken.Scope,
-		ClientID:  "", // TODO: consider how to get client_id
+		ClientID:  accessToken.ClientID,
 		TokenType: model.TokenTypeAccessToken,
-		Exp:       accessToken.ExpiresIn,
-	}, nil
+		Exp:       accessToken.ExpiresAt,
+		Iat:       accessToken.IssuedAt,
+		Sub:       accessToken.Subject,
+		Aud:       accessToken.Audience,
+		AuthTime:  accessToken.AuthTime,
+		ACR:       accessToken.ACR,
+		AMR:       accessToken.AMR,
+
+		AuthorizationDetails: accessToken.AuthorizationDetails,
+		Act:                  accessToken.Actor,
+	}
+}
+
+func (s *AuthUseCase) introspectRefreshToken(ctx context.Context, token string) *model.Introspect {
+	refreshToken, err := s.Storage.GetRefreshToken(ctx, token)
+	if err != nil {
+		return &model.Introspect{Active: false}
+	}
+	if refreshToken.ExpiresAt <= time.Now().Unix() {
+		return &model.Introspect{Active: false}
+	}
+
+	grant := refreshToken.Grant
+	introspect := &model.Introspect{
+		Active:    true,
+		Scope:     grant.Scope,
+		ClientID:  grant.ClientID,
+		TokenType: model.TokenTypeRefreshToken,
+		Exp:       refreshToken.ExpiresAt,
+		Act:       grant.Actor,
+	}
+	if grant.Authentication != nil {
+		introspect.Sub = grant.Authentication.Subject
+	}
+	return introspect
 }
@@ -0,0 +1,45 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// validateAuthorizationDetails checks every detail against its registered type.
+// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-5
+func (s *AuthUseCase) validateAuthorizationDetails(ctx context.Context, details []model.AuthorizationDetail) error {
+	for i, detail := range details {
+		if detail.Type() == "" {
+			return fmt.Errorf("%w: authorization_details[%d].type is required", ErrInvalidAuthorizationDetails, i)
+		}
+
+		typ, err := s.Storage.GetAuthorizationDetailType(ctx, detail.Type())
+		if err != nil {
+			return fmt.Errorf("%w: type %q is unknown", ErrInvalidAuthorizationDetails, detail.Type())
+		}
+		if err := typ.Validate(detail); err != nil {
+			return fmt.Errorf("%w: authorization_details[%d]: %w", ErrInvalidAuthorizationDetails, i, err)
+		}
+	}
+	return nil
+}
+
+// narrowAuthorizationDetails returns the details requested at the token endpoint, each of which
+// must have been granted. All the granted details are returned when none are requested.
+// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-6.1
+func narrowAuthorizationDetails(granted, requested []model.AuthorizationDetail) ([]model.AuthorizationDetail, error) {
+	if len(requested) == 0 {
+		return granted, nil
+	}
+
+	for i, detail := range requested {
+		if !slices.ContainsFunc(granted, func(g model.AuthorizationDetail) bool { return reflect.DeepEqual(g, detail) }) {
+			return nil, fmt.Errorf("%w: authorization_details[%d] is not granted", ErrInvalidAuthorizationDetails, i)
+		}
+	}
+	return requested, nil
+}
@@ -0,0 +1,113 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+func paymentInitiation(amount string) model.AuthorizationDetail {
+	return model.AuthorizationDetail{
+		"type":    "payment_initiation",
+		"actions": []any{"initiate", "status"},
+		"instructedAmount": map[string]any{
+			"currency": "EUR",
+			"amount":   amount,
+		},
+		"creditorName":    "Merchant A",
+		"creditorAccount": map[string]any{"iban": "DE02100100109307118603"},
+	}
+}
+
+func TestAuthUseCaseValidateAuthorizationDetails(t *testing.T) {
+	t.Parallel()
+
+	invalidAction := paymentInitiation("123.50")
+	invalidAction["actions"] = []any{"refund"}
+	missingCreditor := paymentInitiation("123.50")
+	delete(missingCreditor, "creditorAccount")
+
+	tests := map[string]struct {
+		details []model.AuthorizationDetail
+		wantErr error
+	}{
+		"ok: no details": {},
+		"ok: payment_initiation": {
+			details: []model.AuthorizationDetail{paymentInitiation("123.50")},
+		},
+		"ng: no type": {
+			details: []model.AuthorizationDetail{{"actions": []any{"initiate"}}},
+			wantErr: ErrInvalidAuthorizationDetails,
+		},
+		"ng: unknown type": {
+			details: []model.AuthorizationDetail{{"type": "account_information"}},
+			wantErr: ErrInvalidAuthorizationDetails,
+		},
+		"ng: invalid amount": {
+			details: []model.AuthorizationDetail{paymentInitiation("12,3")},
+			wantErr: ErrInvalidAuthorizationDetails,
+		},
+		"ng: invalid action": {
+			details: []model.AuthorizationDetail{invalidAction},
+			wantErr: ErrInvalidAuthorizationDetails,
+		},
+		"ng: missing required field": {
+			details: []model.AuthorizationDetail{missingCreditor},
+			wantErr: ErrInvalidAuthorizationDetails,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := &AuthUseCase{Storage: infra.NewAuthorizationStorage("fixed-key")}
+			err := uc.validateAuthorizationDetails(context.Background(), tt.details)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNarrowAuthorizationDetails(t *testing.T) {
+	t.Parallel()
+
+	granted := []model.AuthorizationDetail{paymentInitiation("10.00"), paymentInitiation("20.00")}
+
+	tests := map[string]struct {
+		requested []model.AuthorizationDetail
+		want      []model.AuthorizationDetail
+		wantErr   error
+	}{
+		"ok: nothing requested": {
+			want: granted,
+		},
+		"ok: subset": {
+			requested: []model.AuthorizationDetail{paymentInitiation("20.00")},
+			want:      []model.AuthorizationDetail{paymentInitiation("20.00")},
+		},
+		"ng: not granted": {
+			requested: []model.AuthorizationDetail{paymentInitiation("30.00")},
+			wantErr:   ErrInvalidAuthorizationDetails,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := narrowAuthorizationDetails(granted, tt.requested)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestAuthUseCaseAuthorizeBeforeLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		req     *model.AuthRequest
+		wantErr error
+	}{
+		"ok": {
+			req: &model.AuthRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "openid"},
+		},
+		"ng: unknown client": {
+			req:     &model.AuthRequest{ClientID: "unknown-client-id", RedirectURI: testRedirectURI, Scope: "openid"},
+			wantErr: ErrInvalidRedirectURI,
+		},
+		"ng: redirect_uri is not registered": {
+			req:     &model.AuthRequest{ClientID: testClientID, RedirectURI: "https://attacker.example.com/callback", Scope: "openid"},
+			wantErr: ErrInvalidRedirectURI,
+		},
+		"ng: redirect_uri is missing": {
+			req:     &model.AuthRequest{ClientID: testClientID, Scope: "openid"},
+			wantErr: ErrInvalidRedirectURI,
+		},
+		"ng: unknown resource": {
+			req:     &model.AuthRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "openid", Resources: []string{"http://localhost:9999"}},
+			wantErr: ErrInvalidTarget,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t)
+			_, client, err := uc.AuthorizeBeforeLogin(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && client.GetID() != testClientID {
+				t.Errorf("unexpected client: %s", client.GetID())
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseAuthorizeAfterLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		acrValues []string
+		maxAge    *int64
+		acr       string
+		authTime  time.Time
+		wantErr   error
+	}{
+		"ok: acr_values are satisfied": {
+			acrValues: []string{model.ACRMultiFactor},
+			acr:       model.ACRMultiFactor,
+		},
+		"ok: stronger acr": {
+			acrValues: []string{model.ACRBasic},
+			acr:       model.ACRMultiFactor,
+		},
+		"ok: no acr_values": {
+			acr: model.ACRBasic,
+		},
+		"ng: acr falls short": {
+			acrValues: []string{model.ACRMultiFactor},
+			acr:       model.ACRBasic,
+			wantErr:   ErrAccessDenied,
+		},
+		"ng: authentication is too old": {
+			maxAge:   new(int64),
+			acr:      model.ACRBasic,
+			authTime: time.Now().Add(-time.Minute),
+			wantErr:  ErrLoginRequired,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthUseCase(t)
+			authReq, _, err := uc.AuthorizeBeforeLogin(ctx, &model.AuthRequest{
+				ClientID:    testClientID,
+				RedirectURI: testRedirectURI,
+				Scope:       "openid",
+				ACRValues:   tt.acrValues,
+				MaxAge:      tt.maxAge,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authn := testUserAuthentication()
+			authn.ACR = tt.acr
+			if !tt.authTime.IsZero() {
+				authn.AuthTime = tt.authTime
+			}
+			loginToken, err := uc.LoginTokens.Encode(authReq.ID, authn)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, _, err := uc.AuthorizeAfterLogin(ctx, &model.AuthRequest{ID: authReq.ID}, loginToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && got.Authentication.ACR != tt.acr {
+				t.Errorf("unexpected authentication: %+v", got.Authentication)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseTokenByAuthorizationCodeConcurrently(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	uc := newTestAuthUseCase(t)
+	authReq, _, err := uc.AuthorizeBeforeLogin(ctx, &model.AuthRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginToken, err := uc.LoginTokens.Encode(authReq.ID, testUserAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.AuthorizeAfterLogin(ctx, &model.AuthRequest{ID: authReq.ID}, loginToken); err != nil {
+		t.Fatal(err)
+	}
+	authReq, _, err = uc.Consent(ctx, authReq.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issued atomic.Int32
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := uc.Token(ctx, &model.TokenRequest{
+				GrantType:    model.GrantTypeAuthorizationCode,
+				Code:         authReq.Code,
+				RedirectURI:  testRedirectURI,
+				ClientID:     testClientID,
+				ClientSecret: "dummy-client-secret",
+			})
+			switch {
+			case err == nil:
+				issued.Add(1)
+			case !errors.Is(err, ErrInvalidGrant):
+				t.Errorf("want invalid_grant, got=%v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the code is redeemed only once.
+	if got := issued.Load(); got != 1 {
+		t.Errorf("want tokens issued once, got %d", got)
+	}
+}
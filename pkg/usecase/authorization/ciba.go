@@ -0,0 +1,312 @@
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// clientNotificationTimeout bounds the requests to the notification endpoints of clients.
+const clientNotificationTimeout = 5 * time.Second
+
+// AuthenticationDeviceNotifier asks the user of a backchannel authentication request to approve it on the authentication device.
+// The device reports the result with AuthUseCase.CompleteBackchannelAuthentication.
+type AuthenticationDeviceNotifier interface {
+	Notify(ctx context.Context, req *model.BackchannelAuthRequest) error
+}
+
+// BackchannelAuthenticate accepts the backchannel authentication request of the authenticated client
+// and notifies the authentication device of the user.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7
+func (s *AuthUseCase) BackchannelAuthenticate(ctx context.Context, req *model.BackchannelAuthRequest, clientID, clientSecret string) (*model.BackchannelAuthRequest, error) {
+	client, err := s.Storage.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, clientSecret)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	if s.DeviceNotifier == nil {
+		return nil, fmt.Errorf("%w: backchannel authentication is disabled", ErrUnauthorizedClient)
+	}
+	mode := client.GetBackchannelTokenDeliveryMode()
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("%w: backchannel token delivery mode is not registered", ErrUnauthorizedClient)
+	}
+
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.1
+	if !slices.Contains(strings.Fields(req.Scope), scopeOpenID) {
+		return nil, fmt.Errorf("%w: scope must contain openid", ErrInvalidScope)
+	}
+	if mode != model.BackchannelTokenDeliveryModePoll && req.ClientNotificationToken == "" {
+		return nil, fmt.Errorf("%w: client_notification_token is required", ErrInvalidRequest)
+	}
+	req.ClientID = client.GetID()
+
+	subject, err := s.resolveLoginHint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Accept(subject, mode)
+	err = s.Storage.CreateBackchannelAuthRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DeviceNotifier.Notify(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// resolveLoginHint returns the user identified by exactly one of the hints of req.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.7.1
+func (s *AuthUseCase) resolveLoginHint(ctx context.Context, req *model.BackchannelAuthRequest) (string, error) {
+	hints := 0
+	for _, hint := range []string{req.LoginHint, req.IDTokenHint, req.LoginHintToken} {
+		if hint != "" {
+			hints++
+		}
+	}
+	if hints != 1 {
+		return "", fmt.Errorf("%w: exactly one of login_hint, id_token_hint and login_hint_token is required", ErrInvalidRequest)
+	}
+
+	switch {
+	case req.LoginHint != "":
+		if s.Users == nil {
+			return "", fmt.Errorf("%w: login_hint is not supported", ErrInvalidRequest)
+		}
+		user, err := s.Users.GetUserByUsername(ctx, req.LoginHint)
+		if err != nil {
+			return "", errors.Join(ErrUnknownUserID, err)
+		}
+		return user.ID, nil
+	case req.IDTokenHint != "":
+		claims, err := s.verifyIDTokenHint(ctx, req.ClientID, req.IDTokenHint)
+		if err != nil {
+			return "", err
+		}
+		return claims.Subject, nil
+	default:
+		return "", fmt.Errorf("%w: login_hint_token is not supported", ErrInvalidRequest)
+	}
+}
+
+// BackchannelAuthRequestForDevice returns the pending request which the user logged in on the authentication device,
+// which is the local stand-in of the device.
+func (s *AuthUseCase) BackchannelAuthRequestForDevice(ctx context.Context, authReqID, loginToken string) (*model.BackchannelAuthRequest, *model.Authentication, error) {
+	req, err := s.Storage.GetBackchannelAuthRequest(ctx, authReqID)
+	if err != nil {
+		return nil, nil, err
+	}
+	authn, err := s.LoginTokens.Decode(loginToken, req.AuthReqID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authn.Subject != req.Subject {
+		return nil, nil, fmt.Errorf("%w: the request is for another user", ErrAccessDenied)
+	}
+	if req.Status != model.BackchannelAuthStatusPending || req.IsExpired() {
+		return nil, nil, fmt.Errorf("%w: the request is not pending", ErrExpiredToken)
+	}
+	return req, authn, nil
+}
+
+// CompleteBackchannelAuthentication records that the user approved the request with authn, or denied it if authn is nil,
+// and notifies the client in ping and push modes.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10
+func (s *AuthUseCase) CompleteBackchannelAuthentication(ctx context.Context, authReqID string, authn *model.Authentication) error {
+	req, err := s.Storage.GetBackchannelAuthRequest(ctx, authReqID)
+	if err != nil {
+		return err
+	}
+	if req.Status != model.BackchannelAuthStatusPending {
+		return fmt.Errorf("%w: the request is already completed", ErrInvalidRequest)
+	}
+	if req.IsExpired() {
+		return ErrExpiredToken
+	}
+	if authn != nil && authn.Subject != req.Subject {
+		return fmt.Errorf("%w: the request is for another user", ErrAccessDenied)
+	}
+
+	req.Status = model.BackchannelAuthStatusDenied
+	if authn != nil {
+		req.Status = model.BackchannelAuthStatusApproved
+		req.Authentication = authn
+	}
+	// the request which is approved or denied concurrently is not overwritten.
+	err = s.Storage.UpdateBackchannelAuthRequest(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%w: the request is already completed: %w", ErrInvalidRequest, err)
+	}
+
+	switch req.DeliveryMode {
+	case model.BackchannelTokenDeliveryModePing:
+		// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.2
+		return s.notifyClient(ctx, req, map[string]any{"auth_req_id": req.AuthReqID})
+	case model.BackchannelTokenDeliveryModePush:
+		// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.3
+		if authn == nil {
+			return s.notifyClient(ctx, req, map[string]any{
+				"auth_req_id":       req.AuthReqID,
+				"error":             "access_denied",
+				"error_description": ErrAccessDenied.Error(),
+			})
+		}
+		accessToken, err := s.issueBackchannelTokens(ctx, req)
+		if err != nil {
+			return err
+		}
+		res := map[string]any{
+			"auth_req_id":   req.AuthReqID,
+			"access_token":  accessToken.AccessToken,
+			"token_type":    accessToken.TokenType,
+			"expires_in":    accessToken.ExpiresIn(),
+			"refresh_token": accessToken.RefreshToken,
+			"id_token":      accessToken.IDToken,
+		}
+		return s.notifyClient(ctx, req, res)
+	default:
+		return nil
+	}
+}
+
+// notifyClient posts body to the notification endpoint of the client with client_notification_token.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.2
+func (s *AuthUseCase) notifyClient(ctx context.Context, req *model.BackchannelAuthRequest, body map[string]any) error {
+	client, err := s.Storage.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, clientNotificationTimeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, client.GetBackchannelClientNotificationEndpoint(), bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to notify the client: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer "+req.ClientNotificationToken)
+
+	res, err := s.httpClient().Do(r)
+	if err != nil {
+		return fmt.Errorf("failed to notify the client: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to notify the client: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// tokenByCIBA issues tokens once the user approves the backchannel authentication request in poll and ping modes.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.1
+func (s *AuthUseCase) tokenByCIBA(ctx context.Context, req *model.TokenRequest) (*model.AccessToken, error) {
+	client, err := s.Storage.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, req.ClientSecret)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+
+	bcReq, err := s.Storage.GetBackchannelAuthRequest(ctx, req.AuthReqID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidGrant, err)
+	}
+	if bcReq.ClientID != client.GetID() {
+		return nil, fmt.Errorf("%w: client_id is mismatched", ErrInvalidGrant)
+	}
+	// tokens are pushed to the client in push mode.
+	if bcReq.DeliveryMode == model.BackchannelTokenDeliveryModePush {
+		return nil, fmt.Errorf("%w: tokens are delivered in push mode", ErrUnauthorizedClient)
+	}
+	if bcReq.IsExpired() {
+		return nil, ErrExpiredToken
+	}
+
+	switch bcReq.Status {
+	case model.BackchannelAuthStatusPending:
+		return nil, s.pollBackchannelAuthRequest(ctx, bcReq)
+	case model.BackchannelAuthStatusDenied:
+		return nil, ErrAccessDenied
+	case model.BackchannelAuthStatusApproved:
+		return s.issueBackchannelTokens(ctx, bcReq)
+	default:
+		return nil, fmt.Errorf("%w: auth_req_id is already used", ErrInvalidGrant)
+	}
+}
+
+// pollBackchannelAuthRequest records the poll of the pending request, and asks the client to slow down
+// by extending the interval if it polls more frequently than the interval.
+// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+func (s *AuthUseCase) pollBackchannelAuthRequest(ctx context.Context, req *model.BackchannelAuthRequest) error {
+	now := time.Now()
+	tooFast := !req.LastPolledAt.IsZero() && now.Sub(req.LastPolledAt) < req.Interval
+	req.LastPolledAt = now
+	if tooFast {
+		req.Interval += model.BackchannelPollingInterval
+	}
+	if err := s.Storage.UpdateBackchannelAuthRequest(ctx, req); err != nil {
+		// the request is completed in the meantime, and the next poll gets the result.
+		return errors.Join(ErrAuthorizationPending, err)
+	}
+
+	if tooFast {
+		return ErrSlowDown
+	}
+	return ErrAuthorizationPending
+}
+
+// issueBackchannelTokens issues the tokens of the approved request, which can be issued only once
+// even if the client polls concurrently.
+func (s *AuthUseCase) issueBackchannelTokens(ctx context.Context, req *model.BackchannelAuthRequest) (*model.AccessToken, error) {
+	req, err := s.Storage.ConsumeBackchannelAuthRequest(ctx, req.AuthReqID, model.BackchannelAuthStatusApproved, model.BackchannelAuthStatusIssued)
+	if err != nil {
+		return nil, fmt.Errorf("%w: auth_req_id is already used: %w", ErrInvalidGrant, err)
+	}
+
+	grant := req.Grant()
+	accessToken, err := s.issueAccessToken(ctx, grant, &model.TokenRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken := model.NewRefreshToken(grant, s.RefreshTokenLifetime)
+	err = s.Storage.CreateRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	accessToken.RefreshToken = refreshToken.RefreshToken
+
+	claims := &idTokenClaims{}
+	if req.DeliveryMode == model.BackchannelTokenDeliveryModePush {
+		// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.3.1
+		claims.AuthReqID = req.AuthReqID
+		claims.RTHash = tokenHash(refreshToken.RefreshToken)
+	}
+	err = s.issueIDToken(ctx, grant, accessToken, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return accessToken, nil
+}
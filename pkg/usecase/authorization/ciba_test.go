@@ -0,0 +1,327 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+const testCIBAClientID = "ciba-client-id"
+
+// withBackchannelClient registers the client which starts CIBA with mode.
+func withBackchannelClient(mode model.BackchannelTokenDeliveryMode, notificationEndpoint string) testAuthOption {
+	return func(t *testing.T, uc *AuthUseCase) {
+		t.Helper()
+
+		secretHash, err := uc.Hasher.Hash(context.Background(), []byte("ciba-client-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		withClient(model.NewConfidentialClient(
+			model.AuthMethodBasic,
+			testCIBAClientID,
+			string(secretHash),
+			nil,
+			model.WithBackchannelTokenDelivery(mode, notificationEndpoint),
+		))(t, uc)
+	}
+}
+
+func TestAuthUseCaseBackchannelAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mode    model.BackchannelTokenDeliveryMode
+		req     *model.BackchannelAuthRequest
+		idToken bool
+		wantErr error
+	}{
+		"ok: login_hint": {
+			mode: model.BackchannelTokenDeliveryModePoll,
+			req:  &model.BackchannelAuthRequest{Scope: "openid", LoginHint: "dummy-user", BindingMessage: "W4SCT"},
+		},
+		"ok: id_token_hint": {
+			mode:    model.BackchannelTokenDeliveryModePoll,
+			req:     &model.BackchannelAuthRequest{Scope: "openid"},
+			idToken: true,
+		},
+		"ng: client is not registered for backchannel authentication": {
+			req:     &model.BackchannelAuthRequest{Scope: "openid", LoginHint: "dummy-user"},
+			wantErr: ErrUnauthorizedClient,
+		},
+		"ng: openid is missing": {
+			mode:    model.BackchannelTokenDeliveryModePoll,
+			req:     &model.BackchannelAuthRequest{Scope: "profile", LoginHint: "dummy-user"},
+			wantErr: ErrInvalidScope,
+		},
+		"ng: multiple hints": {
+			mode:    model.BackchannelTokenDeliveryModePoll,
+			req:     &model.BackchannelAuthRequest{Scope: "openid", LoginHint: "dummy-user", LoginHintToken: "token"},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: unknown user": {
+			mode:    model.BackchannelTokenDeliveryModePoll,
+			req:     &model.BackchannelAuthRequest{Scope: "openid", LoginHint: "unknown"},
+			wantErr: ErrUnknownUserID,
+		},
+		"ng: client_notification_token is missing in ping mode": {
+			mode:    model.BackchannelTokenDeliveryModePing,
+			req:     &model.BackchannelAuthRequest{Scope: "openid", LoginHint: "dummy-user"},
+			wantErr: ErrInvalidRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthUseCase(t, withBackchannelClient(tt.mode, ""), withUsers(), withSigningKey(), withDeviceSimulator())
+			device := uc.DeviceNotifier.(*infra.AuthenticationDeviceSimulator)
+			if tt.idToken {
+				accessToken := &model.AccessToken{AccessToken: "access-token"}
+				err := uc.issueIDToken(ctx, &model.Grant{ClientID: testCIBAClientID, Scope: "openid", Authentication: testUserAuthentication()}, accessToken, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.req.IDTokenHint = accessToken.IDToken
+			}
+
+			got, err := uc.BackchannelAuthenticate(ctx, tt.req, testCIBAClientID, "ciba-client-secret")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Subject != "dummy-user-id" {
+				t.Errorf("subject: want=dummy-user-id, got=%s", got.Subject)
+			}
+			if got.AuthReqID == "" || got.ExpiresIn() <= 0 {
+				t.Errorf("auth_req_id must be issued: %+v", got)
+			}
+			if notified := device.Notified("dummy-user-id"); len(notified) != 1 || notified[0].AuthReqID != got.AuthReqID {
+				t.Errorf("the device must be notified: %+v", notified)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseTokenByCIBA(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	uc := newTestAuthUseCase(t, withBackchannelClient(model.BackchannelTokenDeliveryModePoll, ""), withUsers(), withSigningKey(), withDeviceSimulator())
+	start := func(t *testing.T) *model.BackchannelAuthRequest {
+		t.Helper()
+		req, err := uc.BackchannelAuthenticate(ctx, &model.BackchannelAuthRequest{Scope: "openid profile", LoginHint: "dummy-user"}, testCIBAClientID, "ciba-client-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	poll := func(authReqID string) (*model.AccessToken, error) {
+		return uc.Token(ctx, &model.TokenRequest{
+			GrantType:    model.GrantTypeCIBA,
+			AuthReqID:    authReqID,
+			ClientID:     testCIBAClientID,
+			ClientSecret: "ciba-client-secret",
+		})
+	}
+
+	t.Run("ok: approved", func(t *testing.T) {
+		req := start(t)
+		if _, err := poll(req.AuthReqID); !errors.Is(err, ErrAuthorizationPending) {
+			t.Fatalf("want authorization_pending, got=%v", err)
+		}
+		if _, err := poll(req.AuthReqID); !errors.Is(err, ErrSlowDown) {
+			t.Fatalf("want slow_down, got=%v", err)
+		}
+		polled, err := uc.Storage.GetBackchannelAuthRequest(ctx, req.AuthReqID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if polled.Interval != 2*model.BackchannelPollingInterval {
+			t.Errorf("interval must be extended: got=%v", polled.Interval)
+		}
+
+		if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, testUserAuthentication()); err != nil {
+			t.Fatal(err)
+		}
+		got, err := poll(req.AuthReqID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Subject != "dummy-user-id" || got.RefreshToken == "" || got.IDToken == "" {
+			t.Errorf("tokens must be issued: %+v", got)
+		}
+		if _, err := poll(req.AuthReqID); !errors.Is(err, ErrInvalidGrant) {
+			t.Errorf("auth_req_id must be used only once, got=%v", err)
+		}
+	})
+
+	t.Run("ng: denied", func(t *testing.T) {
+		req := start(t)
+		if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := poll(req.AuthReqID); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("want access_denied, got=%v", err)
+		}
+	})
+
+	t.Run("ng: approved by another user", func(t *testing.T) {
+		req := start(t)
+		authn := testUserAuthentication()
+		authn.Subject = "other-user-id"
+		if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, authn); !errors.Is(err, ErrAccessDenied) {
+			t.Errorf("want access_denied, got=%v", err)
+		}
+	})
+
+	t.Run("ok: polled concurrently", func(t *testing.T) {
+		req := start(t)
+		if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, testUserAuthentication()); err != nil {
+			t.Fatal(err)
+		}
+
+		var issued atomic.Int32
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := poll(req.AuthReqID)
+				switch {
+				case err == nil:
+					issued.Add(1)
+				case !errors.Is(err, ErrInvalidGrant):
+					t.Errorf("want invalid_grant, got=%v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if issued.Load() != 1 {
+			t.Errorf("tokens must be issued only once, got=%d", issued.Load())
+		}
+	})
+
+	t.Run("ng: completed twice", func(t *testing.T) {
+		req := start(t)
+		if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, testUserAuthentication()); err != nil {
+			t.Fatal(err)
+		}
+		if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, nil); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("want invalid_request, got=%v", err)
+		}
+	})
+
+	t.Run("ng: expired", func(t *testing.T) {
+		req := start(t)
+		req.ExpiresAt = time.Now().Add(-time.Second)
+		if err := uc.Storage.UpdateBackchannelAuthRequest(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := poll(req.AuthReqID); !errors.Is(err, ErrExpiredToken) {
+			t.Errorf("want expired_token, got=%v", err)
+		}
+	})
+}
+
+func TestAuthUseCaseCompleteBackchannelAuthentication(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		mode     model.BackchannelTokenDeliveryMode
+		approved bool
+		want     []string // members of the notification
+	}{
+		"ok: ping": {
+			mode:     model.BackchannelTokenDeliveryModePing,
+			approved: true,
+			want:     []string{"auth_req_id"},
+		},
+		"ok: push": {
+			mode:     model.BackchannelTokenDeliveryModePush,
+			approved: true,
+			want:     []string{"auth_req_id", "access_token", "token_type", "expires_in", "refresh_token", "id_token"},
+		},
+		"ok: push denied": {
+			mode: model.BackchannelTokenDeliveryModePush,
+			want: []string{"auth_req_id", "error", "error_description"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			notifications := make(chan map[string]any, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer notification-token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				body := map[string]any{}
+				json.NewDecoder(r.Body).Decode(&body)
+				notifications <- body
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			t.Cleanup(srv.Close)
+
+			uc := newTestAuthUseCase(t, withBackchannelClient(tt.mode, srv.URL), withUsers(), withSigningKey(), withDeviceSimulator())
+			req, err := uc.BackchannelAuthenticate(ctx, &model.BackchannelAuthRequest{
+				Scope:                   "openid",
+				LoginHint:               "dummy-user",
+				ClientNotificationToken: "notification-token",
+			}, testCIBAClientID, "ciba-client-secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var authn *model.Authentication
+			if tt.approved {
+				authn = testUserAuthentication()
+			}
+			if err := uc.CompleteBackchannelAuthentication(ctx, req.AuthReqID, authn); err != nil {
+				t.Fatal(err)
+			}
+
+			got := <-notifications
+			if len(got) != len(tt.want) {
+				t.Errorf("unexpected notification: %v", got)
+			}
+			for _, k := range tt.want {
+				if _, ok := got[k]; !ok {
+					t.Errorf("%s is missing: %v", k, got)
+				}
+			}
+			if got["auth_req_id"] != req.AuthReqID {
+				t.Errorf("auth_req_id: want=%s, got=%v", req.AuthReqID, got["auth_req_id"])
+			}
+
+			if idToken, ok := got["id_token"].(string); ok {
+				jwk, _ := uc.SigningKey.JWK()
+				claims := &idTokenClaims{}
+				_, err := service.ParseJWT(idToken, service.JWKSetKeyFunc(&model.JWKSet{Keys: []model.JWK{*jwk}}, service.KeyUseSignature, service.AlgRS256), claims)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if claims.AuthReqID != req.AuthReqID || claims.ATHash != tokenHash(got["access_token"].(string)) || claims.RTHash == "" {
+					t.Errorf("unexpected id token: %+v", claims)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package authorization
+
+import "errors"
+
+// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
+var (
+	ErrInvalidRequest       = errors.New("request is invalid")
+	ErrInvalidClient        = errors.New("client authentication failed")
+	ErrInvalidGrant         = errors.New("grant is invalid")
+	ErrInvalidScope         = errors.New("scope is invalid")
+	ErrUnsupportedGrantType = errors.New("grant type is unsupported")
+	ErrUnauthorizedClient   = errors.New("client is not authorized to use the grant type")
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.2.1
+	ErrAccessDenied = errors.New("resource owner denied the request")
+	// ErrInvalidRedirectURI is shown to the user instead of being sent to the redirect_uri, which is not trusted.
+	ErrInvalidRedirectURI = errors.New("client_id or redirect_uri is invalid")
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-7
+	ErrInvalidRequestURI    = errors.New("request_uri is invalid")
+	ErrInvalidRequestObject = errors.New("request object is invalid")
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2
+	ErrInvalidTarget = errors.New("resource is invalid")
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9396#section-5
+	ErrInvalidAuthorizationDetails = errors.New("authorization_details is invalid")
+
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.11
+	ErrAuthorizationPending = errors.New("user has not approved the request yet")
+	ErrSlowDown             = errors.New("client polls too frequently")
+	ErrExpiredToken         = errors.New("auth_req_id is expired")
+	ErrUnknownUserID        = errors.New("user is unknown")
+
+	// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+	ErrLoginRequired = errors.New("user authentication is too old for max_age")
+)
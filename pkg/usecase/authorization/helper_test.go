@@ -0,0 +1,185 @@
+package authorization
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+	"github.com/task4233/oauth/pkg/repository"
+)
+
+const (
+	testClientID    = "dummy-client-id"
+	testRedirectURI = "http://localhost:9000/auth/callback"
+)
+
+// testAuthOption customizes the use case built by newTestAuthUseCase.
+type testAuthOption func(t *testing.T, uc *AuthUseCase)
+
+// newTestAuthUseCase returns the use case with the sample client and resource of infra.NewAuthorizationStorage,
+// to which the features under test are added by opts.
+func newTestAuthUseCase(t *testing.T, opts ...testAuthOption) *AuthUseCase {
+	t.Helper()
+
+	uc := NewAuthUseCase(
+		"http://localhost:9001",
+		infra.NewAuthorizationStorage("fixed-key"),
+		service.NewSha256Hasher("fixed-key"),
+		service.NewLoginTokenCodec([]byte("login-token-key")),
+	)
+	for _, opt := range opts {
+		opt(t, uc)
+	}
+	return uc
+}
+
+// withIssuer builds the use case of the tenant whose issuer is issuer, which has its own storage as cmd/server builds it.
+func withIssuer(issuer string) testAuthOption {
+	return func(_ *testing.T, uc *AuthUseCase) {
+		uc.Issuer = issuer
+	}
+}
+
+// withClient registers client in addition to the sample client.
+func withClient(client model.Client) testAuthOption {
+	return func(_ *testing.T, uc *AuthUseCase) {
+		uc.Storage = &clientStorage{Storage: uc.Storage, client: client}
+	}
+}
+
+// withTrustedIssuer trusts the assertions of issuer.
+func withTrustedIssuer(issuer *model.TrustedIssuer) testAuthOption {
+	return func(t *testing.T, uc *AuthUseCase) {
+		t.Helper()
+
+		storage, ok := uc.Storage.(*infra.AuthorizationStorage)
+		if !ok {
+			t.Fatalf("trusted issuers are not registered to %T", uc.Storage)
+		}
+		if err := storage.AddTrustedIssuer(issuer); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// withUsers lets the use case find the dummy user of infra.NewUserStorage.
+func withUsers() testAuthOption {
+	return func(_ *testing.T, uc *AuthUseCase) {
+		uc.Users = infra.NewUserStorage("fixed-key")
+	}
+}
+
+// withSigningKey signs the JWTs with a random key.
+func withSigningKey() testAuthOption {
+	return func(t *testing.T, uc *AuthUseCase) {
+		t.Helper()
+
+		key, err := service.GenerateSigningKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		uc.SigningKey = key
+	}
+}
+
+// withKeyRotation signs the JWTs with the keys of the key manager, whose first key is activated.
+func withKeyRotation() testAuthOption {
+	return func(t *testing.T, uc *AuthUseCase) {
+		t.Helper()
+
+		storage, err := infra.NewKeyStorage("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err := service.NewKeyManager(storage, make([]byte, 32), model.DefaultKeyRotationPolicy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.Rotate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		uc.SigningKeys = keys
+	}
+}
+
+// withEncryptionKey decrypts the JWTs encrypted to the server with a random key.
+func withEncryptionKey() testAuthOption {
+	return func(t *testing.T, uc *AuthUseCase) {
+		t.Helper()
+
+		key, err := service.GenerateEncryptionKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		uc.EncryptionKey = key
+	}
+}
+
+// withEncryptionKeyRotation decrypts the JWTs with the keys of the key manager, whose first key is activated.
+func withEncryptionKeyRotation() testAuthOption {
+	return func(t *testing.T, uc *AuthUseCase) {
+		t.Helper()
+
+		storage, err := infra.NewKeyStorage("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		policy := model.DefaultKeyRotationPolicy
+		policy.Algorithm = service.AlgRSAOAEP256
+		keys, err := service.NewEncryptionKeyManager(storage, make([]byte, 32), policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.Rotate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		uc.EncryptionKeys = keys
+	}
+}
+
+// withDeviceSimulator notifies the authentication device of the users on the simulator.
+func withDeviceSimulator() testAuthOption {
+	return func(_ *testing.T, uc *AuthUseCase) {
+		uc.DeviceNotifier = infra.NewAuthenticationDeviceSimulator("http://localhost:9001/bc-device")
+	}
+}
+
+// clientStorage returns the given client in addition to the clients of the embedded storage.
+type clientStorage struct {
+	repository.Storage
+	client model.Client
+}
+
+func (s *clientStorage) GetClient(ctx context.Context, clientID string) (model.Client, error) {
+	if clientID == s.client.GetID() {
+		return s.client, nil
+	}
+	return s.Storage.GetClient(ctx, clientID)
+}
+
+// testUserAuthentication returns the authentication of the dummy user of infra.NewUserStorage with the password.
+func testUserAuthentication() *model.Authentication {
+	return &model.Authentication{
+		Subject:  "dummy-user-id",
+		AuthTime: time.Now(),
+		ACR:      model.ACRBasic,
+		AMR:      []string{model.AMRPassword},
+	}
+}
+
+// newTestKey returns the key with which the clients and the trusted issuers sign the JWTs.
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
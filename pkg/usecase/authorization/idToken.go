@@ -0,0 +1,106 @@
+package authorization
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+const (
+	scopeOpenID            = "openid"
+	DefaultIDTokenLifetime = 10 * time.Minute
+)
+
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type idTokenClaims struct {
+	service.RegisteredClaims
+	AuthTime int64    `json:"auth_time,omitempty"`
+	ACR      string   `json:"acr,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	ATHash   string   `json:"at_hash,omitempty"`
+	// ref: https://openid.net/specs/openid-client-initiated-backchannel-authentication-core-1_0.html#rfc.section.10.3.1
+	RTHash    string `json:"urn:openid:params:jwt:claim:rt_hash,omitempty"`
+	AuthReqID string `json:"urn:openid:params:jwt:claim:auth_req_id,omitempty"`
+	// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#ClaimsContents
+	SessionID string `json:"sid,omitempty"`
+}
+
+// issueIDToken sets the ID token of the user of grant to accessToken if openid is granted.
+// The authorization server does not issue ID tokens unless a signing key is configured.
+// The client is recorded in the session of the user to be notified when the user logs out.
+func (s *AuthUseCase) issueIDToken(ctx context.Context, grant *model.Grant, accessToken *model.AccessToken, claims *idTokenClaims) error {
+	if grant.Authentication == nil || !slices.Contains(strings.Fields(grant.Scope), scopeOpenID) {
+		return nil
+	}
+	key, err := s.signingKey(ctx)
+	if err != nil || key == nil {
+		return err
+	}
+	if claims == nil {
+		claims = &idTokenClaims{}
+	}
+
+	now := time.Now()
+	claims.Issuer = s.Issuer
+	claims.Subject = grant.Authentication.Subject
+	claims.Audience = service.Audience{grant.ClientID}
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(s.IDTokenLifetime).Unix()
+	claims.AuthTime = grant.Authentication.AuthTime.Unix()
+	claims.ACR = grant.Authentication.ACR
+	claims.AMR = grant.Authentication.AMR
+	claims.SessionID = grant.Authentication.SessionID
+	claims.ATHash = tokenHash(accessToken.AccessToken)
+
+	idToken, err := service.SignJWT(&service.JWSHeader{Algorithm: key.Algorithm, KeyID: key.ID, Type: "JWT"}, key.Key, claims)
+	if err != nil {
+		return err
+	}
+	accessToken.IDToken = idToken
+
+	if grant.Authentication.SessionID != "" {
+		return s.Storage.AddSessionClient(ctx, grant.Authentication.SessionID, grant.ClientID)
+	}
+	return nil
+}
+
+// verifyIDTokenHint verifies the ID token which the authorization server issued to the client, if clientID is not empty.
+// The ID token may be expired, as it only hints who the user is, but it is rejected once its key is unpublished.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (s *AuthUseCase) verifyIDTokenHint(ctx context.Context, clientID, idTokenHint string) (*idTokenClaims, error) {
+	set, err := s.signingJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("%w: id_token_hint is not supported", ErrInvalidRequest)
+	}
+
+	claims := &idTokenClaims{}
+	_, err = service.ParseJWT(idTokenHint, service.JWKSetKeyFunc(set, service.KeyUseSignature, keyAlgs(set)...), claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: id_token_hint: %w", ErrInvalidRequest, err)
+	}
+	if claims.Issuer != s.Issuer || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: id_token_hint is not issued by the authorization server", ErrInvalidRequest)
+	}
+	if clientID != "" && !slices.Contains(claims.Audience, clientID) {
+		return nil, fmt.Errorf("%w: id_token_hint is not issued to the client", ErrInvalidRequest)
+	}
+	return claims, nil
+}
+
+// tokenHash returns the left half of the SHA-256 hash of token, e.g. at_hash.
+// ref: https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+}
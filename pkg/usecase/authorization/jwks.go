@@ -0,0 +1,94 @@
+package authorization
+
+import (
+	"context"
+	"slices"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// JWKS returns the public keys of the authorization server.
+// ref: https://datatracker.ietf.org/doc/html/rfc8414#section-2
+func (s *AuthUseCase) JWKS(ctx context.Context) (*model.JWKSet, error) {
+	set, err := s.signingJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.EncryptionKeys != nil {
+		keys, err := s.EncryptionKeys.JWKS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, keys...)
+	} else if s.EncryptionKey != nil {
+		jwk, err := s.EncryptionKey.JWK()
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+	return set, nil
+}
+
+// SigningAlgs returns the algorithms of the published signing keys, which are empty if the authorization server signs no JWTs.
+func (s *AuthUseCase) SigningAlgs(ctx context.Context) ([]string, error) {
+	set, err := s.signingJWKS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return keyAlgs(set), nil
+}
+
+func keyAlgs(set *model.JWKSet) []string {
+	var algs []string
+	for _, jwk := range set.Keys {
+		if !slices.Contains(algs, jwk.Algorithm) {
+			algs = append(algs, jwk.Algorithm)
+		}
+	}
+	return algs
+}
+
+// signingKey returns the key which signs the JWTs now, which is nil if the authorization server signs no JWTs.
+// The key rotated by SigningKeys is used instead of SigningKey if it is set.
+func (s *AuthUseCase) signingKey(ctx context.Context) (*service.PrivateKey, error) {
+	if s.SigningKeys != nil {
+		return s.SigningKeys.SigningKey(ctx)
+	}
+	return s.SigningKey, nil
+}
+
+// signingJWKS returns the public keys with which the JWTs signed by the authorization server are verified,
+// which include the next key and the retired keys on the rotation.
+func (s *AuthUseCase) signingJWKS(ctx context.Context) (*model.JWKSet, error) {
+	set := &model.JWKSet{Keys: []model.JWK{}}
+	if s.SigningKeys != nil {
+		keys, err := s.SigningKeys.JWKS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, keys...)
+		return set, nil
+	}
+	if s.SigningKey != nil {
+		jwk, err := s.SigningKey.JWK()
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+	return set, nil
+}
+
+// decryptionKey returns the key of kid, or the key to which the clients encrypt if kid is empty.
+// The keys rotated by EncryptionKeys are used instead of EncryptionKey if it is set.
+func (s *AuthUseCase) decryptionKey(ctx context.Context, kid string) (*service.PrivateKey, error) {
+	if s.EncryptionKeys != nil {
+		return s.EncryptionKeys.DecryptionKey(ctx, kid)
+	}
+	if s.EncryptionKey == nil || (kid != "" && kid != s.EncryptionKey.ID) {
+		return nil, service.ErrJWKNotFound
+	}
+	return s.EncryptionKey, nil
+}
@@ -0,0 +1,102 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// assertionMaxLifetime bounds exp of assertions, so that their jti are not recorded for too long.
+const assertionMaxLifetime = time.Hour
+
+// tokenByJWTBearer issues an access token for the local user of a JWT assertion minted by a trusted issuer.
+// ref: https://datatracker.ietf.org/doc/html/rfc7523#section-2.1
+func (s *AuthUseCase) tokenByJWTBearer(ctx context.Context, req *model.TokenRequest) (*model.AccessToken, error) {
+	client, err := s.Storage.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, req.ClientSecret)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+
+	issuer, claims, err := s.verifyAssertion(ctx, req.Assertion)
+	if err != nil {
+		return nil, err
+	}
+	if !issuer.AllowsClient(client.GetID()) {
+		return nil, fmt.Errorf("%w: assertions of %s are not allowed", ErrUnauthorizedClient, issuer.Issuer)
+	}
+	userID, ok := issuer.LocalSubject(claims.Subject)
+	if !ok {
+		return nil, fmt.Errorf("%w: subject %q is unknown", ErrInvalidGrant, claims.Subject)
+	}
+	// ref: https://datatracker.ietf.org/doc/html/rfc7523#section-3
+	err = s.Storage.RecordAssertionID(ctx, issuer.Issuer, claims.ID, time.Unix(claims.ExpiresAt, 0))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGrant, err)
+	}
+
+	authTime := time.Now()
+	if claims.IssuedAt != 0 {
+		authTime = time.Unix(claims.IssuedAt, 0)
+	}
+	grant := &model.Grant{
+		ClientID: client.GetID(),
+		Scope:    strings.Join(issuer.Scopes, " "),
+		Authentication: &model.Authentication{
+			Subject:  userID,
+			AuthTime: authTime,
+		},
+	}
+	return s.issueAccessToken(ctx, grant, req)
+}
+
+// verifyAssertion verifies the assertion with the keys of its issuer and validates its claims.
+// ref: https://datatracker.ietf.org/doc/html/rfc7523#section-3
+func (s *AuthUseCase) verifyAssertion(ctx context.Context, assertion string) (*model.TrustedIssuer, *service.RegisteredClaims, error) {
+	unverified := &service.RegisteredClaims{}
+	if err := service.ParseUnverifiedJWT(assertion, unverified); err != nil {
+		return nil, nil, fmt.Errorf("%w: assertion: %w", ErrInvalidGrant, err)
+	}
+	issuer, err := s.Storage.GetTrustedIssuer(ctx, unverified.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: assertion: %w", ErrInvalidGrant, err)
+	}
+
+	claims := &service.RegisteredClaims{}
+	_, err = service.ParseJWT(assertion, service.JWKSetKeyFunc(issuer.JWKS, service.KeyUseSignature, service.AsymmetricAlgs...), claims)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: assertion: %w", ErrInvalidGrant, err)
+	}
+
+	now := time.Now()
+	if err := claims.Validate(now, issuer.Issuer, ""); err != nil {
+		return nil, nil, fmt.Errorf("%w: assertion: %w", ErrInvalidGrant, err)
+	}
+	// the token endpoint URL may also identify the authorization server.
+	if !slices.Contains(claims.Audience, s.Issuer) && !slices.Contains(claims.Audience, s.Issuer+"/token") {
+		return nil, nil, fmt.Errorf("%w: assertion: %w", ErrInvalidGrant, service.ErrJWTAudienceMissing)
+	}
+	if claims.Subject == "" {
+		return nil, nil, fmt.Errorf("%w: assertion: sub is required", ErrInvalidGrant)
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, nil, fmt.Errorf("%w: assertion: exp is required", ErrInvalidGrant)
+	}
+	if time.Unix(claims.ExpiresAt, 0).After(now.Add(assertionMaxLifetime)) {
+		return nil, nil, fmt.Errorf("%w: assertion: exp is too far in the future", ErrInvalidGrant)
+	}
+	if claims.ID == "" {
+		return nil, nil, fmt.Errorf("%w: assertion: jti is required", ErrInvalidGrant)
+	}
+
+	return issuer, claims, nil
+}
@@ -0,0 +1,143 @@
+package authorization
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+const testAssertionIssuer = "https://idp.example.com"
+
+// testTrustedIssuer returns the issuer of the assertions signed with key, which maps idp-user to the dummy user.
+func testTrustedIssuer(t *testing.T, key *ecdsa.PublicKey) *model.TrustedIssuer {
+	t.Helper()
+
+	jwk, err := service.NewJWK(key, "idp-key", service.KeyUseSignature, service.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.TrustedIssuer{
+		Issuer:    testAssertionIssuer,
+		JWKS:      &model.JWKSet{Keys: []model.JWK{*jwk}},
+		Subjects:  map[string]string{"idp-user": "dummy-user-id"},
+		ClientIDs: []string{testClientID},
+		Scopes:    []string{"openid", "profile"},
+	}
+}
+
+func TestAuthUseCaseTokenByJWTBearer(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss": testAssertionIssuer,
+			"sub": "idp-user",
+			"aud": "http://localhost:9001/token",
+			"exp": now.Add(time.Minute).Unix(),
+			"iat": now.Unix(),
+			"jti": "assertion-id",
+		}
+	}
+
+	tests := map[string]struct {
+		claims    func(map[string]any)
+		scope     string
+		replay    bool
+		wantScope string
+		wantErr   error
+	}{
+		"ok": {
+			wantScope: "openid profile",
+		},
+		"ok: down-scoped": {
+			scope:     "profile",
+			wantScope: "profile",
+		},
+		"ng: replayed": {
+			replay:  true,
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: untrusted issuer": {
+			claims:  func(c map[string]any) { c["iss"] = "https://other.example.com" },
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: unknown subject": {
+			claims:  func(c map[string]any) { c["sub"] = "unknown" },
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: aud is mismatched": {
+			claims:  func(c map[string]any) { c["aud"] = "https://other.example.com" },
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: expired": {
+			claims:  func(c map[string]any) { c["exp"] = now.Add(-time.Second).Unix() },
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: exp is too far": {
+			claims:  func(c map[string]any) { c["exp"] = now.Add(24 * time.Hour).Unix() },
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: jti is missing": {
+			claims:  func(c map[string]any) { delete(c, "jti") },
+			wantErr: ErrInvalidGrant,
+		},
+		"ng: scope is not allowed": {
+			scope:   "email",
+			wantErr: ErrInvalidScope,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			key := newTestKey(t)
+			uc := newTestAuthUseCase(t, withTrustedIssuer(testTrustedIssuer(t, &key.PublicKey)))
+
+			claims := validClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			assertion, err := service.SignJWT(&service.JWSHeader{Algorithm: service.AlgES256, KeyID: "idp-key", Type: "JWT"}, key, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := &model.TokenRequest{
+				GrantType:    model.GrantTypeJWTBearer,
+				Assertion:    assertion,
+				Scope:        tt.scope,
+				ClientID:     testClientID,
+				ClientSecret: "dummy-client-secret",
+			}
+			if tt.replay {
+				if _, err := uc.Token(ctx, req); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := uc.Token(ctx, req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.Subject != "dummy-user-id" {
+				t.Errorf("subject: want=dummy-user-id, got=%s", got.Subject)
+			}
+			if got.Scope != tt.wantScope {
+				t.Errorf("scope: want=%s, got=%s", tt.wantScope, got.Scope)
+			}
+			if got.RefreshToken != "" {
+				t.Errorf("refresh token must not be issued")
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var ErrKeyRotationDisabled = errors.New("key rotation is disabled")
+
+// SigningKeyRecords returns the signing keys with their states, without their private keys.
+func (s *AuthUseCase) SigningKeyRecords(ctx context.Context) ([]*model.KeyRecord, error) {
+	if s.SigningKeys == nil {
+		return nil, ErrKeyRotationDisabled
+	}
+	return s.SigningKeys.Keys(ctx)
+}
+
+// RotateSigningKeys activates the next signing key ahead of the schedule.
+func (s *AuthUseCase) RotateSigningKeys(ctx context.Context) error {
+	if s.SigningKeys == nil {
+		return ErrKeyRotationDisabled
+	}
+	return s.SigningKeys.RotateNow(ctx)
+}
+
+// RevokeSigningKey unpublishes the signing key of kid, so that the JWTs signed by it are rejected, e.g. when it is leaked.
+func (s *AuthUseCase) RevokeSigningKey(ctx context.Context, kid string) error {
+	if s.SigningKeys == nil {
+		return ErrKeyRotationDisabled
+	}
+	if kid == "" {
+		return fmt.Errorf("%w: kid is required", ErrInvalidRequest)
+	}
+	return s.SigningKeys.Revoke(ctx, kid)
+}
@@ -0,0 +1,91 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+func TestAuthUseCaseIDTokenHintOnKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		operate func(ctx context.Context, uc *AuthUseCase, kid string) error
+		wantErr error
+	}{
+		"ok: not rotated": {
+			operate: func(context.Context, *AuthUseCase, string) error { return nil },
+		},
+		"ok: signed by the retired key": {
+			operate: func(ctx context.Context, uc *AuthUseCase, _ string) error {
+				return uc.RotateSigningKeys(ctx)
+			},
+		},
+		"ng: signed by the revoked key": {
+			operate: func(ctx context.Context, uc *AuthUseCase, kid string) error {
+				return uc.RevokeSigningKey(ctx, kid)
+			},
+			wantErr: ErrInvalidRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthUseCase(t, withKeyRotation())
+			key, err := uc.signingKey(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			accessToken := &model.AccessToken{AccessToken: "dummy-access-token"}
+			err = uc.issueIDToken(ctx, &model.Grant{ClientID: testClientID, Scope: "openid", Authentication: testUserAuthentication()}, accessToken, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.operate(ctx, uc, key.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = uc.verifyIDTokenHint(ctx, testClientID, accessToken.IDToken)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("unexpected error: want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseSigningKeyAdmin(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	if err := newTestAuthUseCase(t).RotateSigningKeys(ctx); !errors.Is(err, ErrKeyRotationDisabled) {
+		t.Errorf("unexpected error without the key manager: want %v, got %v", ErrKeyRotationDisabled, err)
+	}
+
+	uc := newTestAuthUseCase(t, withKeyRotation())
+	if err := uc.RevokeSigningKey(ctx, ""); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("unexpected error without kid: want %v, got %v", ErrInvalidRequest, err)
+	}
+	if err := uc.RevokeSigningKey(ctx, "unknown-kid"); !errors.Is(err, service.ErrKeyNotFound) {
+		t.Errorf("unexpected error with unknown kid: want %v, got %v", service.ErrKeyNotFound, err)
+	}
+
+	records, err := uc.SigningKeyRecords(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("the active key and the next key must be listed: %d keys", len(records))
+	}
+	for _, r := range records {
+		if r.EncryptedKey != nil {
+			t.Errorf("the private key of %s must not be listed", r.ID)
+		}
+	}
+}
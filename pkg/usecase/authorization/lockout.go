@@ -0,0 +1,35 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+var ErrLockoutDisabled = errors.New("lockout is disabled")
+
+// FailureCounters returns the users, the clients and the addresses which have failed to authenticate.
+func (s *AuthUseCase) FailureCounters(ctx context.Context) ([]*model.FailureCounter, error) {
+	if s.Lockout == nil {
+		return nil, ErrLockoutDisabled
+	}
+	return s.Lockout.Counters(ctx)
+}
+
+// Unlock lets the user, the client or the address locked out try to authenticate again.
+func (s *AuthUseCase) Unlock(ctx context.Context, key model.FailureKey) error {
+	if s.Lockout == nil {
+		return ErrLockoutDisabled
+	}
+	switch key.Kind {
+	case model.FailureKindUser, model.FailureKindClient, model.FailureKindIP, model.FailureKindOTP:
+	default:
+		return fmt.Errorf("%w: kind and key are required", ErrInvalidRequest)
+	}
+	if key.Key == "" {
+		return fmt.Errorf("%w: kind and key are required", ErrInvalidRequest)
+	}
+	return s.Lockout.Unlock(ctx, key)
+}
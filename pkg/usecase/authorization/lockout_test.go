@@ -0,0 +1,217 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+// testLockoutPolicy locks out on the third failure without the backoff, so that the tests do not wait.
+var testLockoutPolicy = model.LockoutPolicy{
+	FreeAttempts:     3,
+	LockoutThreshold: 3,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       time.Hour,
+}
+
+func newTestLockout(t *testing.T) *service.Lockout {
+	t.Helper()
+
+	storage, err := infra.NewLockoutStorage("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service.NewLockout(storage).WithPolicy(model.FailureKindClient, testLockoutPolicy).WithPolicy(model.FailureKindIP, testLockoutPolicy)
+}
+
+func TestAuthUseCaseAuthenteClientLockout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		unlock    *model.FailureKey
+		otherIP   bool
+		wantErr   error
+		wantCount int
+	}{
+		"ng: locked out": {
+			wantErr:   service.ErrLockedOut,
+			wantCount: 2,
+		},
+		"ng: locked out from other address": {
+			otherIP:   true,
+			wantErr:   service.ErrLockedOut,
+			wantCount: 2,
+		},
+		"ng: address is still locked out": {
+			unlock:    &model.FailureKey{Kind: model.FailureKindClient, Key: testClientID},
+			wantErr:   service.ErrLockedOut,
+			wantCount: 1,
+		},
+		"ok: unlocked": {
+			unlock:    &model.FailureKey{Kind: model.FailureKindClient, Key: testClientID},
+			otherIP:   true,
+			wantCount: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t)
+			uc.Lockout = newTestLockout(t)
+			ctx := service.ContextWithRemoteIP(context.Background(), "192.0.2.1")
+			client, err := uc.Storage.GetClient(ctx, testClientID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for range testLockoutPolicy.LockoutThreshold {
+				if err := uc.AuthenteClient(ctx, client, "wrong-secret"); err == nil || errors.Is(err, service.ErrLockedOut) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+			if tt.unlock != nil {
+				if err := uc.Unlock(ctx, *tt.unlock); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.otherIP {
+				ctx = service.ContextWithRemoteIP(context.Background(), "192.0.2.2")
+			}
+
+			// the correct secret is rejected while the client is locked out.
+			err = uc.AuthenteClient(ctx, client, "dummy-client-secret")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want %v, got %v", tt.wantErr, err)
+			}
+
+			counters, err := uc.FailureCounters(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(counters) != tt.wantCount {
+				t.Errorf("want %d counters, got %+v", tt.wantCount, counters)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseAuthenteClientRateLimit(t *testing.T) {
+	t.Parallel()
+
+	uc := newTestAuthUseCase(t)
+	limiter := service.NewRateLimiter()
+	ctx := service.ContextWithClientRateLimit(context.Background(), func(clientID string) error {
+		if retryAfter, ok := limiter.Allow(clientID, model.RateLimit{Rate: 1, Burst: 1}); !ok {
+			return &service.RateLimitedError{ClientID: clientID, RetryAfter: retryAfter}
+		}
+		return nil
+	})
+	client, err := uc.Storage.GetClient(ctx, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the requests claiming the client_id do not use up the limit of the client.
+	for range 3 {
+		if err := uc.AuthenteClient(ctx, client, "wrong-secret"); err == nil || errors.Is(err, service.ErrRateLimited) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := uc.AuthenteClient(ctx, client, "dummy-client-secret"); err != nil {
+		t.Fatal(err)
+	}
+	err = uc.AuthenteClient(ctx, client, "dummy-client-secret")
+	var limited *service.RateLimitedError
+	if !errors.As(err, &limited) || limited.RetryAfter <= 0 {
+		t.Errorf("want rate limited, got %v", err)
+	}
+}
+
+// publicClient is the client without secret, which is not registered by the configuration.
+type publicClient struct {
+	model.Client
+}
+
+func (c *publicClient) IsPublic() bool {
+	return true
+}
+
+func TestAuthUseCaseAuthenteClientRateLimitPublicClient(t *testing.T) {
+	t.Parallel()
+
+	uc := newTestAuthUseCase(t)
+	limiter := service.NewRateLimiter()
+	ctx := service.ContextWithClientRateLimit(context.Background(), func(clientID string) error {
+		if retryAfter, ok := limiter.Allow(clientID, model.RateLimit{Rate: 1, Burst: 1}); !ok {
+			return &service.RateLimitedError{ClientID: clientID, RetryAfter: retryAfter}
+		}
+		return nil
+	})
+	client, err := uc.Storage.GetClient(ctx, testClientID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := &publicClient{Client: client}
+
+	// the public client is limited by its client_id, as it has no secret to authenticate.
+	if err := uc.AuthenteClient(ctx, public, ""); err != nil {
+		t.Fatal(err)
+	}
+	err = uc.AuthenteClient(ctx, public, "")
+	var limited *service.RateLimitedError
+	if !errors.As(err, &limited) || limited.ClientID != testClientID {
+		t.Errorf("want rate limited, got %v", err)
+	}
+}
+
+func TestAuthUseCaseUnlock(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		lockout bool
+		key     model.FailureKey
+		wantErr error
+	}{
+		"ok": {
+			lockout: true,
+			key:     model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"},
+		},
+		"ng: unknown kind": {
+			lockout: true,
+			key:     model.FailureKey{Kind: "device", Key: "dummy-user"},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: empty key": {
+			lockout: true,
+			key:     model.FailureKey{Kind: model.FailureKindUser},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: lockout disabled": {
+			key:     model.FailureKey{Kind: model.FailureKindUser, Key: "dummy-user"},
+			wantErr: ErrLockoutDisabled,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t)
+			if tt.lockout {
+				uc.Lockout = newTestLockout(t)
+			}
+
+			err := uc.Unlock(context.Background(), tt.key)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
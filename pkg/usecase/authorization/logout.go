@@ -0,0 +1,237 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+const (
+	logoutTokenLifetime    = 2 * time.Minute
+	backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+	backchannelLogoutAttempts      = 3
+	backchannelLogoutTimeout       = 5 * time.Second
+	backchannelLogoutRetryInterval = time.Second
+)
+
+// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+type logoutTokenClaims struct {
+	service.RegisteredClaims
+	Events    map[string]struct{} `json:"events"`
+	SessionID string              `json:"sid,omitempty"`
+}
+
+// StartLogout validates the logout request of the relying party and keeps it while the authentication server ends the session.
+// post_logout_redirect_uri must be registered by the client identified by client_id or id_token_hint.
+// ref: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+func (s *AuthUseCase) StartLogout(ctx context.Context, req *model.LogoutRequest) (*model.LogoutRequest, error) {
+	if req.IDTokenHint != "" {
+		claims, err := s.verifyIDTokenHint(ctx, req.ClientID, req.IDTokenHint)
+		if err != nil {
+			return nil, err
+		}
+		if req.ClientID == "" && len(claims.Audience) == 1 {
+			req.ClientID = claims.Audience[0]
+		}
+		req.Subject = claims.Subject
+		req.SessionID = claims.SessionID
+	}
+
+	if req.PostLogoutRedirectURI != "" {
+		if req.ClientID == "" {
+			return nil, fmt.Errorf("%w: client_id or id_token_hint is required with post_logout_redirect_uri", ErrInvalidRequest)
+		}
+		client, err := s.Storage.GetClient(ctx, req.ClientID)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidClient, err)
+		}
+		if !client.IsValidPostLogoutRedirectURI(req.PostLogoutRedirectURI) {
+			return nil, fmt.Errorf("%w: post_logout_redirect_uri is not registered", ErrInvalidRequest)
+		}
+	}
+
+	req.Accept()
+	err := s.Storage.CreateLogoutRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// CompleteLogout returns the logout request after the authentication server ends the session, and
+// the authentication of the ended session, which is nil if the user had no session.
+func (s *AuthUseCase) CompleteLogout(ctx context.Context, logoutID, loginToken string) (*model.LogoutRequest, *model.Authentication, error) {
+	req, err := s.Storage.ConsumeLogoutRequest(ctx, logoutID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	if req.IsExpired() {
+		return nil, nil, fmt.Errorf("%w: logout request is expired", ErrInvalidRequest)
+	}
+	if loginToken == "" {
+		return req, nil, nil
+	}
+
+	authn, err := s.LoginTokens.Decode(loginToken, req.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	return req, authn, nil
+}
+
+// SessionState returns session_state of the authorization response, with which the relying party
+// checks the session of the user at the check session iframe.
+// It is empty if the user is not authenticated in a session.
+// ref: https://openid.net/specs/openid-connect-session-1_0.html#CreatingUpdatingSessions
+func (s *AuthUseCase) SessionState(authReq *model.AuthRequest) string {
+	if authReq.Authentication == nil || authReq.Authentication.SessionID == "" {
+		return ""
+	}
+	u, err := url.Parse(authReq.RedirectURI)
+	if err != nil {
+		return ""
+	}
+
+	origin := u.Scheme + "://" + u.Host
+	return model.SessionState(authReq.ClientID, origin, model.BrowserState(authReq.Authentication.SessionID), uuid.NewString())
+}
+
+// NotifyLogout tells the clients, to which ID tokens are issued in the ended session, that the user has logged out.
+// The logout tokens are posted to the back-channel logout URIs, and the front-channel logout URIs are returned
+// so that the user agent renders them. The errors of the back-channel logout are returned with the URIs,
+// as they must not stop the logout of the user.
+// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#OPLogout
+func (s *AuthUseCase) NotifyLogout(ctx context.Context, authn *model.Authentication) ([]string, error) {
+	if authn == nil || authn.SessionID == "" {
+		return nil, nil
+	}
+	clientIDs, err := s.Storage.ConsumeSessionClients(ctx, authn.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	frontchannelURIs := make([]string, 0, len(clientIDs))
+	errs := make([]error, len(clientIDs))
+	wg := &sync.WaitGroup{}
+	for i, clientID := range clientIDs {
+		client, err := s.Storage.GetClient(ctx, clientID)
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %w", clientID, err)
+			continue
+		}
+
+		if uri := client.GetFrontchannelLogoutURI(); uri != "" {
+			frontchannelURIs = append(frontchannelURIs, s.frontchannelLogoutURI(uri, authn.SessionID))
+		}
+		if uri := client.GetBackchannelLogoutURI(); uri != "" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.backchannelLogout(ctx, client, authn); err != nil {
+					errs[i] = fmt.Errorf("%s: %w", clientID, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	return frontchannelURIs, errors.Join(errs...)
+}
+
+// frontchannelLogoutURI adds iss and sid to the front-channel logout URI for the client to identify the session.
+// ref: https://openid.net/specs/openid-connect-frontchannel-1_0.html#OPLogout
+func (s *AuthUseCase) frontchannelLogoutURI(uri, sessionID string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	q.Set("iss", s.Issuer)
+	q.Set("sid", sessionID)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// backchannelLogout posts the logout token to the back-channel logout URI of the client,
+// and retries it while the client fails to respond.
+// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (s *AuthUseCase) backchannelLogout(ctx context.Context, client model.Client, authn *model.Authentication) error {
+	logoutToken, err := s.logoutToken(ctx, client.GetID(), authn)
+	if err != nil {
+		return err
+	}
+	body := url.Values{"logout_token": {logoutToken}}.Encode()
+
+	for attempt := 1; ; attempt++ {
+		err = s.postLogoutToken(ctx, client.GetBackchannelLogoutURI(), body)
+		if err == nil || attempt == backchannelLogoutAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(s.logoutRetryInterval * time.Duration(attempt)):
+		}
+	}
+}
+
+func (s *AuthUseCase) postLogoutToken(ctx context.Context, uri, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, backchannelLogoutTimeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post the logout token: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.httpClient().Do(r)
+	if err != nil {
+		return fmt.Errorf("failed to post the logout token: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to post the logout token: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// logoutToken issues the logout token of the session for the client.
+// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#LogoutToken
+func (s *AuthUseCase) logoutToken(ctx context.Context, clientID string, authn *model.Authentication) (string, error) {
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", fmt.Errorf("logout tokens are not supported")
+	}
+
+	now := time.Now()
+	claims := &logoutTokenClaims{
+		RegisteredClaims: service.RegisteredClaims{
+			Issuer:    s.Issuer,
+			Subject:   authn.Subject,
+			Audience:  service.Audience{clientID},
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(logoutTokenLifetime).Unix(),
+			ID:        uuid.NewString(),
+		},
+		Events:    map[string]struct{}{backchannelLogoutEvent: {}},
+		SessionID: authn.SessionID,
+	}
+	// ref: https://openid.net/specs/openid-connect-backchannel-1_0.html#Security
+	return service.SignJWT(&service.JWSHeader{Algorithm: key.Algorithm, KeyID: key.ID, Type: "logout+jwt"}, key.Key, claims)
+}
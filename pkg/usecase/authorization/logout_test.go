@@ -0,0 +1,294 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// issueTestIDToken returns the ID token of the dummy user in the session, which the logout requests hint.
+func issueTestIDToken(t *testing.T, uc *AuthUseCase) string {
+	t.Helper()
+
+	authn := testUserAuthentication()
+	authn.SessionID = "dummy-session-id"
+	accessToken := &model.AccessToken{AccessToken: "dummy-access-token"}
+	err := uc.issueIDToken(context.Background(), &model.Grant{ClientID: testClientID, Scope: "openid", Authentication: authn}, accessToken, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return accessToken.IDToken
+}
+
+func TestAuthUseCaseStartLogout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		req           func(idToken string) *model.LogoutRequest
+		wantClientID  string
+		wantSubject   string
+		wantSessionID string
+		wantErr       error
+	}{
+		"ok: id_token_hint and post_logout_redirect_uri": {
+			req: func(idToken string) *model.LogoutRequest {
+				return &model.LogoutRequest{IDTokenHint: idToken, PostLogoutRedirectURI: "http://localhost:9000/", State: "dummy-state"}
+			},
+			wantClientID:  testClientID,
+			wantSubject:   "dummy-user-id",
+			wantSessionID: "dummy-session-id",
+		},
+		"ok: client_id and post_logout_redirect_uri": {
+			req: func(string) *model.LogoutRequest {
+				return &model.LogoutRequest{ClientID: testClientID, PostLogoutRedirectURI: "http://localhost:9000/"}
+			},
+			wantClientID: testClientID,
+		},
+		"ok: without parameters": {
+			req: func(string) *model.LogoutRequest {
+				return &model.LogoutRequest{}
+			},
+		},
+		"ng: post_logout_redirect_uri is not registered": {
+			req: func(idToken string) *model.LogoutRequest {
+				return &model.LogoutRequest{IDTokenHint: idToken, PostLogoutRedirectURI: "https://attacker.example.com/"}
+			},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: post_logout_redirect_uri without client": {
+			req: func(string) *model.LogoutRequest {
+				return &model.LogoutRequest{PostLogoutRedirectURI: "http://localhost:9000/"}
+			},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: id_token_hint is issued to another client": {
+			req: func(idToken string) *model.LogoutRequest {
+				return &model.LogoutRequest{IDTokenHint: idToken, ClientID: "another-client-id"}
+			},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: invalid id_token_hint": {
+			req: func(string) *model.LogoutRequest {
+				return &model.LogoutRequest{IDTokenHint: "invalid"}
+			},
+			wantErr: ErrInvalidRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t, withSigningKey())
+			idToken := issueTestIDToken(t, uc)
+			got, err := uc.StartLogout(context.Background(), tt.req(idToken))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID == "" {
+				t.Errorf("logout request is not accepted")
+			}
+			if got.ClientID != tt.wantClientID || got.Subject != tt.wantSubject || got.SessionID != tt.wantSessionID {
+				t.Errorf("unexpected logout request: client_id=%q, sub=%q, sid=%q", got.ClientID, got.Subject, got.SessionID)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseCompleteLogout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		withSession bool
+		loginToken  func(uc *AuthUseCase, id string) string
+		wantErr     error
+	}{
+		"ok: session is ended": {
+			loginToken: func(uc *AuthUseCase, id string) string {
+				authn := testUserAuthentication()
+				authn.SessionID = "dummy-session-id"
+				token, err := uc.LoginTokens.Encode(id, authn)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			withSession: true,
+		},
+		"ok: user had no session": {
+			loginToken: func(*AuthUseCase, string) string { return "" },
+		},
+		"ng: login token for another request": {
+			loginToken: func(uc *AuthUseCase, _ string) string {
+				token, err := uc.LoginTokens.Encode("another-id", testUserAuthentication())
+				if err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			wantErr: ErrInvalidRequest,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthUseCase(t, withSigningKey())
+			idToken := issueTestIDToken(t, uc)
+			req, err := uc.StartLogout(ctx, &model.LogoutRequest{IDTokenHint: idToken})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, authn, err := uc.CompleteLogout(ctx, req.ID, tt.loginToken(uc, req.ID))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got.ID != req.ID {
+				t.Errorf("unexpected logout request: want=%s, got=%s", req.ID, got.ID)
+			}
+			if (authn != nil) != tt.withSession || (authn != nil && authn.SessionID != "dummy-session-id") {
+				t.Errorf("unexpected authentication: %+v", authn)
+			}
+
+			// the logout request is completed only once.
+			_, _, err = uc.CompleteLogout(ctx, req.ID, "")
+			if !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("logout request is completed twice: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseNotifyLogout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		failures     int32
+		backchannel  bool
+		frontchannel bool
+		sessionID    string
+		wantCalls    int32
+		wantErr      bool
+	}{
+		"ok: back-channel and front-channel": {
+			backchannel:  true,
+			frontchannel: true,
+			sessionID:    "dummy-session-id",
+			wantCalls:    1,
+		},
+		"ok: back-channel is retried": {
+			failures:    2,
+			backchannel: true,
+			sessionID:   "dummy-session-id",
+			wantCalls:   3,
+		},
+		"ok: another session": {
+			backchannel:  true,
+			frontchannel: true,
+			sessionID:    "another-session-id",
+		},
+		"ng: back-channel keeps failing": {
+			failures:    backchannelLogoutAttempts,
+			backchannel: true,
+			sessionID:   "dummy-session-id",
+			wantCalls:   backchannelLogoutAttempts,
+			wantErr:     true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			uc := newTestAuthUseCase(t, withSigningKey())
+			uc.logoutRetryInterval = time.Millisecond
+			jwk, err := uc.SigningKey.JWK()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var calls atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				claims := &logoutTokenClaims{}
+				header, err := service.ParseJWT(r.PostFormValue("logout_token"), service.JWKSetKeyFunc(&model.JWKSet{Keys: []model.JWK{*jwk}}, service.KeyUseSignature, uc.SigningKey.Algorithm), claims)
+				if err != nil {
+					t.Errorf("invalid logout token: %v", err)
+				} else if header.Type != "logout+jwt" || claims.Subject != "dummy-user-id" || claims.SessionID != "dummy-session-id" ||
+					!slices.Equal(claims.Audience, service.Audience{"backchannel-client-id"}) || claims.ID == "" {
+					t.Errorf("unexpected logout token: %+v, %+v", header, claims)
+				}
+				if _, ok := claims.Events[backchannelLogoutEvent]; !ok {
+					t.Errorf("logout event is missing: %+v", claims.Events)
+				}
+			}))
+			t.Cleanup(receiver.Close)
+
+			clients := []model.Client{}
+			if tt.backchannel {
+				clients = append(clients, model.NewConfidentialClient(model.AuthMethodBasic, "backchannel-client-id", "", nil, model.WithBackchannelLogoutURI(receiver.URL)))
+			}
+			if tt.frontchannel {
+				clients = append(clients, model.NewConfidentialClient(model.AuthMethodBasic, "frontchannel-client-id", "", nil, model.WithFrontchannelLogoutURI("https://rp.example.com/logout?foo=bar")))
+			}
+
+			// ID tokens of the session are issued to the clients.
+			authn := testUserAuthentication()
+			authn.SessionID = "dummy-session-id"
+			for _, client := range clients {
+				withClient(client)(t, uc)
+				err := uc.issueIDToken(ctx, &model.Grant{ClientID: client.GetID(), Scope: "openid", Authentication: authn}, &model.AccessToken{AccessToken: "dummy-access-token"}, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ended := testUserAuthentication()
+			ended.SessionID = tt.sessionID
+			got, err := uc.NotifyLogout(ctx, ended)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Errorf("unexpected back-channel logout calls: want=%d, got=%d", tt.wantCalls, calls.Load())
+			}
+
+			var want []string
+			if tt.frontchannel && tt.sessionID == "dummy-session-id" {
+				want = []string{"https://rp.example.com/logout?" + url.Values{"foo": {"bar"}, "iss": {uc.Issuer}, "sid": {"dummy-session-id"}}.Encode()}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("unexpected front-channel logout URIs: want=%v, got=%v", want, got)
+			}
+
+			// the clients are notified only once per session.
+			got, err = uc.NotifyLogout(ctx, ended)
+			if err != nil || len(got) != 0 {
+				t.Errorf("clients are notified twice: %v, %v", got, err)
+			}
+		})
+	}
+}
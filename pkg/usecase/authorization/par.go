@@ -0,0 +1,70 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// PushAuthorizationRequest validates and stores the authorization request sent by the client,
+// and returns the request_uri which refers to it.
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-2
+func (s *AuthUseCase) PushAuthorizationRequest(ctx context.Context, req *model.AuthRequest, clientID, clientSecret string) (*model.PushedAuthRequest, error) {
+	client, err := s.Storage.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, clientSecret)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-2.1
+	if req.ClientID != client.GetID() {
+		return nil, fmt.Errorf("%w: client_id is mismatched", ErrInvalidRequest)
+	}
+	if !client.IsValidRedirectURI(req.RedirectURI) {
+		return nil, fmt.Errorf("%w: redirect_uri is not registered", ErrInvalidRequest)
+	}
+	if err := s.validateAuthorizationRequest(ctx, req); err != nil {
+		return nil, err
+	}
+
+	par := model.NewPushedAuthRequest(req)
+	err = s.Storage.CreatePushedAuthRequest(ctx, par)
+	if err != nil {
+		return nil, err
+	}
+
+	return par, nil
+}
+
+// AuthorizeWithRequestURI starts the authorization with the request pushed by PushAuthorizationRequest.
+// ref: https://datatracker.ietf.org/doc/html/rfc9126#section-4
+func (s *AuthUseCase) AuthorizeWithRequestURI(ctx context.Context, clientID, requestURI string) (*model.AuthRequest, model.Client, error) {
+	par, err := s.Storage.ConsumePushedAuthRequest(ctx, requestURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidRequestURI, err)
+	}
+	if par.ExpiresAt <= time.Now().Unix() {
+		return nil, nil, fmt.Errorf("%w: request_uri is expired", ErrInvalidRequestURI)
+	}
+	if par.AuthRequest.ClientID != clientID {
+		return nil, nil, fmt.Errorf("%w: client_id is mismatched", ErrInvalidRequestURI)
+	}
+
+	client, err := s.Storage.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authReq, err := s.startAuthorization(ctx, par.AuthRequest)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return authReq, client, nil
+}
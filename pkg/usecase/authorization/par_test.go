@@ -0,0 +1,159 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestAuthUseCasePushAuthorizationRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		req          *model.AuthRequest
+		clientID     string
+		clientSecret string
+		wantErr      error
+	}{
+		"ok": {
+			req:          &model.AuthRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "openid"},
+			clientID:     testClientID,
+			clientSecret: "dummy-client-secret",
+		},
+		"ng: invalid client secret": {
+			req:          &model.AuthRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "openid"},
+			clientID:     testClientID,
+			clientSecret: "invalid",
+			wantErr:      ErrInvalidClient,
+		},
+		"ng: client_id is mismatched": {
+			req:          &model.AuthRequest{ClientID: "other-client-id", RedirectURI: testRedirectURI, Scope: "openid"},
+			clientID:     testClientID,
+			clientSecret: "dummy-client-secret",
+			wantErr:      ErrInvalidRequest,
+		},
+		"ng: redirect_uri is not registered": {
+			req:          &model.AuthRequest{ClientID: testClientID, RedirectURI: "http://localhost:9999/callback", Scope: "openid"},
+			clientID:     testClientID,
+			clientSecret: "dummy-client-secret",
+			wantErr:      ErrInvalidRequest,
+		},
+		"ng: unknown resource": {
+			req:          &model.AuthRequest{ClientID: testClientID, RedirectURI: testRedirectURI, Scope: "openid", Resources: []string{"http://localhost:9999"}},
+			clientID:     testClientID,
+			clientSecret: "dummy-client-secret",
+			wantErr:      ErrInvalidTarget,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t)
+			par, err := uc.PushAuthorizationRequest(context.Background(), tt.req, tt.clientID, tt.clientSecret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(par.RequestURI, model.RequestURIPrefix) {
+				t.Errorf("unexpected request_uri: %s", par.RequestURI)
+			}
+			if par.ExpiresIn() <= 0 {
+				t.Errorf("want positive expires_in, got %d", par.ExpiresIn())
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseAuthorizeWithRequestURI(t *testing.T) {
+	t.Parallel()
+
+	push := func(t *testing.T, uc *AuthUseCase) *model.PushedAuthRequest {
+		t.Helper()
+		par, err := uc.PushAuthorizationRequest(context.Background(), &model.AuthRequest{
+			ClientID:    testClientID,
+			RedirectURI: testRedirectURI,
+			Scope:       "openid",
+			State:       "state",
+		}, testClientID, "dummy-client-secret")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return par
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		uc := newTestAuthUseCase(t)
+		par := push(t, uc)
+		authReq, _, err := uc.AuthorizeWithRequestURI(context.Background(), testClientID, par.RequestURI)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if authReq.State != "state" || authReq.RedirectURI != testRedirectURI {
+			t.Errorf("pushed parameters are not used: %+v", authReq)
+		}
+	})
+
+	t.Run("ng: one-time use", func(t *testing.T) {
+		t.Parallel()
+
+		uc := newTestAuthUseCase(t)
+		par := push(t, uc)
+		if _, _, err := uc.AuthorizeWithRequestURI(context.Background(), testClientID, par.RequestURI); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		_, _, err := uc.AuthorizeWithRequestURI(context.Background(), testClientID, par.RequestURI)
+		if !errors.Is(err, ErrInvalidRequestURI) {
+			t.Errorf("want error %v, got %v", ErrInvalidRequestURI, err)
+		}
+	})
+
+	t.Run("ng: client_id is mismatched", func(t *testing.T) {
+		t.Parallel()
+
+		uc := newTestAuthUseCase(t)
+		par := push(t, uc)
+		_, _, err := uc.AuthorizeWithRequestURI(context.Background(), "other-client-id", par.RequestURI)
+		if !errors.Is(err, ErrInvalidRequestURI) {
+			t.Errorf("want error %v, got %v", ErrInvalidRequestURI, err)
+		}
+	})
+
+	t.Run("ng: expired", func(t *testing.T) {
+		t.Parallel()
+
+		uc := newTestAuthUseCase(t)
+		par := push(t, uc)
+		par.ExpiresAt = 0
+		if err := uc.Storage.CreatePushedAuthRequest(context.Background(), par); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := uc.AuthorizeWithRequestURI(context.Background(), testClientID, par.RequestURI)
+		if !errors.Is(err, ErrInvalidRequestURI) {
+			t.Errorf("want error %v, got %v", ErrInvalidRequestURI, err)
+		}
+	})
+}
+
+func TestAuthUseCaseAuthorizeBeforeLoginRequiresPAR(t *testing.T) {
+	t.Parallel()
+
+	uc := newTestAuthUseCase(t)
+	uc.RequirePushedAuthRequests = true
+	_, _, err := uc.AuthorizeBeforeLogin(context.Background(), &model.AuthRequest{
+		ClientID:    testClientID,
+		RedirectURI: testRedirectURI,
+		Scope:       "openid",
+	})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("want error %v, got %v", ErrInvalidRequest, err)
+	}
+}
@@ -0,0 +1,135 @@
+package authorization
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+const (
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-10.8
+	requestObjectType = "oauth-authz-req+jwt"
+
+	requestObjectMaxSize      = 64 << 10
+	requestObjectFetchTimeout = 5 * time.Second
+)
+
+// requestObjectClaims are the claims which the authorization server validates.
+// The other claims are the parameters of the authorization request.
+type requestObjectClaims struct {
+	service.RegisteredClaims
+	ClientID string `json:"client_id"`
+}
+
+// VerifyRequestObject decrypts the request object if it is encrypted, verifies it with the keys of the client,
+// and returns its claims.
+// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-6
+func (s *AuthUseCase) VerifyRequestObject(ctx context.Context, clientID, requestObject string) ([]byte, error) {
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: client_id is required", ErrInvalidRequest)
+	}
+	client, err := s.Storage.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-6.1
+	if service.IsJWE(requestObject) {
+		if s.EncryptionKey == nil && s.EncryptionKeys == nil {
+			return nil, fmt.Errorf("%w: encrypted request objects are not supported", ErrInvalidRequestObject)
+		}
+		_, plaintext, err := service.DecryptJWE(requestObject, func(header *service.JWEHeader) (*rsa.PrivateKey, error) {
+			decryptionKey, err := s.decryptionKey(ctx, header.KeyID)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := decryptionKey.Key.(*rsa.PrivateKey)
+			if !ok {
+				return nil, service.ErrJWEUnsupportedAlg
+			}
+			return key, nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRequestObject, err)
+		}
+		requestObject = string(plaintext)
+	}
+
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-6.2
+	algs := service.AsymmetricAlgs
+	if alg := client.GetRequestObjectSigningAlg(); alg != "" {
+		algs = []string{alg}
+	}
+	header, payload, err := service.VerifyJWS(requestObject, service.JWKSetKeyFunc(client.GetJWKS(), service.KeyUseSignature, algs...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequestObject, err)
+	}
+	if header.Type != "" && !strings.EqualFold(header.Type, requestObjectType) && !strings.EqualFold(header.Type, "JWT") {
+		return nil, fmt.Errorf("%w: typ must be %s", ErrInvalidRequestObject, requestObjectType)
+	}
+
+	claims := &requestObjectClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: claims are malformed", ErrInvalidRequestObject)
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: exp is required", ErrInvalidRequestObject)
+	}
+	if err := claims.Validate(time.Now(), client.GetID(), s.Issuer); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequestObject, err)
+	}
+	// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-5
+	if claims.ClientID != "" && claims.ClientID != client.GetID() {
+		return nil, fmt.Errorf("%w: client_id is mismatched", ErrInvalidRequestObject)
+	}
+
+	return payload, nil
+}
+
+// FetchRequestObject fetches the request object from the request_uri registered by the client.
+// ref: https://datatracker.ietf.org/doc/html/rfc9101#section-5.2.3
+func (s *AuthUseCase) FetchRequestObject(ctx context.Context, clientID, requestURI string) (string, error) {
+	client, err := s.Storage.GetClient(ctx, clientID)
+	if err != nil {
+		return "", errors.Join(ErrInvalidClient, err)
+	}
+	// the authorization server must not fetch arbitrary URIs, ref: https://datatracker.ietf.org/doc/html/rfc9101#section-10.4.1
+	if !slices.Contains(client.GetRequestURIs(), requestURI) {
+		return "", fmt.Errorf("%w: request_uri is not registered", ErrInvalidRequestURI)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestObjectFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURI, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidRequestURI, err)
+	}
+	req.Header.Set("Accept", "application/oauth-authz-req+jwt")
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidRequestURI, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: unexpected status %d", ErrInvalidRequestURI, res.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, requestObjectMaxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidRequestURI, err)
+	}
+	if len(b) > requestObjectMaxSize {
+		return "", fmt.Errorf("%w: request object is too large", ErrInvalidRequestURI)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,247 @@
+package authorization
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// testRequestObjectClient returns the client which signs the request objects with key.
+func testRequestObjectClient(t *testing.T, key *ecdsa.PublicKey, opts ...model.ClientOption) model.Client {
+	t.Helper()
+
+	jwk, err := service.NewJWK(key, "client-key", service.KeyUseSignature, service.AlgES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model.NewConfidentialClient(
+		model.AuthMethodBasic,
+		"jar-client-id",
+		"",
+		[]string{testRedirectURI},
+		append([]model.ClientOption{model.WithJWKS(&model.JWKSet{Keys: []model.JWK{*jwk}})}, opts...)...,
+	)
+}
+
+func TestAuthUseCaseVerifyRequestObject(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":           "jar-client-id",
+			"aud":           "http://localhost:9001",
+			"exp":           now.Add(time.Minute).Unix(),
+			"nbf":           now.Add(-time.Minute).Unix(),
+			"client_id":     "jar-client-id",
+			"response_type": "code",
+			"scope":         "openid",
+		}
+	}
+
+	tests := map[string]struct {
+		claims  func(map[string]any)
+		header  *service.JWSHeader
+		encrypt bool
+		opts    []model.ClientOption
+		wantErr error
+	}{
+		"ok": {},
+		"ok: encrypted": {
+			encrypt: true,
+		},
+		"ng: iss is mismatched": {
+			claims:  func(c map[string]any) { c["iss"] = "other-client-id" },
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: aud is mismatched": {
+			claims:  func(c map[string]any) { c["aud"] = "https://other.example.com" },
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: exp is missing": {
+			claims:  func(c map[string]any) { delete(c, "exp") },
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: expired": {
+			claims:  func(c map[string]any) { c["exp"] = now.Add(-time.Second).Unix() },
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: not valid yet": {
+			claims:  func(c map[string]any) { c["nbf"] = now.Add(time.Minute).Unix() },
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: client_id is mismatched": {
+			claims:  func(c map[string]any) { c["client_id"] = "other-client-id" },
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: unknown kid": {
+			header:  &service.JWSHeader{Algorithm: service.AlgES256, KeyID: "unknown"},
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: another typ": {
+			header:  &service.JWSHeader{Algorithm: service.AlgES256, Type: "at+jwt"},
+			wantErr: ErrInvalidRequestObject,
+		},
+		"ng: alg is not registered": {
+			opts:    []model.ClientOption{model.WithRequestObjectSigningAlg(service.AlgPS256)},
+			wantErr: ErrInvalidRequestObject,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			key := newTestKey(t)
+			uc := newTestAuthUseCase(t, withClient(testRequestObjectClient(t, &key.PublicKey, tt.opts...)), withEncryptionKey())
+
+			claims := validClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			header := tt.header
+			if header == nil {
+				header = &service.JWSHeader{Algorithm: service.AlgES256, KeyID: "client-key", Type: requestObjectType}
+			}
+			token, err := service.SignJWT(header, key, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.encrypt {
+				token, err = service.EncryptJWE(&service.JWEHeader{
+					Algorithm:   service.AlgRSAOAEP256,
+					Encryption:  service.EncA256GCM,
+					KeyID:       uc.EncryptionKey.ID,
+					ContentType: "JWT",
+				}, uc.EncryptionKey.Key.Public().(*rsa.PublicKey), []byte(token))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			payload, err := uc.VerifyRequestObject(context.Background(), "jar-client-id", token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			got := map[string]any{}
+			if err := json.Unmarshal(payload, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got["scope"] != "openid" {
+				t.Errorf("unexpected claims: %v", got)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseVerifyRequestObjectAfterEncryptionKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	key := newTestKey(t)
+	uc := newTestAuthUseCase(t, withClient(testRequestObjectClient(t, &key.PublicKey)), withEncryptionKeyRotation())
+
+	encryptionKey, err := uc.decryptionKey(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := service.SignJWT(&service.JWSHeader{Algorithm: service.AlgES256, KeyID: "client-key", Type: requestObjectType}, key, map[string]any{
+		"iss":       "jar-client-id",
+		"aud":       "http://localhost:9001",
+		"exp":       time.Now().Add(time.Minute).Unix(),
+		"client_id": "jar-client-id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err = service.EncryptJWE(&service.JWEHeader{
+		Algorithm:   service.AlgRSAOAEP256,
+		Encryption:  service.EncA256GCM,
+		KeyID:       encryptionKey.ID,
+		ContentType: "JWT",
+	}, encryptionKey.Key.Public().(*rsa.PublicKey), []byte(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the request object encrypted before the rotation is decrypted with the retired key.
+	if err := uc.EncryptionKeys.RotateNow(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.VerifyRequestObject(ctx, "jar-client-id", token); err != nil {
+		t.Fatal(err)
+	}
+
+	// the clients encrypt to the new keys only.
+	set, err := uc.JWKS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, jwk := range set.Keys {
+		if jwk.KeyID == encryptionKey.ID {
+			t.Errorf("retired encryption key is published: %v", jwk)
+		}
+	}
+}
+
+func TestAuthUseCaseFetchRequestObject(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/request.jwt":
+			w.Write([]byte("header.payload.signature\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := map[string]struct {
+		requestURI string
+		want       string
+		wantErr    error
+	}{
+		"ok": {
+			requestURI: srv.URL + "/request.jwt",
+			want:       "header.payload.signature",
+		},
+		"ng: not registered": {
+			requestURI: srv.URL + "/other.jwt",
+			wantErr:    ErrInvalidRequestURI,
+		},
+		"ng: not found": {
+			requestURI: srv.URL + "/missing.jwt",
+			wantErr:    ErrInvalidRequestURI,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t, withClient(testRequestObjectClient(t, &newTestKey(t).PublicKey, model.WithRequestURIs(srv.URL+"/request.jwt", srv.URL+"/missing.jwt"))))
+			uc.HTTPClient = srv.Client()
+
+			got, err := uc.FetchRequestObject(context.Background(), "jar-client-id", tt.requestURI)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
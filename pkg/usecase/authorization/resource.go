@@ -0,0 +1,84 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// getProtectedResources resolves resource indicators to registered protected resources.
+// ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2
+func (s *AuthUseCase) getProtectedResources(ctx context.Context, uris []string) ([]*model.ProtectedResource, error) {
+	resources := make([]*model.ProtectedResource, 0, len(uris))
+	for _, uri := range uris {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return nil, fmt.Errorf("%w: %q must be an absolute URI without a fragment", ErrInvalidTarget, uri)
+		}
+
+		resource, err := s.Storage.GetProtectedResource(ctx, uri)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is unknown", ErrInvalidTarget, uri)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// downScope narrows grant to what an access token issued for req may carry.
+// The requested resources must be a subset of the resources of grant when grant has any, and
+// the scope is narrowed to the scopes accepted by every resource in the audience.
+// ref: https://datatracker.ietf.org/doc/html/rfc8707#section-2.2
+func (s *AuthUseCase) downScope(ctx context.Context, grant *model.Grant, req *model.TokenRequest) (*model.Grant, error) {
+	audience := grant.Resources
+	if len(req.Resources) > 0 {
+		for _, uri := range req.Resources {
+			if len(grant.Resources) > 0 && !slices.Contains(grant.Resources, uri) {
+				return nil, fmt.Errorf("%w: %q is not authorized", ErrInvalidTarget, uri)
+			}
+		}
+		audience = req.Resources
+	}
+
+	resources, err := s.getProtectedResources(ctx, audience)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := strings.Fields(grant.Scope)
+	scopes := granted
+	if req.Scope != "" {
+		scopes = strings.Fields(req.Scope)
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				return nil, fmt.Errorf("%w: %q is not granted", ErrInvalidScope, scope)
+			}
+		}
+	}
+
+	if len(resources) > 0 {
+		scopes = slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+			return slices.ContainsFunc(resources, func(r *model.ProtectedResource) bool {
+				return !r.AllowsScope(scope)
+			})
+		})
+	}
+
+	details, err := narrowAuthorizationDetails(grant.AuthorizationDetails, req.AuthorizationDetails)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Grant{
+		ClientID:             grant.ClientID,
+		Scope:                strings.Join(scopes, " "),
+		Resources:            audience,
+		AuthorizationDetails: details,
+		Authentication:       grant.Authentication,
+		Actor:                grant.Actor,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/infra"
+)
+
+func TestAuthUseCaseDownScope(t *testing.T) {
+	t.Parallel()
+
+	const resourceURI = "http://localhost:9003"
+
+	type wants struct {
+		scope    string
+		audience []string
+		err      error
+	}
+
+	tests := map[string]struct {
+		grant     *model.Grant
+		scope     string
+		resources []string
+		wants     wants
+	}{
+		"ok: no resource": {
+			grant: &model.Grant{Scope: "openid profile"},
+			wants: wants{scope: "openid profile"},
+		},
+		"ok: resource of the grant": {
+			grant: &model.Grant{Scope: "openid profile", Resources: []string{resourceURI}},
+			wants: wants{scope: "profile", audience: []string{resourceURI}},
+		},
+		"ok: resource at the token endpoint": {
+			grant:     &model.Grant{Scope: "openid profile email"},
+			resources: []string{resourceURI},
+			wants:     wants{scope: "profile email", audience: []string{resourceURI}},
+		},
+		"ok: narrower scope": {
+			grant:     &model.Grant{Scope: "openid profile email", Resources: []string{resourceURI}},
+			scope:     "email",
+			resources: []string{resourceURI},
+			wants:     wants{scope: "email", audience: []string{resourceURI}},
+		},
+		"ng: unknown resource": {
+			grant:     &model.Grant{Scope: "openid profile"},
+			resources: []string{"http://localhost:9999"},
+			wants:     wants{err: ErrInvalidTarget},
+		},
+		"ng: resource with fragment": {
+			grant:     &model.Grant{Scope: "openid profile"},
+			resources: []string{resourceURI + "#fragment"},
+			wants:     wants{err: ErrInvalidTarget},
+		},
+		"ng: resource is not authorized": {
+			grant:     &model.Grant{Scope: "openid profile", Resources: []string{"http://localhost:9004"}},
+			resources: []string{resourceURI},
+			wants:     wants{err: ErrInvalidTarget},
+		},
+		"ng: scope is not granted": {
+			grant: &model.Grant{Scope: "openid"},
+			scope: "profile",
+			wants: wants{err: ErrInvalidScope},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := &AuthUseCase{Storage: infra.NewAuthorizationStorage("fixed-key")}
+			got, err := uc.downScope(context.Background(), tt.grant, &model.TokenRequest{Scope: tt.scope, Resources: tt.resources})
+			if !errors.Is(err, tt.wants.err) {
+				t.Fatalf("want error %v, got %v", tt.wants.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if got.Scope != tt.wants.scope {
+				t.Errorf("want scope %q, got %q", tt.wants.scope, got.Scope)
+			}
+			if !slices.Equal(got.Resources, tt.wants.audience) {
+				t.Errorf("want audience %v, got %v", tt.wants.audience, got.Resources)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+// ref: https://openid.net/specs/oauth-v2-jarm.html#section-2.1
+const authorizationResponseLifetime = 10 * time.Minute
+
+// ResponseMode returns the response mode of the authorization response to the client.
+// The default mode of the client is used if none is requested, and the default of the
+// authorization code flow, query, is used if the client has no default either.
+// ref: https://openid.net/specs/oauth-v2-jarm.html#section-2.3.4
+func (s *AuthUseCase) ResponseMode(ctx context.Context, clientID string, requested model.ResponseMode) model.ResponseMode {
+	mode := requested
+	if mode == "" {
+		if client, err := s.Storage.GetClient(ctx, clientID); err == nil {
+			mode = client.GetDefaultResponseMode()
+		}
+	}
+
+	switch mode {
+	case "":
+		return model.ResponseModeQuery
+	case model.ResponseModeJWT:
+		return model.ResponseModeQueryJWT
+	default:
+		return mode
+	}
+}
+
+// SignAuthorizationResponse returns the JWT which carries the parameters of the authorization response to the client.
+// ref: https://openid.net/specs/oauth-v2-jarm.html#section-2.1
+func (s *AuthUseCase) SignAuthorizationResponse(ctx context.Context, clientID string, params map[string]string) (string, error) {
+	key, err := s.signingKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return "", fmt.Errorf("signing key is not configured")
+	}
+
+	now := time.Now()
+	claims := map[string]any{}
+	for k, v := range params {
+		claims[k] = v
+	}
+	claims["iss"] = s.Issuer
+	claims["aud"] = clientID
+	claims["exp"] = now.Add(authorizationResponseLifetime).Unix()
+
+	return service.SignJWT(&service.JWSHeader{Algorithm: key.Algorithm, KeyID: key.ID}, key.Key, claims)
+}
@@ -0,0 +1,88 @@
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+	"github.com/task4233/oauth/pkg/domain/service"
+)
+
+func TestAuthUseCaseResponseMode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		defaultMode model.ResponseMode
+		requested   model.ResponseMode
+		want        model.ResponseMode
+	}{
+		"ok: default of the flow": {
+			want: model.ResponseModeQuery,
+		},
+		"ok: requested": {
+			requested: model.ResponseModeFormPost,
+			want:      model.ResponseModeFormPost,
+		},
+		"ok: jwt is query.jwt in the authorization code flow": {
+			requested: model.ResponseModeJWT,
+			want:      model.ResponseModeQueryJWT,
+		},
+		"ok: default of the client": {
+			defaultMode: model.ResponseModeFragmentJWT,
+			want:        model.ResponseModeFragmentJWT,
+		},
+		"ok: requested over default of the client": {
+			defaultMode: model.ResponseModeFragmentJWT,
+			requested:   model.ResponseModeQuery,
+			want:        model.ResponseModeQuery,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			uc := newTestAuthUseCase(t, withClient(testRequestObjectClient(t, &newTestKey(t).PublicKey, model.WithDefaultResponseMode(tt.defaultMode))))
+			if got := uc.ResponseMode(context.Background(), "jar-client-id", tt.requested); got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthUseCaseSignAuthorizationResponse(t *testing.T) {
+	t.Parallel()
+
+	uc := newTestAuthUseCase(t, withSigningKey())
+
+	token, err := uc.SignAuthorizationResponse(context.Background(), testClientID, map[string]string{
+		"code":  "code",
+		"state": "state",
+		"iss":   "https://attacker.example.com",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	jwks, err := uc.JWKS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, payload, err := service.VerifyJWS(token, service.JWKSetKeyFunc(jwks, service.KeyUseSignature, service.AlgRS256))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	claims := map[string]any{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]any{"iss": uc.Issuer, "aud": testClientID, "code": "code", "state": "state"} {
+		if claims[k] != want {
+			t.Errorf("want %s %v, got %v", k, want, claims[k])
+		}
+	}
+	if _, ok := claims["exp"]; !ok {
+		t.Error("exp is required")
+	}
+}
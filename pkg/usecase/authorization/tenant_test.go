@@ -0,0 +1,101 @@
+package authorization
+
+import (
+	"context"
+	"testing"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestAuthUseCaseIntrospectAcrossTenants(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	brandA := newTestAuthUseCase(t, withIssuer("http://localhost:9001/t/brand-a"))
+	brandB := newTestAuthUseCase(t, withIssuer("http://localhost:9001/t/brand-b"))
+
+	accessToken := model.NewAccessToken(&model.Grant{
+		ClientID:       testClientID,
+		Scope:          "openid",
+		Resources:      []string{"http://localhost:9003"},
+		Authentication: &model.Authentication{Subject: "dummy-user-id"},
+	}, model.DefaultAccessTokenLifetime)
+	if err := brandA.Storage.CreateAccessToken(ctx, accessToken); err != nil {
+		t.Fatal(err)
+	}
+	// the token requested without resource indicators is not bound to any resource.
+	unbound := model.NewAccessToken(&model.Grant{
+		ClientID:       testClientID,
+		Scope:          "openid",
+		Authentication: &model.Authentication{Subject: "dummy-user-id"},
+	}, model.DefaultAccessTokenLifetime)
+	if err := brandA.Storage.CreateAccessToken(ctx, unbound); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		tenant       *AuthUseCase
+		unbound      bool
+		callerID     string
+		callerSecret string
+		wantActive   bool
+	}{
+		"ok: resource of the tenant": {
+			tenant:       brandA,
+			callerID:     "dummy-resource-id",
+			callerSecret: "dummy-resource-secret",
+			wantActive:   true,
+		},
+		"ok: client of the tenant": {
+			tenant:       brandA,
+			callerID:     testClientID,
+			callerSecret: "dummy-client-secret",
+			wantActive:   true,
+		},
+		"ok: client with token without audience": {
+			tenant:       brandA,
+			unbound:      true,
+			callerID:     testClientID,
+			callerSecret: "dummy-client-secret",
+			wantActive:   true,
+		},
+		"ng: resource with token without audience": {
+			tenant:       brandA,
+			unbound:      true,
+			callerID:     "dummy-resource-id",
+			callerSecret: "dummy-resource-secret",
+		},
+		"ng: resource of another tenant": {
+			tenant:       brandB,
+			callerID:     "dummy-resource-id",
+			callerSecret: "dummy-resource-secret",
+		},
+		"ng: client of another tenant": {
+			tenant:       brandB,
+			callerID:     testClientID,
+			callerSecret: "dummy-client-secret",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			token := accessToken.AccessToken
+			if tt.unbound {
+				token = unbound.AccessToken
+			}
+			introspect, err := tt.tenant.Introspect(ctx, &model.IntrospectRequest{
+				Token:        token,
+				CallerID:     tt.callerID,
+				CallerSecret: tt.callerSecret,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if introspect.Active != tt.wantActive {
+				t.Errorf("unexpected active: want %v, got %v", tt.wantActive, introspect.Active)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+// TokenExchange is what a client asks to exchange, which is judged by TokenExchangePolicy.
+type TokenExchange struct {
+	Client             model.Client
+	SubjectTokenType   model.TokenTypeURI
+	Subject            *model.Grant
+	ActorTokenType     model.TokenTypeURI // empty if no actor token is presented
+	Actor              *model.Actor
+	RequestedTokenType model.TokenTypeURI
+	Resources          []string
+}
+
+// TokenExchangePolicy returns an error if the client must not exchange the tokens.
+type TokenExchangePolicy func(ctx context.Context, exchange *TokenExchange) error
+
+// TokenExchangeRule allows a client to exchange subject tokens of the types.
+type TokenExchangeRule struct {
+	ClientID          string
+	SubjectTokenTypes []model.TokenTypeURI
+	ActorTokenTypes   []model.TokenTypeURI // actor tokens are not allowed if empty
+}
+
+// AllowTokenExchange returns a policy which allows only the exchanges matching one of the rules.
+func AllowTokenExchange(rules ...TokenExchangeRule) TokenExchangePolicy {
+	return func(ctx context.Context, exchange *TokenExchange) error {
+		for _, rule := range rules {
+			if rule.ClientID != exchange.Client.GetID() || !slices.Contains(rule.SubjectTokenTypes, exchange.SubjectTokenType) {
+				continue
+			}
+			if exchange.ActorTokenType != "" && !slices.Contains(rule.ActorTokenTypes, exchange.ActorTokenType) {
+				continue
+			}
+			return nil
+		}
+		return fmt.Errorf("%w: token exchange is not allowed", ErrUnauthorizedClient)
+	}
+}
+
+// tokenByTokenExchange issues an access token for the subject of subject_token, down-scoped to the requested target.
+// The actor of actor_token becomes the current actor and the prior actors of subject_token are nested in it.
+// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2
+func (s *AuthUseCase) tokenByTokenExchange(ctx context.Context, req *model.TokenRequest) (*model.AccessToken, error) {
+	client, err := s.Storage.GetClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+	err = s.AuthenteClient(ctx, client, req.ClientSecret)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidClient, err)
+	}
+
+	// only access tokens are issued by token exchange.
+	if req.RequestedTokenType != "" && req.RequestedTokenType != model.TokenTypeURIAccessToken {
+		return nil, fmt.Errorf("%w: requested_token_type %s is unsupported", ErrInvalidRequest, req.RequestedTokenType)
+	}
+
+	subject, err := s.grantOfToken(ctx, req.SubjectToken, req.SubjectTokenType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: subject_token: %w", ErrInvalidRequest, err)
+	}
+
+	actor := subject.Actor
+	if req.ActorToken != "" {
+		actorGrant, err := s.grantOfToken(ctx, req.ActorToken, req.ActorTokenType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: actor_token: %w", ErrInvalidRequest, err)
+		}
+		actor = &model.Actor{
+			Subject:  actorGrant.ClientID,
+			ClientID: actorGrant.ClientID,
+			Actor:    subject.Actor,
+		}
+		if actorGrant.Authentication != nil {
+			actor.Subject = actorGrant.Authentication.Subject
+		}
+	}
+
+	resources, err := s.resolveAudiences(ctx, req.Resources, req.Audiences)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.TokenExchangePolicy == nil {
+		return nil, fmt.Errorf("%w: token exchange is disabled", ErrUnauthorizedClient)
+	}
+	err = s.TokenExchangePolicy(ctx, &TokenExchange{
+		Client:             client,
+		SubjectTokenType:   req.SubjectTokenType,
+		Subject:            subject,
+		ActorTokenType:     req.ActorTokenType,
+		Actor:              actor,
+		RequestedTokenType: model.TokenTypeURIAccessToken,
+		Resources:          resources,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the new token is issued to the requesting client for the requested target,
+	// and can only carry the scope and the authorization details of the subject token.
+	grant := &model.Grant{
+		ClientID:             client.GetID(),
+		Scope:                subject.Scope,
+		AuthorizationDetails: subject.AuthorizationDetails,
+		Authentication:       subject.Authentication,
+		Actor:                actor,
+	}
+	accessToken, err := s.issueAccessToken(ctx, grant, &model.TokenRequest{
+		Scope:                req.Scope,
+		Resources:            resources,
+		AuthorizationDetails: req.AuthorizationDetails,
+	})
+	if err != nil {
+		return nil, err
+	}
+	accessToken.IssuedTokenType = model.TokenTypeURIAccessToken
+
+	return accessToken, nil
+}
+
+// grantOfToken returns what the active token of the type grants.
+func (s *AuthUseCase) grantOfToken(ctx context.Context, token string, typ model.TokenTypeURI) (*model.Grant, error) {
+	now := time.Now().Unix()
+
+	switch typ {
+	case model.TokenTypeURIAccessToken:
+		accessToken, err := s.Storage.GetAccessToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if accessToken.ExpiresAt <= now {
+			return nil, fmt.Errorf("token is expired")
+		}
+		return accessToken.Grant(), nil
+	case model.TokenTypeURIRefreshToken:
+		refreshToken, err := s.Storage.GetRefreshToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		if refreshToken.ExpiresAt <= now {
+			return nil, fmt.Errorf("token is expired")
+		}
+		return refreshToken.Grant, nil
+	default:
+		return nil, fmt.Errorf("token type %q is unsupported", typ)
+	}
+}
+
+// resolveAudiences returns the resource indicators of the resources and the audiences,
+// which are the IDs or the URIs of protected resources.
+// ref: https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+func (s *AuthUseCase) resolveAudiences(ctx context.Context, resources, audiences []string) ([]string, error) {
+	uris := slices.Clone(resources)
+	for _, audience := range audiences {
+		if resource, err := s.Storage.GetProtectedResourceByID(ctx, audience); err == nil {
+			audience = resource.URI
+		} else if _, err := s.Storage.GetProtectedResource(ctx, audience); err != nil {
+			return nil, fmt.Errorf("%w: audience %q is unknown", ErrInvalidTarget, audience)
+		}
+		if !slices.Contains(uris, audience) {
+			uris = append(uris, audience)
+		}
+	}
+	return uris, nil
+}
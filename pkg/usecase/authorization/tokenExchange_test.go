@@ -0,0 +1,180 @@
+package authorization
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/task4233/oauth/pkg/domain/model"
+)
+
+func TestAuthUseCaseTokenByTokenExchange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	newToken := func(t *testing.T, s *AuthUseCase, grant *model.Grant) *model.AccessToken {
+		t.Helper()
+		accessToken := model.NewAccessToken(grant, model.DefaultAccessTokenLifetime)
+		if err := s.Storage.CreateAccessToken(ctx, accessToken); err != nil {
+			t.Fatal(err)
+		}
+		return accessToken
+	}
+	user := &model.Grant{
+		ClientID:       "user-client-id",
+		Scope:          "openid profile",
+		Authentication: &model.Authentication{Subject: "user"},
+	}
+	service := &model.Grant{ClientID: testClientID, Scope: "openid"}
+	delegated := &model.Grant{
+		ClientID:       "user-client-id",
+		Scope:          "openid profile",
+		Authentication: &model.Authentication{Subject: "user"},
+		Actor:          &model.Actor{Subject: "prior-actor"},
+	}
+	allowAll := AllowTokenExchange(TokenExchangeRule{
+		ClientID:          testClientID,
+		SubjectTokenTypes: []model.TokenTypeURI{model.TokenTypeURIAccessToken},
+		ActorTokenTypes:   []model.TokenTypeURI{model.TokenTypeURIAccessToken},
+	})
+
+	tests := map[string]struct {
+		policy        TokenExchangePolicy
+		subject       *model.Grant
+		actor         *model.Grant
+		expired       bool
+		req           *model.TokenRequest
+		wantScope     string
+		wantResources []string
+		wantActor     *model.Actor
+		wantErr       error
+	}{
+		"ok: impersonation": {
+			policy:    allowAll,
+			subject:   user,
+			req:       &model.TokenRequest{},
+			wantScope: "openid profile",
+		},
+		"ok: delegation nests the prior actor": {
+			policy:    allowAll,
+			subject:   delegated,
+			actor:     service,
+			req:       &model.TokenRequest{},
+			wantScope: "openid profile",
+			wantActor: &model.Actor{Subject: testClientID, ClientID: testClientID, Actor: &model.Actor{Subject: "prior-actor"}},
+		},
+		"ok: audience is resolved to the resource": {
+			policy:        allowAll,
+			subject:       user,
+			req:           &model.TokenRequest{Audiences: []string{"dummy-resource-id"}},
+			wantScope:     "profile",
+			wantResources: []string{"http://localhost:9003"},
+		},
+		"ng: token exchange is disabled": {
+			subject: user,
+			req:     &model.TokenRequest{},
+			wantErr: ErrUnauthorizedClient,
+		},
+		"ng: actor token is not allowed": {
+			policy: AllowTokenExchange(TokenExchangeRule{
+				ClientID:          testClientID,
+				SubjectTokenTypes: []model.TokenTypeURI{model.TokenTypeURIAccessToken},
+			}),
+			subject: user,
+			actor:   service,
+			req:     &model.TokenRequest{},
+			wantErr: ErrUnauthorizedClient,
+		},
+		"ng: subject token is expired": {
+			policy:  allowAll,
+			subject: user,
+			expired: true,
+			req:     &model.TokenRequest{},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: unsupported requested token type": {
+			policy:  allowAll,
+			subject: user,
+			req:     &model.TokenRequest{RequestedTokenType: model.TokenTypeURIRefreshToken},
+			wantErr: ErrInvalidRequest,
+		},
+		"ng: unknown audience": {
+			policy:  allowAll,
+			subject: user,
+			req:     &model.TokenRequest{Audiences: []string{"unknown"}},
+			wantErr: ErrInvalidTarget,
+		},
+		"ng: scope is not granted to the subject token": {
+			policy:  allowAll,
+			subject: user,
+			req:     &model.TokenRequest{Scope: "email"},
+			wantErr: ErrInvalidScope,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestAuthUseCase(t)
+			s.TokenExchangePolicy = tt.policy
+
+			req := tt.req
+			req.GrantType = model.GrantTypeTokenExchange
+			req.ClientID = testClientID
+			req.ClientSecret = "dummy-client-secret"
+
+			subject := newToken(t, s, tt.subject)
+			if tt.expired {
+				subject.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+				if err := s.Storage.CreateAccessToken(ctx, subject); err != nil {
+					t.Fatal(err)
+				}
+			}
+			req.SubjectToken = subject.AccessToken
+			req.SubjectTokenType = model.TokenTypeURIAccessToken
+			if tt.actor != nil {
+				req.ActorToken = newToken(t, s, tt.actor).AccessToken
+				req.ActorTokenType = model.TokenTypeURIAccessToken
+			}
+
+			got, err := s.Token(ctx, req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unexpected error: want=%v, got=%v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if got.ClientID != testClientID {
+				t.Errorf("client_id: want=%s, got=%s", testClientID, got.ClientID)
+			}
+			if got.Subject != "user" {
+				t.Errorf("subject must be inherited: got=%s", got.Subject)
+			}
+			if got.Scope != tt.wantScope {
+				t.Errorf("scope: want=%s, got=%s", tt.wantScope, got.Scope)
+			}
+			if len(got.Audience) != len(tt.wantResources) || (len(got.Audience) > 0 && got.Audience[0] != tt.wantResources[0]) {
+				t.Errorf("resources: want=%v, got=%v", tt.wantResources, got.Audience)
+			}
+			if !actorEqual(got.Actor, tt.wantActor) {
+				t.Errorf("actor: want=%+v, got=%+v", tt.wantActor, got.Actor)
+			}
+			if got.RefreshToken != "" {
+				t.Errorf("refresh token must not be issued")
+			}
+			if got.IssuedTokenType != model.TokenTypeURIAccessToken {
+				t.Errorf("issued_token_type: got=%s", got.IssuedTokenType)
+			}
+		})
+	}
+}
+
+func actorEqual(a, b *model.Actor) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Subject == b.Subject && a.ClientID == b.ClientID && actorEqual(a.Actor, b.Actor)
+}